@@ -3,6 +3,7 @@ package breakerbp
 import (
 	"context"
 	"fmt"
+	"io"
 	"time"
 
 	"github.com/apache/thrift/lib/go/thrift"
@@ -21,6 +22,8 @@ type FailureRatioBreaker struct {
 	minRequestsToTrip int
 	failureThreshold  float64
 	logger            log.Wrapper
+	statsd            metricsbp.Metrics
+	cancelStateGauge  func()
 }
 
 // Config represents the configuration for a FailureRatioBreaker.
@@ -42,6 +45,22 @@ type Config struct {
 	// Logger is the logger to be called when the breaker changes states.
 	Logger log.Wrapper
 
+	// Statsd is the Metrics to report the breaker states to,
+	// or metricsbp.M if it's nil.
+	//
+	// The breaker reports:
+	//
+	// - counter circuit-breaker.trips, tagged with breaker=Name,
+	// increased every time the breaker changes to open state
+	//
+	// - gauge circuit-breaker.state, tagged with breaker=Name,
+	// the current state of the breaker (0 for closed, 1 for half-open,
+	// 2 for open), computed once per reporting tick of Statsd,
+	// until the breaker is closed
+	//
+	// Unlike EmitStatusMetrics, it doesn't create new goroutines.
+	Statsd metricsbp.Metrics
+
 	// MaxRequestsHalfOpen represents he Maximum amount of requests that will be allowed through while the breaker
 	// is in half-open state. If left unset (or set to 0), exactly 1 request will be allowed through while half-open.
 	MaxRequestsHalfOpen uint32
@@ -63,6 +82,7 @@ func NewFailureRatioBreaker(config Config) FailureRatioBreaker {
 		minRequestsToTrip: config.MinRequestsToTrip,
 		failureThreshold:  config.FailureThreshold,
 		logger:            config.Logger,
+		statsd:            metricsbp.MetricsOrM(config.Statsd),
 	}
 	settings := gobreaker.Settings{
 		Name:          config.Name,
@@ -77,9 +97,31 @@ func NewFailureRatioBreaker(config Config) FailureRatioBreaker {
 	if config.EmitStatusMetrics {
 		go failureBreaker.runStatsProducer()
 	}
+	goBreaker := failureBreaker.goBreaker
+	failureBreaker.cancelStateGauge = failureBreaker.statsd.GaugeFuncWithTags(
+		"circuit-breaker.state",
+		metricsbp.Tags{"breaker": config.Name},
+		func() float64 {
+			return float64(goBreaker.State())
+		},
+	)
 	return failureBreaker
 }
 
+// Close stops reporting the circuit-breaker.state gauge of the breaker.
+//
+// It should be called when the breaker is no longer used,
+// otherwise the gauge keeps being reported until the Statsd is closed.
+// The breaker itself keeps working after Close.
+//
+// It always returns nil, and it's safe to be called multiple times.
+func (cb FailureRatioBreaker) Close() error {
+	if cb.cancelStateGauge != nil {
+		cb.cancelStateGauge()
+	}
+	return nil
+}
+
 func (cb FailureRatioBreaker) runStatsProducer() {
 	circuitBreakerGauge := metricsbp.M.RuntimeGauge(cb.name + "-circuit-breaker-closed")
 
@@ -134,9 +176,13 @@ func (cb FailureRatioBreaker) shouldTrip(counts gobreaker.Counts) bool {
 func (cb FailureRatioBreaker) stateChanged(name string, from gobreaker.State, to gobreaker.State) {
 	message := fmt.Sprintf("circuit breaker %v state changed from %v to %v", name, from, to)
 	cb.logger.Log(context.Background(), message)
+	if to == gobreaker.StateOpen {
+		cb.statsd.Counter("circuit-breaker.trips").With("breaker", cb.name).Add(1)
+	}
 }
 
 var (
+	_ io.Closer      = FailureRatioBreaker{}
 	_ CircuitBreaker = FailureRatioBreaker{}
 	_ CircuitBreaker = (*gobreaker.CircuitBreaker)(nil)
 )
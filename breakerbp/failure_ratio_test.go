@@ -4,10 +4,13 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/apache/thrift/lib/go/thrift"
 
 	"github.com/reddit/baseplate.go/breakerbp"
+	"github.com/reddit/baseplate.go/metricsbp"
+	"github.com/reddit/baseplate.go/metricsbp/metricsbptest"
 	"github.com/reddit/baseplate.go/thriftbp/thrifttest"
 )
 
@@ -106,3 +109,59 @@ func newTestCircuitBreaker() breakerbp.FailureRatioBreaker {
 	}
 	return breakerbp.NewFailureRatioBreaker(config)
 }
+
+func TestFailureBreakerStatsd(t *testing.T) {
+	const name = "test-breaker"
+	st := metricsbptest.NewRecordingStatsd(t, metricsbp.StatsdConfig{})
+	breaker := breakerbp.NewFailureRatioBreaker(breakerbp.Config{
+		Name:              name,
+		MinRequestsToTrip: 1,
+		FailureThreshold:  1,
+		Timeout:           time.Hour,
+		Statsd:            st.Statsd,
+	})
+
+	// reported returns the state reported by a new flush,
+	// or false if it's not reported.
+	reported := func(t *testing.T) (float64, bool) {
+		t.Helper()
+		var value float64
+		var found bool
+		for _, m := range st.Metrics() {
+			if m.Name == "circuit-breaker.state" && m.HasTags(metricsbp.Tags{"breaker": name}) {
+				found = true
+				value = m.Value
+			}
+		}
+		return value, found
+	}
+	state := func(t *testing.T) float64 {
+		t.Helper()
+		value, found := reported(t)
+		if !found {
+			t.Fatal("State gauge not reported")
+		}
+		return value
+	}
+
+	if got := state(t); got != 0 {
+		t.Errorf("Expected closed state 0, got %v", got)
+	}
+	breaker.Execute(func() (interface{}, error) {
+		return nil, errors.New("error")
+	})
+	if got := state(t); got != 2 {
+		t.Errorf("Expected open state 2, got %v", got)
+	}
+	if got := st.AssertCounter("circuit-breaker.trips", metricsbp.Tags{"breaker": name}); got != 1 {
+		t.Errorf("Expected 1 trip, got %v", got)
+	}
+
+	if err := breaker.Close(); err != nil {
+		t.Fatal(err)
+	}
+	st.Reset()
+	if got, found := reported(t); found {
+		t.Errorf("Expected state not reported after Close, got %v", got)
+	}
+}
@@ -0,0 +1,4 @@
+// Package grpcbp provides Baseplate integrations for gRPC.
+//
+// See https://pkg.go.dev/google.golang.org/grpc for documentation for grpc-go.
+package grpcbp
@@ -0,0 +1,183 @@
+package grpcbp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/go-kit/kit/metrics"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/reddit/baseplate.go/metricsbp"
+)
+
+// MetricsArgs are the args to be passed into the metrics interceptors.
+type MetricsArgs struct {
+	// The Statsd to report the metrics to.
+	//
+	// If it's nil, metricsbp.M will be used instead.
+	Statsd metricsbp.Metrics
+
+	// By default the method tag is the full method, e.g. "/pkg.Service/Method".
+	// When StripService is true, it's only the method name, e.g. "Method".
+	StripService bool
+}
+
+// monitor reports the metrics of the calls from one side (client or server).
+type monitor struct {
+	requests     metrics.Counter
+	responses    metrics.Counter
+	latency      metrics.Histogram
+	stripService bool
+}
+
+func newMonitor(args MetricsArgs, side string) monitor {
+	prefix := "grpc." + side
+	st := metricsbp.MetricsOrM(args.Statsd)
+	return monitor{
+		requests:     st.Counter(prefix + ".requests"),
+		responses:    st.Counter(prefix + ".responses"),
+		latency:      st.Timing(prefix + ".latency"),
+		stripService: args.StripService,
+	}
+}
+
+// start reports the request for the full method and returns the function to
+// be called with the returned error once the call finishes.
+func (m monitor) start(fullMethod string) func(err error) {
+	method := fullMethod
+	if m.stripService {
+		method = method[strings.LastIndex(method, "/")+1:]
+	}
+	m.requests.With("method", method).Add(1)
+	timer := metricsbp.NewTimer(m.latency.With("method", method))
+	return func(err error) {
+		timer.ObserveDuration()
+		m.responses.With(
+			"method", method,
+			"code", code(err).String(),
+		).Add(1)
+	}
+}
+
+// code returns the grpc status code of the error,
+// with context errors converted to their corresponding codes.
+func code(err error) codes.Code {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return codes.DeadlineExceeded
+	case errors.Is(err, context.Canceled):
+		return codes.Canceled
+	default:
+		return status.Code(err)
+	}
+}
+
+// UnaryServerMetricsInterceptor returns a grpc.UnaryServerInterceptor that
+// reports the metrics of the calls.
+//
+// For method "/pkg.Service/Method", it reports:
+//
+// - counter grpc.server.requests, tagged with method=/pkg.Service/Method
+//
+// - counter grpc.server.responses, tagged with method=/pkg.Service/Method
+// and code set to the grpc status code (e.g. "OK", "NotFound")
+//
+// - timing grpc.server.latency, tagged with method=/pkg.Service/Method
+//
+// The method tag is "Method" instead if args.StripService is true.
+func UnaryServerMetricsInterceptor(args MetricsArgs) grpc.UnaryServerInterceptor {
+	m := newMonitor(args, "server")
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (_ interface{}, err error) {
+		finish := m.start(info.FullMethod)
+		defer func() {
+			finish(err)
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerMetricsInterceptor returns a grpc.StreamServerInterceptor that
+// reports the metrics of the streams,
+// the same way as UnaryServerMetricsInterceptor.
+//
+// The latency is the whole duration of the stream.
+func StreamServerMetricsInterceptor(args MetricsArgs) grpc.StreamServerInterceptor {
+	m := newMonitor(args, "server")
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		finish := m.start(info.FullMethod)
+		defer func() {
+			finish(err)
+		}()
+		return handler(srv, ss)
+	}
+}
+
+// UnaryClientMetricsInterceptor returns a grpc.UnaryClientInterceptor that
+// reports the metrics of the calls,
+// the same way as UnaryServerMetricsInterceptor,
+// but with the "grpc.client." prefix instead.
+func UnaryClientMetricsInterceptor(args MetricsArgs) grpc.UnaryClientInterceptor {
+	m := newMonitor(args, "client")
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) (err error) {
+		finish := m.start(method)
+		defer func() {
+			finish(err)
+		}()
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// StreamClientMetricsInterceptor returns a grpc.StreamClientInterceptor that
+// reports the metrics of the streams,
+// the same way as UnaryClientMetricsInterceptor.
+//
+// A server-streaming stream is considered finished when RecvMsg returns an
+// error (io.EOF is treated as OK).
+// A stream that is not server-streaming (for example client-streaming ones,
+// finished by CloseAndRecv) is considered finished when RecvMsg returns,
+// as the only response is received by then.
+// Streams that are never read until then are not reported with responses and
+// latency.
+func StreamClientMetricsInterceptor(args MetricsArgs) grpc.StreamClientInterceptor {
+	m := newMonitor(args, "client")
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		finish := m.start(method)
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			finish(err)
+			return nil, err
+		}
+		return &monitoredClientStream{
+			ClientStream:  cs,
+			serverStreams: desc.ServerStreams,
+			finish:        finish,
+		}, nil
+	}
+}
+
+type monitoredClientStream struct {
+	grpc.ClientStream
+
+	serverStreams bool
+	finish        func(err error)
+	once          sync.Once
+}
+
+func (s *monitoredClientStream) RecvMsg(msg interface{}) error {
+	err := s.ClientStream.RecvMsg(msg)
+	if err != nil || !s.serverStreams {
+		s.once.Do(func() {
+			if err == io.EOF {
+				s.finish(nil)
+			} else {
+				s.finish(err)
+			}
+		})
+	}
+	return err
+}
@@ -0,0 +1,173 @@
+package grpcbp_test
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/reddit/baseplate.go/grpcbp"
+	"github.com/reddit/baseplate.go/metricsbp"
+	"github.com/reddit/baseplate.go/metricsbp/metricsbptest"
+)
+
+const fullMethod = "/pkg.Service/Method"
+
+func assertMetrics(t *testing.T, st *metricsbptest.RecordingStatsd, side, method string, code codes.Code) {
+	t.Helper()
+
+	tags := metricsbp.Tags{"method": method}
+	if got := st.AssertCounter("grpc."+side+".requests", tags); got != 1 {
+		t.Errorf("Expected 1 request, got %v", got)
+	}
+	tags["code"] = code.String()
+	if got := st.AssertCounter("grpc."+side+".responses", tags); got != 1 {
+		t.Errorf("Expected 1 response, got %v", got)
+	}
+}
+
+func TestUnaryServerMetricsInterceptor(t *testing.T) {
+	for _, c := range []struct {
+		label        string
+		err          error
+		stripService bool
+		method       string
+		code         codes.Code
+	}{
+		{
+			label:  "ok",
+			method: fullMethod,
+			code:   codes.OK,
+		},
+		{
+			label:  "error",
+			err:    status.Error(codes.NotFound, "not found"),
+			method: fullMethod,
+			code:   codes.NotFound,
+		},
+		{
+			label:        "strip-service",
+			stripService: true,
+			method:       "Method",
+			code:         codes.OK,
+		},
+	} {
+		t.Run(c.label, func(t *testing.T) {
+			st := metricsbptest.NewRecordingStatsd(t, metricsbp.StatsdConfig{})
+			interceptor := grpcbp.UnaryServerMetricsInterceptor(grpcbp.MetricsArgs{
+				Statsd:       st.Statsd,
+				StripService: c.stripService,
+			})
+			interceptor(
+				context.Background(),
+				nil,
+				&grpc.UnaryServerInfo{FullMethod: fullMethod},
+				func(ctx context.Context, req interface{}) (interface{}, error) {
+					return nil, c.err
+				},
+			)
+			assertMetrics(t, st, "server", c.method, c.code)
+		})
+	}
+}
+
+func TestStreamServerMetricsInterceptor(t *testing.T) {
+	st := metricsbptest.NewRecordingStatsd(t, metricsbp.StatsdConfig{})
+	interceptor := grpcbp.StreamServerMetricsInterceptor(grpcbp.MetricsArgs{
+		Statsd: st.Statsd,
+	})
+	interceptor(
+		nil,
+		nil,
+		&grpc.StreamServerInfo{FullMethod: fullMethod},
+		func(srv interface{}, stream grpc.ServerStream) error {
+			return status.Error(codes.Unavailable, "unavailable")
+		},
+	)
+	assertMetrics(t, st, "server", fullMethod, codes.Unavailable)
+}
+
+func TestUnaryClientMetricsInterceptor(t *testing.T) {
+	st := metricsbptest.NewRecordingStatsd(t, metricsbp.StatsdConfig{})
+	interceptor := grpcbp.UnaryClientMetricsInterceptor(grpcbp.MetricsArgs{
+		Statsd: st.Statsd,
+	})
+	interceptor(
+		context.Background(),
+		fullMethod,
+		nil,
+		nil,
+		nil,
+		func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			return context.DeadlineExceeded
+		},
+	)
+	assertMetrics(t, st, "client", fullMethod, codes.DeadlineExceeded)
+}
+
+type fakeClientStream struct {
+	grpc.ClientStream
+
+	msgs int
+}
+
+func (s *fakeClientStream) RecvMsg(interface{}) error {
+	if s.msgs == 0 {
+		return io.EOF
+	}
+	s.msgs--
+	return nil
+}
+
+func TestStreamClientMetricsInterceptor(t *testing.T) {
+	for _, c := range []struct {
+		label string
+		desc  *grpc.StreamDesc
+		msgs  int
+		recvs int
+	}{
+		{
+			label: "server-streaming",
+			desc:  &grpc.StreamDesc{ServerStreams: true},
+			msgs:  2,
+			recvs: 3,
+		},
+		{
+			label: "client-streaming",
+			desc:  &grpc.StreamDesc{ClientStreams: true},
+			msgs:  1,
+			recvs: 1,
+		},
+	} {
+		t.Run(c.label, func(t *testing.T) {
+			st := metricsbptest.NewRecordingStatsd(t, metricsbp.StatsdConfig{})
+			interceptor := grpcbp.StreamClientMetricsInterceptor(grpcbp.MetricsArgs{
+				Statsd: st.Statsd,
+			})
+			stream, err := interceptor(
+				context.Background(),
+				c.desc,
+				nil,
+				fullMethod,
+				func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+					return &fakeClientStream{msgs: c.msgs}, nil
+				},
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < c.recvs; i++ {
+				if err := stream.RecvMsg(nil); err != nil && err != io.EOF {
+					t.Fatal(err)
+				}
+			}
+			assertMetrics(t, st, "client", fullMethod, codes.OK)
+			// Reading again after finished should not report again.
+			stream.RecvMsg(nil)
+			assertMetrics(t, st, "client", fullMethod, codes.OK)
+		})
+	}
+}
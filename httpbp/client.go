@@ -0,0 +1,112 @@
+package httpbp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/reddit/baseplate.go/metricsbp"
+)
+
+// ClientMiddleware wraps the given http.RoundTripper and returns a new,
+// wrapped, http.RoundTripper.
+type ClientMiddleware func(next http.RoundTripper) http.RoundTripper
+
+// WrapTransport wraps the given http.RoundTripper with the given
+// ClientMiddlewares and returns the wrapped http.RoundTripper,
+// to be used as the Transport of an http.Client.
+//
+// If base is nil, http.DefaultTransport will be used instead.
+//
+// Middlewares will be called in the order that they are defined,
+// the same as Wrap.
+func WrapTransport(base http.RoundTripper, middlewares ...ClientMiddleware) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		base = middlewares[i](base)
+	}
+	return base
+}
+
+// roundTripperFunc implements http.RoundTripper with a function.
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// ReportClientMetrics returns a ClientMiddleware that reports the request
+// count, error count and latency of the requests to the service to st.
+//
+// If st is nil, metricsbp.M will be used instead.
+// The counters and timings are sampled with the sample rates configured in st.
+//
+// For requests to service "my-service", it reports:
+//
+// - counter http.client.requests, tagged with service=my-service and method
+//
+// - counter http.client.responses, tagged with service=my-service, method,
+// and status set to the class of the response status code (e.g. "2xx")
+//
+// - counter http.client.errors, tagged with service=my-service, method,
+// and error_type
+//
+// - timing http.client.latency, tagged with service=my-service and method
+//
+// method is the HTTP method of the request, or "other" for non-standard
+// methods.
+//
+// error_type is "timeout" for timeouts and "connection" for all the other
+// errors returned by the next http.RoundTripper,
+// which don't have responses.
+// Responses with 5xx status codes are also reported as errors,
+// with error_type "application".
+func ReportClientMetrics(serviceSlug string, st metricsbp.Metrics) ClientMiddleware {
+	st = metricsbp.MetricsOrM(st)
+	requests := st.Counter("http.client.requests")
+	responses := st.Counter("http.client.responses")
+	errs := st.Counter("http.client.errors")
+	latency := st.Timing("http.client.latency")
+	return func(next http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			method := r.Method
+			if method == "" {
+				method = http.MethodGet
+			}
+			if !allHTTPMethods[method] {
+				method = "other"
+			}
+			tags := []string{"service", serviceSlug, "method", method}
+			timer := metricsbp.NewTimer(latency.With(tags...))
+			defer timer.ObserveDuration()
+
+			resp, err := next.RoundTrip(r)
+			requests.With(tags...).Add(1)
+			if err != nil {
+				errs.With(append(tags, "error_type", clientErrorType(err))...).Add(1)
+				return resp, err
+			}
+			responses.With(append(tags, "status", statusClass(resp.StatusCode))...).Add(1)
+			if resp.StatusCode >= http.StatusInternalServerError {
+				errs.With(append(tags, "error_type", "application")...).Add(1)
+			}
+			return resp, err
+		})
+	}
+}
+
+// clientErrorType returns the error_type tag value for the error returned by
+// a http.RoundTripper.
+func clientErrorType(err error) string {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "timeout"
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return "timeout"
+	}
+	return "connection"
+}
@@ -0,0 +1,110 @@
+package httpbp_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/reddit/baseplate.go/httpbp"
+	"github.com/reddit/baseplate.go/metricsbp"
+	"github.com/reddit/baseplate.go/metricsbp/metricsbptest"
+)
+
+type fakeTransport struct {
+	code int
+	err  error
+}
+
+func (ft fakeTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if ft.err != nil {
+		return nil, ft.err
+	}
+	w := httptest.NewRecorder()
+	w.WriteHeader(ft.code)
+	return w.Result(), nil
+}
+
+func TestReportClientMetrics(t *testing.T) {
+	const service = "test-service"
+	for _, c := range []struct {
+		label     string
+		transport fakeTransport
+		status    string
+		errorType string
+	}{
+		{
+			label:     "ok",
+			transport: fakeTransport{code: http.StatusOK},
+			status:    "2xx",
+		},
+		{
+			label:     "not-found",
+			transport: fakeTransport{code: http.StatusNotFound},
+			status:    "4xx",
+		},
+		{
+			label:     "server-error",
+			transport: fakeTransport{code: http.StatusServiceUnavailable},
+			status:    "5xx",
+			errorType: "application",
+		},
+		{
+			label:     "timeout",
+			transport: fakeTransport{err: context.DeadlineExceeded},
+			errorType: "timeout",
+		},
+		{
+			label:     "connection",
+			transport: fakeTransport{err: errors.New("connection refused")},
+			errorType: "connection",
+		},
+	} {
+		t.Run(c.label, func(t *testing.T) {
+			st := metricsbptest.NewRecordingStatsd(t, metricsbp.StatsdConfig{})
+			client := &http.Client{
+				Transport: httpbp.WrapTransport(
+					c.transport,
+					httpbp.ReportClientMetrics(service, st.Statsd),
+				),
+			}
+			resp, err := client.Get("http://localhost/")
+			if err == nil {
+				resp.Body.Close()
+			}
+
+			tags := metricsbp.Tags{
+				"service": service,
+				"method":  http.MethodGet,
+			}
+			if got := st.AssertCounter("http.client.requests", tags); got != 1 {
+				t.Errorf("Expected 1 request, got %v", got)
+			}
+			var responses, errs int
+			for _, m := range st.Metrics() {
+				if !m.HasTags(tags) {
+					continue
+				}
+				switch m.Name {
+				case "http.client.responses":
+					responses++
+					if got := m.Tags["status"]; got != c.status {
+						t.Errorf("Expected status %q, got %q", c.status, got)
+					}
+				case "http.client.errors":
+					errs++
+					if got := m.Tags["error_type"]; got != c.errorType {
+						t.Errorf("Expected error_type %q, got %q", c.errorType, got)
+					}
+				}
+			}
+			if expected := c.status != ""; expected != (responses == 1) {
+				t.Errorf("Expected response reported to be %v, got %d responses", expected, responses)
+			}
+			if expected := c.errorType != ""; expected != (errs == 1) {
+				t.Errorf("Expected error reported to be %v, got %d errors", expected, errs)
+			}
+		})
+	}
+}
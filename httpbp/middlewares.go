@@ -1,14 +1,20 @@
 package httpbp
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/reddit/baseplate.go/ecinterface"
 	"github.com/reddit/baseplate.go/log"
+	"github.com/reddit/baseplate.go/metricsbp"
 	"github.com/reddit/baseplate.go/tracing"
 )
 
@@ -250,3 +256,216 @@ func SupportedMethods(method string, additional ...string) Middleware {
 		}
 	}
 }
+
+// ReportRequestMetrics returns a Middleware that reports the request count,
+// response status class and latency of every endpoint to st.
+//
+// If st is nil, metricsbp.M will be used instead.
+// The counters and timings are sampled with the sample rates configured in st.
+//
+// The metrics are tagged with endpoint set to the name passed to the
+// middleware, which is the Endpoint.Name when used with NewBaseplateServer.
+// It's the same for all the requests matching the same Pattern,
+// so using it instead of the raw request path keeps the cardinality in check.
+// They are also tagged with method set to the HTTP method of the request,
+// or "other" for non-standard methods.
+//
+// For endpoint named "myEndpoint", it reports:
+//
+// - counter http.server.requests, tagged with endpoint=myEndpoint and method
+//
+// - counter http.server.responses, tagged with endpoint=myEndpoint, method,
+// and status set to the class of the response status code
+// (e.g. "2xx", "4xx")
+//
+// - timing http.server.latency, tagged with endpoint=myEndpoint and method
+//
+// If next returns an error,
+// the status code is the one the error response will be written with:
+// the code of the HTTPError, or 500 for other errors.
+// Otherwise it's the status code written to the http.ResponseWriter,
+// or 200 if it's never written explicitly.
+func ReportRequestMetrics(st metricsbp.Metrics) Middleware {
+	st = metricsbp.MetricsOrM(st)
+	return func(name string, next HandlerFunc) HandlerFunc {
+		requests := st.Counter("http.server.requests")
+		responses := st.Counter("http.server.responses")
+		latency := st.Timing("http.server.latency")
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			method := r.Method
+			if !allHTTPMethods[method] {
+				method = "other"
+			}
+			tags := []string{"endpoint", name, "method", method}
+			timer := metricsbp.NewTimer(latency.With(tags...))
+			defer timer.ObserveDuration()
+			requests.With(tags...).Add(1)
+
+			recorder := &statusRecorder{ResponseWriter: w}
+			err := next(ctx, recorder, r)
+			responses.With(append(tags, "status", statusClass(responseCode(recorder, err)))...).Add(1)
+			return err
+		}
+	}
+}
+
+// responseCode returns the status code the response will be written with.
+func responseCode(recorder *statusRecorder, err error) int {
+	if err != nil {
+		var httpErr HTTPError
+		if errors.As(err, &httpErr) {
+			if code := httpErr.Response().Code; code != 0 {
+				return code
+			}
+			return http.StatusOK
+		}
+		return http.StatusInternalServerError
+	}
+	if recorder.code != 0 {
+		return recorder.code
+	}
+	return http.StatusOK
+}
+
+// statusClass returns the class of the status code, e.g. "2xx" for 204.
+func statusClass(code int) string {
+	return strconv.Itoa(code/100) + "xx"
+}
+
+// RecoverPanicsArgs are the args to be passed into RecoverPanics.
+type RecoverPanicsArgs struct {
+	// Metrics is where the panic counter is reported to.
+	//
+	// If it's nil, metricsbp.M will be used instead.
+	Metrics metricsbp.Metrics
+
+	// Repanic controls what to do after a recovered panic is counted.
+	//
+	// When it's false (default),
+	// the panic is returned as an error,
+	// so the response is written as 500 and the server keeps running.
+	// When it's true, it panics again with the recovered value.
+	Repanic bool
+}
+
+// RecoverPanics returns a Middleware that recovers the panics from the
+// handlers and reports them to a counter,
+// making the number of panics swallowed by the service visible.
+//
+// For endpoint named "myEndpoint", it reports:
+//
+// - counter http.server.panics, tagged with endpoint=myEndpoint
+//
+// It should be the innermost middleware wrapping the handler,
+// so that the other middlewares (for example InjectServerSpan and
+// ReportRequestMetrics) see the error returned instead of the panic.
+//
+// http.ErrAbortHandler is not treated as a panic:
+// it's never counted and always panics again,
+// so net/http still aborts the response silently.
+func RecoverPanics(args RecoverPanicsArgs) Middleware {
+	st := metricsbp.MetricsOrM(args.Metrics)
+	return func(name string, next HandlerFunc) HandlerFunc {
+		panics := st.Counter("http.server.panics").With("endpoint", name)
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request) (err error) {
+			defer func() {
+				rec := recover()
+				if rec == nil {
+					return
+				}
+				if rec == http.ErrAbortHandler {
+					panic(rec)
+				}
+				panics.Add(1)
+				if args.Repanic {
+					panic(rec)
+				}
+				if e, ok := rec.(error); ok {
+					err = fmt.Errorf("httpbp: recovered from panic in endpoint %q: %w", name, e)
+				} else {
+					err = fmt.Errorf("httpbp: recovered from panic in endpoint %q: %v", name, rec)
+				}
+			}()
+
+			return next(ctx, w, r)
+		}
+	}
+}
+
+// statusRecorder is an http.ResponseWriter that records the status code
+// written to it.
+type statusRecorder struct {
+	http.ResponseWriter
+
+	code int
+}
+
+func (sr *statusRecorder) WriteHeader(code int) {
+	if sr.code == 0 {
+		sr.code = code
+	}
+	sr.ResponseWriter.WriteHeader(code)
+}
+
+func (sr *statusRecorder) Write(p []byte) (int, error) {
+	if sr.code == 0 {
+		sr.code = http.StatusOK
+	}
+	return sr.ResponseWriter.Write(p)
+}
+
+// Flush implements http.Flusher,
+// it's a no-op if the underlying http.ResponseWriter is not an http.Flusher.
+func (sr *statusRecorder) Flush() {
+	if f, ok := sr.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker,
+// it returns http.ErrNotSupported if the underlying http.ResponseWriter is not
+// an http.Hijacker.
+func (sr *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := sr.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	return h.Hijack()
+}
+
+// Push implements http.Pusher,
+// it returns http.ErrNotSupported if the underlying http.ResponseWriter is not
+// an http.Pusher.
+func (sr *statusRecorder) Push(target string, opts *http.PushOptions) error {
+	p, ok := sr.ResponseWriter.(http.Pusher)
+	if !ok {
+		return http.ErrNotSupported
+	}
+	return p.Push(target, opts)
+}
+
+// ReadFrom implements io.ReaderFrom,
+// so the underlying http.ResponseWriter can still use sendfile for the
+// responses copied from files.
+func (sr *statusRecorder) ReadFrom(r io.Reader) (int64, error) {
+	if sr.code == 0 {
+		sr.code = http.StatusOK
+	}
+	if rf, ok := sr.ResponseWriter.(io.ReaderFrom); ok {
+		return rf.ReadFrom(r)
+	}
+	// Hide ReadFrom of sr from io.Copy to avoid the infinite recursion.
+	return io.Copy(struct{ io.Writer }{sr.ResponseWriter}, r)
+}
+
+// Unwrap returns the underlying http.ResponseWriter.
+func (sr *statusRecorder) Unwrap() http.ResponseWriter {
+	return sr.ResponseWriter
+}
+
+var (
+	_ http.Flusher  = (*statusRecorder)(nil)
+	_ http.Hijacker = (*statusRecorder)(nil)
+	_ http.Pusher   = (*statusRecorder)(nil)
+	_ io.ReaderFrom = (*statusRecorder)(nil)
+)
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"sort"
@@ -13,6 +14,8 @@ import (
 	"github.com/reddit/baseplate.go/ecinterface"
 	"github.com/reddit/baseplate.go/httpbp"
 	"github.com/reddit/baseplate.go/log"
+	"github.com/reddit/baseplate.go/metricsbp"
+	"github.com/reddit/baseplate.go/metricsbp/metricsbptest"
 	"github.com/reddit/baseplate.go/mqsend"
 	"github.com/reddit/baseplate.go/tracing"
 )
@@ -304,3 +307,208 @@ func TestSupportedMethods(t *testing.T) {
 		)
 	}
 }
+
+func TestReportRequestMetrics(t *testing.T) {
+	const name = "test"
+	for _, c := range []struct {
+		label  string
+		method string
+		plan   testHandlerPlan
+		status string
+	}{
+		{
+			label:  "ok",
+			method: http.MethodGet,
+			status: "2xx",
+		},
+		{
+			label:  "code",
+			method: http.MethodPost,
+			plan:   testHandlerPlan{code: http.StatusNotFound},
+			status: "4xx",
+		},
+		{
+			label:  "http-error",
+			method: http.MethodGet,
+			plan:   testHandlerPlan{err: httpbp.JSONError(httpbp.BadRequest(), nil)},
+			status: "4xx",
+		},
+		{
+			label:  "error",
+			method: http.MethodGet,
+			plan:   testHandlerPlan{err: errors.New("error")},
+			status: "5xx",
+		},
+		{
+			label:  "non-standard-method",
+			method: "FOO",
+			status: "2xx",
+		},
+	} {
+		t.Run(c.label, func(t *testing.T) {
+			st := metricsbptest.NewRecordingStatsd(t, metricsbp.StatsdConfig{})
+			req := newRequest(t, "")
+			req.Method = c.method
+			handle := httpbp.Wrap(
+				name,
+				newTestHandler(c.plan),
+				httpbp.ReportRequestMetrics(st.Statsd),
+			)
+			handle(context.Background(), httptest.NewRecorder(), req)
+
+			method := c.method
+			if method == "FOO" {
+				method = "other"
+			}
+			tags := metricsbp.Tags{
+				"endpoint": name,
+				"method":   method,
+			}
+			if got := st.AssertCounter("http.server.requests", tags); got != 1 {
+				t.Errorf("Expected 1 request, got %v", got)
+			}
+			tags["status"] = c.status
+			if got := st.AssertCounter("http.server.responses", tags); got != 1 {
+				t.Errorf("Expected 1 response, got %v", got)
+			}
+		})
+	}
+}
+
+func TestReportRequestMetricsResponseWriter(t *testing.T) {
+	const body = "hello"
+	st := metricsbptest.NewRecordingStatsd(t, metricsbp.StatsdConfig{})
+	handle := httpbp.Wrap(
+		"test",
+		func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			if _, ok := w.(http.Flusher); !ok {
+				t.Error("Expected http.Flusher")
+			}
+			if _, ok := w.(http.Pusher); !ok {
+				t.Error("Expected http.Pusher")
+			}
+			hijacker, ok := w.(http.Hijacker)
+			if !ok {
+				t.Error("Expected http.Hijacker")
+			} else if _, _, err := hijacker.Hijack(); !errors.Is(err, http.ErrNotSupported) {
+				t.Errorf("Expected http.ErrNotSupported from the recorder, got %v", err)
+			}
+			rf, ok := w.(io.ReaderFrom)
+			if !ok {
+				t.Fatal("Expected io.ReaderFrom")
+			}
+			_, err := rf.ReadFrom(strings.NewReader(body))
+			return err
+		},
+		httpbp.ReportRequestMetrics(st.Statsd),
+	)
+	req := newRequest(t, "")
+	req.Method = http.MethodGet
+	recorder := httptest.NewRecorder()
+	if err := handle(context.Background(), recorder, req); err != nil {
+		t.Fatal(err)
+	}
+	if got := recorder.Body.String(); got != body {
+		t.Errorf("Expected body %q, got %q", body, got)
+	}
+	tags := metricsbp.Tags{
+		"endpoint": "test",
+		"method":   http.MethodGet,
+		"status":   "2xx",
+	}
+	if got := st.AssertCounter("http.server.responses", tags); got != 1 {
+		t.Errorf("Expected 1 response, got %v", got)
+	}
+}
+
+func TestRecoverPanics(t *testing.T) {
+	const name = "test"
+	panicking := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		panic("oops")
+	}
+	tags := metricsbp.Tags{"endpoint": name}
+
+	t.Run("error", func(t *testing.T) {
+		st := metricsbptest.NewRecordingStatsd(t, metricsbp.StatsdConfig{})
+		handle := httpbp.Wrap(
+			name,
+			panicking,
+			httpbp.RecoverPanics(httpbp.RecoverPanicsArgs{Metrics: st.Statsd}),
+		)
+		err := handle(context.Background(), httptest.NewRecorder(), newRequest(t, ""))
+		if err == nil || !strings.Contains(err.Error(), "oops") {
+			t.Errorf("Expected the panic to be returned as an error, got %v", err)
+		}
+		if got := st.AssertCounter("http.server.panics", tags); got != 1 {
+			t.Errorf("Expected 1 panic, got %v", got)
+		}
+	})
+
+	t.Run("repanic", func(t *testing.T) {
+		st := metricsbptest.NewRecordingStatsd(t, metricsbp.StatsdConfig{})
+		handle := httpbp.Wrap(
+			name,
+			panicking,
+			httpbp.RecoverPanics(httpbp.RecoverPanicsArgs{
+				Metrics: st.Statsd,
+				Repanic: true,
+			}),
+		)
+		func() {
+			defer func() {
+				if rec := recover(); rec != "oops" {
+					t.Errorf("Expected to panic again with %q, got %v", "oops", rec)
+				}
+			}()
+			handle(context.Background(), httptest.NewRecorder(), newRequest(t, ""))
+		}()
+		if got := st.AssertCounter("http.server.panics", tags); got != 1 {
+			t.Errorf("Expected 1 panic, got %v", got)
+		}
+	})
+
+	t.Run("abort-handler", func(t *testing.T) {
+		st := metricsbptest.NewRecordingStatsd(t, metricsbp.StatsdConfig{})
+		handle := httpbp.Wrap(
+			name,
+			func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+				panic(http.ErrAbortHandler)
+			},
+			httpbp.RecoverPanics(httpbp.RecoverPanicsArgs{Metrics: st.Statsd}),
+		)
+		w := httptest.NewRecorder()
+		func() {
+			defer func() {
+				if rec := recover(); rec != http.ErrAbortHandler {
+					t.Errorf("Expected to panic again with http.ErrAbortHandler, got %v", rec)
+				}
+			}()
+			handle(context.Background(), w, newRequest(t, ""))
+		}()
+		if w.Code != http.StatusOK || w.Body.Len() != 0 {
+			t.Errorf("Expected nothing written, got %d %q", w.Code, w.Body.String())
+		}
+		for _, m := range st.Metrics() {
+			if m.Name == "http.server.panics" {
+				t.Errorf("Expected no panics reported, got %+v", m)
+			}
+		}
+	})
+
+	t.Run("no-panic", func(t *testing.T) {
+		st := metricsbptest.NewRecordingStatsd(t, metricsbp.StatsdConfig{})
+		handle := httpbp.Wrap(
+			name,
+			newTestHandler(testHandlerPlan{}),
+			httpbp.RecoverPanics(httpbp.RecoverPanicsArgs{Metrics: st.Statsd}),
+		)
+		if err := handle(context.Background(), httptest.NewRecorder(), newRequest(t, "")); err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+		for _, m := range st.Metrics() {
+			if m.Name == "http.server.panics" {
+				t.Errorf("Expected no panics reported, got %+v", m)
+			}
+		}
+	})
+}
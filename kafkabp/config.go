@@ -7,6 +7,7 @@ import (
 	"github.com/Shopify/sarama"
 
 	"github.com/reddit/baseplate.go/log"
+	"github.com/reddit/baseplate.go/metricsbp"
 )
 
 // Allowed Offset values
@@ -99,6 +100,22 @@ type ConsumerConfig struct {
 	// or it might make things worse.
 	// You are advised to test before using non-empty rack id in production.
 	RackID RackIDFunc `yaml:"rackID"`
+
+	// Optional. The metrics of the consumed messages will be reported to it,
+	// or metricsbp.M if it's nil:
+	//
+	// - counter kafka.consumer.messages, tagged with topic
+	//
+	// - timing kafka.consumer.latency, tagged with topic,
+	// the time it takes for the ConsumeMessageFunc to process a message
+	//
+	// - gauge kafka.consumer.lag, tagged with topic and partition,
+	// the number of messages of the partition not consumed yet,
+	// computed once per reporting tick.
+	// It's 0 until the first message of the partition is consumed,
+	// and it's no longer reported after the partition is no longer assigned to
+	// this consumer, or the consumer is closed.
+	Statsd metricsbp.Metrics `yaml:"-"`
 }
 
 // Since not all sarama's default config are zero values,
@@ -5,6 +5,7 @@ import (
 	"io"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/Shopify/sarama"
 
@@ -66,6 +67,8 @@ type consumer struct {
 	offset          int64
 
 	wg sync.WaitGroup
+
+	metrics *consumerMetrics
 }
 
 // NewConsumer creates a new Kafka consumer.
@@ -97,9 +100,10 @@ func NewConsumer(cfg ConsumerConfig) (Consumer, error) {
 
 func newTopicConsumer(cfg ConsumerConfig, sc *sarama.Config) (Consumer, error) {
 	kc := &consumer{
-		cfg:    cfg,
-		sc:     sc,
-		offset: sc.Consumer.Offsets.Initial,
+		cfg:     cfg,
+		sc:      sc,
+		offset:  sc.Consumer.Offsets.Initial,
+		metrics: newConsumerMetrics(cfg.Statsd, cfg.Topic),
 	}
 
 	// Initialize Sarama consumer and set atomic values.
@@ -211,8 +215,10 @@ func (kc *consumer) Consume(
 
 			// consume partition consumer messages
 			wg.Add(1)
-			go func(pc sarama.PartitionConsumer) {
+			go func(p int32, pc sarama.PartitionConsumer) {
 				defer wg.Done()
+				pl := kc.metrics.claim(p, pc.HighWaterMarkOffset)
+				defer pl.release()
 				for m := range pc.Messages() {
 					// Wrap in anonymous function for easier defer.
 					func() {
@@ -226,10 +232,12 @@ func (kc *consumer) Consume(
 							}.Convert())
 						}()
 
+						start := time.Now()
 						messagesFunc(ctx, m)
+						kc.metrics.observe(pl, m, start)
 					}()
 				}
-			}(partitionConsumer)
+			}(p, partitionConsumer)
 
 			// consume partition consumer errors
 			wg.Add(1)
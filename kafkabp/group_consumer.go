@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/Shopify/sarama"
 
@@ -19,6 +20,8 @@ type groupConsumer struct {
 
 	consumeReturned int64
 	closed          int64
+
+	metrics *consumerMetrics
 }
 
 // newGroupConsumer creates a new group Consumer.
@@ -30,6 +33,7 @@ func newGroupConsumer(cfg ConsumerConfig, sc *sarama.Config) (Consumer, error) {
 	return &groupConsumer{
 		consumer: consumer,
 		cfg:      cfg,
+		metrics:  newConsumerMetrics(cfg.Statsd, cfg.Topic),
 	}, nil
 }
 
@@ -52,6 +56,7 @@ func (gc *groupConsumer) Consume(
 	handler := GroupConsumerHandler{
 		Callback: messagesFunc,
 		Topic:    gc.cfg.Topic,
+		metrics:  gc.metrics,
 	}
 
 	// gc.consumer.Consume returns when either:
@@ -91,6 +96,8 @@ func (gc *groupConsumer) IsHealthy() bool {
 type GroupConsumerHandler struct {
 	Callback ConsumeMessageFunc
 	Topic    string
+
+	metrics *consumerMetrics
 }
 
 // Setup is run at the beginning of a new session, before ConsumeClaim.
@@ -108,6 +115,8 @@ func (h GroupConsumerHandler) Cleanup(sarama.ConsumerGroupSession) error {
 
 // ConsumeClaim starts a consumer loop of ConsumerGroupClaim's Messages() chan.
 func (h GroupConsumerHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	pl := h.metrics.claim(claim.Partition(), claim.HighWaterMarkOffset)
+	defer pl.release()
 	for m := range claim.Messages() {
 		// Wrap in anonymous function for easier defer.
 		func() {
@@ -121,7 +130,9 @@ func (h GroupConsumerHandler) ConsumeClaim(session sarama.ConsumerGroupSession,
 				}.Convert())
 			}()
 
+			start := time.Now()
 			h.Callback(ctx, m)
+			h.metrics.observe(pl, m, start)
 			session.MarkMessage(
 				m,
 				"", // metadata
@@ -0,0 +1,198 @@
+package kafkabp
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/go-kit/kit/metrics"
+
+	"github.com/reddit/baseplate.go/metricsbp"
+)
+
+// consumerMetrics reports the metrics of a consumer,
+// configured by ConsumerConfig.Statsd.
+//
+// A nil *consumerMetrics (for example in a GroupConsumerHandler not created by
+// NewConsumer) reports nothing.
+type consumerMetrics struct {
+	st       metricsbp.Metrics
+	topic    string
+	messages metrics.Counter
+	latency  metrics.Histogram
+
+	mu         sync.Mutex
+	partitions map[int32]*partitionLag
+}
+
+// newConsumerMetrics creates a consumerMetrics reporting to st,
+// or metricsbp.M when st is nil.
+func newConsumerMetrics(st metricsbp.Metrics, topic string) *consumerMetrics {
+	st = metricsbp.MetricsOrM(st)
+	return &consumerMetrics{
+		st:         st,
+		topic:      topic,
+		messages:   st.Counter("kafka.consumer.messages").With("topic", topic),
+		latency:    st.Timing("kafka.consumer.latency").With("topic", topic),
+		partitions: make(map[int32]*partitionLag),
+	}
+}
+
+// claim starts tracking the lag of the partition,
+// with hwm returning its current high water mark offset,
+// until the returned partitionLag is released.
+//
+// The lag gauge of a partition is registered when it's claimed,
+// and unregistered when all its claims are released.
+// The offset consumed is kept for the next claim.
+func (cm *consumerMetrics) claim(partition int32, hwm func() int64) *partitionLag {
+	if cm == nil {
+		return nil
+	}
+
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	pl := cm.partitions[partition]
+	if pl == nil {
+		pl = &partitionLag{offset: -1}
+		cm.partitions[partition] = pl
+	}
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	pl.hwm = hwm
+	pl.claims++
+	if pl.cancel == nil {
+		pl.cancel = cm.st.GaugeFuncWithTags("kafka.consumer.lag", metricsbp.Tags{
+			"topic":     cm.topic,
+			"partition": strconv.FormatInt(int64(partition), 10),
+		}, pl.lag)
+	}
+	return pl
+}
+
+// observe reports the consumed message,
+// which started processing at start.
+func (cm *consumerMetrics) observe(pl *partitionLag, msg *sarama.ConsumerMessage, start time.Time) {
+	if cm == nil {
+		return
+	}
+	cm.messages.Add(1)
+	metricsbp.NewTimer(cm.latency).OverrideStartTime(start).ObserveDuration()
+	if pl != nil {
+		atomic.StoreInt64(&pl.offset, msg.Offset)
+	}
+}
+
+// partitionLag tracks the lag of a partition.
+type partitionLag struct {
+	// offset of the last consumed message, or -1 if none consumed yet.
+	offset int64 // atomic
+
+	mu     sync.Mutex
+	hwm    func() int64
+	claims int
+	cancel func()
+}
+
+// release releases a claim of the partition,
+// and unregisters its lag gauge when it's the last one,
+// so it's no longer reported until claimed again.
+func (pl *partitionLag) release() {
+	if pl == nil {
+		return
+	}
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	pl.claims--
+	if pl.claims > 0 {
+		return
+	}
+	pl.hwm = nil
+	if pl.cancel != nil {
+		pl.cancel()
+		pl.cancel = nil
+	}
+}
+
+func (pl *partitionLag) lag() float64 {
+	pl.mu.Lock()
+	hwm := pl.hwm
+	pl.mu.Unlock()
+
+	offset := atomic.LoadInt64(&pl.offset)
+	if hwm == nil || offset < 0 {
+		return 0
+	}
+	// The high water mark is the offset of the next message to be produced.
+	lag := hwm() - offset - 1
+	if lag < 0 {
+		return 0
+	}
+	return float64(lag)
+}
+
+// MonitorSyncProducer wraps a sarama.SyncProducer to report the metrics of
+// the messages sent to st.
+//
+// If st is nil, metricsbp.M will be used instead.
+//
+// It reports:
+//
+// - counter kafka.producer.messages, tagged with topic
+//
+// - counter kafka.producer.bytes, tagged with topic,
+// the total size of the keys and values of the messages
+//
+// - counter kafka.producer.errors, tagged with topic
+//
+// Only the messages sent successfully are counted in messages and bytes.
+func MonitorSyncProducer(producer sarama.SyncProducer, st metricsbp.Metrics) sarama.SyncProducer {
+	return monitoredSyncProducer{
+		SyncProducer: producer,
+		st:           metricsbp.MetricsOrM(st),
+	}
+}
+
+type monitoredSyncProducer struct {
+	sarama.SyncProducer
+
+	st metricsbp.Metrics
+}
+
+func (p monitoredSyncProducer) SendMessage(msg *sarama.ProducerMessage) (partition int32, offset int64, err error) {
+	partition, offset, err = p.SyncProducer.SendMessage(msg)
+	p.observe(msg, err == nil)
+	return
+}
+
+func (p monitoredSyncProducer) SendMessages(msgs []*sarama.ProducerMessage) error {
+	err := p.SyncProducer.SendMessages(msgs)
+	failed := make(map[*sarama.ProducerMessage]bool)
+	if errs, ok := err.(sarama.ProducerErrors); ok {
+		for _, e := range errs {
+			failed[e.Msg] = true
+		}
+	}
+	for _, msg := range msgs {
+		p.observe(msg, err == nil || (len(failed) > 0 && !failed[msg]))
+	}
+	return err
+}
+
+func (p monitoredSyncProducer) observe(msg *sarama.ProducerMessage, success bool) {
+	if !success {
+		p.st.Counter("kafka.producer.errors").With("topic", msg.Topic).Add(1)
+		return
+	}
+	p.st.Counter("kafka.producer.messages").With("topic", msg.Topic).Add(1)
+	p.st.Counter("kafka.producer.bytes").With("topic", msg.Topic).Add(float64(encoderLength(msg.Key) + encoderLength(msg.Value)))
+}
+
+func encoderLength(e sarama.Encoder) int {
+	if e == nil {
+		return 0
+	}
+	return e.Length()
+}
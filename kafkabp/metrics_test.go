@@ -0,0 +1,141 @@
+package kafkabp
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/Shopify/sarama/mocks"
+
+	"github.com/reddit/baseplate.go/metricsbp"
+	"github.com/reddit/baseplate.go/metricsbp/metricsbptest"
+)
+
+func TestConsumerMetrics(t *testing.T) {
+	const topic = "topic"
+	st := metricsbptest.NewRecordingStatsd(t, metricsbp.StatsdConfig{})
+	cm := newConsumerMetrics(st.Statsd, topic)
+
+	// lag returns the lag reported by a new flush,
+	// or false if it's not reported.
+	lag := func(t *testing.T) (float64, bool) {
+		t.Helper()
+		st.Reset()
+		var value float64
+		var found bool
+		for _, m := range st.Metrics() {
+			if m.Name == "kafka.consumer.lag" && m.HasTags(metricsbp.Tags{"topic": topic, "partition": "1"}) {
+				found = true
+				value = m.Value
+			}
+		}
+		return value, found
+	}
+	assertLag := func(t *testing.T, expected float64) {
+		t.Helper()
+		got, found := lag(t)
+		if !found {
+			t.Fatal("Lag gauge not reported")
+		}
+		if got != expected {
+			t.Errorf("Expected lag %v, got %v", expected, got)
+		}
+	}
+	assertNoLag := func(t *testing.T) {
+		t.Helper()
+		if got, found := lag(t); found {
+			t.Errorf("Expected lag not reported after released, got %v", got)
+		}
+	}
+
+	pl := cm.claim(1, func() int64 {
+		return 10
+	})
+	assertLag(t, 0)
+
+	cm.observe(pl, &sarama.ConsumerMessage{Topic: topic, Partition: 1, Offset: 4}, time.Now())
+	if got := st.AssertCounter("kafka.consumer.messages", metricsbp.Tags{"topic": topic}); got != 1 {
+		t.Errorf("Expected 1 message, got %v", got)
+	}
+	assertLag(t, 5)
+
+	// Claimed again before released, for example during a rebalance.
+	if cm.claim(1, func() int64 { return 10 }) != pl {
+		t.Error("Expected the same partitionLag to be reused when claimed again")
+	}
+	pl.release()
+	assertLag(t, 5)
+
+	pl.release()
+	assertNoLag(t)
+
+	if cm.claim(1, func() int64 { return 10 }) != pl {
+		t.Error("Expected the same partitionLag to be reused when claimed again")
+	}
+	assertLag(t, 5)
+	pl.release()
+	assertNoLag(t)
+}
+
+func TestConsumerMetricsNil(t *testing.T) {
+	var cm *consumerMetrics
+	pl := cm.claim(1, func() int64 { return 0 })
+	cm.observe(pl, &sarama.ConsumerMessage{}, time.Now())
+	pl.release()
+}
+
+func TestConsumerMetricsFallback(t *testing.T) {
+	prev := metricsbp.GetM()
+	defer metricsbp.SetM(prev)
+	st := metricsbptest.NewRecordingStatsd(t, metricsbp.StatsdConfig{})
+	metricsbp.SetM(st.Statsd)
+
+	cm := newConsumerMetrics(nil, "topic")
+	cm.observe(nil, &sarama.ConsumerMessage{}, time.Now())
+	if got := st.AssertCounter("kafka.consumer.messages", metricsbp.Tags{"topic": "topic"}); got != 1 {
+		t.Errorf("Expected 1 message reported to M, got %v", got)
+	}
+}
+
+func TestMonitorSyncProducer(t *testing.T) {
+	const topic = "topic"
+	st := metricsbptest.NewRecordingStatsd(t, metricsbp.StatsdConfig{})
+	mock := mocks.NewSyncProducer(t, nil)
+	defer mock.Close()
+	mock.ExpectSendMessageAndSucceed()
+	mock.ExpectSendMessageAndFail(errors.New("error"))
+	mock.ExpectSendMessageAndSucceed()
+	mock.ExpectSendMessageAndSucceed()
+	mock.ExpectSendMessageAndSucceed()
+	producer := MonitorSyncProducer(mock, st.Statsd)
+
+	msg := func() *sarama.ProducerMessage {
+		return &sarama.ProducerMessage{
+			Topic: topic,
+			Key:   sarama.StringEncoder("key"),
+			Value: sarama.StringEncoder("value"),
+		}
+	}
+	if _, _, err := producer.SendMessage(msg()); err != nil {
+		t.Fatal(err)
+	}
+	// Without sarama.ProducerErrors all the messages are treated as failed.
+	if err := producer.SendMessages([]*sarama.ProducerMessage{msg(), msg()}); err == nil {
+		t.Error("Expected error, got nil")
+	}
+	if err := producer.SendMessages([]*sarama.ProducerMessage{msg(), msg()}); err != nil {
+		t.Fatal(err)
+	}
+
+	tags := metricsbp.Tags{"topic": topic}
+	if got := st.AssertCounter("kafka.producer.messages", tags); got != 3 {
+		t.Errorf("Expected 3 messages, got %v", got)
+	}
+	if got := st.AssertCounter("kafka.producer.bytes", tags); got != 24 {
+		t.Errorf("Expected 24 bytes, got %v", got)
+	}
+	if got := st.AssertCounter("kafka.producer.errors", tags); got != 2 {
+		t.Errorf("Expected 2 errors, got %v", got)
+	}
+}
@@ -0,0 +1,54 @@
+package metricsbp
+
+import (
+	"sync"
+
+	"github.com/go-kit/kit/metrics"
+)
+
+// ActiveRequestsGauge is a gauge tracking the number of in-flight work,
+// for example the number of active requests of a server.
+//
+// Please use Statsd.ActiveRequests to create it.
+//
+// It's safe to be used concurrently.
+type ActiveRequestsGauge struct {
+	gauge metrics.Gauge
+}
+
+// ActiveRequests returns an ActiveRequestsGauge backed by the gauge metrics to
+// the name.
+func (st *Statsd) ActiveRequests(name string) ActiveRequestsGauge {
+	return ActiveRequestsGauge{
+		gauge: st.Gauge(name),
+	}
+}
+
+// Incr increments the number of in-flight work by 1.
+func (g ActiveRequestsGauge) Incr() {
+	g.gauge.Add(1)
+}
+
+// Decr decrements the number of in-flight work by 1.
+func (g ActiveRequestsGauge) Decr() {
+	g.gauge.Add(-1)
+}
+
+// Track increments the number of in-flight work by 1,
+// and returns a function to decrement it.
+//
+// The returned function is safe to be called multiple times,
+// only the first call decrements.
+// It's usually used with defer:
+//
+//     func (h *myHandler) Handle(ctx context.Context) {
+//       defer h.activeRequests.Track()()
+//       // do the work
+//     }
+func (g ActiveRequestsGauge) Track() (done func()) {
+	g.Incr()
+	var once sync.Once
+	return func() {
+		once.Do(g.Decr)
+	}
+}
@@ -0,0 +1,47 @@
+package metricsbp_test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/reddit/baseplate.go/metricsbp"
+)
+
+func TestActiveRequests(t *testing.T) {
+	st := metricsbp.NewStatsd(context.Background(), metricsbp.StatsdConfig{})
+	gauge := st.ActiveRequests("active")
+
+	check := func(t *testing.T, expected string) {
+		t.Helper()
+		var sb strings.Builder
+		if _, err := st.WriteTo(&sb); err != nil {
+			t.Fatal(err)
+		}
+		if got := sb.String(); got != expected {
+			t.Errorf("Expected %q, got %q", expected, got)
+		}
+	}
+
+	const n = 10
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			gauge.Incr()
+		}()
+	}
+	wg.Wait()
+	check(t, "active:10.000000|g\n")
+
+	done := gauge.Track()
+	check(t, "active:11.000000|g\n")
+	done()
+	done()
+	check(t, "active:10.000000|g\n")
+
+	gauge.Decr()
+	check(t, "active:9.000000|g\n")
+}
@@ -0,0 +1,135 @@
+package metricsbp
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+
+	"github.com/reddit/baseplate.go/randbp"
+)
+
+// adaptiveSampler adjusts the sample rates of the histograms and timings on
+// every reporting tick, according to TargetEmitRate in StatsdConfig.
+//
+// A nil *adaptiveSampler is disabled.
+type adaptiveSampler struct {
+	target float64
+	clock  clock
+
+	mu    sync.Mutex
+	last  time.Time
+	rates map[string]*adaptiveRate
+}
+
+// newAdaptiveSampler creates an adaptiveSampler,
+// or returns nil if target is not positive.
+func newAdaptiveSampler(target float64, clock clock) *adaptiveSampler {
+	if !(target > 0) {
+		return nil
+	}
+	return &adaptiveSampler{
+		target: target,
+		clock:  clock,
+		last:   clock.Now(),
+		rates:  make(map[string]*adaptiveRate),
+	}
+}
+
+// adaptiveRate is the adaptive sample rate of a metric name.
+type adaptiveRate struct {
+	rate  uint64 // atomic, math.Float64bits
+	count int64  // atomic, the number of observations since the last adjust
+
+	// setRate updates the sample rate reported to the statsd collector.
+	//
+	// It's guarded by the mu of adaptiveSampler.
+	setRate func(rate float64)
+}
+
+func (ar *adaptiveRate) get() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&ar.rate))
+}
+
+// get returns the adaptiveRate of the name, creating it if it doesn't exist
+// yet.
+func (as *adaptiveSampler) get(name string, setRate func(rate float64)) *adaptiveRate {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	ar := as.rates[name]
+	if ar == nil {
+		ar = &adaptiveRate{rate: math.Float64bits(1)}
+		as.rates[name] = ar
+	}
+	ar.setRate = setRate
+	return ar
+}
+
+// adjust sets the sample rates for the next reporting interval,
+// from the number of observations in the previous one,
+// so that the number of observations emitted stays around or below target
+// per second.
+//
+// It's called right after the metrics are written.
+func (as *adaptiveSampler) adjust() {
+	if as == nil {
+		return
+	}
+	now := as.clock.Now()
+
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	elapsed := now.Sub(as.last)
+	if elapsed <= 0 {
+		return
+	}
+	as.last = now
+	budget := as.target * elapsed.Seconds()
+	for _, ar := range as.rates {
+		rate := 1.0
+		if n := float64(atomic.SwapInt64(&ar.count, 0)); n > budget {
+			rate = budget / n
+		}
+		atomic.StoreUint64(&ar.rate, math.Float64bits(rate))
+		ar.setRate(rate)
+	}
+}
+
+// adaptiveHistogram creates a histogram to the name with the adaptive sample
+// rate, via newHistogram of the provider.
+func (st *Statsd) adaptiveHistogram(name string, newHistogram func(name string, sampleRate float64) metrics.Histogram) metrics.Histogram {
+	name = st.metricName(name)
+	ar := st.adaptive.get(name, func(rate float64) {
+		// The rates are registered by name in the provider.
+		newHistogram(name, rate)
+	})
+	return adaptiveHistogram{
+		Histogram: newTaggedHistogram(st, newHistogram(name, ar.get())),
+		rate:      ar,
+	}
+}
+
+// adaptiveHistogram is a metrics.Histogram sampling the Observe calls with the
+// adaptive sample rate.
+type adaptiveHistogram struct {
+	metrics.Histogram
+
+	rate *adaptiveRate
+}
+
+func (h adaptiveHistogram) With(labelValues ...string) metrics.Histogram {
+	return adaptiveHistogram{
+		Histogram: h.Histogram.With(labelValues...),
+		rate:      h.rate,
+	}
+}
+
+func (h adaptiveHistogram) Observe(value float64) {
+	atomic.AddInt64(&h.rate.count, 1)
+	if rate := h.rate.get(); rate < 1 && !randbp.ShouldSampleWithRate(rate) {
+		return
+	}
+	h.Histogram.Observe(value)
+}
@@ -0,0 +1,88 @@
+package metricsbp
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAdaptiveSampling(t *testing.T) {
+	clock := newFakeClock()
+	st := NewStatsd(context.Background(), StatsdConfig{
+		TargetEmitRate: 10,
+		SampleRates: map[string]float64{
+			"fixed": 1,
+		},
+		clock: clock,
+	})
+	defer st.Close()
+
+	write := func(t *testing.T) []string {
+		t.Helper()
+		var sb strings.Builder
+		if _, err := st.WriteTo(&sb); err != nil {
+			t.Fatal(err)
+		}
+		var lines []string
+		for _, line := range strings.Split(strings.TrimSpace(sb.String()), "\n") {
+			if line != "" {
+				lines = append(lines, line)
+			}
+		}
+		return lines
+	}
+	observe := func(n int) {
+		for i := 0; i < n; i++ {
+			st.Timing("timing").Observe(1)
+			st.Histogram("fixed").Observe(1)
+		}
+	}
+
+	// The first interval is not sampled.
+	observe(100)
+	clock.Advance(time.Second)
+	lines := write(t)
+	if len(lines) != 200 {
+		t.Fatalf("Expected 200 lines, got %d: %q", len(lines), lines)
+	}
+	for _, line := range lines {
+		if strings.Contains(line, "|@") {
+			t.Errorf("Did not expect sample rate in %q", line)
+		}
+	}
+
+	// 100 observations in the previous second gives the rate of 10/100.
+	observe(100)
+	clock.Advance(time.Second)
+	var timings, fixed int
+	for _, line := range write(t) {
+		switch {
+		case strings.HasPrefix(line, "timing:"):
+			timings++
+			const expected = "timing:1.000000|ms|@0.100000"
+			if line != expected {
+				t.Errorf("Expected %q, got %q", expected, line)
+			}
+		case strings.HasPrefix(line, "fixed:"):
+			fixed++
+		}
+	}
+	if timings == 0 || timings >= 50 {
+		t.Errorf("Expected about 10 timings emitted, got %d", timings)
+	}
+	if fixed != 100 {
+		t.Errorf("Expected 100 fixed histograms emitted, got %d", fixed)
+	}
+
+	// The rate goes back to 1 with low traffic.
+	clock.Advance(time.Second)
+	write(t)
+	st.Timing("timing").Observe(1)
+	clock.Advance(time.Second)
+	lines = write(t)
+	const expected = "timing:1.000000|ms"
+	if len(lines) != 1 || lines[0] != expected {
+		t.Errorf("Expected [%q], got %q", expected, lines)
+	}
+}
@@ -0,0 +1,224 @@
+package metricsbp
+
+import (
+	"sync"
+
+	"github.com/go-kit/kit/metrics"
+
+	"github.com/reddit/baseplate.go/randbp"
+)
+
+// Batch buffers the updates of several related metrics of the same event,
+// so that they are either all sampled in or all sampled out together,
+// when Done is called (except the gauges, which are never sampled).
+//
+// Please use Statsd.Batch to create it.
+// A Batch must not be used after Done is called.
+// It's safe to be used concurrently.
+type Batch struct {
+	st   *Statsd
+	rate float64
+
+	mu   sync.Mutex
+	ops  []batchOp
+	done bool
+}
+
+type batchOpKind int
+
+const (
+	batchCounterAdd batchOpKind = iota
+	batchHistogramObserve
+	batchTimingObserve
+	batchGaugeSet
+	batchGaugeAdd
+)
+
+type batchOp struct {
+	kind        batchOpKind
+	name        string
+	labelValues []string
+	value       float64
+}
+
+// Batch returns a Batch to buffer the updates of several related metrics,
+// for example the count, bytes, and latency of a request,
+// so that they share the same sampling decision and stay coherent.
+//
+// The sample rate of the Batch is HistogramSampleRate in StatsdConfig
+// (or the rate passed into WithSampleRate).
+// The per-metric overrides in StatsdConfig.SampleRates are not used.
+// When sampled in, all the updates of counters and histograms are reported
+// with the sample rate, so the statsd collector can scale them accordingly.
+// Gauges are never sampled (see Gauge),
+// so the updates of gauges are always applied, regardless of the decision.
+//
+// Example:
+//
+//     batch := metricsbp.M.Batch()
+//     defer batch.Done()
+//     batch.Counter("requests").Add(1)
+//     batch.Counter("bytes").Add(float64(size))
+//     batch.Timing("latency").Observe(float64(duration.Milliseconds()))
+func (st *Statsd) Batch() *Batch {
+	st = st.fallback()
+	return &Batch{
+		st:   st,
+		rate: st.histogramSampleRate,
+	}
+}
+
+func (b *Batch) add(op batchOp) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.done {
+		return
+	}
+	b.ops = append(b.ops, op)
+}
+
+// Counter returns a counter buffered by the Batch.
+func (b *Batch) Counter(name string) metrics.Counter {
+	return batchCounter{batch: b, name: name}
+}
+
+// Histogram returns a histogram with no specific unit buffered by the Batch.
+func (b *Batch) Histogram(name string) metrics.Histogram {
+	return batchHistogram{batch: b, name: name, kind: batchHistogramObserve}
+}
+
+// Timing returns a histogram with milliseconds as the unit buffered by the
+// Batch.
+func (b *Batch) Timing(name string) metrics.Histogram {
+	return batchHistogram{batch: b, name: name, kind: batchTimingObserve}
+}
+
+// Gauge returns a gauge buffered by the Batch.
+func (b *Batch) Gauge(name string) metrics.Gauge {
+	return batchGauge{batch: b, name: name}
+}
+
+// Done makes the sampling decision for all the buffered updates of counters
+// and histograms, and reports them if sampled in,
+// while the updates of gauges are always reported.
+//
+// Calling Done more than once is no-op.
+func (b *Batch) Done() {
+	b.mu.Lock()
+	if b.done {
+		b.mu.Unlock()
+		return
+	}
+	b.done = true
+	ops := b.ops
+	b.ops = nil
+	b.mu.Unlock()
+
+	if len(ops) == 0 {
+		return
+	}
+	sampled := randbp.ShouldSampleWithRate(b.rate)
+	rate := b.rate
+	args := func(name string) RateArgs {
+		return RateArgs{
+			Name:             name,
+			Rate:             1,
+			AlreadySampledAt: &rate,
+		}
+	}
+	for _, op := range ops {
+		if !sampled && op.kind != batchGaugeSet && op.kind != batchGaugeAdd {
+			continue
+		}
+		switch op.kind {
+		case batchCounterAdd:
+			b.st.CounterWithRate(args(op.name)).With(op.labelValues...).Add(op.value)
+		case batchHistogramObserve:
+			b.st.HistogramWithRate(args(op.name)).With(op.labelValues...).Observe(op.value)
+		case batchTimingObserve:
+			b.st.TimingWithRate(args(op.name)).With(op.labelValues...).Observe(op.value)
+		case batchGaugeSet:
+			b.st.Gauge(op.name).With(op.labelValues...).Set(op.value)
+		case batchGaugeAdd:
+			b.st.Gauge(op.name).With(op.labelValues...).Add(op.value)
+		}
+	}
+}
+
+// appendLabelValues returns a new slice, so the ones derived from the same
+// metric via With don't share the underlying array.
+func appendLabelValues(a, b []string) []string {
+	combined := make([]string, 0, len(a)+len(b))
+	combined = append(combined, a...)
+	return append(combined, b...)
+}
+
+type batchCounter struct {
+	batch       *Batch
+	name        string
+	labelValues []string
+}
+
+func (c batchCounter) With(labelValues ...string) metrics.Counter {
+	c.labelValues = appendLabelValues(c.labelValues, labelValues)
+	return c
+}
+
+func (c batchCounter) Add(delta float64) {
+	c.batch.add(batchOp{
+		kind:        batchCounterAdd,
+		name:        c.name,
+		labelValues: c.labelValues,
+		value:       delta,
+	})
+}
+
+type batchHistogram struct {
+	batch       *Batch
+	name        string
+	kind        batchOpKind
+	labelValues []string
+}
+
+func (h batchHistogram) With(labelValues ...string) metrics.Histogram {
+	h.labelValues = appendLabelValues(h.labelValues, labelValues)
+	return h
+}
+
+func (h batchHistogram) Observe(value float64) {
+	h.batch.add(batchOp{
+		kind:        h.kind,
+		name:        h.name,
+		labelValues: h.labelValues,
+		value:       value,
+	})
+}
+
+type batchGauge struct {
+	batch       *Batch
+	name        string
+	labelValues []string
+}
+
+func (g batchGauge) With(labelValues ...string) metrics.Gauge {
+	g.labelValues = appendLabelValues(g.labelValues, labelValues)
+	return g
+}
+
+func (g batchGauge) Set(value float64) {
+	g.batch.add(batchOp{
+		kind:        batchGaugeSet,
+		name:        g.name,
+		labelValues: g.labelValues,
+		value:       value,
+	})
+}
+
+func (g batchGauge) Add(delta float64) {
+	g.batch.add(batchOp{
+		kind:        batchGaugeAdd,
+		name:        g.name,
+		labelValues: g.labelValues,
+		value:       delta,
+	})
+}
@@ -0,0 +1,102 @@
+package metricsbp_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/reddit/baseplate.go/metricsbp"
+)
+
+func TestBatch(t *testing.T) {
+	st := metricsbp.NewStatsd(
+		context.Background(),
+		metricsbp.StatsdConfig{},
+	)
+
+	batch := st.Batch()
+	batch.Counter("count").With("key", "value").Add(1)
+	batch.Histogram("bytes").Observe(10)
+	batch.Timing("latency").Observe(2)
+	batch.Gauge("gauge").Set(3)
+
+	var sb strings.Builder
+	if _, err := st.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	if sb.Len() != 0 {
+		t.Errorf("Expected nothing reported before Done, got %q", sb.String())
+	}
+
+	batch.Done()
+	// Updates after Done are dropped, and Done is idempotent.
+	batch.Counter("count").With("key", "value").Add(1)
+	batch.Done()
+
+	sb.Reset()
+	if _, err := st.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"count,key=value:1.000000|c\n",
+		"bytes:10.000000|h\n",
+		"latency:2.000000|ms\n",
+		"gauge:3.000000|g\n",
+	} {
+		if !strings.Contains(sb.String(), expected) {
+			t.Errorf("Expected %q in %q", expected, sb.String())
+		}
+	}
+}
+
+func TestBatchSampled(t *testing.T) {
+	const n = 1000
+
+	st := metricsbp.NewStatsd(
+		context.Background(),
+		metricsbp.StatsdConfig{
+			HistogramSampleRate: metricsbp.Float64Ptr(0.5),
+		},
+	)
+	for i := 0; i < n; i++ {
+		batch := st.Batch()
+		batch.Counter("count").Add(1)
+		batch.Timing("latency").Observe(1)
+		batch.Gauge("gauge").Add(1)
+		batch.Done()
+	}
+
+	var sb strings.Builder
+	if _, err := st.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	var count float64
+	var latencies int
+	var gauge string
+	for _, line := range strings.Split(strings.TrimSpace(sb.String()), "\n") {
+		switch {
+		case strings.HasPrefix(line, "gauge:"):
+			gauge = line
+		case strings.HasPrefix(line, "count:"):
+			if !strings.HasSuffix(line, "|c|@0.500000") {
+				t.Errorf("Expected count to be reported at sample rate 0.5, got %q", line)
+			}
+			if _, err := fmt.Sscanf(line, "count:%f|c", &count); err != nil {
+				t.Fatalf("Failed to parse %q: %v", line, err)
+			}
+		case strings.HasPrefix(line, "latency:"):
+			latencies++
+		}
+	}
+	if count == 0 || count == n {
+		t.Errorf("Expected some of the batches to be sampled out, got %v", count)
+	}
+	if int(count) != latencies {
+		t.Errorf("Expected the same number of counts and latencies, got %v and %d", count, latencies)
+	}
+	// Gauges are never sampled.
+	if expected := "gauge:1000.000000|g"; gauge != expected {
+		t.Errorf("Expected %q, got %q", expected, gauge)
+	}
+}
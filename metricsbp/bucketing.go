@@ -0,0 +1,155 @@
+package metricsbp
+
+import (
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-kit/kit/metrics"
+)
+
+// bucketedHistogram is a metrics.Histogram tallying the observations into the
+// local buckets configured by LocalBucketing in StatsdConfig,
+// instead of keeping every observation.
+//
+// It's always wrapped by taggedHistogram,
+// so With is only called once on the untagged one with all the tags.
+type bucketedHistogram struct {
+	name   string
+	typ    string
+	tags   []string
+	bounds []float64
+	st     *Statsd
+}
+
+// newBucketedHistogram creates the bucketedHistogram of name,
+// with typ being the statsd type of the lines.
+func (st *Statsd) newBucketedHistogram(name, typ string, bounds []float64) bucketedHistogram {
+	return bucketedHistogram{
+		name:   st.metricName(name),
+		typ:    typ,
+		bounds: bounds,
+		st:     st,
+	}
+}
+
+func (h bucketedHistogram) With(tagValues ...string) metrics.Histogram {
+	tags := make([]string, 0, len(h.tags)+len(tagValues))
+	tags = append(tags, h.tags...)
+	tags = append(tags, tagValues...)
+	h.tags = tags
+	return h
+}
+
+func (h bucketedHistogram) Observe(value float64) {
+	h.st.bucketed.observe(h, value)
+	for _, sink := range h.st.sinks {
+		sink.bucketed.observe(h, value)
+	}
+}
+
+// bucketKey is the key of a bucketed histogram with tags in bucketSpace.
+type bucketKey struct {
+	name string
+	typ  string
+	tags string
+}
+
+// bucketCounts are the counts of the local buckets of a bucketed histogram
+// with tags.
+type bucketCounts struct {
+	tags   []string
+	bounds []float64
+	// counts has one more entry than bounds,
+	// for the values larger than the last bound.
+	counts []int
+	// overflowMax is the largest value larger than the last bound.
+	overflowMax float64
+}
+
+// bucketSpace holds the counts of all the bucketed histograms since last
+// write.
+//
+// The memory it uses is bounded by the number of buckets of every bucketed
+// histogram with tags, regardless of the number of observations.
+type bucketSpace struct {
+	prefix   string
+	provider provider
+
+	mu     sync.Mutex
+	series map[bucketKey]*bucketCounts
+}
+
+func newBucketSpace(prefix string, p provider) *bucketSpace {
+	return &bucketSpace{
+		prefix:   prefix,
+		provider: p,
+		series:   make(map[bucketKey]*bucketCounts),
+	}
+}
+
+func (bs *bucketSpace) observe(h bucketedHistogram, value float64) {
+	key := bucketKey{
+		name: h.name,
+		typ:  h.typ,
+		tags: strings.Join(h.tags, "\x00"),
+	}
+	// The first bound not smaller than value,
+	// or len(h.bounds) for the values larger than all the bounds.
+	i := sort.SearchFloat64s(h.bounds, value)
+
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	counts, ok := bs.series[key]
+	if !ok {
+		counts = &bucketCounts{
+			tags:   h.tags,
+			bounds: h.bounds,
+			counts: make([]int, len(h.bounds)+1),
+		}
+		bs.series[key] = counts
+	}
+	counts.counts[i]++
+	if i == len(h.bounds) && (counts.counts[i] == 1 || value > counts.overflowMax) {
+		counts.overflowMax = value
+	}
+}
+
+// WriteTo writes a line for every non-empty bucket to w and resets the space.
+//
+// The value of the line is the upper bound of the bucket
+// (or the largest value observed for the values larger than all the bounds),
+// and the count of the bucket is encoded the same way as CountedHistogram.
+func (bs *bucketSpace) WriteTo(w io.Writer) (count int64, err error) {
+	bs.mu.Lock()
+	all := bs.series
+	bs.series = make(map[bucketKey]*bucketCounts)
+	bs.mu.Unlock()
+
+	for key, counts := range all {
+		for i, c := range counts.counts {
+			if c == 0 {
+				continue
+			}
+			value := counts.overflowMax
+			if i < len(counts.bounds) {
+				value = counts.bounds[i]
+			}
+			var n int
+			n, err = io.WriteString(w, bs.provider.countedLine(
+				bs.prefix+key.name,
+				counts.tags,
+				key.typ,
+				value,
+				c,
+			))
+			count += int64(n)
+			if err != nil {
+				return count, err
+			}
+		}
+	}
+	return count, nil
+}
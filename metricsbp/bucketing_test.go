@@ -0,0 +1,55 @@
+package metricsbp_test
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/reddit/baseplate.go/metricsbp"
+)
+
+func TestLocalBucketing(t *testing.T) {
+	st := metricsbp.NewStatsd(context.Background(), metricsbp.StatsdConfig{
+		LocalBucketing: map[string][]float64{
+			"latency": {100, 10, 50, 10},
+			"size":    {1024},
+		},
+	})
+	latency := st.Timing("latency")
+	for _, v := range []float64{1, 10, 20, 30, 50, 200, 300} {
+		latency.Observe(v)
+	}
+	st.Timing("latency").With("key", "value").Observe(5)
+	st.Histogram("size").Observe(1)
+	// Not configured, emitted as-is.
+	st.Timing("other").Observe(1)
+
+	var sb strings.Builder
+	if _, err := st.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(sb.String()), "\n")
+	sort.Strings(lines)
+	expected := []string{
+		"latency,key=value:10.000000|ms",
+		"latency:10.000000|ms|@0.5",
+		"latency:300.000000|ms|@0.5",
+		"latency:50.000000|ms|@0.3333333333333333",
+		"other:1.000000|ms",
+		"size:1024.000000|h",
+	}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Expected %q, got %q", expected, lines)
+	}
+
+	// The buckets should be reset after WriteTo.
+	sb.Reset()
+	if _, err := st.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	if sb.Len() != 0 {
+		t.Errorf("Expected nothing written after reset, got %q", sb.String())
+	}
+}
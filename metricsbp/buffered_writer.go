@@ -3,20 +3,43 @@ package metricsbp
 import (
 	"bytes"
 	"io"
+	"sync"
+	"time"
 
-	"github.com/reddit/baseplate.go/log"
+	kitlog "github.com/go-kit/kit/log"
 )
 
+// errorLogInterval is the min interval between the logs of the consecutive
+// write failures.
+const errorLogInterval = time.Minute
+
 type bufferedWriter struct {
+	// mu guards doWrite calls from the reporting goroutine and Statsd.Flush.
+	mu sync.Mutex
+
 	buf  bytes.Buffer
 	w    io.Writer
 	size int
+
+	// failures is the number of consecutive failed doWrite calls.
+	failures int
+
+	// suppressed is the number of failures not logged since lastLogged.
+	suppressed int
+	lastLogged time.Time
+
+	// now is the source of time for the rate limiting of the logs.
+	now func() time.Time
+
+	// written is the number of bytes written to w by the current doWrite call.
+	written int64
 }
 
 func newBufferedWriter(w io.Writer, size int) *bufferedWriter {
 	bufWriter := &bufferedWriter{
 		w:    w,
 		size: size,
+		now:  time.Now,
 	}
 	if size > 0 {
 		bufWriter.buf.Grow(size)
@@ -29,7 +52,8 @@ func (bw *bufferedWriter) Flush() error {
 		return nil
 	}
 
-	_, err := bw.w.Write(bw.buf.Bytes())
+	n, err := bw.w.Write(bw.buf.Bytes())
+	bw.written += int64(n)
 	bw.buf.Reset()
 	return err
 }
@@ -44,15 +68,60 @@ func (bw *bufferedWriter) Write(p []byte) (n int, err error) {
 	return bw.buf.Write(p)
 }
 
-func (bw *bufferedWriter) doWrite(src io.WriterTo, logger log.KitWrapper) (err error) {
+// doWrite writes everything from src to the underlying writer,
+// and returns the number of bytes written to the underlying writer.
+func (bw *bufferedWriter) doWrite(src io.WriterTo, logger kitlog.Logger) (written int64, err error) {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+
+	bw.written = 0
 	defer func() {
-		if err != nil {
-			logger.Log("during", "WriteTo", "err", err)
-		}
+		written = bw.written
+		bw.logResult(logger, err)
 	}()
 
 	if _, err := src.WriteTo(bw); err != nil {
-		return err
+		return 0, err
+	}
+	return 0, bw.Flush()
+}
+
+// logResult logs the result of a doWrite call.
+//
+// To avoid flooding the logs when the statsd collector is unreachable,
+// the first failure is logged,
+// then the consecutive failures are logged at most once per errorLogInterval,
+// with the number of failures suppressed since the previous log.
+// The recovery is logged when it succeeds again.
+//
+// It must be called with bw.mu held.
+func (bw *bufferedWriter) logResult(logger kitlog.Logger, err error) {
+	if err == nil {
+		if bw.failures > 0 {
+			logger.Log(
+				"during", "WriteTo",
+				"msg", "recovered from failures",
+				"failures", bw.failures,
+				"suppressed", bw.suppressed,
+			)
+		}
+		bw.failures = 0
+		bw.suppressed = 0
+		return
+	}
+
+	bw.failures++
+	now := bw.now()
+	if bw.failures > 1 && now.Sub(bw.lastLogged) < errorLogInterval {
+		bw.suppressed++
+		return
 	}
-	return bw.Flush()
+	logger.Log(
+		"during", "WriteTo",
+		"err", err,
+		"failures", bw.failures,
+		"suppressed", bw.suppressed,
+	)
+	bw.lastLogged = now
+	bw.suppressed = 0
 }
@@ -2,8 +2,11 @@ package metricsbp
 
 import (
 	"bytes"
+	"errors"
+	"io"
 	"strings"
 	"testing"
+	"time"
 )
 
 const (
@@ -117,3 +120,86 @@ func TestBufferedWriter(t *testing.T) {
 		})
 	}
 }
+
+type failingWriter struct {
+	fail bool
+}
+
+func (fw *failingWriter) Write(p []byte) (int, error) {
+	if fw.fail {
+		return 0, errors.New("failed")
+	}
+	return len(p), nil
+}
+
+type recordingLogger struct {
+	logs [][]interface{}
+}
+
+func (rl *recordingLogger) Log(keyvals ...interface{}) error {
+	rl.logs = append(rl.logs, keyvals)
+	return nil
+}
+
+type msgWriterTo struct{}
+
+func (msgWriterTo) WriteTo(w io.Writer) (int64, error) {
+	n, err := io.WriteString(w, msg)
+	return int64(n), err
+}
+
+func TestBufferedWriterLogRateLimit(t *testing.T) {
+	writer := &failingWriter{fail: true}
+	logger := new(recordingLogger)
+	bufWriter := newBufferedWriter(writer, msgSize)
+	now := time.Unix(0, 0)
+	bufWriter.now = func() time.Time {
+		return now
+	}
+
+	// One failure every 10 seconds, for 2 minutes.
+	const failures = 13
+	for i := 0; i < failures; i++ {
+		if _, err := bufWriter.doWrite(msgWriterTo{}, logger); err == nil {
+			t.Fatalf("Expected error on write #%d", i)
+		}
+		now = now.Add(10 * time.Second)
+	}
+	// Should be logged on the 1st, 7th (1 minute later), and 13th failures.
+	if len(logger.logs) != 3 {
+		t.Fatalf("Expected 3 logs, got %d: %v", len(logger.logs), logger.logs)
+	}
+	if got := logValue(logger.logs[1], "suppressed"); got != 5 {
+		t.Errorf("Expected 5 suppressed failures in %v, got %v", logger.logs[1], got)
+	}
+
+	writer.fail = false
+	if n, err := bufWriter.doWrite(msgWriterTo{}, logger); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	} else if n != msgSize {
+		t.Errorf("Expected %d bytes written, got %d", msgSize, n)
+	}
+	if len(logger.logs) != 4 {
+		t.Errorf("Expected recovery to be logged, got %v", logger.logs)
+	}
+	if bufWriter.failures != 0 {
+		t.Errorf("Expected failures to be reset, got %d", bufWriter.failures)
+	}
+
+	// The first failure after recovery should always be logged.
+	writer.fail = true
+	bufWriter.doWrite(msgWriterTo{}, logger)
+	if len(logger.logs) != 5 {
+		t.Errorf("Expected the first failure after recovery to be logged, got %v", logger.logs)
+	}
+}
+
+// logValue returns the value of key in the go-kit style keyvals.
+func logValue(keyvals []interface{}, key string) interface{} {
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		if keyvals[i] == key {
+			return keyvals[i+1]
+		}
+	}
+	return nil
+}
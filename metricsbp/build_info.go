@@ -0,0 +1,47 @@
+package metricsbp
+
+import (
+	"runtime"
+)
+
+// BuildInfoGauge is the name of the gauge reported by ReportBuildInfo.
+const BuildInfoGauge = "build_info"
+
+// GoVersionTagKey is the tag key of the go version added by ReportBuildInfo.
+const GoVersionTagKey = "go_version"
+
+// ReportBuildInfo registers a constant gauge of 1 to BuildInfoGauge,
+// tagged with info and reported on every tick,
+// so that dashboards can annotate the deploys of new versions.
+//
+// The values of info usually come from the build time ldflags, for example:
+//
+//     var (
+//       version string
+//       gitSHA  string
+//     )
+//
+//     func main() {
+//       // ...
+//       metricsbp.M.ReportBuildInfo(map[string]string{
+//         "version": version,
+//         "git_sha": gitSHA,
+//       })
+//     }
+//
+//     go build -ldflags "-X main.version=1.2.3 -X main.gitSHA=$(git rev-parse HEAD)"
+//
+// The go version (runtime.Version) is added with GoVersionTagKey,
+// unless it's already in info.
+//
+// It should only be called once per Statsd.
+func (st *Statsd) ReportBuildInfo(info map[string]string) {
+	tags := make(Tags, len(info)+1)
+	tags[GoVersionTagKey] = runtime.Version()
+	for k, v := range info {
+		tags[k] = v
+	}
+	st.WithTags(tags).GaugeFunc(BuildInfoGauge, func() float64 {
+		return 1
+	})
+}
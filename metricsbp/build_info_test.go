@@ -0,0 +1,42 @@
+package metricsbp_test
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/reddit/baseplate.go/metricsbp"
+)
+
+func TestReportBuildInfo(t *testing.T) {
+	st := metricsbp.NewStatsd(
+		context.Background(),
+		metricsbp.StatsdConfig{},
+	)
+	st.ReportBuildInfo(map[string]string{
+		"version": "1.2.3",
+		"git_sha": "deadbeef",
+	})
+
+	// Reported on every tick.
+	for i := 0; i < 2; i++ {
+		var sb strings.Builder
+		if _, err := st.WriteTo(&sb); err != nil {
+			t.Fatal(err)
+		}
+		line := strings.TrimSpace(sb.String())
+		if !strings.HasPrefix(line, metricsbp.BuildInfoGauge+",") || !strings.HasSuffix(line, ":1.000000|g") {
+			t.Errorf("Tick %d: Unexpected line %q", i, line)
+		}
+		for _, tag := range []string{
+			"git_sha=deadbeef",
+			"version=1.2.3",
+			"go_version=" + runtime.Version(),
+		} {
+			if !strings.Contains(line, tag) {
+				t.Errorf("Tick %d: Expected tag %q in %q", i, tag, line)
+			}
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package metricsbp
+
+import (
+	"sync"
+	"time"
+)
+
+// tickFuncs holds the callbacks to be called before every write,
+// which happens once per reporting tick when Address or Writer is configured.
+//
+// It's also used to hold the callbacks discarding the states accumulated in
+// process by Meter and Summary, see Statsd.Reset.
+type tickFuncs struct {
+	mu    sync.Mutex
+	funcs []*tickFunc
+}
+
+type tickFunc struct {
+	f func()
+}
+
+// add registers f,
+// and returns the func removing it, which is safe to be called multiple times.
+func (tf *tickFuncs) add(f func()) (remove func()) {
+	entry := &tickFunc{f: f}
+	tf.mu.Lock()
+	defer tf.mu.Unlock()
+	tf.funcs = append(tf.funcs, entry)
+	return func() {
+		tf.remove(entry)
+	}
+}
+
+func (tf *tickFuncs) remove(entry *tickFunc) {
+	tf.mu.Lock()
+	defer tf.mu.Unlock()
+	for i, e := range tf.funcs {
+		if e == entry {
+			// Copy instead of modifying in place,
+			// as run could be iterating the current slice.
+			funcs := make([]*tickFunc, 0, len(tf.funcs)-1)
+			funcs = append(funcs, tf.funcs[:i]...)
+			tf.funcs = append(funcs, tf.funcs[i+1:]...)
+			return
+		}
+	}
+}
+
+func (tf *tickFuncs) run() {
+	tf.mu.Lock()
+	funcs := tf.funcs
+	tf.mu.Unlock()
+
+	for _, e := range funcs {
+		e.f()
+	}
+}
+
+// startTickFuncs starts a background goroutine running the tick callbacks
+// every interval until the context of st is canceled.
+//
+// It's used by the Provider and Prometheus in StatsdConfig without Address or
+// Writer, which report the metrics on their own but would otherwise never run
+// the callbacks of GaugeFunc, CounterFunc, Meter and Summary.
+func (st *Statsd) startTickFuncs(interval time.Duration) {
+	st.shared.tickDone = make(chan struct{})
+	go func() {
+		defer close(st.shared.tickDone)
+		ticker := st.clock.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-st.ctx.Done():
+				return
+			case <-ticker.Chan():
+				st.onTick.run()
+			}
+		}
+	}()
+}
+
+// GaugeFunc registers a callback to report a gauge metrics to the name.
+//
+// f will be called once per reporting tick (or every time WriteTo is called),
+// including when only Provider or Prometheus is set in StatsdConfig,
+// and the returned value will be reported as the value of the gauge.
+// It's useful for gauges that are cheap to compute on demand,
+// for example the size of a queue or a connection pool.
+//
+// f will no longer be called after the context passed into NewStatsd is
+// canceled, or after the returned cancel func is called.
+// Callbacks tied to something with a shorter lifetime than st
+// (for example a connection, or a partition assigned to a consumer)
+// should be canceled when it's gone,
+// otherwise they keep being called and reported.
+// It's safe to register many callbacks,
+// they will be called in the order they are registered.
+func (st *Statsd) GaugeFunc(name string, f func() float64) (cancel func()) {
+	st = st.fallback()
+	gauge := st.Gauge(name)
+	return st.onTick.add(func() {
+		gauge.Set(f())
+	})
+}
+
+// GaugeFuncWithTags is the same as GaugeFunc,
+// with the gauge tagged with tags in addition to the tags of st.
+//
+// It's the same as st.WithTags(tags).GaugeFunc(name, f),
+// for the code only having the Metrics interface.
+func (st *Statsd) GaugeFuncWithTags(name string, tags Tags, f func() float64) (cancel func()) {
+	return st.WithTags(tags).GaugeFunc(name, f)
+}
+
+// CounterFunc registers a callback to report a counter metrics to the name,
+// from an external monotonic source that can be read but not incremented
+// directly, for example the total processed count of a C library.
+//
+// f will be called once per reporting tick (or every time WriteTo is called),
+// including when only Provider or Prometheus is set in StatsdConfig,
+// and the delta from the previous returned value will be added to the counter.
+// The first call only establishes the baseline and reports nothing.
+// If the returned value decreases (for example the source was reset),
+// it's treated as a new baseline and nothing is reported for that tick.
+//
+// The counter is never sampled.
+// Other than that, f is called the same way as the callbacks registered via
+// GaugeFunc.
+func (st *Statsd) CounterFunc(name string, f func() float64) {
+	st = st.fallback()
+	counter := st.CounterWithRate(RateArgs{
+		Name: name,
+		Rate: 1,
+	})
+	var (
+		mu       sync.Mutex
+		last     float64
+		baseline bool
+	)
+	st.onTick.add(func() {
+		mu.Lock()
+		defer mu.Unlock()
+
+		value := f()
+		if baseline && value > last {
+			counter.Add(value - last)
+		}
+		last = value
+		baseline = true
+	})
+}
@@ -0,0 +1,122 @@
+package metricsbp_test
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/reddit/baseplate.go/metricsbp"
+)
+
+func TestGaugeFunc(t *testing.T) {
+	st := metricsbp.NewStatsd(context.Background(), metricsbp.StatsdConfig{})
+	var calls int
+	st.GaugeFunc("gauge", func() float64 {
+		calls++
+		return 42
+	})
+
+	var sb strings.Builder
+	if _, err := st.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	const expected = "gauge:42.000000|g\n"
+	if got := sb.String(); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+	if calls != 1 {
+		t.Errorf("Expected f to be called once, got %d", calls)
+	}
+
+	st.Close()
+	sb.Reset()
+	if _, err := st.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected f to not be called after Close, got %d calls", calls)
+	}
+}
+
+func TestGaugeFuncWithTags(t *testing.T) {
+	st := metricsbp.NewStatsd(context.Background(), metricsbp.StatsdConfig{})
+	var m metricsbp.Metrics = st
+	cancel := m.GaugeFuncWithTags("gauge", metricsbp.Tags{"foo": "bar"}, func() float64 {
+		return 42
+	})
+
+	var sb strings.Builder
+	if _, err := st.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	const expected = "gauge,foo=bar:42.000000|g\n"
+	if got := sb.String(); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+
+	cancel()
+	cancel()
+	sb.Reset()
+	if _, err := st.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	if got := sb.String(); got != "" {
+		t.Errorf("Expected nothing after canceled, got %q", got)
+	}
+}
+
+func TestGaugeFuncCancel(t *testing.T) {
+	st := metricsbp.NewStatsd(context.Background(), metricsbp.StatsdConfig{})
+	var calls []string
+	cancels := make(map[string]func())
+	for _, name := range []string{"a", "b", "c"} {
+		name := name
+		cancels[name] = st.GaugeFunc(name, func() float64 {
+			calls = append(calls, name)
+			return 1
+		})
+	}
+	cancels["b"]()
+
+	if _, err := st.WriteTo(ioutil.Discard); err != nil {
+		t.Fatal(err)
+	}
+	if got, expected := strings.Join(calls, ","), "a,c"; got != expected {
+		t.Errorf("Expected the callbacks %q called in order, got %q", expected, got)
+	}
+}
+
+func TestCounterFunc(t *testing.T) {
+	st := metricsbp.NewStatsd(context.Background(), metricsbp.StatsdConfig{
+		// CounterFunc is never sampled, so the deltas would be dropped or scaled
+		// if it inherited the counter sample rate.
+		CounterSampleRate: metricsbp.Float64Ptr(0.01),
+	})
+	values := []float64{10, 15, 15, 3, 5}
+	var calls int
+	st.CounterFunc("counter", func() float64 {
+		value := values[calls]
+		calls++
+		return value
+	})
+
+	for _, expected := range []string{
+		// baseline
+		"",
+		"counter:5.000000|c\n",
+		// unchanged
+		"",
+		// reset
+		"",
+		"counter:2.000000|c\n",
+	} {
+		var sb strings.Builder
+		if _, err := st.WriteTo(&sb); err != nil {
+			t.Fatal(err)
+		}
+		if got := sb.String(); got != expected {
+			t.Errorf("Expected %q after %d calls, got %q", expected, calls, got)
+		}
+	}
+}
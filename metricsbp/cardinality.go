@@ -0,0 +1,97 @@
+package metricsbp
+
+import (
+	"sync"
+
+	"github.com/go-kit/kit/log"
+)
+
+// OverflowTagValue is the tag value used in lieu of the actual ones after a
+// tag key reached MaxTagCardinality in StatsdConfig.
+const OverflowTagValue = "__overflow__"
+
+// cardinalityLimiter limits the number of distinct values of every tag key
+// within a reporting window.
+type cardinalityLimiter struct {
+	max    int
+	logger log.Logger
+
+	mu         sync.Mutex
+	values     map[string]map[string]struct{}
+	overflowed map[string]bool
+}
+
+// newCardinalityLimiter creates a cardinalityLimiter.
+//
+// It returns nil when max is not positive,
+// and a nil *cardinalityLimiter does not limit anything.
+func newCardinalityLimiter(max int, logger log.Logger) *cardinalityLimiter {
+	if max <= 0 {
+		return nil
+	}
+	return &cardinalityLimiter{
+		max:        max,
+		logger:     logger,
+		values:     make(map[string]map[string]struct{}),
+		overflowed: make(map[string]bool),
+	}
+}
+
+// limit returns tagValues (as key-value pairs) with the values over the
+// limit replaced by OverflowTagValue.
+func (cl *cardinalityLimiter) limit(tagValues []string) []string {
+	if cl == nil {
+		return tagValues
+	}
+
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	var limited []string
+	for i := 0; i+1 < len(tagValues); i += 2 {
+		key, value := tagValues[i], tagValues[i+1]
+		values := cl.values[key]
+		if values == nil {
+			values = make(map[string]struct{})
+			cl.values[key] = values
+		}
+		if _, ok := values[value]; ok {
+			continue
+		}
+		if len(values) < cl.max {
+			values[value] = struct{}{}
+			continue
+		}
+
+		if !cl.overflowed[key] {
+			cl.overflowed[key] = true
+			cl.logger.Log(
+				"during", "metricsbp.MaxTagCardinality",
+				"msg", "tag key reached max cardinality, further values will be reported as "+OverflowTagValue,
+				"key", key,
+				"max", cl.max,
+			)
+		}
+		if limited == nil {
+			limited = make([]string, len(tagValues))
+			copy(limited, tagValues)
+		}
+		limited[i+1] = OverflowTagValue
+	}
+	if limited == nil {
+		return tagValues
+	}
+	return limited
+}
+
+// reset starts a new reporting window.
+func (cl *cardinalityLimiter) reset() {
+	if cl == nil {
+		return
+	}
+
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	cl.values = make(map[string]map[string]struct{})
+	cl.overflowed = make(map[string]bool)
+}
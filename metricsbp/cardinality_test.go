@@ -0,0 +1,78 @@
+package metricsbp_test
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/reddit/baseplate.go/metricsbp"
+)
+
+func TestMaxTagCardinality(t *testing.T) {
+	st := metricsbp.NewStatsd(
+		context.Background(),
+		metricsbp.StatsdConfig{
+			MaxTagCardinality: 2,
+			Tags: metricsbp.Tags{
+				"static": "value",
+			},
+		},
+	)
+	write := func() []string {
+		t.Helper()
+		var sb strings.Builder
+		if _, err := st.WriteTo(&sb); err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSpace(sb.String()), "\n")
+		sort.Strings(lines)
+		return lines
+	}
+
+	counter := st.Counter("counter")
+	for _, id := range []string{"a", "b", "a", "c", "d"} {
+		counter.With("id", id, "other", "x").Add(1)
+	}
+	st.Set("set").With("id", "e").Add("foo")
+	expected := []string{
+		"counter,static=value,id=__overflow__,other=x:2.000000|c",
+		"counter,static=value,id=a,other=x:2.000000|c",
+		"counter,static=value,id=b,other=x:1.000000|c",
+		"set,static=value,id=__overflow__:foo|s",
+	}
+	if lines := write(); !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Expected %q, got %q", expected, lines)
+	}
+
+	// The limit resets on every report.
+	for _, id := range []string{"c", "d", "e"} {
+		counter.With("id", id).Add(1)
+	}
+	expected = []string{
+		"counter,static=value,id=__overflow__:1.000000|c",
+		"counter,static=value,id=c:1.000000|c",
+		"counter,static=value,id=d:1.000000|c",
+	}
+	if lines := write(); !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Expected %q, got %q", expected, lines)
+	}
+}
+
+func TestMaxTagCardinalityDisabled(t *testing.T) {
+	st := metricsbp.NewStatsd(
+		context.Background(),
+		metricsbp.StatsdConfig{},
+	)
+	for _, id := range []string{"a", "b", "c"} {
+		st.Counter("counter").With("id", id).Add(1)
+	}
+	var sb strings.Builder
+	if _, err := st.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(sb.String(), metricsbp.OverflowTagValue) {
+		t.Errorf("Did not expect %q in %q", metricsbp.OverflowTagValue, sb.String())
+	}
+}
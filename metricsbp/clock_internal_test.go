@@ -0,0 +1,531 @@
+package metricsbp
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock that only advances when Advance is called.
+type fakeClock struct {
+	// created receives every ticker created by NewTicker.
+	created chan *fakeTicker
+
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{
+		created: make(chan *fakeTicker, 10),
+		now:     time.Unix(0, 0),
+	}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) NewTicker(d time.Duration) ticker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTicker{
+		c:    make(chan time.Time, 1),
+		d:    d,
+		next: c.now.Add(d),
+	}
+	c.tickers = append(c.tickers, t)
+	c.created <- t
+	return t
+}
+
+// Advance moves the clock forward by d,
+// and fires the tickers the same way as time.Ticker,
+// dropping the ticks when the receiver falls behind.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	for _, t := range c.tickers {
+		t.fire(c.now)
+	}
+}
+
+type fakeTicker struct {
+	c chan time.Time
+	d time.Duration
+
+	mu      sync.Mutex
+	next    time.Time
+	stopped bool
+}
+
+func (t *fakeTicker) Chan() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTicker) Stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stopped = true
+}
+
+func (t *fakeTicker) fire(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for !t.stopped && !t.next.After(now) {
+		select {
+		case t.c <- t.next:
+		default:
+		}
+		t.next = t.next.Add(t.d)
+	}
+}
+
+// notifyWriter is an io.Writer sending a notification on every write.
+type notifyWriter struct {
+	writes chan string
+}
+
+func (w notifyWriter) Write(p []byte) (int, error) {
+	w.writes <- string(p)
+	return len(p), nil
+}
+
+func TestReporterJitter(t *testing.T) {
+	const (
+		interval = time.Minute
+		jitter   = time.Second * 10
+	)
+
+	for _, c := range []struct {
+		label string
+		every bool
+	}{
+		{
+			label: "first",
+		},
+		{
+			label: "every",
+			every: true,
+		},
+	} {
+		t.Run(c.label, func(t *testing.T) {
+			clk := newFakeClock()
+			w := notifyWriter{writes: make(chan string, 10)}
+			st := NewStatsd(context.Background(), StatsdConfig{
+				Writer:              w,
+				ReportingInterval:   interval,
+				ReportingJitter:     jitter,
+				JitterEveryInterval: c.every,
+				clock:               clk,
+			})
+			defer st.Close()
+
+			nextTicker := func(label string) *fakeTicker {
+				t.Helper()
+				select {
+				case tk := <-clk.created:
+					return tk
+				case <-time.After(time.Second * 5):
+					t.Fatalf("%s: The reporter did not create the ticker", label)
+					return nil
+				}
+			}
+			checkJittered := func(label string, tk *fakeTicker) {
+				t.Helper()
+				if tk.d < interval || tk.d >= interval+jitter {
+					t.Errorf(
+						"%s: Expected ticker interval in [%v, %v), got %v",
+						label,
+						interval,
+						interval+jitter,
+						tk.d,
+					)
+				}
+			}
+
+			tk := nextTicker("first")
+			checkJittered("first", tk)
+
+			for i := 0; i < 3; i++ {
+				st.Counter("counter").Add(1)
+				clk.Advance(tk.d)
+				select {
+				case <-w.writes:
+				case <-time.After(time.Second * 5):
+					t.Fatal("Expected a flush, got none")
+				}
+
+				if !c.every && i > 0 {
+					// The plain ticker is never replaced.
+					continue
+				}
+				tk = nextTicker("next")
+				if c.every {
+					checkJittered("next", tk)
+				} else if tk.d != interval {
+					t.Errorf("Expected ticker interval %v, got %v", interval, tk.d)
+				}
+			}
+		})
+	}
+}
+
+func TestReporterFakeClock(t *testing.T) {
+	const interval = time.Minute
+
+	clk := newFakeClock()
+	w := notifyWriter{writes: make(chan string, 10)}
+	st := NewStatsd(context.Background(), StatsdConfig{
+		Writer:            w,
+		ReportingInterval: interval,
+		clock:             clk,
+	})
+	defer st.Close()
+
+	select {
+	case tk := <-clk.created:
+		if tk.d != interval {
+			t.Errorf("Expected ticker interval %v, got %v", interval, tk.d)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("The reporter did not create the ticker")
+	}
+
+	waitWrite := func(label string) {
+		t.Helper()
+		select {
+		case <-w.writes:
+		case <-time.After(time.Second * 5):
+			t.Fatalf("%s: Expected a flush, got none", label)
+		}
+	}
+
+	const ticks = 3
+	for i := 0; i < ticks; i++ {
+		st.Counter("counter").Add(1)
+		clk.Advance(interval)
+		waitWrite("tick")
+	}
+
+	// Advancing less than the interval does not trigger a flush,
+	// so the only write left is the final flush from Close.
+	st.Counter("counter").Add(1)
+	clk.Advance(interval / 2)
+	if err := st.Close(); err != nil {
+		t.Fatal(err)
+	}
+	waitWrite("close")
+	if n := len(w.writes); n != 0 {
+		t.Errorf("Expected exactly one flush per tick, got %d extra", n)
+	}
+}
+
+func TestTriggerFlush(t *testing.T) {
+	newStatsd := func(t *testing.T) (*Statsd, notifyWriter) {
+		t.Helper()
+		clk := newFakeClock()
+		w := notifyWriter{writes: make(chan string, 10)}
+		st := NewStatsd(context.Background(), StatsdConfig{
+			Writer:            w,
+			ReportingInterval: time.Minute,
+			clock:             clk,
+		})
+		t.Cleanup(func() { st.Close() })
+		select {
+		case <-clk.created:
+		case <-time.After(time.Second * 5):
+			t.Fatal("The reporter did not create the ticker")
+		}
+		return st, w
+	}
+	waitWrite := func(t *testing.T, w notifyWriter, expected string) {
+		t.Helper()
+		select {
+		case got := <-w.writes:
+			if !strings.Contains(got, expected) {
+				t.Errorf("Expected %q in the flush, got %q", expected, got)
+			}
+		case <-time.After(time.Second * 5):
+			t.Fatal("Expected a flush, got none")
+		}
+	}
+
+	// The fake clock never advances in these tests,
+	// so all the flushes are from the triggers, Resume and Close.
+
+	t.Run("trigger", func(t *testing.T) {
+		st, w := newStatsd(t)
+		st.Counter("counter").Add(1)
+		st.TriggerFlush()
+		waitWrite(t, w, "counter:1.000000|c\n")
+	})
+
+	t.Run("resume", func(t *testing.T) {
+		st, w := newStatsd(t)
+		st.Pause()
+		st.Counter("counter").Add(1)
+		st.Resume()
+		waitWrite(t, w, "counter:1.000000|c\n")
+	})
+
+	t.Run("paused", func(t *testing.T) {
+		st, w := newStatsd(t)
+		st.Pause()
+		for i := 0; i < 3; i++ {
+			st.Counter("counter").Add(1)
+			st.TriggerFlush()
+		}
+		// Close waits for the background reporting goroutine to handle all the
+		// triggers and do the final flush, which still happens while paused.
+		if err := st.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if n := len(w.writes); n != 1 {
+			t.Fatalf("Expected no flush while paused other than the final one, got %d flushes", n)
+		}
+		waitWrite(t, w, "counter:3.000000|c\n")
+	})
+}
+
+func TestOnSendError(t *testing.T) {
+	clk := newFakeClock()
+	errs := make(chan error, 10)
+	st := NewStatsd(context.Background(), StatsdConfig{
+		Writer:            &failingWriter{fail: true},
+		ReportingInterval: time.Minute,
+		OnSendError: func(err error) {
+			errs <- err
+		},
+		clock: clk,
+	})
+	defer st.Close()
+
+	waitErr := func(label string) {
+		t.Helper()
+		select {
+		case <-errs:
+		case <-time.After(time.Second * 5):
+			t.Fatalf("%s: Expected OnSendError to be called, got none", label)
+		}
+	}
+
+	st.Counter("counter").Add(1)
+	st.TriggerFlush()
+	waitErr("first")
+
+	// Rate limited until the clock advances.
+	for i := 0; i < 3; i++ {
+		st.Counter("counter").Add(1)
+		st.TriggerFlush()
+		time.Sleep(time.Millisecond * 20)
+	}
+	if n := len(errs); n != 0 {
+		t.Errorf("Expected OnSendError to be rate limited, got %d extra calls", n)
+	}
+
+	clk.Advance(time.Minute)
+	waitErr("tick")
+}
+
+func TestHeartbeat(t *testing.T) {
+	const interval = time.Minute
+
+	clk := newFakeClock()
+	w := notifyWriter{writes: make(chan string, 10)}
+	st := NewStatsd(context.Background(), StatsdConfig{
+		Writer:            w,
+		ReportingInterval: interval,
+		Heartbeat:         true,
+		Tags: Tags{
+			"host": "foo",
+		},
+		clock: clk,
+	})
+	defer st.Close()
+
+	select {
+	case <-clk.created:
+	case <-time.After(time.Second * 5):
+		t.Fatal("The reporter did not create the ticker")
+	}
+
+	const expected = "baseplate.metricsbp.heartbeat,host=foo:1.000000|c\n"
+	// The heartbeat is in every write, even without any other metrics.
+	for i := 0; i < 3; i++ {
+		clk.Advance(interval)
+		select {
+		case write := <-w.writes:
+			if !strings.Contains(write, expected) {
+				t.Errorf("Expected %q in %q", expected, write)
+			}
+		case <-time.After(time.Second * 5):
+			t.Fatal("Expected a flush, got none")
+		}
+	}
+}
+
+// panicWriter is an io.Writer panicking on the first write,
+// and writing to w afterwards.
+type panicWriter struct {
+	w        notifyWriter
+	panicked int32
+}
+
+func (w *panicWriter) Write(p []byte) (int, error) {
+	if atomic.CompareAndSwapInt32(&w.panicked, 0, 1) {
+		panic("write panicked")
+	}
+	return w.w.Write(p)
+}
+
+func TestReporterPanic(t *testing.T) {
+	const interval = time.Minute
+
+	clk := newFakeClock()
+	w := &panicWriter{w: notifyWriter{writes: make(chan string, 10)}}
+	st := NewStatsd(context.Background(), StatsdConfig{
+		Writer:            w,
+		ReportingInterval: interval,
+		clock:             clk,
+	})
+	defer st.Close()
+
+	select {
+	case <-clk.created:
+	case <-time.After(time.Second * 5):
+		t.Fatal("The reporter did not create the ticker")
+	}
+	if !st.Running() {
+		t.Fatal("Expected the reporter to be running")
+	}
+
+	st.Counter("counter").Add(1)
+	clk.Advance(interval)
+	// The backoff ticker.
+	select {
+	case <-clk.created:
+	case <-time.After(time.Second * 5):
+		t.Fatal("The reporter did not back off after the panic")
+	}
+	if st.Running() {
+		t.Error("Expected the reporter to not be running during the backoff")
+	}
+
+	// Keep ticking until the restarted loop writes.
+	waitWrite := func() string {
+		deadline := time.After(time.Second * 5)
+		for {
+			clk.Advance(interval)
+			select {
+			case write := <-w.w.writes:
+				return write
+			case <-deadline:
+				t.Fatal("Expected a write after the restart, got none")
+			case <-time.After(time.Millisecond * 10):
+			}
+		}
+	}
+	write := waitWrite()
+	if !st.Running() {
+		t.Error("Expected the reporter to be running after the restart")
+	}
+	const expected = panicsCounter + ":1.000000|c\n"
+	if !strings.Contains(write, expected) {
+		t.Errorf("Expected %q in %q", expected, write)
+	}
+
+	t.Run("close-during-backoff", func(t *testing.T) {
+		clk := newFakeClock()
+		w := &panicWriter{w: notifyWriter{writes: make(chan string, 10)}}
+		st := NewStatsd(context.Background(), StatsdConfig{
+			Writer:            w,
+			ReportingInterval: interval,
+			clock:             clk,
+		})
+		<-clk.created
+		st.Counter("before.panic").Add(1)
+		clk.Advance(interval)
+		// The backoff ticker.
+		select {
+		case <-clk.created:
+		case <-time.After(time.Second * 5):
+			t.Fatal("The reporter did not back off after the panic")
+		}
+
+		st.Counter("after.panic").Add(1)
+		if err := st.Close(); err != nil {
+			t.Errorf("Expected no error from Close, got %v", err)
+		}
+		select {
+		case write := <-w.w.writes:
+			const expected = "after.panic:1.000000|c\n"
+			if !strings.Contains(write, expected) {
+				t.Errorf("Expected %q in the final flush, got %q", expected, write)
+			}
+		default:
+			t.Error("Expected the final flush after closing during the backoff")
+		}
+	})
+
+	t.Run("no-writer", func(t *testing.T) {
+		st := NewStatsd(context.Background(), StatsdConfig{})
+		if st.Running() {
+			t.Error("Expected no reporter without a writer")
+		}
+	})
+
+	t.Run("closed", func(t *testing.T) {
+		st := NewStatsd(context.Background(), StatsdConfig{
+			Writer:            notifyWriter{writes: make(chan string, 10)},
+			ReportingInterval: interval,
+			clock:             newFakeClock(),
+		})
+		if err := st.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if st.Running() {
+			t.Error("Expected the reporter to stop after Close")
+		}
+	})
+}
+
+func TestOnSendErrorFinal(t *testing.T) {
+	clk := newFakeClock()
+	errs := make(chan error, 10)
+	st := NewStatsd(context.Background(), StatsdConfig{
+		Writer:            &failingWriter{fail: true},
+		ReportingInterval: time.Minute,
+		OnSendError: func(err error) {
+			errs <- err
+		},
+		clock: clk,
+	})
+
+	st.Counter("counter").Add(1)
+	st.TriggerFlush()
+	select {
+	case <-errs:
+	case <-time.After(time.Second * 5):
+		t.Fatal("Expected OnSendError to be called, got none")
+	}
+
+	// The final flush happens within sendErrorInterval of the previous error,
+	// but it's not rate limited.
+	st.Counter("counter").Add(1)
+	if err := st.Close(); err == nil {
+		t.Fatal("Expected error from Close, got nil")
+	}
+	select {
+	case <-errs:
+	case <-time.After(time.Second * 5):
+		t.Fatal("Expected OnSendError to be called with the final error, got none")
+	}
+}
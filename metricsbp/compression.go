@@ -0,0 +1,96 @@
+package metricsbp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+// Compression is the compression of the payloads sent to the statsd collector,
+// see Compression in StatsdConfig.
+type Compression string
+
+// Supported Compression values.
+const (
+	// CompressionNone sends the payloads uncompressed.
+	CompressionNone Compression = "none"
+
+	// CompressionGzip compresses every payload as a complete gzip stream.
+	CompressionGzip Compression = "gzip"
+)
+
+// DefaultCompression is the Compression to be used when Compression in
+// StatsdConfig is empty.
+const DefaultCompression = CompressionNone
+
+// validateCompression returns an error if c is not a supported Compression.
+func validateCompression(c Compression) error {
+	switch c {
+	case "", CompressionNone, CompressionGzip:
+		return nil
+	default:
+		return fmt.Errorf("metricsbp: unsupported compression %q", c)
+	}
+}
+
+// isStreamNetwork returns whether network is a stream network,
+// for which Compression in StatsdConfig is supported.
+func isStreamNetwork(network string) bool {
+	switch network {
+	case "tcp", "tcp4", "tcp6", "unix":
+		return true
+	default:
+		return false
+	}
+}
+
+// gzipCompressor compresses the payloads with gzip,
+// reusing the buffer and the gzip.Writer between the payloads.
+//
+// It's not safe for concurrent use.
+type gzipCompressor struct {
+	buf bytes.Buffer
+	zw  *gzip.Writer
+}
+
+// compress returns p compressed as a complete gzip stream.
+//
+// The returned slice is only valid until the next compress call.
+func (c *gzipCompressor) compress(p []byte) ([]byte, error) {
+	c.buf.Reset()
+	if c.zw == nil {
+		c.zw = gzip.NewWriter(&c.buf)
+	} else {
+		c.zw.Reset(&c.buf)
+	}
+	if _, err := c.zw.Write(p); err != nil {
+		return nil, err
+	}
+	if err := c.zw.Close(); err != nil {
+		return nil, err
+	}
+	return c.buf.Bytes(), nil
+}
+
+// gzipWriter is an io.Writer writing every write to w as a complete gzip
+// stream,
+// so that the collector can decode the concatenated streams as a multistream.
+//
+// The underlying bufferedWriter serializes the writes,
+// so it's not safe for concurrent use.
+type gzipWriter struct {
+	w          io.Writer
+	compressor gzipCompressor
+}
+
+func (w *gzipWriter) Write(p []byte) (int, error) {
+	compressed, err := w.compressor.compress(p)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := w.w.Write(compressed); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
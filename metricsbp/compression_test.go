@@ -0,0 +1,98 @@
+package metricsbp_test
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/reddit/baseplate.go/metricsbp"
+)
+
+func TestCompressionHTTP(t *testing.T) {
+	bodies := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Content-Encoding"); got != "gzip" {
+			t.Errorf("Expected Content-Encoding gzip, got %q", got)
+		}
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		body, err := ioutil.ReadAll(zr)
+		if err != nil {
+			t.Error(err)
+		}
+		bodies <- string(body)
+	}))
+	defer server.Close()
+
+	st := metricsbp.NewStatsd(
+		context.Background(),
+		metricsbp.StatsdConfig{
+			HTTP: &metricsbp.HTTPConfig{
+				URL: server.URL,
+			},
+			Compression:       metricsbp.CompressionGzip,
+			ReportingInterval: time.Hour,
+		},
+	)
+	defer st.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	st.Counter("counter").Add(1)
+	if err := st.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	const expected = "counter:1.000000|c\n"
+	if got := <-bodies; got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
+func TestCompressionTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	st := metricsbp.NewStatsd(
+		ctx,
+		metricsbp.StatsdConfig{
+			Address:     ln.Addr().String(),
+			Network:     "tcp",
+			Compression: metricsbp.CompressionGzip,
+		},
+	)
+	st.Counter("foo").Add(1)
+	// Canceling the context triggers the final flush.
+	cancel()
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(time.Second * 5))
+	zr, err := gzip.NewReader(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line, err := bufio.NewReader(zr).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	const expected = "foo:1.000000|c\n"
+	if line != expected {
+		t.Errorf("Expected %q, got %q", expected, line)
+	}
+}
@@ -44,14 +44,14 @@ type Config struct {
 	RunSysStats bool `yaml:"runSysStats"`
 }
 
-// InitFromConfig initializes the global metricsbp.M with the given context and
-// Config and returns an io.Closer to use to close out the metrics client when
+// InitFromConfig initializes the global metricsbp.M (via SetM) with the given
+// context and Config and returns an io.Closer to use to close out the metrics client when
 // your server exits.
 //
 // It also registers CreateServerSpanHook and ConcurrencyCreateServerSpanHook
 // with the global tracing hook registry.
 func InitFromConfig(ctx context.Context, cfg Config) io.Closer {
-	M = NewStatsd(ctx, StatsdConfig{
+	st := NewStatsd(ctx, StatsdConfig{
 		CounterSampleRate:   cfg.CounterSampleRate,
 		HistogramSampleRate: cfg.HistogramSampleRate,
 		Prefix:              cfg.Namespace,
@@ -59,9 +59,10 @@ func InitFromConfig(ctx context.Context, cfg Config) io.Closer {
 		LogLevel:            log.ErrorLevel,
 		Tags:                cfg.Tags,
 	})
-	tracing.RegisterCreateServerSpanHooks(CreateServerSpanHook{Metrics: M})
+	SetM(st)
+	tracing.RegisterCreateServerSpanHooks(CreateServerSpanHook{Metrics: st})
 	if cfg.RunSysStats {
-		M.RunSysStats()
+		st.RunSysStats()
 	}
-	return M
+	return st
 }
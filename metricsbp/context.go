@@ -0,0 +1,39 @@
+package metricsbp
+
+import (
+	"context"
+)
+
+type statsdContextKeyType struct{}
+
+var statsdContextKey statsdContextKeyType
+
+// ContextWithStatsd returns a context derived from ctx with st attached,
+// to be extracted by StatsdFromContext.
+//
+// It's useful for the middlewares creating a request scoped Statsd,
+// for example via WithTags with the tags of the request,
+// so that the handlers can use it without threading it as a parameter:
+//
+//     func middleware(next Handler) Handler {
+//       return func(ctx context.Context, r *Request) {
+//         st := metricsbp.M.WithTags(metricsbp.Tags{"client": r.Client})
+//         next(metricsbp.ContextWithStatsd(ctx, st), r)
+//       }
+//     }
+//
+//     func handler(ctx context.Context, r *Request) {
+//       metricsbp.StatsdFromContext(ctx).Counter("handler.calls").Add(1)
+//     }
+func ContextWithStatsd(ctx context.Context, st *Statsd) context.Context {
+	return context.WithValue(ctx, statsdContextKey, st)
+}
+
+// StatsdFromContext extracts the Statsd attached to ctx by ContextWithStatsd,
+// and falls back to the global one (see GetM) if none is found.
+func StatsdFromContext(ctx context.Context) *Statsd {
+	if st, ok := ctx.Value(statsdContextKey).(*Statsd); ok && st != nil {
+		return st
+	}
+	return GetM()
+}
@@ -0,0 +1,25 @@
+package metricsbp_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/reddit/baseplate.go/metricsbp"
+)
+
+func TestStatsdFromContext(t *testing.T) {
+	if st := metricsbp.StatsdFromContext(context.Background()); st != metricsbp.GetM() {
+		t.Errorf("Expected the fallback to GetM() %p, got %p", metricsbp.GetM(), st)
+	}
+
+	st := metricsbp.NewStatsd(context.Background(), metricsbp.StatsdConfig{})
+	ctx := metricsbp.ContextWithStatsd(context.Background(), st)
+	if got := metricsbp.StatsdFromContext(ctx); got != st {
+		t.Errorf("Expected %p, got %p", st, got)
+	}
+
+	ctx = metricsbp.ContextWithStatsd(ctx, nil)
+	if got := metricsbp.StatsdFromContext(ctx); got != metricsbp.GetM() {
+		t.Errorf("Expected the fallback to GetM() %p for nil, got %p", metricsbp.GetM(), got)
+	}
+}
@@ -0,0 +1,197 @@
+package metricsbp
+
+import (
+	"io"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// The statsd types of the CountedHistogram lines.
+const (
+	countedHistogramType = "h"
+	countedTimingType    = "ms"
+)
+
+// CountedHistogram is a histogram metric observing pre-aggregated values,
+// each representing a number of samples.
+//
+// Please use Statsd.CountedHistogram or Statsd.CountedTiming to create it.
+type CountedHistogram struct {
+	name   string
+	typ    string
+	tags   []string
+	factor float64
+	space  *countedSpace
+	st     *Statsd
+
+	// emissions is the emission count when TrackEmissions is true.
+	emissions *int64
+}
+
+// CountedHistogram returns a histogram metric to the name with no specific
+// unit, to observe pre-aggregated values via Observe.
+//
+// For example, after aggregating 5 requests with an average payload size of
+// 1024 bytes in-process:
+//
+//     st.CountedHistogram("payload.size").Observe(1024, 5)
+//
+// is reported as a single line telling the statsd collector that the value
+// represents 5 samples, via the sample rate 1/5:
+//
+//     payload.size:1024.000000|h|@0.2
+//
+// so the rates and counts calculated by the collector are the same as
+// observing the value 5 times with a Histogram.
+//
+// As the sample rate in the line is used for the number of samples,
+// the values are never sampled, and the sample rates from StatsdConfig and
+// WithSampleRate are not used.
+//
+// Same as sets, the values are not reported to Provider or Prometheus in
+// StatsdConfig.
+func (st *Statsd) CountedHistogram(name string) CountedHistogram {
+	return st.fallback().newCountedHistogram(name, countedHistogramType, MetricTypeHistogram)
+}
+
+// CountedTiming returns a histogram metric to the name with milliseconds as the
+// unit, to observe pre-aggregated durations via Observe.
+//
+// For example, after observing 5 requests averaging 10ms in-process:
+//
+//     st.CountedTiming("request.latency").Observe(10, 5)
+//
+// The values are converted to TimingUnit in StatsdConfig the same way as
+// Timing.
+// Other than that it's the same as CountedHistogram.
+func (st *Statsd) CountedTiming(name string) CountedHistogram {
+	st = st.fallback()
+	h := st.newCountedHistogram(name, countedTimingType, MetricTypeTiming)
+	if h.space != nil && st.timingFactor != 1 {
+		h.tags = st.mergeTags(h.tags, []string{TimingUnitTagKey, string(st.timingUnit)})
+		h.factor = st.timingFactor
+	}
+	return h
+}
+
+func (st *Statsd) newCountedHistogram(name, typ, metricType string) CountedHistogram {
+	if st.discard {
+		return CountedHistogram{st: st}
+	}
+	name = st.scopedName(name)
+	return CountedHistogram{
+		name:      st.metricName(name),
+		typ:       typ,
+		tags:      st.tags,
+		factor:    1,
+		space:     st.counted,
+		st:        st,
+		emissions: st.emissionCount(name, metricType),
+	}
+}
+
+// With returns a CountedHistogram with the additional tags (as key-value
+// pairs).
+func (h CountedHistogram) With(tagValues ...string) CountedHistogram {
+	if len(tagValues)%2 != 0 {
+		panic("metricsbp: odd number of tagValues; programmer error!")
+	}
+	h.tags = h.st.mergeTags(h.tags, h.st.withTags(tagValues))
+	return h
+}
+
+// Observe observes value as n samples.
+//
+// It's a no-op when n is not positive.
+func (h CountedHistogram) Observe(value float64, n int) {
+	if h.space == nil || n <= 0 {
+		return
+	}
+	if h.emissions != nil {
+		atomic.AddInt64(h.emissions, 1)
+	}
+	obs := countedObservation{
+		name:  h.name,
+		typ:   h.typ,
+		tags:  h.tags,
+		value: value * h.factor,
+		n:     n,
+	}
+	h.space.add(obs)
+	for _, sink := range h.st.sinks {
+		sink.counted.add(obs)
+	}
+	h.st.retention.observe()
+}
+
+// countedObservation is a value observed by CountedHistogram.
+type countedObservation struct {
+	name  string
+	typ   string
+	tags  []string
+	value float64
+	n     int
+}
+
+// countedSpace holds all the values observed by CountedHistogram since last
+// write.
+type countedSpace struct {
+	prefix   string
+	provider provider
+
+	mu           sync.Mutex
+	observations []countedObservation
+}
+
+func newCountedSpace(prefix string, p provider) *countedSpace {
+	return &countedSpace{
+		prefix:   prefix,
+		provider: p,
+	}
+}
+
+func (cs *countedSpace) add(obs countedObservation) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.observations = append(cs.observations, obs)
+}
+
+// WriteTo writes all the observed values to w and resets the space.
+func (cs *countedSpace) WriteTo(w io.Writer) (count int64, err error) {
+	cs.mu.Lock()
+	all := cs.observations
+	cs.observations = nil
+	cs.mu.Unlock()
+
+	for _, obs := range all {
+		var n int
+		n, err = io.WriteString(w, cs.provider.countedLine(
+			cs.prefix+obs.name,
+			obs.tags,
+			obs.typ,
+			obs.value,
+			obs.n,
+		))
+		count += int64(n)
+		if err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
+
+// countedRate returns the sample rate part of the statsd line for a value
+// representing n samples, which is empty when n is 1.
+func countedRate(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "|@" + strconv.FormatFloat(1/float64(n), 'f', -1, 64)
+}
+
+// countedValue returns the value part of the statsd line,
+// formatted the same way as go-kit.
+func countedValue(value float64) string {
+	return strconv.FormatFloat(value, 'f', 6, 64)
+}
@@ -0,0 +1,88 @@
+package metricsbp_test
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/reddit/baseplate.go/metricsbp"
+)
+
+func TestCountedHistogram(t *testing.T) {
+	for _, c := range []struct {
+		label    string
+		cfg      metricsbp.StatsdConfig
+		expected []string
+	}{
+		{
+			label: "influx",
+			cfg: metricsbp.StatsdConfig{
+				Prefix: "prefix",
+				Tags: metricsbp.Tags{
+					"foo": "bar",
+				},
+			},
+			expected: []string{
+				"prefix.latency,foo=bar,key=value:10.000000|ms|@0.2",
+				"prefix.latency,foo=bar:5.000000|ms",
+				"prefix.size,foo=bar:1024.000000|h|@0.25",
+			},
+		},
+		{
+			label: "dogstatsd",
+			cfg: metricsbp.StatsdConfig{
+				Prefix: "prefix",
+				Format: metricsbp.FormatDogStatsd,
+				Tags: metricsbp.Tags{
+					"foo": "bar",
+				},
+			},
+			expected: []string{
+				"prefix.latency:10.000000|ms|@0.2|#foo:bar,key:value",
+				"prefix.latency:5.000000|ms|#foo:bar",
+				"prefix.size:1024.000000|h|@0.25|#foo:bar",
+			},
+		},
+		{
+			label: "timing-unit",
+			cfg: metricsbp.StatsdConfig{
+				TimingUnit: metricsbp.TimingUnitMicroseconds,
+			},
+			expected: []string{
+				"latency,unit=us,key=value:10000.000000|ms|@0.2",
+				"latency,unit=us:5000.000000|ms",
+				"size:1024.000000|h|@0.25",
+			},
+		},
+	} {
+		t.Run(c.label, func(t *testing.T) {
+			st := metricsbp.NewStatsd(context.Background(), c.cfg)
+			st.CountedTiming("latency").With("key", "value").Observe(10, 5)
+			st.CountedTiming("latency").Observe(5, 1)
+			st.CountedHistogram("size").Observe(1024, 4)
+			// Not positive counts are ignored.
+			st.CountedHistogram("size").Observe(1, 0)
+
+			var sb strings.Builder
+			if _, err := st.WriteTo(&sb); err != nil {
+				t.Fatal(err)
+			}
+			lines := strings.Split(strings.TrimSpace(sb.String()), "\n")
+			sort.Strings(lines)
+			if !reflect.DeepEqual(lines, c.expected) {
+				t.Errorf("Expected %q, got %q", c.expected, lines)
+			}
+
+			// The observations should be reset after WriteTo.
+			sb.Reset()
+			if _, err := st.WriteTo(&sb); err != nil {
+				t.Fatal(err)
+			}
+			if sb.Len() != 0 {
+				t.Errorf("Expected nothing written after reset, got %q", sb.String())
+			}
+		})
+	}
+}
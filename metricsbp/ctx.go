@@ -0,0 +1,140 @@
+package metricsbp
+
+import (
+	"context"
+
+	"github.com/go-kit/kit/metrics"
+	opentracing "github.com/opentracing/opentracing-go"
+
+	"github.com/reddit/baseplate.go/tracing"
+)
+
+// TraceIDTagKey is the tag key of the trace id attached to the histograms and
+// timings created via HistogramCtx and TimingCtx,
+// when TraceExemplars is true in StatsdConfig.
+const TraceIDTagKey = "trace_id"
+
+// CounterCtx returns a counter metrics to the name bound to ctx.
+//
+// It behaves exactly the same as Counter,
+// except that Add calls are cheaply skipped after ctx is canceled or its
+// deadline exceeded, for example:
+//
+//     func (h *myHandler) Handle(ctx context.Context) {
+//       // Not reported if the request already timed out.
+//       defer metricsbp.M.CounterCtx(ctx, "my.handler.done").Add(1)
+//       ...
+//     }
+func (st *Statsd) CounterCtx(ctx context.Context, name string) metrics.Counter {
+	st = st.fallback()
+	return ctxCounter{
+		Counter: st.Counter(name),
+		ctx:     ctx,
+		tags:    st.ctxTags,
+	}
+}
+
+// HistogramCtx returns a histogram metrics to the name with no specific unit
+// bound to ctx.
+//
+// It behaves exactly the same as Histogram,
+// except that Observe calls are cheaply skipped after ctx is canceled or its
+// deadline exceeded,
+// and the trace id is attached when TraceExemplars is true in StatsdConfig
+// (see withTraceID for more details).
+func (st *Statsd) HistogramCtx(ctx context.Context, name string) metrics.Histogram {
+	st = st.fallback()
+	return ctxHistogram{
+		Histogram: st.withTraceID(ctx, st.Histogram(name)),
+		ctx:       ctx,
+		tags:      st.ctxTags,
+	}
+}
+
+// TimingCtx returns a histogram metrics to the name with milliseconds as the
+// unit bound to ctx.
+//
+// It behaves exactly the same as Timing,
+// except that Observe calls are cheaply skipped after ctx is canceled or its
+// deadline exceeded,
+// and the trace id is attached when TraceExemplars is true in StatsdConfig
+// (see withTraceID for more details).
+func (st *Statsd) TimingCtx(ctx context.Context, name string) metrics.Histogram {
+	st = st.fallback()
+	return ctxHistogram{
+		Histogram: st.withTraceID(ctx, st.Timing(name)),
+		ctx:       ctx,
+		tags:      st.ctxTags,
+	}
+}
+
+// withTraceID attaches the trace id of the sampled baseplate span in ctx to h
+// as TraceIDTagKey, when TraceExemplars is true in StatsdConfig.
+//
+// h is returned as is when there's no sampled baseplate span in ctx.
+func (st *Statsd) withTraceID(ctx context.Context, h metrics.Histogram) metrics.Histogram {
+	if !st.cfg.TraceExemplars {
+		return h
+	}
+	span, ok := opentracing.SpanFromContext(ctx).(*tracing.Span)
+	if !ok || span == nil || !span.Sampled() || span.TraceID() == "" {
+		return h
+	}
+	return h.With(TraceIDTagKey, span.TraceID())
+}
+
+// ctxCounter is a metrics.Counter skipping Add calls after ctx is done,
+// and applying the tags resolved from ctx on every Add.
+type ctxCounter struct {
+	metrics.Counter
+
+	ctx  context.Context
+	tags []CtxTag
+}
+
+func (c ctxCounter) With(tagValues ...string) metrics.Counter {
+	return ctxCounter{
+		Counter: c.Counter.With(tagValues...),
+		ctx:     c.ctx,
+		tags:    c.tags,
+	}
+}
+
+func (c ctxCounter) Add(delta float64) {
+	if c.ctx.Err() != nil {
+		return
+	}
+	if len(c.tags) > 0 {
+		c.Counter.With(resolveCtxTags(c.ctx, c.tags)...).Add(delta)
+		return
+	}
+	c.Counter.Add(delta)
+}
+
+// ctxHistogram is a metrics.Histogram skipping Observe calls after ctx is
+// done, and applying the tags resolved from ctx on every Observe.
+type ctxHistogram struct {
+	metrics.Histogram
+
+	ctx  context.Context
+	tags []CtxTag
+}
+
+func (h ctxHistogram) With(tagValues ...string) metrics.Histogram {
+	return ctxHistogram{
+		Histogram: h.Histogram.With(tagValues...),
+		ctx:       h.ctx,
+		tags:      h.tags,
+	}
+}
+
+func (h ctxHistogram) Observe(value float64) {
+	if h.ctx.Err() != nil {
+		return
+	}
+	if len(h.tags) > 0 {
+		h.Histogram.With(resolveCtxTags(h.ctx, h.tags)...).Observe(value)
+		return
+	}
+	h.Histogram.Observe(value)
+}
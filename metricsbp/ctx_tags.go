@@ -0,0 +1,69 @@
+package metricsbp
+
+import (
+	"context"
+)
+
+// CtxTag is a tag resolved from the context of the metrics created via
+// CounterCtx, HistogramCtx and TimingCtx, see WithCtxTags.
+//
+// It returns the key and value of the tag,
+// or the empty key to skip the tag for the context.
+type CtxTag func(ctx context.Context) (key, value string)
+
+// WithCtxTags returns a Statsd derived from st,
+// with the additional tags resolved from the context every time a metric
+// created via CounterCtx, HistogramCtx or TimingCtx is added to or observed.
+//
+// It's useful for the tags derived from the request context,
+// for example the tenant of the request,
+// without creating a new Statsd or metric per tenant:
+//
+//     var tenantTag metricsbp.CtxTag = func(ctx context.Context) (string, string) {
+//       tenant, ok := tenantFromContext(ctx)
+//       if !ok {
+//         return "", ""
+//       }
+//       return "tenant", tenant
+//     }
+//
+//     var stWithTenant = metricsbp.M.WithCtxTags(tenantTag)
+//
+//     func (h *myHandler) Handle(ctx context.Context) {
+//       defer stWithTenant.CounterCtx(ctx, "my.handler.done").Add(1)
+//       ...
+//     }
+//
+// The tags are sanitized the same way as the ones passed into With,
+// and they override the tags with the same keys from With, WithTags and the
+// Tags in StatsdConfig.
+// The metrics created without a context (for example via Counter) are not
+// affected.
+//
+// The derived Statsd shares everything else with st, the same as WithTags,
+// and st is not modified.
+func (st *Statsd) WithCtxTags(tags ...CtxTag) *Statsd {
+	st = st.fallback()
+	if len(tags) == 0 {
+		return st
+	}
+
+	derived := *st
+	derived.ctxTags = make([]CtxTag, 0, len(st.ctxTags)+len(tags))
+	derived.ctxTags = append(derived.ctxTags, st.ctxTags...)
+	derived.ctxTags = append(derived.ctxTags, tags...)
+	return &derived
+}
+
+// resolveCtxTags returns the key-value pairs of tags resolved from ctx.
+func resolveCtxTags(ctx context.Context, tags []CtxTag) []string {
+	tagValues := make([]string, 0, 2*len(tags))
+	for _, tag := range tags {
+		key, value := tag(ctx)
+		if key == "" {
+			continue
+		}
+		tagValues = append(tagValues, key, value)
+	}
+	return tagValues
+}
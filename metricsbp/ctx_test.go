@@ -0,0 +1,185 @@
+package metricsbp_test
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/reddit/baseplate.go/metricsbp"
+	"github.com/reddit/baseplate.go/tracing"
+)
+
+func TestCtxMetrics(t *testing.T) {
+	st := metricsbp.NewStatsd(
+		context.Background(),
+		metricsbp.StatsdConfig{},
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	counter := st.CounterCtx(ctx, "counter").With("key", "value")
+	histogram := st.HistogramCtx(ctx, "histogram")
+	timing := st.TimingCtx(ctx, "timing")
+	counter.Add(1)
+	histogram.Observe(1)
+	timing.With("key", "value").Observe(1)
+
+	cancel()
+	counter.Add(1)
+	histogram.Observe(2)
+	timing.Observe(2)
+	st.CounterCtx(ctx, "canceled").Add(1)
+
+	var sb strings.Builder
+	if _, err := st.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(sb.String()), "\n")
+	sort.Strings(lines)
+	expected := []string{
+		"counter,key=value:1.000000|c",
+		"histogram:1.000000|h",
+		"timing,key=value:1.000000|ms",
+	}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Expected %q, got %q", expected, lines)
+	}
+}
+
+func TestTraceExemplars(t *testing.T) {
+	sampled := true
+	notSampled := false
+	sampledCtx, _ := tracing.StartSpanFromHeaders(
+		context.Background(),
+		"sampled",
+		tracing.Headers{
+			TraceID: "12345",
+			Sampled: &sampled,
+		},
+	)
+	notSampledCtx, _ := tracing.StartSpanFromHeaders(
+		context.Background(),
+		"not-sampled",
+		tracing.Headers{
+			TraceID: "54321",
+			Sampled: &notSampled,
+		},
+	)
+
+	for _, c := range []struct {
+		label     string
+		exemplars bool
+		ctx       context.Context
+		expected  []string
+	}{
+		{
+			label:     "sampled",
+			exemplars: true,
+			ctx:       sampledCtx,
+			expected: []string{
+				"histogram,trace_id=12345:1.000000|h",
+				"timing,trace_id=12345,key=value:1.000000|ms",
+			},
+		},
+		{
+			label:     "disabled",
+			exemplars: false,
+			ctx:       sampledCtx,
+			expected: []string{
+				"histogram:1.000000|h",
+				"timing,key=value:1.000000|ms",
+			},
+		},
+		{
+			label:     "not-sampled",
+			exemplars: true,
+			ctx:       notSampledCtx,
+			expected: []string{
+				"histogram:1.000000|h",
+				"timing,key=value:1.000000|ms",
+			},
+		},
+		{
+			label:     "no-span",
+			exemplars: true,
+			ctx:       context.Background(),
+			expected: []string{
+				"histogram:1.000000|h",
+				"timing,key=value:1.000000|ms",
+			},
+		},
+	} {
+		t.Run(c.label, func(t *testing.T) {
+			st := metricsbp.NewStatsd(
+				context.Background(),
+				metricsbp.StatsdConfig{
+					TraceExemplars: c.exemplars,
+				},
+			)
+			st.HistogramCtx(c.ctx, "histogram").Observe(1)
+			st.TimingCtx(c.ctx, "timing").With("key", "value").Observe(1)
+
+			var sb strings.Builder
+			if _, err := st.WriteTo(&sb); err != nil {
+				t.Fatal(err)
+			}
+			lines := strings.Split(strings.TrimSpace(sb.String()), "\n")
+			sort.Strings(lines)
+			if !reflect.DeepEqual(lines, c.expected) {
+				t.Errorf("Expected %q, got %q", c.expected, lines)
+			}
+		})
+	}
+}
+
+type tenantKey struct{}
+
+func TestCtxTags(t *testing.T) {
+	st := metricsbp.NewStatsd(
+		context.Background(),
+		metricsbp.StatsdConfig{
+			Tags: metricsbp.Tags{
+				"foo": "bar",
+			},
+		},
+	)
+	derived := st.WithCtxTags(func(ctx context.Context) (string, string) {
+		tenant, ok := ctx.Value(tenantKey{}).(string)
+		if !ok {
+			return "", ""
+		}
+		return "tenant", tenant
+	})
+
+	ctx := context.Background()
+	ctxA := context.WithValue(ctx, tenantKey{}, "a")
+	ctxB := context.WithValue(ctx, tenantKey{}, "b")
+	derived.CounterCtx(ctxA, "counter").Add(1)
+	derived.CounterCtx(ctxA, "counter").With("key", "value").Add(1)
+	derived.CounterCtx(ctxB, "counter").Add(1)
+	derived.CounterCtx(ctx, "counter").Add(1)
+	derived.HistogramCtx(ctxB, "histogram").With("tenant", "overridden").Observe(1)
+	derived.TimingCtx(ctxA, "timing").Observe(1)
+	// Not affected.
+	st.CounterCtx(ctxA, "counter").Add(1)
+	derived.Counter("counter").Add(1)
+
+	var sb strings.Builder
+	if _, err := st.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(sb.String()), "\n")
+	sort.Strings(lines)
+	expected := []string{
+		"counter,foo=bar,key=value,tenant=a:1.000000|c",
+		"counter,foo=bar,tenant=a:1.000000|c",
+		"counter,foo=bar,tenant=b:1.000000|c",
+		"counter,foo=bar:3.000000|c",
+		"histogram,foo=bar,tenant=b:1.000000|h",
+		"timing,foo=bar,tenant=a:1.000000|ms",
+	}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Expected %q, got %q", expected, lines)
+	}
+}
@@ -0,0 +1,126 @@
+package metricsbp
+
+import (
+	"io"
+	"sync"
+
+	"github.com/go-kit/kit/metrics"
+)
+
+// Provider is a go-kit style metrics provider,
+// to be set as the Provider in StatsdConfig.
+//
+// It has the same methods as Provider in go-kit's metrics/provider package,
+// so all the implementations of that interface can be used directly.
+//
+// It's also the extension point to send the metrics through an OpenTelemetry
+// MeterProvider (for example with the OTLP exporter) instead of statsd,
+// without changing any instrumentation code,
+// by implementing it on top of an OpenTelemetry Meter:
+//
+// - NewCounter returns a metrics.Counter adding to a Float64Counter
+// (go-kit counters are float64, so Int64Counter only fits integral deltas);
+//
+// - NewGauge returns a metrics.Gauge keeping the last value per tag
+// combination, reported by the callback of a Float64ObservableGauge;
+//
+// - NewHistogram returns a metrics.Histogram recording to a Float64Histogram,
+// the buckets are configured by the views of the MeterProvider instead;
+//
+// - the tags passed into With (including the Tags in StatsdConfig) are the
+// key-value pairs to be converted into the attributes;
+//
+// - Stop calls Shutdown of the MeterProvider.
+//
+// The separate module github.com/reddit/baseplate.go/metricsbp/otelprovider
+// provides such an implementation,
+// without adding the OpenTelemetry dependencies to this module.
+//
+// The OTLP endpoint and the export interval are configured on the
+// MeterProvider and its reader,
+// and ReportingInterval is only used to run the callbacks of GaugeFunc,
+// CounterFunc, Meter and Summary.
+type Provider interface {
+	NewCounter(name string) metrics.Counter
+	NewGauge(name string) metrics.Gauge
+	NewHistogram(name string, buckets int) metrics.Histogram
+	Stop()
+}
+
+// CustomProviderHistogramBuckets is the number of buckets passed into
+// NewHistogram of the Provider in StatsdConfig.
+const CustomProviderHistogramBuckets = 50
+
+// customProvider adapts a go-kit metrics provider set as the Provider in
+// StatsdConfig to the provider used by Statsd.
+type customProvider struct {
+	provider Provider
+	prefix   string
+
+	stopOnce sync.Once
+}
+
+func (p *customProvider) NewCounter(name string, sampleRate float64) metrics.Counter {
+	counter := p.provider.NewCounter(p.prefix + name)
+	if sampleRate >= 1 {
+		return counter
+	}
+	return scaledCounter{
+		Counter: counter,
+		rate:    sampleRate,
+	}
+}
+
+func (p *customProvider) NewGauge(name string) metrics.Gauge {
+	return p.provider.NewGauge(p.prefix + name)
+}
+
+func (p *customProvider) NewTiming(name string, sampleRate float64) metrics.Histogram {
+	return p.provider.NewHistogram(p.prefix+name, CustomProviderHistogramBuckets)
+}
+
+func (p *customProvider) NewHistogram(name string, sampleRate float64) metrics.Histogram {
+	return p.provider.NewHistogram(p.prefix+name, CustomProviderHistogramBuckets)
+}
+
+// WriteTo writes nothing, as the custom provider reports the metrics on its
+// own.
+func (p *customProvider) WriteTo(w io.Writer) (int64, error) {
+	return 0, nil
+}
+
+// setLine returns the empty string, as sets are not supported by custom
+// providers.
+func (p *customProvider) setLine(name string, tags []string, value string) string {
+	return ""
+}
+
+// countedLine returns the empty string, as CountedHistogram is not supported
+// by custom providers.
+func (p *customProvider) countedLine(name string, tags []string, typ string, value float64, n int) string {
+	return ""
+}
+
+// stop calls Stop of the custom provider, only the first time it's called.
+func (p *customProvider) stop() {
+	p.stopOnce.Do(p.provider.Stop)
+}
+
+// scaledCounter is a metrics.Counter scaling the deltas back by the sample
+// rate, the same way statsd collectors do for sampled counters.
+type scaledCounter struct {
+	metrics.Counter
+
+	rate float64
+}
+
+func (c scaledCounter) With(tagValues ...string) metrics.Counter {
+	return scaledCounter{
+		Counter: c.Counter.With(tagValues...),
+		rate:    c.rate,
+	}
+}
+
+func (c scaledCounter) Add(delta float64) {
+	c.Counter.Add(delta / c.rate)
+}
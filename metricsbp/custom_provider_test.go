@@ -0,0 +1,168 @@
+package metricsbp_test
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+
+	"github.com/reddit/baseplate.go/metricsbp"
+)
+
+// fakeProvider is a metricsbp.Provider recording the last value of every
+// metric, keyed by the name and the tags joined by commas.
+type fakeProvider struct {
+	mu      sync.Mutex
+	values  map[string]float64
+	stopped int
+}
+
+func (p *fakeProvider) record(name string, lvs []string, f func(float64) float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	key := strings.Join(append([]string{name}, lvs...), ",")
+	p.values[key] = f(p.values[key])
+}
+
+func (p *fakeProvider) NewCounter(name string) metrics.Counter {
+	return fakeCounter{fakeMetric{p: p, name: name}}
+}
+
+func (p *fakeProvider) NewGauge(name string) metrics.Gauge {
+	return fakeGauge{fakeMetric{p: p, name: name}}
+}
+
+func (p *fakeProvider) NewHistogram(name string, buckets int) metrics.Histogram {
+	return fakeHistogram{fakeMetric{p: p, name: name}}
+}
+
+func (p *fakeProvider) Stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stopped++
+}
+
+type fakeMetric struct {
+	p    *fakeProvider
+	name string
+	lvs  []string
+}
+
+func (m fakeMetric) with(lvs []string) fakeMetric {
+	m.lvs = append(m.lvs[:len(m.lvs):len(m.lvs)], lvs...)
+	return m
+}
+
+func (m fakeMetric) Add(delta float64) {
+	m.p.record(m.name, m.lvs, func(v float64) float64 { return v + delta })
+}
+
+func (m fakeMetric) Set(value float64) {
+	m.p.record(m.name, m.lvs, func(float64) float64 { return value })
+}
+
+func (m fakeMetric) Observe(value float64) {
+	m.Set(value)
+}
+
+type fakeCounter struct{ fakeMetric }
+
+func (c fakeCounter) With(lvs ...string) metrics.Counter {
+	return fakeCounter{c.with(lvs)}
+}
+
+type fakeGauge struct{ fakeMetric }
+
+func (g fakeGauge) With(lvs ...string) metrics.Gauge {
+	return fakeGauge{g.with(lvs)}
+}
+
+type fakeHistogram struct{ fakeMetric }
+
+func (h fakeHistogram) With(lvs ...string) metrics.Histogram {
+	return fakeHistogram{h.with(lvs)}
+}
+
+func TestCustomProvider(t *testing.T) {
+	p := &fakeProvider{values: make(map[string]float64)}
+	st := metricsbp.NewStatsd(
+		context.Background(),
+		metricsbp.StatsdConfig{
+			Prefix:   "svc",
+			Provider: p,
+			Tags: metricsbp.Tags{
+				"foo": "bar",
+			},
+		},
+	)
+
+	st.Counter("counter").With("key", "value").Add(1)
+	sampledAt := 0.5
+	st.CounterWithRate(metricsbp.RateArgs{
+		Name:             "sampled",
+		Rate:             1,
+		AlreadySampledAt: &sampledAt,
+	}).Add(1)
+	st.Gauge("gauge").Set(2)
+	st.Histogram("histogram").Observe(3)
+	st.Timing("timing").Observe(4)
+	st.Set("set").Add("a")
+
+	if err := st.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]float64{
+		"svc.counter,foo,bar,key,value": 1,
+		"svc.sampled,foo,bar":           2,
+		"svc.gauge,foo,bar":             2,
+		"svc.histogram,foo,bar":         3,
+		"svc.timing,foo,bar":            4,
+	}
+	if !reflect.DeepEqual(p.values, expected) {
+		t.Errorf("Expected %v, got %v", expected, p.values)
+	}
+	if p.stopped != 1 {
+		t.Errorf("Expected Stop to be called once, got %d", p.stopped)
+	}
+}
+
+func TestCustomProviderTickFuncs(t *testing.T) {
+	p := &fakeProvider{values: make(map[string]float64)}
+	st := metricsbp.NewStatsd(
+		context.Background(),
+		metricsbp.StatsdConfig{
+			Provider:          p,
+			ReportingInterval: time.Millisecond,
+		},
+	)
+	st.GaugeFunc("gauge", func() float64 {
+		return 1
+	})
+
+	// The callbacks run without any writer configured.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		p.mu.Lock()
+		_, ok := p.values["gauge"]
+		p.mu.Unlock()
+		if ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the GaugeFunc to be reported without a writer")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := st.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,78 @@
+package metricsbp
+
+import (
+	"os"
+
+	"github.com/reddit/baseplate.go/log"
+)
+
+// DefaultHostnameTagKey is the tag key to be used for the hostname when
+// AddHostnameTag is true and HostnameTagKey is empty in StatsdConfig.
+const DefaultHostnameTagKey = "hostname"
+
+// hostname is os.Hostname, overridable in tests.
+var hostname = os.Hostname
+
+// TagsFromEnv returns the tags with the values read from environment
+// variables.
+//
+// envs maps the tag keys to the names of the environment variables,
+// for example:
+//
+//     metricsbp.TagsFromEnv(map[string]string{
+//       "pod":       "POD_NAME",
+//       "namespace": "POD_NAMESPACE",
+//     })
+//
+// Environment variables not set are skipped and logged at debug level.
+func TagsFromEnv(envs map[string]string) Tags {
+	tags := make(Tags, len(envs))
+	for key, env := range envs {
+		if value, ok := os.LookupEnv(env); ok {
+			tags[key] = value
+		} else {
+			log.KitLogger(log.DebugLevel).Log(
+				"during", "metricsbp.TagsFromEnv",
+				"msg", "environment variable not set, skipping the tag",
+				"env", env,
+				"tag", key,
+			)
+		}
+	}
+	return tags
+}
+
+// defaultTags returns the tags to be attached to all the metrics created from
+// a Statsd with the cfg,
+// which are Tags in cfg with the automatic tags added.
+//
+// The precedence for the same keys is Tags, EnvTags, then the hostname tag.
+func defaultTags(cfg StatsdConfig) Tags {
+	if !cfg.AddHostnameTag && len(cfg.EnvTags) == 0 {
+		return cfg.Tags
+	}
+
+	tags := make(Tags, len(cfg.Tags)+len(cfg.EnvTags)+1)
+	if cfg.AddHostnameTag {
+		key := cfg.HostnameTagKey
+		if key == "" {
+			key = DefaultHostnameTagKey
+		}
+		if name, err := hostname(); err != nil {
+			log.KitLogger(log.DebugLevel).Log(
+				"during", "metricsbp.AddHostnameTag",
+				"msg", "failed to get hostname, skipping the tag",
+				"err", err,
+			)
+		} else {
+			tags[key] = name
+		}
+	}
+	for k, v := range TagsFromEnv(cfg.EnvTags) {
+		tags[k] = v
+	}
+	for k, v := range cfg.Tags {
+		tags[k] = v
+	}
+	return tags
+}
@@ -0,0 +1,122 @@
+package metricsbp
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestDefaultTagsHostname(t *testing.T) {
+	defer func() {
+		hostname = os.Hostname
+	}()
+
+	for _, c := range []struct {
+		label    string
+		cfg      StatsdConfig
+		hostname func() (string, error)
+		expected Tags
+	}{
+		{
+			label: "disabled",
+			cfg: StatsdConfig{
+				Tags: Tags{"foo": "bar"},
+			},
+			hostname: func() (string, error) {
+				return "host", nil
+			},
+			expected: Tags{"foo": "bar"},
+		},
+		{
+			label: "default-key",
+			cfg: StatsdConfig{
+				Tags:           Tags{"foo": "bar"},
+				AddHostnameTag: true,
+			},
+			hostname: func() (string, error) {
+				return "host", nil
+			},
+			expected: Tags{"foo": "bar", "hostname": "host"},
+		},
+		{
+			label: "custom-key",
+			cfg: StatsdConfig{
+				AddHostnameTag: true,
+				HostnameTagKey: "pod",
+			},
+			hostname: func() (string, error) {
+				return "host", nil
+			},
+			expected: Tags{"pod": "host"},
+		},
+		{
+			label: "tags-precedence",
+			cfg: StatsdConfig{
+				Tags:           Tags{"hostname": "explicit"},
+				AddHostnameTag: true,
+			},
+			hostname: func() (string, error) {
+				return "host", nil
+			},
+			expected: Tags{"hostname": "explicit"},
+		},
+		{
+			label: "error",
+			cfg: StatsdConfig{
+				Tags:           Tags{"foo": "bar"},
+				AddHostnameTag: true,
+			},
+			hostname: func() (string, error) {
+				return "", errors.New("failed")
+			},
+			expected: Tags{"foo": "bar"},
+		},
+	} {
+		t.Run(c.label, func(t *testing.T) {
+			hostname = c.hostname
+			if got := defaultTags(c.cfg); !reflect.DeepEqual(got, c.expected) {
+				t.Errorf("Expected %v, got %v", c.expected, got)
+			}
+		})
+	}
+}
+
+func TestDefaultTagsEnv(t *testing.T) {
+	const (
+		env        = "METRICSBP_TEST_POD"
+		missingEnv = "METRICSBP_TEST_MISSING"
+	)
+	os.Setenv(env, "pod-1")
+	defer os.Unsetenv(env)
+	os.Unsetenv(missingEnv)
+	defer func() {
+		hostname = os.Hostname
+	}()
+	hostname = func() (string, error) {
+		return "host", nil
+	}
+
+	got := defaultTags(StatsdConfig{
+		Tags: Tags{
+			"foo":  "bar",
+			"pod2": "explicit",
+		},
+		EnvTags: map[string]string{
+			"pod":      env,
+			"pod2":     env,
+			"hostname": env,
+			"missing":  missingEnv,
+		},
+		AddHostnameTag: true,
+	})
+	expected := Tags{
+		"foo":      "bar",
+		"pod":      "pod-1",
+		"pod2":     "explicit",
+		"hostname": "pod-1",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
@@ -0,0 +1,23 @@
+package metricsbp
+
+import (
+	"github.com/go-kit/kit/metrics/discard"
+)
+
+// The shared no-op metrics returned by a Statsd with DiscardUnreported in
+// StatsdConfig.
+var (
+	discardCounter   = discard.NewCounter()
+	discardGauge     = discard.NewGauge()
+	discardHistogram = discard.NewHistogram()
+)
+
+// shouldDiscard returns whether the metrics of the Statsd created from cfg are
+// never reported anywhere and can be discarded, according to
+// DiscardUnreported in cfg.
+func shouldDiscard(cfg StatsdConfig) bool {
+	return cfg.DiscardUnreported &&
+		!reportsToWriter(cfg) &&
+		cfg.Provider == nil &&
+		cfg.Prometheus == nil
+}
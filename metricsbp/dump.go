@@ -0,0 +1,221 @@
+package metricsbp
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-kit/kit/log"
+)
+
+// MaxDumpedLines is the max number of lines kept by Dump for the next WriteTo.
+//
+// The lines of the counters, gauges and sets are merged per series,
+// so it only bounds the number of series of them,
+// while every histogram and timing observation is a separate line.
+// Once it's reached, the lines of the new series and observations are
+// discarded.
+const MaxDumpedLines = 10000
+
+// Dump synchronously writes the current state of all the metrics accumulated in
+// memory by st to w, in the statsd line format, without sending them anywhere.
+//
+// It's meant for debug handlers and manual inspection, for example:
+//
+//     http.HandleFunc("/debug/metrics", func(w http.ResponseWriter, r *http.Request) {
+//       if err := metricsbp.GetM().Dump(w); err != nil {
+//         log.Errorw("Failed to dump metrics", "err", err)
+//       }
+//     })
+//
+// Unlike WriteTo, it doesn't reset anything:
+// the lines written by Dump are kept and written again
+// by the next WriteTo (and so the next report to the statsd collector),
+// so calling Dump doesn't change what's eventually reported.
+// The following Dump calls before that also include them,
+// merged with the new ones per series:
+// the counters are summed, the gauges keep the last value,
+// and the sets are deduplicated.
+// At most MaxDumpedLines lines are kept.
+//
+// Before writing, it calls the callbacks registered via GaugeFunc,
+// unless the context passed into NewStatsd is already canceled.
+// The sinks are not included.
+func (st *Statsd) Dump(w io.Writer) error {
+	st = st.fallback()
+	if st.ctx.Err() == nil {
+		st.onTick.run()
+	}
+
+	d := &st.shared.dumped
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var buf bytes.Buffer
+	if _, err := st.writeMetrics(&buf); err != nil {
+		return err
+	}
+	d.merge(&buf, st.logger)
+	_, err := d.writeTo(w)
+	return err
+}
+
+// dumpedLines are the lines drained from the metrics by Dump,
+// to be written again by the next WriteTo.
+type dumpedLines struct {
+	mu     sync.Mutex
+	lines  []dumpedLine
+	series map[string]int // series key -> index of lines
+	logged bool
+}
+
+// dumpedLine is a statsd line split into the name (with influx tags),
+// the value, and the rest (type, sample rate and dogstatsd tags).
+//
+// The lines failed to be split are kept as-is in raw.
+type dumpedLine struct {
+	name   string
+	value  string
+	suffix string
+	raw    string
+}
+
+func (l dumpedLine) String() string {
+	if l.raw != "" {
+		return l.raw
+	}
+	return l.name + ":" + l.value + l.suffix
+}
+
+// splitDumpedLine splits a statsd line into a dumpedLine.
+//
+// It returns false if line is not a valid statsd line.
+func splitDumpedLine(line string) (dumpedLine, bool) {
+	i := strings.IndexByte(line, ':')
+	if i < 0 {
+		return dumpedLine{}, false
+	}
+	j := strings.IndexByte(line[i+1:], '|')
+	if j < 0 {
+		return dumpedLine{}, false
+	}
+	j += i + 1
+	return dumpedLine{
+		name:   line[:i],
+		value:  line[i+1 : j],
+		suffix: line[j:],
+	}, true
+}
+
+// typ returns the metric type of the line, for example "c" or "ms".
+func (l dumpedLine) typ() string {
+	typ := strings.TrimPrefix(l.suffix, "|")
+	if i := strings.IndexByte(typ, '|'); i >= 0 {
+		typ = typ[:i]
+	}
+	return typ
+}
+
+// merge merges the lines in buf into d.
+//
+// It must be called with d.mu held.
+func (d *dumpedLines) merge(buf *bytes.Buffer, logger log.Logger) {
+	if d.series == nil {
+		d.series = make(map[string]int)
+	}
+	scanner := bufio.NewScanner(buf)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		l, ok := splitDumpedLine(line)
+		var key string
+		if ok {
+			switch l.typ() {
+			case "c", "g":
+				key = l.name + l.suffix
+			case "s":
+				key = line
+			}
+		}
+		if key != "" {
+			if i, ok := d.series[key]; ok {
+				d.lines[i] = mergeDumpedLine(d.lines[i], l)
+				continue
+			}
+		}
+		if len(d.lines) >= MaxDumpedLines {
+			if !d.logged {
+				d.logged = true
+				logger.Log(
+					"during", "metricsbp.Dump",
+					"msg", "reached MaxDumpedLines, further lines will be discarded until the next write",
+					"max", MaxDumpedLines,
+				)
+			}
+			continue
+		}
+		if !ok {
+			l = dumpedLine{raw: line}
+		}
+		if key != "" {
+			d.series[key] = len(d.lines)
+		}
+		d.lines = append(d.lines, l)
+	}
+}
+
+// mergeDumpedLine returns the result of merging l into prev of the same series.
+func mergeDumpedLine(prev, l dumpedLine) dumpedLine {
+	if l.typ() != "c" {
+		// The last value of gauges, or the same line of sets.
+		return l
+	}
+	a, errA := strconv.ParseFloat(prev.value, 64)
+	b, errB := strconv.ParseFloat(l.value, 64)
+	if errA != nil || errB != nil {
+		return l
+	}
+	l.value = strconv.FormatFloat(a+b, 'f', 6, 64)
+	return l
+}
+
+// writeTo writes all the dumped lines to w.
+//
+// It must be called with d.mu held.
+func (d *dumpedLines) writeTo(w io.Writer) (int64, error) {
+	if len(d.lines) == 0 {
+		return 0, nil
+	}
+	var buf bytes.Buffer
+	for _, l := range d.lines {
+		buf.WriteString(l.String())
+		buf.WriteByte('\n')
+	}
+	return buf.WriteTo(w)
+}
+
+// WriteTo writes all the dumped lines to w and resets them.
+func (d *dumpedLines) WriteTo(w io.Writer) (int64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	n, err := d.writeTo(w)
+	d.resetLocked()
+	return n, err
+}
+
+// reset discards all the dumped lines.
+func (d *dumpedLines) reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.resetLocked()
+}
+
+func (d *dumpedLines) resetLocked() {
+	d.lines = nil
+	d.series = nil
+	d.logged = false
+}
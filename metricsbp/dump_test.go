@@ -0,0 +1,112 @@
+package metricsbp_test
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/reddit/baseplate.go/metricsbp"
+)
+
+func TestDump(t *testing.T) {
+	st := metricsbp.NewStatsd(context.Background(), metricsbp.StatsdConfig{})
+	st.Counter("counter").Add(1)
+	st.Gauge("gauge").Set(2)
+
+	var dump strings.Builder
+	if err := st.Dump(&dump); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"counter:1.000000|c\n",
+		"gauge:2.000000|g\n",
+	} {
+		if !strings.Contains(dump.String(), expected) {
+			t.Errorf("Expected %q in the dump, got %q", expected, dump.String())
+		}
+	}
+
+	// Following dumps still include the previously dumped lines,
+	// merged per series.
+	st.Counter("counter").Add(2)
+	st.Gauge("gauge").Set(3)
+	st.Timing("timing").Observe(1)
+	st.Set("set").Add("foo")
+	dump.Reset()
+	if err := st.Dump(&dump); err != nil {
+		t.Fatal(err)
+	}
+	st.Timing("timing").Observe(2)
+	st.Set("set").Add("foo")
+	dump.Reset()
+	if err := st.Dump(&dump); err != nil {
+		t.Fatal(err)
+	}
+	const expected = "counter:3.000000|c\n" +
+		"gauge:3.000000|g\n" +
+		"timing:1.000000|ms\n" +
+		"set:foo|s\n" +
+		"timing:2.000000|ms\n"
+	if got := dump.String(); got != expected {
+		t.Errorf("Expected the third dump to be %q, got %q", expected, got)
+	}
+
+	// Dump doesn't change what's written by WriteTo.
+	var sb strings.Builder
+	if _, err := st.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	if sb.String() != dump.String() {
+		t.Errorf("Expected WriteTo to write %q, got %q", dump.String(), sb.String())
+	}
+
+	sb.Reset()
+	if _, err := st.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	if sb.String() != "" {
+		t.Errorf("Expected nothing after WriteTo, got %q", sb.String())
+	}
+}
+
+func TestDumpReset(t *testing.T) {
+	st := metricsbp.NewStatsd(context.Background(), metricsbp.StatsdConfig{})
+	st.Counter("counter").Add(1)
+
+	var dump strings.Builder
+	if err := st.Dump(&dump); err != nil {
+		t.Fatal(err)
+	}
+	st.Reset()
+
+	var sb strings.Builder
+	if _, err := st.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	if sb.String() != "" {
+		t.Errorf("Expected the dumped lines to be reset, got %q", sb.String())
+	}
+}
+
+func TestDumpMaxLines(t *testing.T) {
+	st := metricsbp.NewStatsd(context.Background(), metricsbp.StatsdConfig{})
+	for i := 0; i < metricsbp.MaxDumpedLines+1; i++ {
+		st.Timing("timing").Observe(1)
+	}
+	if err := st.Dump(ioutil.Discard); err != nil {
+		t.Fatal(err)
+	}
+	st.Counter("counter").Add(1)
+	if err := st.Dump(ioutil.Discard); err != nil {
+		t.Fatal(err)
+	}
+
+	var sb strings.Builder
+	if _, err := st.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Count(sb.String(), "\n"); got != metricsbp.MaxDumpedLines {
+		t.Errorf("Expected %d lines, got %d", metricsbp.MaxDumpedLines, got)
+	}
+}
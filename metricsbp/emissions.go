@@ -0,0 +1,190 @@
+package metricsbp
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/go-kit/kit/metrics"
+)
+
+// The metric types in EmissionCount.
+const (
+	MetricTypeCounter   = "counter"
+	MetricTypeGauge     = "gauge"
+	MetricTypeHistogram = "histogram"
+	MetricTypeTiming    = "timing"
+	MetricTypeSet       = "set"
+)
+
+// EmissionCount is the number of emissions of a metric since NewStatsd,
+// returned by Statsd.EmissionCounts.
+type EmissionCount struct {
+	// Name is the name of the metric passed into Counter, Gauge, etc.,
+	// without Prefix.
+	Name string
+
+	// Type is one of the MetricType constants.
+	Type string
+
+	// Count is the number of Add, Set, and Observe calls to the metric,
+	// including the ones dropped by sampling.
+	// It's 0 if the metric was created but never emitted.
+	Count int64
+}
+
+// EmissionCounts returns the number of emissions of every metric created from
+// st since NewStatsd, sorted by Name and Type,
+// when TrackEmissions is true in StatsdConfig.
+//
+// It's meant for debugging "missing" metrics:
+// a metric not in the result was never created,
+// and a metric with Count 0 was created but never emitted.
+//
+// The counts are shared by st and all the Statsd derived from it via WithTags,
+// WithoutTags, WithCtxTags, WithSampleRate and Scoped,
+// and all the tags of the same metric are counted together.
+// The metrics created from a scoped Statsd are counted under their scoped
+// names.
+//
+// It returns nil when TrackEmissions is false.
+func (st *Statsd) EmissionCounts() []EmissionCount {
+	st = st.fallback()
+	if st.shared.emissions == nil {
+		return nil
+	}
+	var counts []EmissionCount
+	st.shared.emissions.Range(func(k, v interface{}) bool {
+		key := k.(emissionKey)
+		counts = append(counts, EmissionCount{
+			Name:  key.name,
+			Type:  key.typ,
+			Count: atomic.LoadInt64(v.(*int64)),
+		})
+		return true
+	})
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Name != counts[j].Name {
+			return counts[i].Name < counts[j].Name
+		}
+		return counts[i].Type < counts[j].Type
+	})
+	return counts
+}
+
+// emissionKey is the key of the emission counts.
+type emissionKey struct {
+	name string
+	typ  string
+}
+
+// emissionCount returns the pointer to the emission count of the metric,
+// or nil when TrackEmissions is false.
+func (st *Statsd) emissionCount(name, typ string) *int64 {
+	if st.shared.emissions == nil {
+		return nil
+	}
+	key := emissionKey{name: name, typ: typ}
+	if n, ok := st.shared.emissions.Load(key); ok {
+		return n.(*int64)
+	}
+	n, _ := st.shared.emissions.LoadOrStore(key, new(int64))
+	return n.(*int64)
+}
+
+// trackCounter returns c counting the emissions when TrackEmissions is true.
+func (st *Statsd) trackCounter(name string, c metrics.Counter) metrics.Counter {
+	n := st.emissionCount(name, MetricTypeCounter)
+	if n == nil {
+		return c
+	}
+	return trackedCounter{Counter: c, n: n}
+}
+
+// trackGauge returns g counting the emissions when TrackEmissions is true.
+func (st *Statsd) trackGauge(name string, g metrics.Gauge) metrics.Gauge {
+	n := st.emissionCount(name, MetricTypeGauge)
+	if n == nil {
+		return g
+	}
+	return trackedGauge{Gauge: g, n: n}
+}
+
+// trackHistogram returns h counting the emissions when TrackEmissions is true.
+//
+// typ is either MetricTypeHistogram or MetricTypeTiming.
+func (st *Statsd) trackHistogram(name, typ string, h metrics.Histogram) metrics.Histogram {
+	n := st.emissionCount(name, typ)
+	if n == nil {
+		return h
+	}
+	return trackedHistogram{Histogram: h, n: n}
+}
+
+type trackedCounter struct {
+	metrics.Counter
+
+	n *int64
+}
+
+func (c trackedCounter) With(tagValues ...string) metrics.Counter {
+	return trackedCounter{
+		Counter: c.Counter.With(tagValues...),
+		n:       c.n,
+	}
+}
+
+func (c trackedCounter) Add(delta float64) {
+	atomic.AddInt64(c.n, 1)
+	c.Counter.Add(delta)
+}
+
+type trackedGauge struct {
+	metrics.Gauge
+
+	n *int64
+}
+
+func (g trackedGauge) With(tagValues ...string) metrics.Gauge {
+	return trackedGauge{
+		Gauge: g.Gauge.With(tagValues...),
+		n:     g.n,
+	}
+}
+
+func (g trackedGauge) Set(value float64) {
+	atomic.AddInt64(g.n, 1)
+	g.Gauge.Set(value)
+}
+
+func (g trackedGauge) Add(delta float64) {
+	atomic.AddInt64(g.n, 1)
+	g.Gauge.Add(delta)
+}
+
+type trackedHistogram struct {
+	metrics.Histogram
+
+	n *int64
+}
+
+func (h trackedHistogram) With(tagValues ...string) metrics.Histogram {
+	return trackedHistogram{
+		Histogram: h.Histogram.With(tagValues...),
+		n:         h.n,
+	}
+}
+
+func (h trackedHistogram) Observe(value float64) {
+	atomic.AddInt64(h.n, 1)
+	h.Histogram.Observe(value)
+}
+
+// newEmissions returns the map of the emission counts keyed by emissionKey
+// when track is true, or nil otherwise.
+func newEmissions(track bool) *sync.Map {
+	if !track {
+		return nil
+	}
+	return new(sync.Map)
+}
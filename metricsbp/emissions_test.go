@@ -0,0 +1,52 @@
+package metricsbp_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/reddit/baseplate.go/metricsbp"
+)
+
+func TestEmissionCounts(t *testing.T) {
+	st := metricsbp.NewStatsd(
+		context.Background(),
+		metricsbp.StatsdConfig{
+			Prefix:         "svc",
+			TrackEmissions: true,
+		},
+	)
+
+	counter := st.Counter("counter")
+	counter.Add(1)
+	counter.With("foo", "bar").Add(1)
+	st.WithTags(metricsbp.Tags{"key": "value"}).Counter("counter").Add(1)
+	st.CounterWithRate(metricsbp.RateArgs{
+		Name: "sampled",
+		Rate: 0.000001,
+	}).Add(1)
+	st.Gauge("gauge").Set(1)
+	st.Histogram("created")
+	st.Timing("timing").Observe(1)
+	st.Set("set").Add("value")
+
+	expected := []metricsbp.EmissionCount{
+		{Name: "counter", Type: metricsbp.MetricTypeCounter, Count: 3},
+		{Name: "created", Type: metricsbp.MetricTypeHistogram, Count: 0},
+		{Name: "gauge", Type: metricsbp.MetricTypeGauge, Count: 1},
+		{Name: "sampled", Type: metricsbp.MetricTypeCounter, Count: 1},
+		{Name: "set", Type: metricsbp.MetricTypeSet, Count: 1},
+		{Name: "timing", Type: metricsbp.MetricTypeTiming, Count: 1},
+	}
+	if got := st.EmissionCounts(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, got)
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		st := metricsbp.NewStatsd(context.Background(), metricsbp.StatsdConfig{})
+		st.Counter("counter").Add(1)
+		if got := st.EmissionCounts(); got != nil {
+			t.Errorf("Expected nil, got %+v", got)
+		}
+	})
+}
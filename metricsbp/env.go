@@ -0,0 +1,58 @@
+package metricsbp
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// The environment variables read by StatsdConfigFromEnv.
+const (
+	// EnvAddress is the environment variable for StatsdConfig.Address.
+	EnvAddress = "BASEPLATE_STATSD_ADDRESS"
+
+	// EnvPrefix is the environment variable for StatsdConfig.Prefix.
+	EnvPrefix = "BASEPLATE_STATSD_PREFIX"
+
+	// EnvSampleRate is the environment variable for
+	// StatsdConfig.HistogramSampleRate, as a float (e.g. "0.1").
+	EnvSampleRate = "BASEPLATE_STATSD_SAMPLE_RATE"
+)
+
+// StatsdConfigFromEnv returns a StatsdConfig with the fields read from the
+// environment variables EnvAddress, EnvPrefix, and EnvSampleRate.
+//
+// Environment variables not set leave the corresponding fields at their zero
+// values.
+// It only returns an error when EnvSampleRate is set but not a valid float.
+//
+// The returned StatsdConfig is meant to be used as the base,
+// with the fields set in code taking precedence over the environment
+// variables:
+//
+//     cfg, err := metricsbp.StatsdConfigFromEnv()
+//     if err != nil {
+//       log.Fatal(err)
+//     }
+//     if cfg.Prefix == "" {
+//       cfg.Prefix = "my-service"
+//     }
+//     cfg.Tags = metricsbp.Tags{"env": "prod"}
+//     st := metricsbp.NewStatsd(ctx, cfg)
+func StatsdConfigFromEnv() (StatsdConfig, error) {
+	var cfg StatsdConfig
+	if address, ok := os.LookupEnv(EnvAddress); ok {
+		cfg.Address = address
+	}
+	if prefix, ok := os.LookupEnv(EnvPrefix); ok {
+		cfg.Prefix = prefix
+	}
+	if s, ok := os.LookupEnv(EnvSampleRate); ok {
+		rate, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return cfg, fmt.Errorf("metricsbp: invalid %s %q: %w", EnvSampleRate, s, err)
+		}
+		cfg.HistogramSampleRate = &rate
+	}
+	return cfg, nil
+}
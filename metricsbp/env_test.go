@@ -0,0 +1,63 @@
+package metricsbp_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/reddit/baseplate.go/metricsbp"
+)
+
+func TestStatsdConfigFromEnv(t *testing.T) {
+	envs := []string{
+		metricsbp.EnvAddress,
+		metricsbp.EnvPrefix,
+		metricsbp.EnvSampleRate,
+	}
+	unsetAll := func() {
+		for _, env := range envs {
+			os.Unsetenv(env)
+		}
+	}
+	unsetAll()
+	defer unsetAll()
+
+	t.Run("unset", func(t *testing.T) {
+		cfg, err := metricsbp.StatsdConfigFromEnv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Address != "" || cfg.Prefix != "" || cfg.HistogramSampleRate != nil {
+			t.Errorf("Expected zero values, got %+v", cfg)
+		}
+	})
+
+	t.Run("set", func(t *testing.T) {
+		os.Setenv(metricsbp.EnvAddress, "localhost:8125")
+		os.Setenv(metricsbp.EnvPrefix, "my-service")
+		os.Setenv(metricsbp.EnvSampleRate, "0.1")
+		defer unsetAll()
+
+		cfg, err := metricsbp.StatsdConfigFromEnv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Address != "localhost:8125" {
+			t.Errorf("Expected Address %q, got %q", "localhost:8125", cfg.Address)
+		}
+		if cfg.Prefix != "my-service" {
+			t.Errorf("Expected Prefix %q, got %q", "my-service", cfg.Prefix)
+		}
+		if cfg.HistogramSampleRate == nil || *cfg.HistogramSampleRate != 0.1 {
+			t.Errorf("Expected HistogramSampleRate 0.1, got %v", cfg.HistogramSampleRate)
+		}
+	})
+
+	t.Run("invalid-rate", func(t *testing.T) {
+		os.Setenv(metricsbp.EnvSampleRate, "foo")
+		defer unsetAll()
+
+		if _, err := metricsbp.StatsdConfigFromEnv(); err == nil {
+			t.Error("Expected error for invalid sample rate, got nil")
+		}
+	})
+}
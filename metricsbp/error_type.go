@@ -0,0 +1,129 @@
+package metricsbp
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// The error types returned by ClassifyError.
+const (
+	// ErrorTypeTimeout is the error type of context.DeadlineExceeded,
+	// and the errors with a Timeout method returning true (for example
+	// net.Error).
+	ErrorTypeTimeout = "timeout"
+
+	// ErrorTypeCanceled is the error type of context.Canceled.
+	ErrorTypeCanceled = "canceled"
+
+	// ErrorTypeValidation is the error type of invalid inputs.
+	//
+	// No errors are classified as it by default,
+	// implement ErrorTyper or register an ErrorClassifier for them.
+	ErrorTypeValidation = "validation"
+
+	// ErrorTypeInternal is the error type of all the other errors.
+	ErrorTypeInternal = "internal"
+)
+
+// ErrorTyper is the interface an error can implement to classify itself,
+// see ClassifyError.
+//
+// ErrorType can return the ErrorType constants or the custom ones,
+// or the empty string to leave it to the default classification.
+type ErrorTyper interface {
+	error
+
+	ErrorType() string
+}
+
+// ErrorClassifier classifies an error for ClassifyError,
+// it returns the error type,
+// or the empty string to leave it to the next classifier.
+type ErrorClassifier func(err error) string
+
+var (
+	errorClassifiersLock sync.RWMutex
+	errorClassifiers     []ErrorClassifier
+)
+
+// RegisterErrorClassifiers registers custom ErrorClassifiers used by
+// ClassifyError, before the default classification.
+//
+// The classifiers are called in the order they are registered.
+//
+// It's safe to be called concurrently with ClassifyError,
+// but it's usually called from the main function before serving.
+func RegisterErrorClassifiers(classifiers ...ErrorClassifier) {
+	errorClassifiersLock.Lock()
+	defer errorClassifiersLock.Unlock()
+	errorClassifiers = append(errorClassifiers, classifiers...)
+}
+
+// ResetErrorClassifiers removes all the ErrorClassifiers registered via
+// RegisterErrorClassifiers.
+func ResetErrorClassifiers() {
+	errorClassifiersLock.Lock()
+	defer errorClassifiersLock.Unlock()
+	errorClassifiers = nil
+}
+
+// ClassifyError returns the error type of err,
+// to be used as the value of the LabelErrorType tag.
+//
+// The error type is the first non-empty one from:
+//
+// 1. The ErrorClassifiers registered via RegisterErrorClassifiers.
+//
+// 2. The ErrorType of the first ErrorTyper in the chain of err.
+//
+// 3. ErrorTypeTimeout, ErrorTypeCanceled or ErrorTypeInternal.
+//
+// It returns the empty string when err is nil.
+func ClassifyError(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	errorClassifiersLock.RLock()
+	classifiers := errorClassifiers
+	errorClassifiersLock.RUnlock()
+	for _, classify := range classifiers {
+		if typ := classify(err); typ != "" {
+			return typ
+		}
+	}
+
+	var typer ErrorTyper
+	if errors.As(err, &typer) {
+		if typ := typer.ErrorType(); typ != "" {
+			return typ
+		}
+	}
+
+	var timeout interface {
+		Timeout() bool
+	}
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorTypeTimeout
+	case errors.As(err, &timeout) && timeout.Timeout():
+		return ErrorTypeTimeout
+	case errors.Is(err, context.Canceled):
+		return ErrorTypeCanceled
+	default:
+		return ErrorTypeInternal
+	}
+}
+
+// IncError adds 1 to the counter of the name,
+// tagged with the LabelErrorType of err (see ClassifyError).
+//
+// The counter is created via ErrorCounter, so it's never sampled.
+// It's a no-op when err is nil.
+func (st *Statsd) IncError(name string, err error) {
+	if err == nil {
+		return
+	}
+	st.ErrorCounter(name).With(string(LabelErrorType), ClassifyError(err)).Add(1)
+}
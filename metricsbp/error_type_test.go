@@ -0,0 +1,139 @@
+package metricsbp_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/reddit/baseplate.go/metricsbp"
+)
+
+type validationError struct{}
+
+func (validationError) Error() string {
+	return "invalid"
+}
+
+func (validationError) ErrorType() string {
+	return metricsbp.ErrorTypeValidation
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string {
+	return "timeout"
+}
+
+func (timeoutError) Timeout() bool {
+	return true
+}
+
+var errCustom = errors.New("custom")
+
+func TestClassifyError(t *testing.T) {
+	for _, c := range []struct {
+		label    string
+		err      error
+		expected string
+	}{
+		{
+			label:    "nil",
+			err:      nil,
+			expected: "",
+		},
+		{
+			label:    "deadline",
+			err:      fmt.Errorf("wrapped: %w", context.DeadlineExceeded),
+			expected: metricsbp.ErrorTypeTimeout,
+		},
+		{
+			label:    "timeout",
+			err:      timeoutError{},
+			expected: metricsbp.ErrorTypeTimeout,
+		},
+		{
+			label:    "canceled",
+			err:      context.Canceled,
+			expected: metricsbp.ErrorTypeCanceled,
+		},
+		{
+			label:    "typer",
+			err:      fmt.Errorf("wrapped: %w", validationError{}),
+			expected: metricsbp.ErrorTypeValidation,
+		},
+		{
+			label:    "internal",
+			err:      errCustom,
+			expected: metricsbp.ErrorTypeInternal,
+		},
+	} {
+		t.Run(c.label, func(t *testing.T) {
+			if typ := metricsbp.ClassifyError(c.err); typ != c.expected {
+				t.Errorf("Expected %q, got %q", c.expected, typ)
+			}
+		})
+	}
+}
+
+func TestRegisterErrorClassifiers(t *testing.T) {
+	defer metricsbp.ResetErrorClassifiers()
+	metricsbp.RegisterErrorClassifiers(
+		func(err error) string {
+			if errors.Is(err, errCustom) {
+				return "custom"
+			}
+			return ""
+		},
+		func(err error) string {
+			if errors.Is(err, context.Canceled) {
+				return "overridden"
+			}
+			return ""
+		},
+	)
+
+	for err, expected := range map[error]string{
+		errCustom:                   "custom",
+		context.Canceled:            "overridden",
+		context.DeadlineExceeded:    metricsbp.ErrorTypeTimeout,
+		errors.New("other"):         metricsbp.ErrorTypeInternal,
+		fmt.Errorf("%w", errCustom): "custom",
+	} {
+		if typ := metricsbp.ClassifyError(err); typ != expected {
+			t.Errorf("%v: Expected %q, got %q", err, expected, typ)
+		}
+	}
+
+	metricsbp.ResetErrorClassifiers()
+	if typ := metricsbp.ClassifyError(errCustom); typ != metricsbp.ErrorTypeInternal {
+		t.Errorf("Expected %q after reset, got %q", metricsbp.ErrorTypeInternal, typ)
+	}
+}
+
+func TestIncError(t *testing.T) {
+	st := metricsbp.NewStatsd(context.Background(), metricsbp.StatsdConfig{
+		CounterSampleRate: metricsbp.Float64Ptr(0.01),
+	})
+	st.IncError("errors", context.DeadlineExceeded)
+	st.IncError("errors", context.DeadlineExceeded)
+	st.IncError("errors", errCustom)
+	st.IncError("errors", nil)
+
+	var sb strings.Builder
+	if _, err := st.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"errors,error_type=timeout:2.000000|c\n",
+		"errors,error_type=internal:1.000000|c\n",
+	} {
+		if !strings.Contains(sb.String(), expected) {
+			t.Errorf("Expected %q in %q", expected, sb.String())
+		}
+	}
+	if strings.Count(sb.String(), "\n") != 2 {
+		t.Errorf("Expected 2 lines, got %q", sb.String())
+	}
+}
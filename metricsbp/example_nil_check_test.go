@@ -31,7 +31,7 @@ func ExampleCheckNilFields() {
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
-	metricsbp.M = metricsbp.NewStatsd(
+	metricsbp.SetM(metricsbp.NewStatsd(
 		ctx,
 		metricsbp.StatsdConfig{
 			Prefix:              prefix,
@@ -39,13 +39,13 @@ func ExampleCheckNilFields() {
 			CounterSampleRate:   metricsbp.Float64Ptr(sampleRate),
 			HistogramSampleRate: metricsbp.Float64Ptr(sampleRate),
 		},
-	)
+	))
 
 	// Initialize metrics
 	m := PreCreatedMetrics{
-		MyCounter: metricsbp.M.Counter("my.counter"),
+		MyCounter: metricsbp.GetM().Counter("my.counter"),
 		MySubMetrics: SubMetrics{
-			MyHistogram: metricsbp.M.Histogram("my.histogram"),
+			MyHistogram: metricsbp.GetM().Histogram("my.histogram"),
 			// Forgot to initialize MyGauge here
 		},
 	}
@@ -0,0 +1,107 @@
+package metricsbp
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// FileConfig is the config used in StatsdConfig to append the metrics to a
+// file, for the environments without a statsd collector.
+type FileConfig struct {
+	// Path is the path of the file to append the statsd lines to.
+	//
+	// The file is created if it does not exist.
+	Path string
+
+	// MaxBytes is the max size of the file.
+	//
+	// When it's positive,
+	// and a write would grow the file beyond MaxBytes,
+	// the file is rotated (see MaxBackups) or truncated before the write.
+	//
+	// When it's 0 (default), the file grows without bound.
+	MaxBytes int64
+
+	// MaxBackups is the number of the rotated files to keep,
+	// named Path with the suffixes ".1" (the most recent one), ".2", etc.
+	//
+	// When it's 0 (default), the file is truncated instead of rotated.
+	// It's ignored when MaxBytes is not positive.
+	MaxBackups int
+}
+
+// validateFileConfig returns an error if the Path in cfg is empty.
+func validateFileConfig(cfg FileConfig) error {
+	if cfg.Path == "" {
+		return errors.New("metricsbp: File.Path is empty")
+	}
+	return nil
+}
+
+// fileWriter is an io.Writer appending every write to the file.
+//
+// The file is opened and closed on every write,
+// so the written lines are always complete on disk,
+// and it never needs to be closed.
+// The underlying bufferedWriter serializes the writes,
+// so it's not safe for concurrent use.
+type fileWriter struct {
+	cfg FileConfig
+}
+
+func (w *fileWriter) Write(p []byte) (int, error) {
+	flag := os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	if w.cfg.MaxBytes > 0 {
+		truncate, err := w.rotate(int64(len(p)))
+		if err != nil {
+			return 0, err
+		}
+		if truncate {
+			flag |= os.O_TRUNC
+		}
+	}
+	f, err := os.OpenFile(w.cfg.Path, flag, 0644)
+	if err != nil {
+		return 0, err
+	}
+	n, err := f.Write(p)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return n, err
+}
+
+// rotate rotates the file when writing n more bytes to it would grow it beyond
+// MaxBytes, or returns true when it should be truncated instead.
+func (w *fileWriter) rotate(n int64) (truncate bool, err error) {
+	info, err := os.Stat(w.cfg.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if info.Size() == 0 || info.Size()+n <= w.cfg.MaxBytes {
+		return false, nil
+	}
+	if w.cfg.MaxBackups <= 0 {
+		return true, nil
+	}
+	for i := w.cfg.MaxBackups - 1; i > 0; i-- {
+		err := os.Rename(w.backupPath(i), w.backupPath(i+1))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return false, fmt.Errorf("metricsbp: failed to rotate %q: %w", w.backupPath(i), err)
+		}
+	}
+	if err := os.Rename(w.cfg.Path, w.backupPath(1)); err != nil {
+		return false, fmt.Errorf("metricsbp: failed to rotate %q: %w", w.cfg.Path, err)
+	}
+	return false, nil
+}
+
+// backupPath returns the path of the i-th rotated file.
+func (w *fileWriter) backupPath(i int) string {
+	return w.cfg.Path + "." + strconv.Itoa(i)
+}
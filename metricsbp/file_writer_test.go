@@ -0,0 +1,115 @@
+package metricsbp_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/reddit/baseplate.go/metricsbp"
+)
+
+func TestFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "metricsbp-file-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "metrics.txt")
+
+	// Every run appends to the same file.
+	for _, name := range []string{"first", "second"} {
+		st := metricsbp.NewStatsd(context.Background(), metricsbp.StatsdConfig{
+			File: &metricsbp.FileConfig{
+				Path: path,
+			},
+			ReportingInterval: time.Hour,
+		})
+		st.Counter(name).Add(1)
+		if err := st.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"first:1.000000|c\n",
+		"second:1.000000|c\n",
+	} {
+		if !strings.Contains(string(content), expected) {
+			t.Errorf("Expected %q in %q", expected, content)
+		}
+	}
+}
+
+func TestFileMaxBytes(t *testing.T) {
+	for _, c := range []struct {
+		label      string
+		maxBackups int
+		expected   []string
+	}{
+		{
+			label:    "truncate",
+			expected: []string{"metrics.txt"},
+		},
+		{
+			label:      "rotate",
+			maxBackups: 1,
+			expected:   []string{"metrics.txt", "metrics.txt.1"},
+		},
+		{
+			label:      "rotate-more",
+			maxBackups: 5,
+			expected:   []string{"metrics.txt", "metrics.txt.1", "metrics.txt.2"},
+		},
+	} {
+		t.Run(c.label, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "metricsbp-file-")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			st := metricsbp.NewStatsd(context.Background(), metricsbp.StatsdConfig{
+				File: &metricsbp.FileConfig{
+					Path:       filepath.Join(dir, "metrics.txt"),
+					MaxBytes:   20,
+					MaxBackups: c.maxBackups,
+				},
+				// Write every line separately.
+				BufferSize:        1,
+				ReportingInterval: time.Hour,
+			})
+			defer st.Close()
+			// Every line is 13 bytes, so every write rotates or truncates the file.
+			st.Counter("a").Add(1)
+			st.Counter("b").Add(1)
+			st.Counter("c").Add(1)
+			if err := st.Flush(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			files, err := ioutil.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, f := range files {
+				names = append(names, f.Name())
+				// Every file only has a single line.
+				if f.Size() != 13 {
+					t.Errorf("Expected %s to be 13 bytes, got %d", f.Name(), f.Size())
+				}
+			}
+			if strings.Join(names, ",") != strings.Join(c.expected, ",") {
+				t.Errorf("Expected files %q, got %q", c.expected, names)
+			}
+		})
+	}
+}
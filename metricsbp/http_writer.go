@@ -0,0 +1,141 @@
+package metricsbp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// DefaultHTTPTimeout is the timeout of every request when neither Timeout in
+// HTTPConfig nor the Timeout of its Client is set.
+const DefaultHTTPTimeout = 10 * time.Second
+
+// HTTPConfig is the config used in StatsdConfig to send the metrics to a
+// statsd-over-HTTP gateway.
+type HTTPConfig struct {
+	// URL is the endpoint to POST the statsd lines to,
+	// for example "https://statsd-gateway.example.com/lines".
+	//
+	// It must be an absolute http or https URL.
+	URL string
+
+	// Header is the additional headers of every request,
+	// for example an Authorization header.
+	Header http.Header
+
+	// Client is the http client used to send the requests.
+	//
+	// When it's nil (default),
+	// http.DefaultTransport will be used.
+	// The proxy from the environment is used by both of them by default.
+	Client *http.Client
+
+	// Timeout is the timeout of every request,
+	// including the ones of the final flush after the context passed into
+	// NewStatsd is canceled.
+	//
+	// When it's 0 (default),
+	// the Timeout of Client is used if it's set,
+	// otherwise DefaultHTTPTimeout is used,
+	// so that a stuck gateway never blocks the reporting forever.
+	Timeout time.Duration
+}
+
+// validateHTTPConfig returns an error if the URL in cfg is not an absolute
+// http or https URL.
+func validateHTTPConfig(cfg HTTPConfig) error {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return fmt.Errorf("metricsbp: invalid HTTP URL %q: %w", cfg.URL, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("metricsbp: HTTP URL %q is not an absolute http or https URL", cfg.URL)
+	}
+	return nil
+}
+
+// httpWriter is an io.Writer POSTing every write to the URL.
+type httpWriter struct {
+	url    string
+	header  http.Header
+	client  *http.Client
+	timeout time.Duration
+
+	// compressor is non-nil when the payloads are compressed with gzip.
+	compressor *gzipCompressor
+}
+
+func newHTTPWriter(cfg HTTPConfig, compression Compression) *httpWriter {
+	client := cfg.Client
+	if client == nil {
+		client = new(http.Client)
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 && client.Timeout <= 0 {
+		timeout = DefaultHTTPTimeout
+	}
+	w := &httpWriter{
+		url:     cfg.URL,
+		header:  cfg.Header,
+		client:  client,
+		timeout: timeout,
+	}
+	if compression == CompressionGzip {
+		w.compressor = new(gzipCompressor)
+	}
+	return w
+}
+
+func (w *httpWriter) Write(p []byte) (int, error) {
+	body := p
+	if w.compressor != nil {
+		var err error
+		body, err = w.compressor.compress(p)
+		if err != nil {
+			return 0, err
+		}
+	}
+	// The final flush happens after the context of Statsd is canceled,
+	// so the requests are bound by their own timeout instead.
+	ctx := context.Background()
+	if w.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, w.timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		w.url,
+		bytes.NewReader(body),
+	)
+	if err != nil {
+		return 0, err
+	}
+	if w.header != nil {
+		req.Header = w.header.Clone()
+	}
+	if req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	if w.compressor != nil {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// Drain the body so the connection can be reused.
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return 0, fmt.Errorf("metricsbp: HTTP gateway responded with %s", resp.Status)
+	}
+	return len(p), nil
+}
@@ -0,0 +1,105 @@
+package metricsbp_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/reddit/baseplate.go/metricsbp"
+)
+
+func TestHTTP(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("Expected POST, got %s", r.Method)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer token" {
+			t.Errorf("Expected Authorization header %q, got %q", "Bearer token", got)
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		bodies = append(bodies, string(body))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	st := metricsbp.NewStatsd(
+		context.Background(),
+		metricsbp.StatsdConfig{
+			HTTP: &metricsbp.HTTPConfig{
+				URL: server.URL,
+				Header: http.Header{
+					"Authorization": []string{"Bearer token"},
+				},
+			},
+			ReportingInterval: time.Hour,
+		},
+	)
+	defer st.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	st.Counter("counter").Add(1)
+	if err := st.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	if len(bodies) != 1 {
+		t.Fatalf("Expected 1 request, got %q", bodies)
+	}
+	const expected = "counter:1.000000|c\n"
+	if !strings.Contains(bodies[0], expected) {
+		t.Errorf("Expected %q in %q", expected, bodies[0])
+	}
+	status = http.StatusUnauthorized
+	mu.Unlock()
+
+	st.Counter("counter").Add(1)
+	if err := st.Flush(ctx); err == nil {
+		t.Error("Expected Flush to fail on non-2xx responses")
+	}
+}
+
+func TestHTTPTimeout(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer server.Close()
+	defer close(done)
+
+	st := metricsbp.NewStatsd(
+		context.Background(),
+		metricsbp.StatsdConfig{
+			HTTP: &metricsbp.HTTPConfig{
+				URL: server.URL,
+				// A client without its own timeout.
+				Client:  new(http.Client),
+				Timeout: time.Millisecond * 10,
+			},
+			ReportingInterval: time.Hour,
+		},
+	)
+	defer st.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	st.Counter("counter").Add(1)
+	if err := st.Flush(ctx); err == nil || ctx.Err() != nil {
+		t.Errorf("Expected Flush to fail with the request timeout, got %v", err)
+	}
+}
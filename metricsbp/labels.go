@@ -0,0 +1,175 @@
+package metricsbp
+
+import (
+	"sync"
+
+	"github.com/go-kit/kit/log"
+)
+
+// LabelKey is a tag key,
+// used by Labels to keep the tag keys consistent across a codebase.
+//
+// Prefer the LabelKey constants, or define your own ones,
+// over passing string literals into Labels.Add:
+// a typo in a constant is a compile error,
+// while a typo in a string literal is a new tag key.
+type LabelKey string
+
+// The LabelKey constants of the commonly used tags.
+//
+// Please use them instead of the alternative spellings
+// (for example "status_code" or "rpc_method"),
+// so that the same tags are named the same across services.
+const (
+	// LabelEndpoint is the tag key of the name of the endpoint being served,
+	// for example the thrift method or the http route name.
+	LabelEndpoint LabelKey = "endpoint"
+
+	// LabelMethod is the tag key of the method being called,
+	// for example the http method or the thrift method of a client.
+	LabelMethod LabelKey = "method"
+
+	// LabelStatus is the tag key of the status of a response,
+	// for example the http status class ("2xx").
+	LabelStatus LabelKey = "status"
+
+	// LabelCode is the tag key of the protocol specific code of a response,
+	// for example the grpc status code.
+	LabelCode LabelKey = "code"
+
+	// LabelSuccess is the tag key of whether the operation succeeded
+	// ("true" or "false").
+	LabelSuccess LabelKey = "success"
+
+	// LabelClient is the tag key of the name of the client.
+	LabelClient LabelKey = "client"
+
+	// LabelErrorType is the tag key of the classification of an error,
+	// see ClassifyError.
+	LabelErrorType LabelKey = "error_type"
+)
+
+// Labels builds the tags (as key-value pairs) to be passed into With of the
+// metrics, or the Tags to be passed into WithTags.
+//
+// Please use NewLabels to create it, for example:
+//
+//     labels := metricsbp.NewLabels().
+//       Add(metricsbp.LabelEndpoint, name).
+//       Add(metricsbp.LabelSuccess, strconv.FormatBool(err == nil))
+//     st.Counter("requests").With(labels.Build()...).Add(1)
+//
+// The same key added more than once is only kept once,
+// with the value added last.
+// It's not safe for concurrent use.
+type Labels struct {
+	tags []string
+}
+
+// NewLabels creates an empty Labels.
+func NewLabels() *Labels {
+	return new(Labels)
+}
+
+// Add adds the tag to l, and returns l for chaining.
+func (l *Labels) Add(key LabelKey, value string) *Labels {
+	for i := 0; i+1 < len(l.tags); i += 2 {
+		if l.tags[i] == string(key) {
+			l.tags[i+1] = value
+			return l
+		}
+	}
+	l.tags = append(l.tags, string(key), value)
+	return l
+}
+
+// Build returns the tags added to l as key-value pairs,
+// in the order they are first added.
+//
+// The returned slice is a copy, so l can be added to later.
+func (l *Labels) Build() []string {
+	tags := make([]string, len(l.tags))
+	copy(tags, l.tags)
+	return tags
+}
+
+// Tags returns the tags added to l as Tags.
+func (l *Labels) Tags() Tags {
+	tags := make(Tags, len(l.tags)/2)
+	for i := 0; i+1 < len(l.tags); i += 2 {
+		tags[l.tags[i]] = l.tags[i+1]
+	}
+	return tags
+}
+
+// builtinTagKeys are the tag keys attached by metricsbp itself and the
+// LabelKey constants, which are always known by WarnUnknownTagKeys.
+var builtinTagKeys = []string{
+	string(LabelEndpoint),
+	string(LabelMethod),
+	string(LabelStatus),
+	string(LabelCode),
+	string(LabelSuccess),
+	string(LabelClient),
+	string(LabelErrorType),
+	TraceIDTagKey,
+	TimingUnitTagKey,
+	GoVersionTagKey,
+	DefaultHostnameTagKey,
+}
+
+// tagKeyChecker logs the first use of every unknown tag key,
+// see WarnUnknownTagKeys in StatsdConfig.
+type tagKeyChecker struct {
+	known  map[string]bool
+	logger log.Logger
+
+	// warned are the unknown tag keys already logged.
+	warned sync.Map
+}
+
+// newTagKeyChecker creates the tagKeyChecker according to cfg,
+// with the keys of tags being known as well.
+//
+// It returns nil when WarnUnknownTagKeys is false,
+// and a nil *tagKeyChecker does not check anything.
+func newTagKeyChecker(cfg StatsdConfig, tags []string, logger log.Logger) *tagKeyChecker {
+	if !cfg.WarnUnknownTagKeys {
+		return nil
+	}
+	known := make(map[string]bool, len(builtinTagKeys)+len(cfg.KnownTagKeys)+len(tags)/2)
+	for _, key := range builtinTagKeys {
+		known[key] = true
+	}
+	for _, key := range cfg.KnownTagKeys {
+		known[SanitizeTag(key)] = true
+	}
+	for i := 0; i < len(tags); i += 2 {
+		known[tags[i]] = true
+	}
+	return &tagKeyChecker{
+		known:  known,
+		logger: logger,
+	}
+}
+
+// check checks the keys of tagValues (as key-value pairs, already sanitized).
+func (c *tagKeyChecker) check(tagValues []string) {
+	if c == nil {
+		return
+	}
+	for i := 0; i < len(tagValues); i += 2 {
+		key := tagValues[i]
+		if c.known[key] {
+			continue
+		}
+		if _, warned := c.warned.LoadOrStore(key, true); warned {
+			continue
+		}
+		c.logger.Log(
+			"during", "metricsbp.WarnUnknownTagKeys",
+			"msg", "unknown tag key used, further uses of it will not be logged",
+			"key", key,
+		)
+	}
+}
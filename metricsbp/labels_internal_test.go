@@ -0,0 +1,67 @@
+package metricsbp
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestLabels(t *testing.T) {
+	labels := NewLabels().
+		Add(LabelEndpoint, "foo").
+		Add(LabelSuccess, "false").
+		Add("custom", "value").
+		Add(LabelSuccess, "true")
+
+	expected := []string{"endpoint", "foo", "success", "true", "custom", "value"}
+	built := labels.Build()
+	if !reflect.DeepEqual(built, expected) {
+		t.Errorf("Expected %q, got %q", expected, built)
+	}
+	// The built tags should not be affected by later Add calls.
+	labels.Add(LabelEndpoint, "bar")
+	if !reflect.DeepEqual(built, expected) {
+		t.Errorf("Expected %q after Add, got %q", expected, built)
+	}
+
+	expectedTags := Tags{
+		"endpoint": "bar",
+		"success":  "true",
+		"custom":   "value",
+	}
+	if tags := labels.Tags(); !reflect.DeepEqual(tags, expectedTags) {
+		t.Errorf("Expected %v, got %v", expectedTags, tags)
+	}
+}
+
+func TestWarnUnknownTagKeys(t *testing.T) {
+	logger := new(recordingLogger)
+	st := NewStatsd(context.Background(), StatsdConfig{})
+	st.tagKeys = newTagKeyChecker(
+		StatsdConfig{
+			WarnUnknownTagKeys: true,
+			KnownTagKeys:       []string{"shard"},
+		},
+		[]string{"env", "prod"},
+		logger,
+	)
+
+	st.Counter("counter").With("endpoint", "foo", "shard", "1", "env", "test").Add(1)
+	if len(logger.logs) != 0 {
+		t.Errorf("Expected no logs for known tag keys, got %v", logger.logs)
+	}
+
+	st.Counter("counter").With("status_code", "200").Add(1)
+	st.WithTags(Tags{"status_code": "500"})
+	st.Timing("timing").With("status_code", "200").Observe(1)
+	if len(logger.logs) != 1 {
+		t.Fatalf("Expected the unknown tag key to be logged once, got %v", logger.logs)
+	}
+	if !reflect.DeepEqual(logger.logs[0][len(logger.logs[0])-2:], []interface{}{"key", "status_code"}) {
+		t.Errorf("Expected the unknown tag key in the log, got %v", logger.logs[0])
+	}
+
+	if newTagKeyChecker(StatsdConfig{}, nil, logger) != nil {
+		t.Error("Expected nil tagKeyChecker when WarnUnknownTagKeys is false")
+	}
+}
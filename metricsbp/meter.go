@@ -0,0 +1,131 @@
+package metricsbp
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+)
+
+// Meter is a metric reporting the rate of events per second.
+//
+// Please use Statsd.Meter to create it.
+type Meter struct {
+	space       *meterSpace
+	labelValues []string
+}
+
+// Meter returns a meter metrics to the name.
+//
+// The events marked are accumulated in process,
+// and on every reporting tick it reports a gauge to the name,
+// with the number of events per second since the previous tick,
+// so the statsd backend doesn't need to calculate the rate from a counter.
+//
+// A tag combination without any events since the previous tick is reported
+// as 0 once, and no longer reported until it's marked again.
+//
+// The meters are cached the same way as Summary,
+// so calling Meter with the same name again (for example on every request)
+// returns the same meter.
+//
+// The events are never sampled.
+func (st *Statsd) Meter(name string) Meter {
+	st = st.fallback()
+	if st.discard {
+		return Meter{}
+	}
+	return st.shared.metrics.getPinned(st.nameKey(meterKind, st.scopedName(name)), func() interface{} {
+		s := &meterSpace{
+			gauge:  st.Gauge(name),
+			clock:  st.clock,
+			last:   st.clock.Now(),
+			counts: make(map[string]*meterCount),
+		}
+		st.onTick.add(s.report)
+		st.onDiscard.add(s.reset)
+		return Meter{space: s}
+	}).(Meter)
+}
+
+// With returns a Meter with the additional tags (as key-value pairs).
+func (m Meter) With(tagValues ...string) Meter {
+	combined := make([]string, 0, len(m.labelValues)+len(tagValues))
+	combined = append(combined, m.labelValues...)
+	combined = append(combined, tagValues...)
+	m.labelValues = combined
+	return m
+}
+
+// Mark records n events.
+func (m Meter) Mark(n int64) {
+	if m.space == nil {
+		return
+	}
+	m.space.mark(m.labelValues, n)
+}
+
+// meterSpace holds the counts of all the label values of a meter.
+type meterSpace struct {
+	gauge metrics.Gauge
+	clock clock
+
+	mu     sync.Mutex
+	last   time.Time
+	counts map[string]*meterCount
+}
+
+type meterCount struct {
+	labelValues []string
+	n           int64
+}
+
+func (s *meterSpace) mark(labelValues []string, n int64) {
+	key := strings.Join(labelValues, "\x00")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.counts[key]
+	if c == nil {
+		c = &meterCount{labelValues: labelValues}
+		s.counts[key] = c
+	}
+	c.n += n
+}
+
+// report sets the gauges to the rates since the previous report,
+// and resets the counts.
+func (s *meterSpace) report() {
+	now := s.clock.Now()
+	s.mu.Lock()
+	elapsed := now.Sub(s.last)
+	if elapsed <= 0 {
+		s.mu.Unlock()
+		return
+	}
+	s.last = now
+	reports := make([]meterCount, 0, len(s.counts))
+	for key, c := range s.counts {
+		reports = append(reports, *c)
+		if c.n == 0 {
+			// Reported as 0 for the last time.
+			delete(s.counts, key)
+		}
+		c.n = 0
+	}
+	s.mu.Unlock()
+
+	for _, c := range reports {
+		s.gauge.With(c.labelValues...).Set(float64(c.n) / elapsed.Seconds())
+	}
+}
+
+// reset discards all the counts,
+// and starts a new window for the rates.
+func (s *meterSpace) reset() {
+	now := s.clock.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.last = now
+	s.counts = make(map[string]*meterCount)
+}
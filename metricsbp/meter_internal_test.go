@@ -0,0 +1,101 @@
+package metricsbp
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMeter(t *testing.T) {
+	clock := newFakeClock()
+	st := NewStatsd(context.Background(), StatsdConfig{
+		clock: clock,
+	})
+	defer st.Close()
+
+	write := func(t *testing.T) []string {
+		t.Helper()
+		var sb strings.Builder
+		if _, err := st.WriteTo(&sb); err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSpace(sb.String()), "\n")
+		sort.Strings(lines)
+		return lines
+	}
+
+	meter := st.Meter("requests")
+	meter.With("code", "200").Mark(10)
+	meter.With("code", "200").Mark(20)
+	meter.With("code", "500").Mark(5)
+	clock.Advance(10 * time.Second)
+	expected := []string{
+		"requests,code=200:3.000000|g",
+		"requests,code=500:0.500000|g",
+	}
+	if lines := write(t); !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Expected %q, got %q", expected, lines)
+	}
+
+	// Series without events are reported as 0 once.
+	meter.With("code", "200").Mark(5)
+	clock.Advance(5 * time.Second)
+	expected = []string{
+		"requests,code=200:1.000000|g",
+		"requests,code=500:0.000000|g",
+	}
+	if lines := write(t); !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Expected %q, got %q", expected, lines)
+	}
+
+	clock.Advance(5 * time.Second)
+	expected = []string{
+		"requests,code=200:0.000000|g",
+	}
+	if lines := write(t); !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Expected %q, got %q", expected, lines)
+	}
+
+	// Without time passed nothing is reported.
+	meter.Mark(1)
+	expected = []string{""}
+	if lines := write(t); !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Expected %q, got %q", expected, lines)
+	}
+
+	// The zero Meter is a no-op.
+	Meter{}.With("foo", "bar").Mark(1)
+}
+
+func TestMeterCached(t *testing.T) {
+	clock := newFakeClock()
+	st := NewStatsd(context.Background(), StatsdConfig{
+		clock: clock,
+	})
+	defer st.Close()
+
+	st.Meter("requests").Mark(10)
+	st.Meter("requests").Mark(10)
+	st.Scoped("foo").Meter("requests").Mark(10)
+	if n := len(st.onTick.funcs); n != 2 {
+		t.Errorf("Expected 2 tick callbacks registered, got %d", n)
+	}
+
+	clock.Advance(10 * time.Second)
+	var sb strings.Builder
+	if _, err := st.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(sb.String()), "\n")
+	sort.Strings(lines)
+	expected := []string{
+		"foo.requests:1.000000|g",
+		"requests:2.000000|g",
+	}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Expected %q, got %q", expected, lines)
+	}
+}
@@ -0,0 +1,211 @@
+package metricsbp
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// MaxCachedMetrics is the max number of metrics cached by a Statsd and all the
+// Statsd derived from it.
+//
+// Once it's reached, the metrics not in the cache yet are created without being
+// cached, until the idle ones are dropped on the next write.
+//
+// It also bounds the series kept for the hot path of the metrics mirrored to
+// Prometheus in StatsdConfig, which are never dropped.
+const MaxCachedMetrics = 10000
+
+// metricKind is the kind of a metric cached in the metric cache.
+type metricKind int
+
+const (
+	counterKind metricKind = iota
+	gaugeKind
+	sampledGaugeKind
+	histogramKind
+	timingKind
+	adaptiveHistogramKind
+	adaptiveTimingKind
+	bucketedHistogramKind
+	bucketedTimingKind
+	summaryKind
+	meterKind
+)
+
+// metricKey is the key of a metric in the metric cache.
+//
+// tags is the canonical string of the tags of the Statsd the metric is created
+// from (see tagsKey), so the metrics created from a Statsd derived via WithTags
+// are cached separately,
+// while the ones created from different Statsd in the same derivation tree with
+// the same name and tags share the same metric, and the same series.
+//
+// quantiles is only used by Summary, see quantilesKey.
+type metricKey struct {
+	kind          metricKind
+	name          string
+	tags          string
+	rate          float64
+	reportingRate float64
+	quantiles     string
+}
+
+// tagsKey returns the canonical string of tags,
+// used as the tags in metricKey.
+//
+// The tags are already merged by mergeTags so every key only appears once,
+// but their order depends on the order of the WithTags calls,
+// so the pairs are sorted by key to get the same string for the same tags.
+func tagsKey(tags []string) string {
+	pairs := make([]string, 0, len(tags)/2)
+	for i := 0; i+1 < len(tags); i += 2 {
+		pairs = append(pairs, tags[i]+"\x00"+tags[i+1])
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "\x00")
+}
+
+// metricCache is the cache of the metrics keyed by metricKey.
+//
+// size only counts the entries not pinned.
+type metricCache struct {
+	entries sync.Map // metricKey -> *cachedMetricEntry
+	size    int64
+	pinMu   sync.Mutex
+}
+
+type cachedMetricEntry struct {
+	metric interface{}
+
+	// used is set to 1 every time the entry is returned,
+	// and back to 0 by prune.
+	used int32
+
+	// pinned entries are never dropped, see getPinned.
+	pinned bool
+}
+
+func (e *cachedMetricEntry) markUsed() {
+	if atomic.LoadInt32(&e.used) == 0 {
+		atomic.StoreInt32(&e.used, 1)
+	}
+}
+
+// get returns the metric cached under key,
+// or creates it via create and caches it if there's none,
+// unless the cache is already full.
+func (c *metricCache) get(key metricKey, create func() interface{}) interface{} {
+	if v, ok := c.entries.Load(key); ok {
+		e := v.(*cachedMetricEntry)
+		e.markUsed()
+		return e.metric
+	}
+	if atomic.LoadInt64(&c.size) >= MaxCachedMetrics {
+		return create()
+	}
+	v, loaded := c.entries.LoadOrStore(key, &cachedMetricEntry{
+		metric: create(),
+		used:   1,
+	})
+	e := v.(*cachedMetricEntry)
+	if loaded {
+		e.markUsed()
+	} else {
+		atomic.AddInt64(&c.size, 1)
+	}
+	return e.metric
+}
+
+// getPinned returns the metric cached under key,
+// or creates it via create and caches it if there's none,
+// regardless of MaxCachedMetrics.
+//
+// It's used by the metrics registering tick callbacks (Summary and Meter),
+// which are never dropped by prune or reset,
+// as a metric created again would register the callbacks again,
+// and report the same series twice.
+// Their number is bounded by the metric names and the tags of the Statsd,
+// not by the tags passed into With.
+func (c *metricCache) getPinned(key metricKey, create func() interface{}) interface{} {
+	if v, ok := c.entries.Load(key); ok {
+		return v.(*cachedMetricEntry).metric
+	}
+	// create registers the tick callbacks,
+	// so it must only be called once per key.
+	c.pinMu.Lock()
+	defer c.pinMu.Unlock()
+	if v, ok := c.entries.Load(key); ok {
+		return v.(*cachedMetricEntry).metric
+	}
+	m := create()
+	c.entries.Store(key, &cachedMetricEntry{
+		metric: m,
+		pinned: true,
+	})
+	return m
+}
+
+// prune drops the metrics not used since the previous prune.
+//
+// The metrics dropped are still working,
+// they share the same series with the ones created again later.
+func (c *metricCache) prune() {
+	c.entries.Range(func(k, v interface{}) bool {
+		e := v.(*cachedMetricEntry)
+		if !e.pinned && !atomic.CompareAndSwapInt32(&e.used, 1, 0) {
+			c.delete(k)
+		}
+		return true
+	})
+}
+
+// reset drops all the metrics not pinned.
+func (c *metricCache) reset() {
+	c.entries.Range(func(k, v interface{}) bool {
+		if !v.(*cachedMetricEntry).pinned {
+			c.delete(k)
+		}
+		return true
+	})
+}
+
+func (c *metricCache) delete(key interface{}) {
+	if _, ok := c.entries.LoadAndDelete(key); ok {
+		atomic.AddInt64(&c.size, -1)
+	}
+}
+
+// cachedMetric returns the metric cached under key,
+// or creates it via create and caches it if there's none.
+//
+// The metrics are cached in the state shared by st and all the Statsd derived
+// from it.
+// Metrics with NaN rates are never cached, as the keys would never match.
+func (st *Statsd) cachedMetric(key metricKey, create func() interface{}) interface{} {
+	if math.IsNaN(key.rate) || math.IsNaN(key.reportingRate) {
+		return create()
+	}
+	return st.shared.metrics.get(key, create)
+}
+
+// nameKey returns the key of the metric of the kind and name created from st,
+// for the metrics without explicit sample rates.
+func (st *Statsd) nameKey(kind metricKind, name string) metricKey {
+	return metricKey{
+		kind: kind,
+		name: name,
+		tags: st.tagsKey,
+	}
+}
+
+// rateKey returns the key of the metric of the kind created from st via the
+// -WithRate functions with args.
+func (st *Statsd) rateKey(kind metricKind, args RateArgs) metricKey {
+	key := st.nameKey(kind, args.Name)
+	key.rate = args.Rate
+	key.reportingRate = args.ReportingRate()
+	return key
+}
@@ -0,0 +1,167 @@
+package metricsbp
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestMetricCache(t *testing.T) {
+	st := NewStatsd(context.Background(), StatsdConfig{
+		Tags: Tags{
+			"foo": "bar",
+		},
+	})
+
+	for i := 0; i < 3; i++ {
+		st.Counter("counter").Add(1)
+		st.Gauge("gauge").Set(float64(i))
+	}
+	st.Counter("counter").With("foo", "baz").Add(1)
+	derived := st.WithTags(Tags{"key": "value"})
+	derived.Counter("counter").Add(1)
+	derived.Counter("counter").Add(1)
+	st.CounterWithRate(RateArgs{
+		Name: "counter",
+		Rate: 1,
+	}).Add(1)
+
+	keys := cachedMetrics(st)
+	// counter and gauge of st, and counter of derived.
+	if keys != 3 {
+		t.Errorf("Expected 3 cached metrics, got %d", keys)
+	}
+
+	var sb strings.Builder
+	if _, err := st.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(sb.String(), "\n"), "\n")
+	sort.Strings(lines)
+	expected := []string{
+		"counter,foo=bar,key=value:2.000000|c",
+		"counter,foo=bar:4.000000|c",
+		"counter,foo=baz:1.000000|c",
+		"gauge,foo=bar:2.000000|g",
+	}
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected %q, got %q", expected, lines)
+	}
+}
+
+func TestMetricCacheDerivationTree(t *testing.T) {
+	st := NewStatsd(context.Background(), StatsdConfig{})
+
+	// The same name and tags from different paths of the derivation tree.
+	st.WithTags(Tags{"a": "1"}).WithTags(Tags{"b": "2"}).Counter("scope.counter").Add(1)
+	st.WithTags(Tags{"b": "2"}).WithTags(Tags{"a": "1"}).Counter("scope.counter").Add(1)
+	st.Scoped("scope").WithTags(Tags{"a": "1", "b": "2"}).Counter("counter").Add(1)
+	st.WithTags(Tags{"a": "1", "b": "2"}).Scoped("scope").Counter("counter").Add(1)
+
+	keys := cachedMetrics(st)
+	if keys != 1 {
+		t.Errorf("Expected 1 cached metric, got %d", keys)
+	}
+
+	var sb strings.Builder
+	if _, err := st.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	const expected = "scope.counter,a=1,b=2:4.000000|c\n"
+	if sb.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, sb.String())
+	}
+}
+
+func TestTagsKey(t *testing.T) {
+	for _, c := range []struct {
+		label string
+		a, b  []string
+		equal bool
+	}{
+		{
+			label: "order",
+			a:     []string{"a", "1", "b", "2"},
+			b:     []string{"b", "2", "a", "1"},
+			equal: true,
+		},
+		{
+			label: "values",
+			a:     []string{"a", "1", "b", "2"},
+			b:     []string{"a", "2", "b", "1"},
+		},
+		{
+			label: "pairs",
+			a:     []string{"a", "b"},
+			b:     []string{"b", "a"},
+		},
+	} {
+		t.Run(c.label, func(t *testing.T) {
+			if equal := tagsKey(c.a) == tagsKey(c.b); equal != c.equal {
+				t.Errorf("Expected tagsKey(%q) == tagsKey(%q) to be %v", c.a, c.b, c.equal)
+			}
+		})
+	}
+}
+
+// cachedMetrics returns the number of metrics in the cache of st.
+func cachedMetrics(st *Statsd) int {
+	var n int
+	st.shared.metrics.entries.Range(func(_, _ interface{}) bool {
+		n++
+		return true
+	})
+	return n
+}
+
+func TestMetricCachePrune(t *testing.T) {
+	st := NewStatsd(context.Background(), StatsdConfig{})
+
+	st.Counter("idle").Add(1)
+	st.Counter("used").Add(1)
+	st.WriteTo(ioutil.Discard)
+	if got := cachedMetrics(st); got != 2 {
+		t.Errorf("Expected 2 cached metrics after the first write, got %d", got)
+	}
+
+	st.Counter("used").Add(1)
+	st.WriteTo(ioutil.Discard)
+	if got := cachedMetrics(st); got != 1 {
+		t.Errorf("Expected the idle metric to be dropped, got %d cached metrics", got)
+	}
+
+	st.Counter("used").Add(1)
+	st.Reset()
+	if got := cachedMetrics(st); got != 0 {
+		t.Errorf("Expected no cached metrics after Reset, got %d", got)
+	}
+}
+
+func TestMetricCacheMax(t *testing.T) {
+	st := NewStatsd(context.Background(), StatsdConfig{})
+
+	for i := 0; i < MaxCachedMetrics+1; i++ {
+		st.WithTags(Tags{"id": strconv.Itoa(i)}).Counter("counter").Add(1)
+	}
+	if got := cachedMetrics(st); got != MaxCachedMetrics {
+		t.Errorf("Expected %d cached metrics, got %d", MaxCachedMetrics, got)
+	}
+	if got, want := atomic.LoadInt64(&st.shared.metrics.size), int64(MaxCachedMetrics); got != want {
+		t.Errorf("Expected size %d, got %d", want, got)
+	}
+
+	// The metric not cached still works.
+	var sb strings.Builder
+	if _, err := st.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	expected := fmt.Sprintf("counter,id=%d:1.000000|c\n", MaxCachedMetrics)
+	if !strings.Contains(sb.String(), expected) {
+		t.Errorf("Expected %q to be written", expected)
+	}
+}
@@ -0,0 +1,39 @@
+package metricsbp
+
+import (
+	"github.com/go-kit/kit/metrics"
+)
+
+// Metrics is the interface of the functions to create metrics,
+// implemented by *Statsd.
+//
+// It's deliberately the minimal set of the functions the middlewares and the
+// other helpers reporting metrics in baseplate.go need,
+// which all take Metrics instead of *Statsd,
+// so they can be tested with fakes without the real reporting machinery.
+// The other helpers of *Statsd (for example WithTags, Time and ErrorCounter)
+// are built on top of these functions,
+// so they are not part of it to keep the fakes small.
+// *Statsd stays the canonical implementation.
+type Metrics interface {
+	Counter(name string) metrics.Counter
+	Gauge(name string) metrics.Gauge
+	Histogram(name string) metrics.Histogram
+	Timing(name string) metrics.Histogram
+	GaugeFunc(name string, f func() float64) (cancel func())
+	GaugeFuncWithTags(name string, tags Tags, f func() float64) (cancel func())
+}
+
+// MetricsOrM returns m, or a Metrics falling back to M if m is nil.
+//
+// The fallback happens when the metrics are created,
+// same as a nil *Statsd,
+// so the Statsd set via SetM later is still used.
+func MetricsOrM(m Metrics) Metrics {
+	if m == nil {
+		return (*Statsd)(nil)
+	}
+	return m
+}
+
+var _ Metrics = (*Statsd)(nil)
@@ -0,0 +1,33 @@
+package metricsbp_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/reddit/baseplate.go/metricsbp"
+)
+
+func TestMetricsOrM(t *testing.T) {
+	prev := metricsbp.GetM()
+	defer metricsbp.SetM(prev)
+
+	st := metricsbp.NewStatsd(context.Background(), metricsbp.StatsdConfig{})
+	if got := metricsbp.MetricsOrM(st); got != metricsbp.Metrics(st) {
+		t.Errorf("Expected %p, got %v", st, got)
+	}
+
+	m := metricsbp.MetricsOrM(nil)
+	// The fallback happens when the metrics are created,
+	// so SetM after MetricsOrM is still honored.
+	metricsbp.SetM(st)
+	m.Counter("fallback").Add(1)
+
+	var sb strings.Builder
+	if _, err := st.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(sb.String(), "fallback:1.000000|c") {
+		t.Errorf("Expected nil fallback to use the Statsd from SetM, got %q", sb.String())
+	}
+}
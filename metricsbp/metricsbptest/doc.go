@@ -0,0 +1,3 @@
+// Package metricsbptest contains objects and utility methods to aid with
+// testing code emitting metrics via metricsbp.
+package metricsbptest
@@ -0,0 +1,265 @@
+package metricsbptest
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/reddit/baseplate.go/metricsbp"
+)
+
+// internalMetricsPath is the path shared by all the metrics metricsbp reports
+// about itself, which are excluded from RecordingStatsd.
+const internalMetricsPath = "baseplate.metricsbp."
+
+// RecordingStatsd is a *metricsbp.Statsd that records all the metrics lines
+// emitted in memory, to be checked in tests.
+//
+// Please use NewRecordingStatsd to create it.
+//
+// The metrics metricsbp reports about itself
+// (with "baseplate.metricsbp." in their paths) are not recorded.
+type RecordingStatsd struct {
+	*metricsbp.Statsd
+
+	tb testing.TB
+
+	mu    sync.Mutex
+	buf   bytes.Buffer
+	lines []string
+}
+
+// NewRecordingStatsd creates a RecordingStatsd.
+//
+// cfg.Writer will be overridden, and cfg.Address will be ignored.
+// The RecordingStatsd will be closed automatically when the test finishes.
+func NewRecordingStatsd(tb testing.TB, cfg metricsbp.StatsdConfig) *RecordingStatsd {
+	r := &RecordingStatsd{
+		tb: tb,
+	}
+	cfg.Writer = (*recordingWriter)(r)
+	r.Statsd = metricsbp.NewStatsd(context.Background(), cfg)
+	tb.Cleanup(func() {
+		r.Statsd.Close()
+	})
+	return r
+}
+
+type recordingWriter RecordingStatsd
+
+func (w *recordingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Write(p)
+}
+
+// Lines flushes the metrics synchronously,
+// and returns all the metrics lines recorded so far.
+func (r *RecordingStatsd) Lines() []string {
+	r.tb.Helper()
+
+	if err := r.Statsd.Flush(context.Background()); err != nil {
+		r.tb.Fatalf("metricsbptest: failed to flush metrics: %v", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, line := range strings.Split(r.buf.String(), "\n") {
+		if line == "" || strings.Contains(line, internalMetricsPath) {
+			continue
+		}
+		r.lines = append(r.lines, line)
+	}
+	r.buf.Reset()
+
+	lines := make([]string, len(r.lines))
+	copy(lines, r.lines)
+	return lines
+}
+
+// Reset discards all the metrics recorded so far,
+// and the ones accumulated in the underlying Statsd but not yet flushed
+// (see metricsbp.Statsd.Reset).
+//
+// It's useful when the same RecordingStatsd is shared between the cases of a
+// table-driven test,
+// so that the counts from a previous case don't leak into the next one.
+func (r *RecordingStatsd) Reset() {
+	r.Statsd.Reset()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.buf.Reset()
+	r.lines = nil
+}
+
+// Metrics flushes the metrics synchronously,
+// and returns all the metrics recorded so far, parsed.
+func (r *RecordingStatsd) Metrics() []Metric {
+	r.tb.Helper()
+
+	lines := r.Lines()
+	metrics := make([]Metric, 0, len(lines))
+	for _, line := range lines {
+		m, err := ParseLine(line)
+		if err != nil {
+			r.tb.Fatalf("metricsbptest: %v", err)
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics
+}
+
+// AssertCounter asserts that a counter with the name (including Prefix) and
+// tags was emitted, and returns the sum of all its recorded values.
+//
+// The counter matches as long as it has all the tags passed in,
+// it could also have other tags.
+func (r *RecordingStatsd) AssertCounter(name string, tags metricsbp.Tags) float64 {
+	r.tb.Helper()
+
+	var sum float64
+	var found bool
+	for _, m := range r.Metrics() {
+		if m.Type == TypeCounter && m.Name == name && m.HasTags(tags) {
+			found = true
+			sum += m.Value
+		}
+	}
+	if !found {
+		r.tb.Errorf(
+			"metricsbptest: counter %q with tags %v not found in %q",
+			name,
+			tags,
+			r.lines,
+		)
+	}
+	return sum
+}
+
+// The metrics types in statsd format.
+const (
+	TypeCounter   = "c"
+	TypeGauge     = "g"
+	TypeTiming    = "ms"
+	TypeHistogram = "h"
+	TypeSet       = "s"
+)
+
+// Metric is a parsed statsd metrics line.
+type Metric struct {
+	// The name of the metric, including the prefix.
+	Name string
+
+	// The tags of the metric.
+	Tags metricsbp.Tags
+
+	// The value of the metric.
+	//
+	// For sets it's always 0, use RawValue instead.
+	Value float64
+
+	// The raw value string of the metric.
+	RawValue string
+
+	// The type of the metric, e.g. TypeCounter.
+	Type string
+
+	// The sample rate of the metric, 1 when it's not reported.
+	Rate float64
+}
+
+// HasTags returns true if the metric has all the tags.
+func (m Metric) HasTags(tags metricsbp.Tags) bool {
+	for k, v := range tags {
+		if value, ok := m.Tags[k]; !ok || value != v {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseLine parses a statsd metrics line in Influxstatsd or DogStatsD format.
+func ParseLine(line string) (Metric, error) {
+	m := Metric{
+		Rate: 1,
+	}
+	invalid := func() (Metric, error) {
+		return Metric{}, &InvalidLineError{Line: line}
+	}
+
+	pipe := strings.Index(line, "|")
+	if pipe < 0 {
+		return invalid()
+	}
+	colon := strings.LastIndex(line[:pipe], ":")
+	if colon < 0 {
+		return invalid()
+	}
+	nameAndTags := line[:colon]
+	parts := strings.Split(line[colon+1:], "|")
+	if len(parts) < 2 {
+		return invalid()
+	}
+
+	tags := strings.Split(nameAndTags, ",")
+	m.Name = tags[0]
+	if len(tags) > 1 {
+		m.Tags = make(metricsbp.Tags, len(tags)-1)
+		for _, tag := range tags[1:] {
+			kv := strings.SplitN(tag, "=", 2)
+			if len(kv) != 2 {
+				return invalid()
+			}
+			m.Tags[kv[0]] = kv[1]
+		}
+	}
+
+	m.RawValue = parts[0]
+	m.Type = parts[1]
+	if m.Type != TypeSet {
+		value, err := strconv.ParseFloat(m.RawValue, 64)
+		if err != nil {
+			return invalid()
+		}
+		m.Value = value
+	}
+	for _, part := range parts[2:] {
+		switch {
+		case strings.HasPrefix(part, "@"):
+			rate, err := strconv.ParseFloat(part[1:], 64)
+			if err != nil {
+				return invalid()
+			}
+			m.Rate = rate
+		case strings.HasPrefix(part, "#"):
+			// DogStatsD tags.
+			if m.Tags == nil {
+				m.Tags = make(metricsbp.Tags)
+			}
+			for _, tag := range strings.Split(part[1:], ",") {
+				kv := strings.SplitN(tag, ":", 2)
+				if len(kv) != 2 {
+					return invalid()
+				}
+				m.Tags[kv[0]] = kv[1]
+			}
+		default:
+			return invalid()
+		}
+	}
+	return m, nil
+}
+
+// InvalidLineError is the error returned by ParseLine when the line is not a
+// valid statsd metrics line.
+type InvalidLineError struct {
+	Line string
+}
+
+func (e *InvalidLineError) Error() string {
+	return "invalid statsd line: " + strconv.Quote(e.Line)
+}
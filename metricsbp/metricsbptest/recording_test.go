@@ -0,0 +1,167 @@
+package metricsbptest_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/reddit/baseplate.go/metricsbp"
+	"github.com/reddit/baseplate.go/metricsbp/metricsbptest"
+)
+
+func TestRecordingStatsd(t *testing.T) {
+	st := metricsbptest.NewRecordingStatsd(t, metricsbp.StatsdConfig{
+		Prefix: "prefix",
+		Tags: metricsbp.Tags{
+			"foo": "bar",
+		},
+	})
+	st.Counter("counter").With("key", "value").Add(1)
+	st.Counter("counter").With("key", "value").Add(2)
+	st.Gauge("gauge").Set(1)
+
+	if sum := st.AssertCounter("prefix.counter", metricsbp.Tags{"key": "value"}); sum != 3 {
+		t.Errorf("Expected counter sum 3, got %v", sum)
+	}
+	if sum := st.AssertCounter("prefix.counter", metricsbp.Tags{"foo": "bar"}); sum != 3 {
+		t.Errorf("Expected counter sum 3, got %v", sum)
+	}
+
+	// Lines should accumulate across flushes.
+	st.Counter("counter").With("key", "value").Add(4)
+	if sum := st.AssertCounter("prefix.counter", nil); sum != 7 {
+		t.Errorf("Expected counter sum 7, got %v", sum)
+	}
+	for _, line := range st.Lines() {
+		m, err := metricsbptest.ParseLine(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.Name != "prefix.counter" && m.Name != "prefix.gauge" {
+			t.Errorf("Unexpected line %q", line)
+		}
+	}
+}
+
+// failureRecorder is a testing.TB recording the Errorf calls instead of
+// failing the test.
+//
+// Other than Errorf, it forwards everything to the embedded testing.TB.
+type failureRecorder struct {
+	testing.TB
+
+	errors []string
+}
+
+func (r *failureRecorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestRecordingStatsdAssertCounterFailure(t *testing.T) {
+	recorder := &failureRecorder{TB: t}
+	st := metricsbptest.NewRecordingStatsd(recorder, metricsbp.StatsdConfig{})
+	st.Counter("counter").Add(1)
+	st.AssertCounter("counter", metricsbp.Tags{"key": "value"})
+	if len(recorder.errors) != 1 {
+		t.Errorf("Expected AssertCounter to fail once on missing tags, got %q", recorder.errors)
+	}
+}
+
+func TestRecordingStatsdReset(t *testing.T) {
+	st := metricsbptest.NewRecordingStatsd(t, metricsbp.StatsdConfig{})
+
+	// Recorded and flushed.
+	st.Counter("counter").Add(1)
+	if sum := st.AssertCounter("counter", nil); sum != 1 {
+		t.Errorf("Expected counter sum 1, got %v", sum)
+	}
+	// Accumulated but not flushed yet.
+	st.Counter("counter").Add(2)
+	st.Reset()
+	if lines := st.Lines(); len(lines) != 0 {
+		t.Errorf("Expected no lines after Reset, got %q", lines)
+	}
+
+	st.Counter("counter").Add(4)
+	if sum := st.AssertCounter("counter", nil); sum != 4 {
+		t.Errorf("Expected counter sum 4, got %v", sum)
+	}
+}
+
+func TestParseLine(t *testing.T) {
+	for _, c := range []struct {
+		line     string
+		expected metricsbptest.Metric
+		err      bool
+	}{
+		{
+			line: "foo:1.000000|c",
+			expected: metricsbptest.Metric{
+				Name:     "foo",
+				Value:    1,
+				RawValue: "1.000000",
+				Type:     metricsbptest.TypeCounter,
+				Rate:     1,
+			},
+		},
+		{
+			line: "foo,a=b,c=d:2.5|ms|@0.100000",
+			expected: metricsbptest.Metric{
+				Name:     "foo",
+				Tags:     metricsbp.Tags{"a": "b", "c": "d"},
+				Value:    2.5,
+				RawValue: "2.5",
+				Type:     metricsbptest.TypeTiming,
+				Rate:     0.1,
+			},
+		},
+		{
+			line: "foo:user|s",
+			expected: metricsbptest.Metric{
+				Name:     "foo",
+				RawValue: "user",
+				Type:     metricsbptest.TypeSet,
+				Rate:     1,
+			},
+		},
+		{
+			line: "foo:2|h|@0.5|#a:b,c:d",
+			expected: metricsbptest.Metric{
+				Name:     "foo",
+				Tags:     metricsbp.Tags{"a": "b", "c": "d"},
+				Value:    2,
+				RawValue: "2",
+				Type:     metricsbptest.TypeHistogram,
+				Rate:     0.5,
+			},
+		},
+		{
+			line: "foo",
+			err:  true,
+		},
+		{
+			line: "foo:1|c|#a",
+			err:  true,
+		},
+		{
+			line: "foo,a:1|c",
+			err:  true,
+		},
+	} {
+		t.Run(c.line, func(t *testing.T) {
+			m, err := metricsbptest.ParseLine(c.line)
+			if c.err {
+				if err == nil {
+					t.Errorf("Expected error, got %#v", m)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(m, c.expected) {
+				t.Errorf("Expected %#v, got %#v", c.expected, m)
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+// Package otelprovider provides a metricsbp.Provider sending the metrics
+// through an OpenTelemetry Meter instead of statsd.
+//
+// It's a separate module so that the OpenTelemetry dependencies (and the newer
+// Go version they require) are only pulled in by the services using it.
+//
+// Example:
+//
+//     exporter, err := otlpmetricgrpc.New(ctx)
+//     if err != nil {
+//       log.Fatal(err)
+//     }
+//     mp := sdkmetric.NewMeterProvider(
+//       sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exporter)),
+//     )
+//     metricsbp.SetM(metricsbp.NewStatsd(ctx, metricsbp.StatsdConfig{
+//       Prefix:   "my-service",
+//       Provider: otelprovider.New(mp.Meter("my-service"), mp.Shutdown),
+//     }))
+package otelprovider
+
+import (
+	"context"
+	"sync"
+
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/discard"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/reddit/baseplate.go/metricsbp"
+)
+
+// Provider is a metricsbp.Provider on top of an OpenTelemetry Meter.
+//
+// The metrics that cannot be created by the Meter (for example with invalid
+// instrument names) are discarded,
+// with the errors sent to the OpenTelemetry global error handler.
+type Provider struct {
+	meter    metric.Meter
+	shutdown func(context.Context) error
+
+	mu     sync.Mutex
+	gauges map[string]*gauge
+}
+
+// gauge is the Float64ObservableGauge of a name,
+// with the values reported by its callback.
+type gauge struct {
+	space        *gaugeSpace
+	registration metric.Registration
+}
+
+var _ metricsbp.Provider = (*Provider)(nil)
+
+// New creates a Provider creating the instruments from meter.
+//
+// shutdown, if non-nil, is called by Stop,
+// usually it's the Shutdown of the MeterProvider meter is from,
+// to flush the metrics not yet exported.
+func New(meter metric.Meter, shutdown func(context.Context) error) *Provider {
+	return &Provider{
+		meter:    meter,
+		shutdown: shutdown,
+		gauges:   make(map[string]*gauge),
+	}
+}
+
+// NewCounter returns a metrics.Counter adding to a Float64Counter.
+func (p *Provider) NewCounter(name string) metrics.Counter {
+	counter, err := p.meter.Float64Counter(name)
+	if err != nil {
+		otel.Handle(err)
+		return discard.NewCounter()
+	}
+	return otelCounter{counter: counter}
+}
+
+// NewGauge returns a metrics.Gauge keeping the last value per tag combination,
+// reported by the callback of a Float64ObservableGauge.
+//
+// The Float64ObservableGauge and its callback are only registered once per
+// name, the gauges returned for the same name share the same values.
+func (p *Provider) NewGauge(name string) metrics.Gauge {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if g, ok := p.gauges[name]; ok {
+		return otelGauge{space: g.space}
+	}
+
+	gs := &gaugeSpace{
+		values: make(map[attribute.Distinct]*gaugeValue),
+	}
+	instrument, err := p.meter.Float64ObservableGauge(name)
+	if err != nil {
+		otel.Handle(err)
+		return discard.NewGauge()
+	}
+	registration, err := p.meter.RegisterCallback(
+		func(ctx context.Context, o metric.Observer) error {
+			return gs.observe(ctx, instrument, o)
+		},
+		instrument,
+	)
+	if err != nil {
+		otel.Handle(err)
+		return discard.NewGauge()
+	}
+	p.gauges[name] = &gauge{
+		space:        gs,
+		registration: registration,
+	}
+	return otelGauge{space: gs}
+}
+
+// NewHistogram returns a metrics.Histogram recording to a Float64Histogram.
+//
+// buckets is ignored,
+// the buckets are configured by the views of the MeterProvider instead.
+func (p *Provider) NewHistogram(name string, buckets int) metrics.Histogram {
+	histogram, err := p.meter.Float64Histogram(name)
+	if err != nil {
+		otel.Handle(err)
+		return discard.NewHistogram()
+	}
+	return otelHistogram{histogram: histogram}
+}
+
+// Stop unregisters the callbacks of the gauges,
+// and calls the shutdown function passed into New, if any.
+func (p *Provider) Stop() {
+	p.mu.Lock()
+	for name, g := range p.gauges {
+		if err := g.registration.Unregister(); err != nil {
+			otel.Handle(err)
+		}
+		delete(p.gauges, name)
+	}
+	p.mu.Unlock()
+
+	if p.shutdown == nil {
+		return
+	}
+	if err := p.shutdown(context.Background()); err != nil {
+		otel.Handle(err)
+	}
+}
+
+// attrs holds the tags (as key-value pairs) of a metric,
+// and the attribute set converted from them.
+type attrs struct {
+	tags []string
+	set  attribute.Set
+}
+
+// with returns the attrs with tagValues appended.
+func (a attrs) with(tagValues []string) attrs {
+	tags := make([]string, 0, len(a.tags)+len(tagValues))
+	tags = append(tags, a.tags...)
+	tags = append(tags, tagValues...)
+	kvs := make([]attribute.KeyValue, 0, len(tags)/2)
+	for i := 0; i+1 < len(tags); i += 2 {
+		kvs = append(kvs, attribute.String(tags[i], tags[i+1]))
+	}
+	return attrs{
+		tags: tags,
+		set:  attribute.NewSet(kvs...),
+	}
+}
+
+type otelCounter struct {
+	counter metric.Float64Counter
+	attrs   attrs
+}
+
+func (c otelCounter) With(tagValues ...string) metrics.Counter {
+	c.attrs = c.attrs.with(tagValues)
+	return c
+}
+
+func (c otelCounter) Add(delta float64) {
+	// OpenTelemetry counters are monotonic.
+	if delta < 0 {
+		return
+	}
+	c.counter.Add(context.Background(), delta, metric.WithAttributeSet(c.attrs.set))
+}
+
+type otelHistogram struct {
+	histogram metric.Float64Histogram
+	attrs     attrs
+}
+
+func (h otelHistogram) With(tagValues ...string) metrics.Histogram {
+	h.attrs = h.attrs.with(tagValues)
+	return h
+}
+
+func (h otelHistogram) Observe(value float64) {
+	h.histogram.Record(context.Background(), value, metric.WithAttributeSet(h.attrs.set))
+}
+
+// gaugeSpace holds the last values of all the attribute sets of a gauge.
+type gaugeSpace struct {
+	mu     sync.Mutex
+	values map[attribute.Distinct]*gaugeValue
+}
+
+type gaugeValue struct {
+	set   attribute.Set
+	value float64
+}
+
+// update applies f to the value of set.
+func (gs *gaugeSpace) update(set attribute.Set, f func(float64) float64) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	key := set.Equivalent()
+	v := gs.values[key]
+	if v == nil {
+		v = &gaugeValue{set: set}
+		gs.values[key] = v
+	}
+	v.value = f(v.value)
+}
+
+// observe reports the last values of all the attribute sets to instrument.
+func (gs *gaugeSpace) observe(_ context.Context, instrument metric.Float64Observable, o metric.Observer) error {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	for _, v := range gs.values {
+		o.ObserveFloat64(instrument, v.value, metric.WithAttributeSet(v.set))
+	}
+	return nil
+}
+
+type otelGauge struct {
+	space *gaugeSpace
+	attrs attrs
+}
+
+func (g otelGauge) With(tagValues ...string) metrics.Gauge {
+	g.attrs = g.attrs.with(tagValues)
+	return g
+}
+
+func (g otelGauge) Set(value float64) {
+	g.space.update(g.attrs.set, func(float64) float64 { return value })
+}
+
+func (g otelGauge) Add(delta float64) {
+	g.space.update(g.attrs.set, func(v float64) float64 { return v + delta })
+}
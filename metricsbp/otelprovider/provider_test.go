@@ -0,0 +1,112 @@
+package otelprovider_test
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/reddit/baseplate.go/metricsbp"
+	"github.com/reddit/baseplate.go/metricsbp/otelprovider"
+)
+
+func TestProvider(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	st := metricsbp.NewStatsd(context.Background(), metricsbp.StatsdConfig{
+		Prefix:   "svc",
+		Provider: otelprovider.New(mp.Meter("test"), mp.Shutdown),
+		Tags: metricsbp.Tags{
+			"foo": "bar",
+		},
+	})
+
+	st.Counter("counter").With("key", "value").Add(1)
+	st.Counter("counter").With("key", "value").Add(2)
+	st.Gauge("gauge").Set(2)
+	st.Gauge("gauge").Add(1)
+	st.Histogram("histogram").Observe(3)
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]metricdata.Aggregation)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			got[m.Name] = m.Data
+		}
+	}
+
+	counter, ok := got["svc.counter"].(metricdata.Sum[float64])
+	if !ok || len(counter.DataPoints) != 1 {
+		t.Fatalf("Expected 1 counter data point, got %#v", got["svc.counter"])
+	}
+	dp := counter.DataPoints[0]
+	if dp.Value != 3 {
+		t.Errorf("Expected counter 3, got %v", dp.Value)
+	}
+	expected := attribute.NewSet(attribute.String("foo", "bar"), attribute.String("key", "value"))
+	if !dp.Attributes.Equals(&expected) {
+		t.Errorf("Expected attributes %v, got %v", expected.Encoded(attribute.DefaultEncoder()), dp.Attributes.Encoded(attribute.DefaultEncoder()))
+	}
+
+	gauge, ok := got["svc.gauge"].(metricdata.Gauge[float64])
+	if !ok || len(gauge.DataPoints) != 1 || gauge.DataPoints[0].Value != 3 {
+		t.Errorf("Expected gauge 3, got %#v", got["svc.gauge"])
+	}
+
+	histogram, ok := got["svc.histogram"].(metricdata.Histogram[float64])
+	if !ok || len(histogram.DataPoints) != 1 || histogram.DataPoints[0].Sum != 3 {
+		t.Errorf("Expected histogram with sum 3, got %#v", got["svc.histogram"])
+	}
+
+	if err := st.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := reader.Collect(context.Background(), &rm); err == nil {
+		t.Error("Expected the MeterProvider to be shut down by Close")
+	}
+}
+
+func TestProviderGauge(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	defer mp.Shutdown(context.Background())
+	p := otelprovider.New(mp.Meter("test"), nil)
+
+	collect := func(t *testing.T) []metricdata.DataPoint[float64] {
+		t.Helper()
+		var rm metricdata.ResourceMetrics
+		if err := reader.Collect(context.Background(), &rm); err != nil {
+			t.Fatal(err)
+		}
+		var points []metricdata.DataPoint[float64]
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				if m.Name != "gauge" {
+					continue
+				}
+				points = append(points, m.Data.(metricdata.Gauge[float64]).DataPoints...)
+			}
+		}
+		return points
+	}
+
+	// Created again for the same name, for example after the metric cache of
+	// metricsbp is pruned.
+	p.NewGauge("gauge").Set(1)
+	p.NewGauge("gauge").Set(2)
+	p.NewGauge("gauge").Add(1)
+	points := collect(t)
+	if len(points) != 1 || points[0].Value != 3 {
+		t.Errorf("Expected a single data point of 3, got %#v", points)
+	}
+
+	p.Stop()
+	if points := collect(t); len(points) != 0 {
+		t.Errorf("Expected no data points after Stop, got %#v", points)
+	}
+}
@@ -0,0 +1,60 @@
+package metricsbp
+
+import (
+	"sync/atomic"
+)
+
+// DefaultMaxPausedObservations is the MaxPausedObservations to be used when
+// it's 0 in StatsdConfig.
+const DefaultMaxPausedObservations = 1000000
+
+// Pause pauses the background reporting goroutine,
+// so it stops writing the metrics to the statsd collector on every tick,
+// for example during a maintenance window or a load test.
+//
+// The metrics are still accumulated in memory while paused,
+// up to MaxPausedObservations in StatsdConfig,
+// and they will be written on Resume.
+// Explicit Flush and Close calls still write the metrics while paused.
+//
+// Pause and Resume affect the Statsd and all the ones derived from it (or the
+// one it's derived from) via WithTags and WithSampleRate.
+// Without a background reporting goroutine it has no effect other than the
+// result of Paused.
+func (st *Statsd) Pause() {
+	st = st.fallback()
+	if atomic.CompareAndSwapInt32(&st.shared.paused, 0, 1) {
+		st.logger.Log(
+			"during", "metricsbp.Statsd.Pause",
+			"msg", "paused the background reporting goroutine",
+		)
+	}
+}
+
+// Resume resumes the background reporting goroutine paused by Pause,
+// and writes the metrics accumulated while paused immediately,
+// without waiting for the next tick.
+//
+// It's a no-op when st is not paused.
+func (st *Statsd) Resume() {
+	st = st.fallback()
+	if !atomic.CompareAndSwapInt32(&st.shared.paused, 1, 0) {
+		return
+	}
+	st.logger.Log(
+		"during", "metricsbp.Statsd.Resume",
+		"msg", "resumed the background reporting goroutine",
+	)
+	st.triggerFlush()
+}
+
+// Paused returns whether st is paused by Pause.
+func (st *Statsd) Paused() bool {
+	return st.fallback().paused()
+}
+
+// paused is Paused without the fallback,
+// as it's used by NewStatsd during the initialization of M.
+func (st *Statsd) paused() bool {
+	return atomic.LoadInt32(&st.shared.paused) == 1
+}
@@ -0,0 +1,83 @@
+package metricsbp
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPauseResume(t *testing.T) {
+	const interval = time.Minute
+
+	for _, c := range []struct {
+		label     string
+		maxPaused int
+		expected  string
+	}{
+		{
+			label:    "default",
+			expected: "counter:3.000000|c\n",
+		},
+		{
+			label:     "discarded",
+			maxPaused: 2,
+		},
+	} {
+		t.Run(c.label, func(t *testing.T) {
+			clk := newFakeClock()
+			w := notifyWriter{writes: make(chan string, 10)}
+			st := NewStatsd(context.Background(), StatsdConfig{
+				Writer:                w,
+				ReportingInterval:     interval,
+				MaxPausedObservations: c.maxPaused,
+				clock:                 clk,
+			})
+			defer st.Close()
+
+			select {
+			case <-clk.created:
+			case <-time.After(time.Second * 5):
+				t.Fatal("The reporter did not create the ticker")
+			}
+
+			st.WithTags(nil).Pause()
+			if !st.Paused() {
+				t.Fatal("Expected Paused to be true after Pause")
+			}
+			for i := 0; i < 3; i++ {
+				st.Counter("counter").Add(1)
+			}
+			clk.Advance(interval)
+			select {
+			case got := <-w.writes:
+				t.Fatalf("Expected no flush while paused, got %q", got)
+			case <-time.After(time.Millisecond * 100):
+			}
+
+			st.Resume()
+			if st.Paused() {
+				t.Fatal("Expected Paused to be false after Resume")
+			}
+			if c.expected == "" {
+				// Everything was discarded so there's nothing left to write.
+				select {
+				case got := <-w.writes:
+					if strings.Contains(got, "counter:") {
+						t.Errorf("Expected counter to be discarded, got %q", got)
+					}
+				case <-time.After(time.Millisecond * 100):
+				}
+				return
+			}
+			select {
+			case got := <-w.writes:
+				if !strings.Contains(got, c.expected) {
+					t.Errorf("Expected %q in %q", c.expected, got)
+				}
+			case <-time.After(time.Second * 5):
+				t.Fatal("Expected a flush on Resume, got none")
+			}
+		})
+	}
+}
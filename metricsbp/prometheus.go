@@ -0,0 +1,450 @@
+package metricsbp
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	kitlog "github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/multi"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// PrometheusConfig is the config used in StatsdConfig to also export the
+// metrics to Prometheus.
+type PrometheusConfig struct {
+	// Registry is the Prometheus registry the metrics will be registered to.
+	//
+	// When it's nil (default), a new registry will be created and used.
+	Registry *prometheus.Registry
+}
+
+// PrometheusHandler returns the http.Handler serving the metrics created from
+// this Statsd in Prometheus format.
+//
+// When Prometheus in StatsdConfig is nil,
+// it returns an http.Handler that always responds with 404.
+func (st *Statsd) PrometheusHandler() http.Handler {
+	st = st.fallback()
+	if st.prometheus == nil {
+		return http.NotFoundHandler()
+	}
+	return promhttp.HandlerFor(st.prometheus.registry, promhttp.HandlerOpts{})
+}
+
+// multiProvider sends the metrics to both the statsd provider and Prometheus.
+type multiProvider struct {
+	provider
+
+	prometheus *prometheusProvider
+}
+
+func (m multiProvider) NewCounter(name string, sampleRate float64) metrics.Counter {
+	return multi.NewCounter(
+		m.provider.NewCounter(name, sampleRate),
+		m.prometheus.newCounter(name, sampleRate),
+	)
+}
+
+func (m multiProvider) NewGauge(name string) metrics.Gauge {
+	return multi.NewGauge(
+		m.provider.NewGauge(name),
+		m.prometheus.newGauge(name),
+	)
+}
+
+func (m multiProvider) NewTiming(name string, sampleRate float64) metrics.Histogram {
+	return multi.NewHistogram(
+		m.provider.NewTiming(name, sampleRate),
+		m.prometheus.newHistogram(name),
+	)
+}
+
+func (m multiProvider) NewHistogram(name string, sampleRate float64) metrics.Histogram {
+	return multi.NewHistogram(
+		m.provider.NewHistogram(name, sampleRate),
+		m.prometheus.newHistogram(name),
+	)
+}
+
+// The kinds of the Prometheus metrics.
+const (
+	promCounterKind   = "counter"
+	promGaugeKind     = "gauge"
+	promHistogramKind = "histogram"
+)
+
+// promVec is a Prometheus metric vector registered by prometheusProvider.
+type promVec struct {
+	kind      string
+	labels    []string
+	collector prometheus.Collector
+
+	// extraLogged is set after the first time extra labels are dropped.
+	extraLogged bool
+}
+
+// prometheusProvider creates the Prometheus metric vectors lazily,
+// with the label names from the first time the metric is used.
+type prometheusProvider struct {
+	prefix   string
+	registry *prometheus.Registry
+	buckets  func(name string) []float64
+	logger   kitlog.Logger
+
+	mu   sync.Mutex
+	vecs map[string]*promVec
+
+	// series are the *promSeries keyed by seriesKey,
+	// so the hot path of the metrics never takes mu.
+	//
+	// It holds at most MaxCachedMetrics series, see seriesOf.
+	series     sync.Map
+	seriesSize int64
+}
+
+// newPrometheusProvider creates a prometheusProvider.
+//
+// buckets returns the histogram buckets for a metric name (without prefix),
+// or nil to use prometheus.DefBuckets.
+func newPrometheusProvider(
+	cfg PrometheusConfig,
+	prefix string,
+	logger kitlog.Logger,
+	buckets func(name string) []float64,
+) *prometheusProvider {
+	p := &prometheusProvider{
+		prefix:   prefix,
+		registry: cfg.Registry,
+		buckets:  buckets,
+		logger:   logger,
+		vecs:     make(map[string]*promVec),
+	}
+	if p.registry == nil {
+		p.registry = prometheus.NewRegistry()
+	}
+	return p
+}
+
+// promName converts a statsd metric path into a valid Prometheus metric name.
+func promName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z',
+			r >= 'A' && r <= 'Z',
+			r >= '0' && r <= '9',
+			r == '_',
+			r == ':':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
+
+// promLabelName converts a tag key into a valid Prometheus label name.
+func promLabelName(key string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z',
+			r >= 'A' && r <= 'Z',
+			r >= '0' && r <= '9',
+			r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, key)
+}
+
+// labels converts tags (as key-value pairs) into Prometheus labels.
+func (p *prometheusProvider) labels(tags []string) prometheus.Labels {
+	labels := make(prometheus.Labels, len(tags)/2)
+	for i := 0; i+1 < len(tags); i += 2 {
+		labels[promLabelName(tags[i])] = tags[i+1]
+	}
+	return labels
+}
+
+// collector returns the collector of the metric and the labels to use with it,
+// or nil if the metric cannot be registered.
+func (p *prometheusProvider) collector(kind, name string, tags []string) (prometheus.Collector, prometheus.Labels) {
+	labels := p.labels(tags)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	vec, ok := p.vecs[name]
+	if !ok {
+		vec = p.register(kind, name, labels)
+		p.vecs[name] = vec
+	}
+	if vec.collector == nil {
+		return nil, nil
+	}
+	if vec.kind != kind {
+		p.logger.Log(
+			"during", "metricsbp.Prometheus",
+			"msg", "metric already registered as a different kind",
+			"name", name,
+			"kind", kind,
+			"existing", vec.kind,
+		)
+		return nil, nil
+	}
+
+	// Labels missing are reported as empty,
+	// which is the same as not having the label in Prometheus.
+	values := make(prometheus.Labels, len(vec.labels))
+	for _, label := range vec.labels {
+		values[label] = labels[label]
+		delete(labels, label)
+	}
+	if len(labels) > 0 && !vec.extraLogged {
+		vec.extraLogged = true
+		p.logger.Log(
+			"during", "metricsbp.Prometheus",
+			"msg", "labels not used the first time are dropped, further drops will not be logged",
+			"name", name,
+		)
+	}
+	return vec.collector, values
+}
+
+// register creates and registers the metric vector.
+//
+// It must be called with p.mu held.
+func (p *prometheusProvider) register(kind, name string, labels prometheus.Labels) *promVec {
+	vec := &promVec{
+		kind:   kind,
+		labels: make([]string, 0, len(labels)),
+	}
+	for label := range labels {
+		vec.labels = append(vec.labels, label)
+	}
+	sort.Strings(vec.labels)
+
+	fqName := promName(p.prefix + name)
+	help := "metricsbp " + kind + " " + p.prefix + name
+	var collector prometheus.Collector
+	switch kind {
+	case promCounterKind:
+		collector = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: fqName,
+			Help: help,
+		}, vec.labels)
+	case promGaugeKind:
+		collector = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: fqName,
+			Help: help,
+		}, vec.labels)
+	case promHistogramKind:
+		buckets := p.buckets(name)
+		if len(buckets) == 0 {
+			buckets = prometheus.DefBuckets
+		}
+		collector = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    fqName,
+			Help:    help,
+			Buckets: buckets,
+		}, vec.labels)
+	}
+
+	if err := p.registry.Register(collector); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			collector = are.ExistingCollector
+		} else {
+			p.logger.Log(
+				"during", "metricsbp.Prometheus",
+				"name", name,
+				"err", err,
+			)
+			collector = nil
+		}
+	}
+	vec.collector = collector
+	return vec
+}
+
+// promSeries is a single series of a Prometheus metric vector,
+// resolved lazily the first time it's used.
+type promSeries struct {
+	p    *prometheusProvider
+	kind string
+	name string
+	tags []string
+
+	once   sync.Once
+	metric interface{} // prometheus.Counter, Gauge, Observer, or nil
+}
+
+// get returns the resolved Prometheus metric of the series,
+// or nil if the metric cannot be registered.
+func (s *promSeries) get() interface{} {
+	s.once.Do(func() {
+		collector, labels := s.p.collector(s.kind, s.name, s.tags)
+		switch vec := collector.(type) {
+		case *prometheus.CounterVec:
+			s.metric = vec.With(labels)
+		case *prometheus.GaugeVec:
+			s.metric = vec.With(labels)
+		case *prometheus.HistogramVec:
+			s.metric = vec.With(labels)
+		}
+	})
+	return s.metric
+}
+
+// seriesKey returns the key of the series in prometheusProvider.series.
+func seriesKey(kind, name string, tags []string) string {
+	var sb strings.Builder
+	sb.WriteString(kind)
+	sb.WriteByte(0)
+	sb.WriteString(name)
+	for _, tag := range tags {
+		sb.WriteByte(0)
+		sb.WriteString(tag)
+	}
+	return sb.String()
+}
+
+// seriesOf returns the series of the metric with tags (as key-value pairs),
+// without resolving it.
+//
+// Once MaxCachedMetrics series are kept,
+// the new ones are returned without being kept, the same as the metric cache.
+// They still resolve to the same Prometheus series,
+// they are only resolved again every time they are created.
+func (p *prometheusProvider) seriesOf(kind, name string, tags []string) *promSeries {
+	key := seriesKey(kind, name, tags)
+	if s, ok := p.series.Load(key); ok {
+		return s.(*promSeries)
+	}
+	s := &promSeries{
+		p:    p,
+		kind: kind,
+		name: name,
+		tags: tags,
+	}
+	if atomic.LoadInt64(&p.seriesSize) >= MaxCachedMetrics {
+		return s
+	}
+	v, loaded := p.series.LoadOrStore(key, s)
+	if !loaded {
+		atomic.AddInt64(&p.seriesSize, 1)
+	}
+	return v.(*promSeries)
+}
+
+func (p *prometheusProvider) newCounter(name string, sampleRate float64) metrics.Counter {
+	return promCounter{
+		p:      p,
+		name:   name,
+		rate:   sampleRate,
+		series: p.seriesOf(promCounterKind, name, nil),
+	}
+}
+
+func (p *prometheusProvider) newGauge(name string) metrics.Gauge {
+	return promGauge{
+		p:      p,
+		name:   name,
+		series: p.seriesOf(promGaugeKind, name, nil),
+	}
+}
+
+func (p *prometheusProvider) newHistogram(name string) metrics.Histogram {
+	return promHistogram{
+		p:      p,
+		name:   name,
+		series: p.seriesOf(promHistogramKind, name, nil),
+	}
+}
+
+// promCounter is a metrics.Counter backed by a Prometheus counter.
+//
+// As Prometheus counters are not sampled,
+// the deltas are scaled by the reporting rate.
+//
+// The series is looked up in With,
+// so Add doesn't allocate or take any lock.
+type promCounter struct {
+	p      *prometheusProvider
+	name   string
+	rate   float64
+	tags   []string
+	series *promSeries
+}
+
+func (c promCounter) With(tagValues ...string) metrics.Counter {
+	c.tags = appendTags(c.tags, tagValues)
+	c.series = c.p.seriesOf(promCounterKind, c.name, c.tags)
+	return c
+}
+
+func (c promCounter) Add(delta float64) {
+	// Prometheus counters can only go up.
+	if c.rate <= 0 || delta < 0 {
+		return
+	}
+	if counter, ok := c.series.get().(prometheus.Counter); ok {
+		counter.Add(delta / c.rate)
+	}
+}
+
+// promGauge is a metrics.Gauge backed by a Prometheus gauge.
+type promGauge struct {
+	p      *prometheusProvider
+	name   string
+	tags   []string
+	series *promSeries
+}
+
+func (g promGauge) With(tagValues ...string) metrics.Gauge {
+	g.tags = appendTags(g.tags, tagValues)
+	g.series = g.p.seriesOf(promGaugeKind, g.name, g.tags)
+	return g
+}
+
+func (g promGauge) Set(value float64) {
+	if gauge, ok := g.series.get().(prometheus.Gauge); ok {
+		gauge.Set(value)
+	}
+}
+
+func (g promGauge) Add(delta float64) {
+	if gauge, ok := g.series.get().(prometheus.Gauge); ok {
+		gauge.Add(delta)
+	}
+}
+
+// promHistogram is a metrics.Histogram backed by a Prometheus histogram.
+type promHistogram struct {
+	p      *prometheusProvider
+	name   string
+	tags   []string
+	series *promSeries
+}
+
+func (h promHistogram) With(tagValues ...string) metrics.Histogram {
+	h.tags = appendTags(h.tags, tagValues)
+	h.series = h.p.seriesOf(promHistogramKind, h.name, h.tags)
+	return h
+}
+
+func (h promHistogram) Observe(value float64) {
+	if observer, ok := h.series.get().(prometheus.Observer); ok {
+		observer.Observe(value)
+	}
+}
+
+// appendTags returns a new slice with tagValues appended to tags.
+func appendTags(tags, tagValues []string) []string {
+	appended := make([]string, 0, len(tags)+len(tagValues))
+	appended = append(appended, tags...)
+	return append(appended, tagValues...)
+}
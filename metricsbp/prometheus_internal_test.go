@@ -0,0 +1,76 @@
+package metricsbp
+
+import (
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+)
+
+func TestPrometheusNoAllocs(t *testing.T) {
+	p := newPrometheusProvider(
+		PrometheusConfig{},
+		"",
+		log.NewNopLogger(),
+		func(string) []float64 { return nil },
+	)
+	counter := p.newCounter("counter", 1).With("key", "value")
+	gauge := p.newGauge("gauge").With("key", "value")
+	histogram := p.newHistogram("histogram").With("key", "value")
+
+	if allocs := testing.AllocsPerRun(100, func() {
+		counter.Add(1)
+		gauge.Set(1)
+		histogram.Observe(1)
+	}); allocs != 0 {
+		t.Errorf("Expected no allocations, got %v", allocs)
+	}
+}
+
+func TestPrometheusMaxSeries(t *testing.T) {
+	p := newPrometheusProvider(
+		PrometheusConfig{},
+		"",
+		log.NewNopLogger(),
+		func(string) []float64 { return nil },
+	)
+	counter := p.newCounter("counter", 1)
+	for i := 0; i < MaxCachedMetrics+10; i++ {
+		counter.With("key", strconv.Itoa(i)).Add(1)
+	}
+	// Including the series without tags created by newCounter.
+	if got := atomic.LoadInt64(&p.seriesSize); got != MaxCachedMetrics {
+		t.Errorf("Expected %d series kept, got %d", MaxCachedMetrics, got)
+	}
+	var n int
+	p.series.Range(func(_, _ interface{}) bool {
+		n++
+		return true
+	})
+	if n != MaxCachedMetrics {
+		t.Errorf("Expected %d series kept, got %d", MaxCachedMetrics, n)
+	}
+
+	// The ones not kept still report to the same series.
+	counter.With("key", strconv.Itoa(MaxCachedMetrics+1)).Add(1)
+	families, err := p.registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			if m.GetLabel()[0].GetValue() != strconv.Itoa(MaxCachedMetrics+1) {
+				continue
+			}
+			found = true
+			if got := m.GetCounter().GetValue(); got != 2 {
+				t.Errorf("Expected the counter not kept to be 2, got %v", got)
+			}
+		}
+	}
+	if !found {
+		t.Error("Expected the counter not kept to be reported")
+	}
+}
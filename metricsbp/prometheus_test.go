@@ -0,0 +1,175 @@
+package metricsbp_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/reddit/baseplate.go/metricsbp"
+)
+
+func scrapePrometheus(t *testing.T, st *metricsbp.Statsd) string {
+	t.Helper()
+
+	server := httptest.NewServer(st.PrometheusHandler())
+	defer server.Close()
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestPrometheus(t *testing.T) {
+	st := metricsbp.NewStatsd(
+		context.Background(),
+		metricsbp.StatsdConfig{
+			Prefix: "my-service",
+			Tags: metricsbp.Tags{
+				"env": "test",
+			},
+			Prometheus:       &metricsbp.PrometheusConfig{},
+			HistogramBuckets: []float64{10, 1, 10},
+		},
+	)
+	st.Counter("foo.requests").With("endpoint", "a").Add(2)
+	st.Counter("foo.requests").With("endpoint", "a").Add(1)
+	st.Counter("foo.requests").With("endpoint", "b").Add(1)
+	// Scaled by the sample rate.
+	st.CounterWithRate(metricsbp.RateArgs{
+		Name:             "sampled",
+		Rate:             1,
+		AlreadySampledAt: metricsbp.Float64Ptr(0.5),
+	}).Add(1)
+	st.Gauge("foo.gauge").Set(42)
+	st.Histogram("foo.histogram").Observe(5)
+	st.Timing("foo.timing").With("endpoint", "a").Observe(20)
+
+	body := scrapePrometheus(t, st)
+	for _, expected := range []string{
+		`my_service_foo_requests{endpoint="a",env="test"} 3`,
+		`my_service_foo_requests{endpoint="b",env="test"} 1`,
+		`my_service_sampled{env="test"} 2`,
+		`my_service_foo_gauge{env="test"} 42`,
+		`my_service_foo_histogram_bucket{env="test",le="1"} 0`,
+		`my_service_foo_histogram_bucket{env="test",le="10"} 1`,
+		`my_service_foo_histogram_count{env="test"} 1`,
+		`my_service_foo_timing_bucket{endpoint="a",env="test",le="10"} 0`,
+		`my_service_foo_timing_sum{endpoint="a",env="test"} 20`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Expected %q in:\n%s", expected, body)
+		}
+	}
+
+	// The statsd output is not affected.
+	var sb strings.Builder
+	if _, err := st.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	const expected = "my-service.foo.requests,env=test,endpoint=a:3.000000|c"
+	if !strings.Contains(sb.String(), expected) {
+		t.Errorf("Expected %q in %q", expected, sb.String())
+	}
+}
+
+func TestPrometheusMetricHistogramBuckets(t *testing.T) {
+	st := metricsbp.NewStatsd(
+		context.Background(),
+		metricsbp.StatsdConfig{
+			Prometheus:       &metricsbp.PrometheusConfig{},
+			HistogramBuckets: []float64{1, 10},
+			MetricHistogramBuckets: map[string][]float64{
+				"fast": {0.5, 0.1},
+			},
+		},
+	)
+	st.Timing("fast").Observe(0.2)
+	st.Timing("slow").Observe(5)
+	st.Histogram("default").Observe(5)
+
+	body := scrapePrometheus(t, st)
+	for _, expected := range []string{
+		`fast_bucket{le="0.1"} 0`,
+		`fast_bucket{le="0.5"} 1`,
+		`slow_bucket{le="1"} 0`,
+		`slow_bucket{le="10"} 1`,
+		`default_bucket{le="10"} 1`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Expected %q in:\n%s", expected, body)
+		}
+	}
+	if strings.Contains(body, `fast_bucket{le="10"}`) {
+		t.Errorf("Expected override buckets for fast in:\n%s", body)
+	}
+
+	defaultSt := metricsbp.NewStatsd(
+		context.Background(),
+		metricsbp.StatsdConfig{
+			Prometheus: &metricsbp.PrometheusConfig{},
+		},
+	)
+	defaultSt.Histogram("default").Observe(5)
+	// prometheus.DefBuckets
+	const expected = `default_bucket{le="0.005"} 0`
+	if body := scrapePrometheus(t, defaultSt); !strings.Contains(body, expected) {
+		t.Errorf("Expected %q in:\n%s", expected, body)
+	}
+}
+
+func TestPrometheusLabels(t *testing.T) {
+	st := metricsbp.NewStatsd(
+		context.Background(),
+		metricsbp.StatsdConfig{
+			Prometheus: &metricsbp.PrometheusConfig{},
+		},
+	)
+	counter := st.Counter("counter")
+	counter.With("a", "1").Add(1)
+	// Missing labels are reported as empty.
+	counter.Add(1)
+	// Extra labels are dropped.
+	counter.With("a", "1", "b", "2").Add(1)
+	// Different kinds with the same name are dropped.
+	st.Gauge("counter").Set(1)
+
+	body := scrapePrometheus(t, st)
+	for _, expected := range []string{
+		`counter{a="1"} 2`,
+		`counter{a=""} 1`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Expected %q in:\n%s", expected, body)
+		}
+	}
+	if strings.Contains(body, "# TYPE counter gauge") {
+		t.Errorf("Did not expect gauge in:\n%s", body)
+	}
+}
+
+func TestPrometheusDisabled(t *testing.T) {
+	st := metricsbp.NewStatsd(
+		context.Background(),
+		metricsbp.StatsdConfig{},
+	)
+	recorder := httptest.NewRecorder()
+	st.PrometheusHandler().ServeHTTP(
+		recorder,
+		httptest.NewRequest(http.MethodGet, "/metrics", nil),
+	)
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, recorder.Code)
+	}
+}
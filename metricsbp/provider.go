@@ -0,0 +1,302 @@
+package metricsbp
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	kitlog "github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/dogstatsd"
+	"github.com/go-kit/kit/metrics/influxstatsd"
+
+	"github.com/reddit/baseplate.go/log"
+)
+
+// Format is the statsd line format used to report metrics.
+type Format string
+
+// Supported Format values.
+const (
+	// FormatInflux is the Influxstatsd format,
+	// with tags appended to the metric name:
+	//
+	//     name,key=value:1|c
+	FormatInflux Format = "influx"
+
+	// FormatDogStatsd is the DogStatsD format,
+	// with tags appended after the type and sample rate:
+	//
+	//     name:1|c|#key:value
+	FormatDogStatsd Format = "dogstatsd"
+
+	// FormatPlain is the plain statsd format without any tags:
+	//
+	//     name:1|c
+	//
+	// All the tags, including Tags in StatsdConfig and the ones passed into
+	// With, are dropped.
+	// It's for legacy statsd collectors that don't understand any tag syntax.
+	FormatPlain Format = "plain"
+)
+
+// DefaultFormat is the Format to be used when Format in StatsdConfig is empty.
+const DefaultFormat = FormatInflux
+
+// provider is the underlying statsd implementation for a Format.
+type provider interface {
+	NewCounter(name string, sampleRate float64) metrics.Counter
+	NewGauge(name string) metrics.Gauge
+	NewTiming(name string, sampleRate float64) metrics.Histogram
+	NewHistogram(name string, sampleRate float64) metrics.Histogram
+	WriteTo(w io.Writer) (int64, error)
+
+	// setLine returns the statsd line of a set value,
+	// name already includes the prefix.
+	setLine(name string, tags []string, value string) string
+
+	// countedLine returns the statsd line of a value of the statsd type typ
+	// representing n samples, observed by CountedHistogram,
+	// name already includes the prefix.
+	countedLine(name string, tags []string, typ string, value float64, n int) string
+}
+
+// newProvider creates the provider for the format.
+//
+// The provider doesn't attach any tags by itself,
+// the Tags in StatsdConfig are passed into With of every metric instead,
+// so that they can be overridden by the tags passed into With later.
+func newProvider(format Format, prefix string, logger kitlog.Logger) (provider, error) {
+	switch format {
+	case "", FormatInflux:
+		return influxProvider{influxstatsd.New(prefix, logger)}, nil
+	case FormatDogStatsd:
+		return dogstatsdProvider{dogstatsd.New(prefix, logger)}, nil
+	case FormatPlain:
+		return &plainProvider{
+			influxProvider: influxProvider{influxstatsd.New(prefix, logger)},
+		}, nil
+	default:
+		return nil, fmt.Errorf("metricsbp: unsupported format %q", format)
+	}
+}
+
+type influxProvider struct {
+	*influxstatsd.Influxstatsd
+}
+
+func (p influxProvider) NewCounter(name string, sampleRate float64) metrics.Counter {
+	return p.Influxstatsd.NewCounter(name, sampleRate)
+}
+
+func (p influxProvider) NewGauge(name string) metrics.Gauge {
+	return p.Influxstatsd.NewGauge(name)
+}
+
+func (p influxProvider) NewTiming(name string, sampleRate float64) metrics.Histogram {
+	return p.Influxstatsd.NewTiming(name, sampleRate)
+}
+
+func (p influxProvider) NewHistogram(name string, sampleRate float64) metrics.Histogram {
+	return p.Influxstatsd.NewHistogram(name, sampleRate)
+}
+
+func (influxProvider) setLine(name string, tags []string, value string) string {
+	var sb strings.Builder
+	sb.WriteString(name)
+	for i := 0; i+1 < len(tags); i += 2 {
+		sb.WriteString(",")
+		sb.WriteString(tags[i])
+		sb.WriteString("=")
+		sb.WriteString(tags[i+1])
+	}
+	sb.WriteString(":")
+	sb.WriteString(value)
+	sb.WriteString("|s\n")
+	return sb.String()
+}
+
+func (influxProvider) countedLine(name string, tags []string, typ string, value float64, n int) string {
+	var sb strings.Builder
+	sb.WriteString(name)
+	for i := 0; i+1 < len(tags); i += 2 {
+		sb.WriteString(",")
+		sb.WriteString(tags[i])
+		sb.WriteString("=")
+		sb.WriteString(tags[i+1])
+	}
+	sb.WriteString(":")
+	sb.WriteString(countedValue(value))
+	sb.WriteString("|")
+	sb.WriteString(typ)
+	sb.WriteString(countedRate(n))
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+type dogstatsdProvider struct {
+	*dogstatsd.Dogstatsd
+}
+
+func (p dogstatsdProvider) NewCounter(name string, sampleRate float64) metrics.Counter {
+	return p.Dogstatsd.NewCounter(name, sampleRate)
+}
+
+func (p dogstatsdProvider) NewGauge(name string) metrics.Gauge {
+	return p.Dogstatsd.NewGauge(name)
+}
+
+func (p dogstatsdProvider) NewTiming(name string, sampleRate float64) metrics.Histogram {
+	return p.Dogstatsd.NewTiming(name, sampleRate)
+}
+
+func (p dogstatsdProvider) NewHistogram(name string, sampleRate float64) metrics.Histogram {
+	return p.Dogstatsd.NewHistogram(name, sampleRate)
+}
+
+func (dogstatsdProvider) setLine(name string, tags []string, value string) string {
+	var sb strings.Builder
+	sb.WriteString(name)
+	sb.WriteString(":")
+	sb.WriteString(value)
+	sb.WriteString("|s")
+	for i := 0; i+1 < len(tags); i += 2 {
+		if i == 0 {
+			sb.WriteString("|#")
+		} else {
+			sb.WriteString(",")
+		}
+		sb.WriteString(tags[i])
+		sb.WriteString(":")
+		sb.WriteString(tags[i+1])
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+func (dogstatsdProvider) countedLine(name string, tags []string, typ string, value float64, n int) string {
+	var sb strings.Builder
+	sb.WriteString(name)
+	sb.WriteString(":")
+	sb.WriteString(countedValue(value))
+	sb.WriteString("|")
+	sb.WriteString(typ)
+	sb.WriteString(countedRate(n))
+	for i := 0; i+1 < len(tags); i += 2 {
+		if i == 0 {
+			sb.WriteString("|#")
+		} else {
+			sb.WriteString(",")
+		}
+		sb.WriteString(tags[i])
+		sb.WriteString(":")
+		sb.WriteString(tags[i+1])
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+// plainProvider is the provider for FormatPlain.
+//
+// It's backed by an influxProvider without any tags,
+// as Influxstatsd format without tags is the plain statsd format.
+type plainProvider struct {
+	influxProvider
+
+	dropOnce sync.Once
+}
+
+// tagsDropped logs at debug level the first time tags are dropped.
+func (p *plainProvider) tagsDropped() {
+	p.dropOnce.Do(func() {
+		log.KitLogger(log.DebugLevel).Log(
+			"during", "metricsbp.FormatPlain",
+			"msg", "tags are dropped in plain format, further drops will not be logged",
+		)
+	})
+}
+
+func (p *plainProvider) NewCounter(name string, sampleRate float64) metrics.Counter {
+	return plainCounter{
+		Counter: p.influxProvider.NewCounter(name, sampleRate),
+		p:       p,
+	}
+}
+
+func (p *plainProvider) NewGauge(name string) metrics.Gauge {
+	return plainGauge{
+		Gauge: p.influxProvider.NewGauge(name),
+		p:     p,
+	}
+}
+
+func (p *plainProvider) NewTiming(name string, sampleRate float64) metrics.Histogram {
+	return plainHistogram{
+		Histogram: p.influxProvider.NewTiming(name, sampleRate),
+		p:         p,
+	}
+}
+
+func (p *plainProvider) NewHistogram(name string, sampleRate float64) metrics.Histogram {
+	return plainHistogram{
+		Histogram: p.influxProvider.NewHistogram(name, sampleRate),
+		p:         p,
+	}
+}
+
+func (p *plainProvider) setLine(name string, tags []string, value string) string {
+	if len(tags) > 0 {
+		p.tagsDropped()
+	}
+	return p.influxProvider.setLine(name, nil, value)
+}
+
+func (p *plainProvider) countedLine(name string, tags []string, typ string, value float64, n int) string {
+	if len(tags) > 0 {
+		p.tagsDropped()
+	}
+	return p.influxProvider.countedLine(name, nil, typ, value, n)
+}
+
+// plainCounter is a metrics.Counter dropping the tags passed into With.
+type plainCounter struct {
+	metrics.Counter
+
+	p *plainProvider
+}
+
+func (c plainCounter) With(tagValues ...string) metrics.Counter {
+	if len(tagValues) > 0 {
+		c.p.tagsDropped()
+	}
+	return c
+}
+
+// plainHistogram is a metrics.Histogram dropping the tags passed into With.
+type plainHistogram struct {
+	metrics.Histogram
+
+	p *plainProvider
+}
+
+func (h plainHistogram) With(tagValues ...string) metrics.Histogram {
+	if len(tagValues) > 0 {
+		h.p.tagsDropped()
+	}
+	return h
+}
+
+// plainGauge is a metrics.Gauge dropping the tags passed into With.
+type plainGauge struct {
+	metrics.Gauge
+
+	p *plainProvider
+}
+
+func (g plainGauge) With(tagValues ...string) metrics.Gauge {
+	if len(tagValues) > 0 {
+		g.p.tagsDropped()
+	}
+	return g
+}
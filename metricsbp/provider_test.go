@@ -0,0 +1,114 @@
+package metricsbp_test
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/reddit/baseplate.go/metricsbp"
+)
+
+func TestFormat(t *testing.T) {
+	for _, c := range []struct {
+		format   metricsbp.Format
+		expected []string
+	}{
+		{
+			format: "",
+			expected: []string{
+				"prefix.counter,foo=bar,key=value:1.000000|c|@0.999990",
+				"prefix.gauge,foo=bar,key=value:1.000000|g",
+				"prefix.histogram,foo=bar,key=value:1.000000|h",
+				"prefix.set,foo=bar,key=value:a|s",
+				"prefix.timing,foo=bar,key=value:1.000000|ms",
+			},
+		},
+		{
+			format: metricsbp.FormatInflux,
+			expected: []string{
+				"prefix.counter,foo=bar,key=value:1.000000|c|@0.999990",
+				"prefix.gauge,foo=bar,key=value:1.000000|g",
+				"prefix.histogram,foo=bar,key=value:1.000000|h",
+				"prefix.set,foo=bar,key=value:a|s",
+				"prefix.timing,foo=bar,key=value:1.000000|ms",
+			},
+		},
+		{
+			format: metricsbp.FormatDogStatsd,
+			expected: []string{
+				"prefix.counter:1.000000|c|@0.999990|#foo:bar,key:value",
+				"prefix.gauge:1.000000|g|#foo:bar,key:value",
+				"prefix.histogram:1.000000|h|#foo:bar,key:value",
+				"prefix.set:a|s|#foo:bar,key:value",
+				"prefix.timing:1.000000|ms|#foo:bar,key:value",
+			},
+		},
+		{
+			format: metricsbp.FormatPlain,
+			expected: []string{
+				"prefix.counter:1.000000|c|@0.999990",
+				"prefix.gauge:1.000000|g",
+				"prefix.histogram:1.000000|h",
+				"prefix.set:a|s",
+				"prefix.timing:1.000000|ms",
+			},
+		},
+	} {
+		t.Run(string(c.format), func(t *testing.T) {
+			st := metricsbp.NewStatsd(
+				context.Background(),
+				metricsbp.StatsdConfig{
+					Prefix: "prefix",
+					Format: c.format,
+					Tags: metricsbp.Tags{
+						"foo": "bar",
+					},
+				},
+			)
+			// Use a rate that's practically always reported to verify the sample
+			// rate is reported.
+			st.CounterWithRate(metricsbp.RateArgs{
+				Name:             "counter",
+				Rate:             1,
+				AlreadySampledAt: metricsbp.Float64Ptr(0.99999),
+			}).With("key", "value").Add(1)
+			st.Gauge("gauge").With("key", "value").Set(1)
+			st.Histogram("histogram").With("key", "value").Observe(1)
+			st.Timing("timing").With("key", "value").Observe(1)
+			st.Set("set").With("key", "value").Add("a")
+
+			var sb strings.Builder
+			if _, err := st.WriteTo(&sb); err != nil {
+				t.Fatal(err)
+			}
+			lines := strings.Split(strings.TrimSpace(sb.String()), "\n")
+			sort.Strings(lines)
+			if !reflect.DeepEqual(lines, c.expected) {
+				t.Errorf("Expected %q, got %q", c.expected, lines)
+			}
+		})
+	}
+}
+
+func TestFormatUnsupported(t *testing.T) {
+	var buf bytes.Buffer
+	st := metricsbp.NewStatsd(
+		context.Background(),
+		metricsbp.StatsdConfig{
+			Format: "foo",
+			Writer: &buf,
+		},
+	)
+	defer st.Close()
+
+	st.Counter("counter").Add(1)
+	if err := st.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("Expected nothing written for unsupported format, got %q", buf.String())
+	}
+}
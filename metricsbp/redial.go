@@ -0,0 +1,139 @@
+package metricsbp
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	kitlog "github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/util/conn"
+)
+
+// DefaultRedialInterval is the default value to be used when RedialInterval in
+// StatsdConfig is 0.
+const DefaultRedialInterval = 5 * time.Minute
+
+// newConnManager creates the connection manager writing to the statsd
+// collector at the network and address,
+// with the connections re-dialed according to RedialInterval in StatsdConfig.
+func (st *Statsd) newConnManager(network, address string, logger kitlog.Logger) *conn.Manager {
+	return conn.NewManager(
+		redialDialer(net.Dial, st.cfg.RedialInterval, st.clock.Now),
+		network,
+		address,
+		time.After,
+		logger,
+	)
+}
+
+// redialDialer returns a conn.Dialer wrapping dial,
+// with the connections dialed by it re-dialed every interval,
+// so that the address is resolved again,
+// and the previous connection closed when a new one is dialed,
+// as conn.Manager doesn't close the connections after a failure.
+//
+// A non-positive interval disables the re-dialing on interval.
+func redialDialer(dial conn.Dialer, interval time.Duration, now func() time.Time) conn.Dialer {
+	var mu sync.Mutex
+	var last *redialConn
+	return func(network, address string) (net.Conn, error) {
+		c, err := dial(network, address)
+		if err != nil {
+			return nil, err
+		}
+		rc := &redialConn{
+			dial:     dial,
+			network:  network,
+			address:  address,
+			interval: interval,
+			now:      now,
+			conn:     c,
+			dialedAt: now(),
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if last != nil {
+			last.Close()
+		}
+		last = rc
+		return rc, nil
+	}
+}
+
+// redialConn is a net.Conn re-dialing its address before the first write after
+// every interval.
+//
+// When the re-dial fails, the current connection is kept until the next
+// re-dial.
+type redialConn struct {
+	dial     conn.Dialer
+	network  string
+	address  string
+	interval time.Duration
+	now      func() time.Time
+
+	mu       sync.Mutex
+	conn     net.Conn
+	dialedAt time.Time
+}
+
+// current returns the current connection.
+func (rc *redialConn) current() net.Conn {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.conn
+}
+
+// redial re-dials the address if interval passed since the last dial,
+// and returns the current connection.
+func (rc *redialConn) redial() net.Conn {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.interval <= 0 {
+		return rc.conn
+	}
+	now := rc.now()
+	if now.Sub(rc.dialedAt) < rc.interval {
+		return rc.conn
+	}
+	rc.dialedAt = now
+	if c, err := rc.dial(rc.network, rc.address); err == nil {
+		rc.conn.Close()
+		rc.conn = c
+	}
+	return rc.conn
+}
+
+func (rc *redialConn) Write(p []byte) (int, error) {
+	return rc.redial().Write(p)
+}
+
+func (rc *redialConn) Read(p []byte) (int, error) {
+	return rc.current().Read(p)
+}
+
+func (rc *redialConn) Close() error {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.conn.Close()
+}
+
+func (rc *redialConn) LocalAddr() net.Addr {
+	return rc.current().LocalAddr()
+}
+
+func (rc *redialConn) RemoteAddr() net.Addr {
+	return rc.current().RemoteAddr()
+}
+
+func (rc *redialConn) SetDeadline(t time.Time) error {
+	return rc.current().SetDeadline(t)
+}
+
+func (rc *redialConn) SetReadDeadline(t time.Time) error {
+	return rc.current().SetReadDeadline(t)
+}
+
+func (rc *redialConn) SetWriteDeadline(t time.Time) error {
+	return rc.current().SetWriteDeadline(t)
+}
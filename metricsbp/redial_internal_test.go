@@ -0,0 +1,82 @@
+package metricsbp
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// fakeConn is a net.Conn recording the writes.
+type fakeConn struct {
+	net.Conn
+
+	writes int
+	closed bool
+}
+
+func (fc *fakeConn) Write(p []byte) (int, error) {
+	fc.writes++
+	return len(p), nil
+}
+
+func (fc *fakeConn) Close() error {
+	fc.closed = true
+	return nil
+}
+
+func TestRedialDialer(t *testing.T) {
+	var conns []*fakeConn
+	var fail bool
+	dial := func(network, address string) (net.Conn, error) {
+		if fail {
+			return nil, errors.New("failed")
+		}
+		c := new(fakeConn)
+		conns = append(conns, c)
+		return c, nil
+	}
+	now := time.Unix(0, 0)
+	dialer := redialDialer(dial, time.Minute, func() time.Time { return now })
+
+	c, err := dialer("udp", "localhost:8125")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Write([]byte("foo"))
+	now = now.Add(time.Second * 59)
+	c.Write([]byte("foo"))
+	if len(conns) != 1 || conns[0].writes != 2 {
+		t.Fatalf("Expected 2 writes to 1 connection before the interval, got %d connections", len(conns))
+	}
+
+	// Re-dialed after the interval.
+	now = now.Add(time.Second)
+	c.Write([]byte("foo"))
+	if len(conns) != 2 {
+		t.Fatalf("Expected 2 connections after the interval, got %d", len(conns))
+	}
+	if !conns[0].closed {
+		t.Error("Expected the previous connection to be closed")
+	}
+	if conns[1].writes != 1 {
+		t.Errorf("Expected the write to go to the new connection, got %d writes", conns[1].writes)
+	}
+
+	// The current connection is kept when the re-dial fails.
+	fail = true
+	now = now.Add(time.Minute)
+	c.Write([]byte("foo"))
+	if conns[1].closed || conns[1].writes != 2 {
+		t.Errorf("Expected the current connection to be kept, got closed=%v, writes=%d", conns[1].closed, conns[1].writes)
+	}
+
+	// Dialing a new one closes the previous one.
+	fail = false
+	if _, err := dialer("udp", "localhost:8125"); err != nil {
+		t.Fatal(err)
+	}
+	if !conns[1].closed {
+		t.Error("Expected the previous connection to be closed after dialing a new one")
+	}
+}
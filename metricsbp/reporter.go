@@ -0,0 +1,419 @@
+package metricsbp
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	kitlog "github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+
+	"github.com/reddit/baseplate.go/log"
+	"github.com/reddit/baseplate.go/randbp"
+)
+
+// The internal metrics the background reporting goroutine reports about
+// itself.
+const (
+	sendErrorsCounter = "baseplate.metricsbp.send_errors"
+	sentBytesCounter  = "baseplate.metricsbp.sent_bytes"
+	flushesCounter    = "baseplate.metricsbp.flushes"
+	heartbeatCounter  = "baseplate.metricsbp.heartbeat"
+	panicsCounter     = "baseplate.metricsbp.reporter_panics"
+
+	flushDurationTiming = "baseplate.metricsbp.flush_duration"
+)
+
+// sendErrorInterval is the minimal interval between the OnSendError calls.
+const sendErrorInterval = time.Second
+
+// The backoff before restarting the background reporting goroutine after a
+// panic, doubled on every consecutive panic.
+//
+// The backoff is reset when the restarted loop ran for at least
+// maxRestartBackoff before the next panic.
+const (
+	minRestartBackoff = time.Second
+	maxRestartBackoff = time.Minute
+)
+
+// reporterMetrics are the metrics about the writes to the statsd collector.
+//
+// Since they are reported via the same Statsd,
+// the counts of a write are reported in the next write.
+type reporterMetrics struct {
+	sendErrors metrics.Counter
+	sentBytes  metrics.Counter
+	flushes    metrics.Counter
+	panics     metrics.Counter
+
+	flushDuration metrics.Histogram
+
+	// heartbeat is nil when Heartbeat in StatsdConfig is false.
+	heartbeat metrics.Counter
+}
+
+func (st *Statsd) newReporterMetrics() reporterMetrics {
+	// Use the underlying statsd directly to avoid the fallback to M,
+	// as this is called during the initialization of M.
+	newCounter := func(name string) metrics.Counter {
+		return st.statsd.NewCounter(name, 1).With(st.tags...)
+	}
+	m := reporterMetrics{
+		sendErrors: newCounter(sendErrorsCounter),
+		sentBytes:  newCounter(sentBytesCounter),
+		flushes:    newCounter(flushesCounter),
+		panics:     newCounter(panicsCounter),
+
+		flushDuration: st.withTimingUnit(
+			st.statsd.NewTiming(flushDurationTiming, 1).With(st.tags...),
+		),
+	}
+	if st.cfg.Heartbeat {
+		m.heartbeat = newCounter(heartbeatCounter)
+	}
+	return m
+}
+
+// debugLogger returns the logger for the debug logs of the reporting
+// goroutine, which is nop when level is nop.
+func debugLogger(level log.Level) log.KitWrapper {
+	if level.ToZapLevel() == log.ZapNopLevel {
+		return log.KitWrapper(log.ZapNopLevel)
+	}
+	return log.KitLogger(log.DebugLevel)
+}
+
+// startReporter starts the background reporting goroutine.
+//
+// target is the description of where the metrics are written to,
+// used in the logs.
+//
+// It must only be called when st.writer is non-nil.
+func (st *Statsd) startReporter(interval time.Duration, target string) {
+	st.shared.reporterMetrics = st.newReporterMetrics()
+	st.shared.done = make(chan struct{})
+	st.shared.flushNow = make(chan struct{}, 1)
+	st.logger.Log(
+		"during", "metricsbp.startReporter",
+		"msg", "started the background reporting goroutine",
+		"address", target,
+		"interval", interval,
+	)
+	notify := st.sendErrorNotifier()
+	atomic.StoreInt32(&st.shared.running, 1)
+	go func() {
+		defer close(st.shared.done)
+		defer atomic.StoreInt32(&st.shared.running, 0)
+		ticker := st.newReportingTicker(interval)
+		defer ticker.Stop()
+
+		backoff := minRestartBackoff
+		for {
+			start := st.clock.Now()
+			if st.reportLoop(ticker, notify) {
+				return
+			}
+			st.shared.reporterMetrics.panics.Add(1)
+			if st.ctx.Err() != nil {
+				st.finalFlush(notify)
+				return
+			}
+			if st.clock.Now().Sub(start) >= maxRestartBackoff {
+				backoff = minRestartBackoff
+			}
+			atomic.StoreInt32(&st.shared.running, 0)
+			if !st.sleep(backoff) {
+				st.finalFlush(notify)
+				return
+			}
+			atomic.StoreInt32(&st.shared.running, 1)
+			backoff *= 2
+			if backoff > maxRestartBackoff {
+				backoff = maxRestartBackoff
+			}
+		}
+	}()
+}
+
+// sleep blocks for d, and returns false early when the context passed into
+// NewStatsd is done.
+func (st *Statsd) sleep(d time.Duration) bool {
+	t := st.clock.NewTicker(d)
+	defer t.Stop()
+	select {
+	case <-t.Chan():
+		return true
+	case <-st.ctx.Done():
+		return false
+	}
+}
+
+// reportLoop is the loop of the background reporting goroutine.
+//
+// It returns true when it's stopped by the context passed into NewStatsd,
+// or false when it's stopped by a panic (for example from a malformed metric),
+// which is recovered and logged, and the loop will be restarted after a
+// backoff.
+func (st *Statsd) reportLoop(ticker *reportingTicker, notify func(err error, final bool)) (stopped bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			st.logger.Log(
+				"during", "metricsbp.startReporter",
+				"msg", "recovered from a panic in the background reporting goroutine, restarting",
+				"panic", r,
+			)
+		}
+	}()
+
+	for {
+		select {
+		case <-ticker.Chan():
+			ticker.ticked()
+			if st.paused() {
+				continue
+			}
+			// The pending trigger, if any, is satisfied by this flush.
+			select {
+			case <-st.shared.flushNow:
+			default:
+			}
+			notify(st.flush(), false)
+		case <-st.shared.flushNow:
+			if st.paused() {
+				continue
+			}
+			notify(st.flush(), false)
+		case <-st.ctx.Done():
+			st.finalFlush(notify)
+			return true
+		}
+	}
+}
+
+// finalFlush flushes one more time before the background reporting goroutine
+// returns, and sets the result as the error returned by the first Close.
+//
+// A panic during the final flush is recovered and returned by Close as well,
+// as the background reporting goroutine won't be restarted.
+func (st *Statsd) finalFlush(notify func(err error, final bool)) {
+	defer func() {
+		if r := recover(); r != nil {
+			st.shared.finalErr = fmt.Errorf("metricsbp: panic during the final flush: %v", r)
+		}
+		st.logger.Log(
+			"during", "metricsbp.startReporter",
+			"msg", "stopped the background reporting goroutine",
+			"reason", st.ctx.Err(),
+			"err", st.shared.finalErr,
+		)
+	}()
+	st.shared.finalErr = st.flush()
+	notify(st.shared.finalErr, true)
+}
+
+// Running returns whether the background reporting goroutine is alive.
+//
+// It's meant for health checks to confirm that the metrics are flowing.
+// It returns false when there's no background reporting goroutine
+// (neither Address nor Writer was set), after it's stopped by Close or the
+// context passed into NewStatsd,
+// or during the backoff before restarting it after a panic.
+// The panics are counted by the "baseplate.metricsbp.reporter_panics"
+// counter.
+// It still returns true while paused (see Pause).
+func (st *Statsd) Running() bool {
+	return atomic.LoadInt32(&st.fallback().shared.running) != 0
+}
+
+// reportingTicker is the ticker of the background reporting goroutine,
+// applying ReportingJitter and JitterEveryInterval in StatsdConfig.
+//
+// It's only used by the background reporting goroutine,
+// so it's not safe for concurrent use.
+type reportingTicker struct {
+	clock    clock
+	interval time.Duration
+	jitter   time.Duration
+	every    bool
+
+	ticker ticker
+	// jittered is true when the current ticker is created with the jitter and
+	// should be replaced after it ticks.
+	jittered bool
+}
+
+func (st *Statsd) newReportingTicker(interval time.Duration) *reportingTicker {
+	t := &reportingTicker{
+		clock:    st.clock,
+		interval: interval,
+		jitter:   st.cfg.ReportingJitter,
+		every:    st.cfg.JitterEveryInterval,
+	}
+	t.reset()
+	return t
+}
+
+// reset replaces the current ticker, if any,
+// with a new one ticking after interval plus a random jitter,
+// or a plain one ticking every interval when there's no jitter to apply.
+func (t *reportingTicker) reset() {
+	first := t.ticker == nil
+	if !first {
+		t.ticker.Stop()
+	}
+	d := t.interval
+	t.jittered = false
+	if t.jitter > 0 && (first || t.every) {
+		d += time.Duration(randbp.R.Int63n(int64(t.jitter)))
+		t.jittered = true
+	}
+	t.ticker = t.clock.NewTicker(d)
+}
+
+func (t *reportingTicker) Chan() <-chan time.Time {
+	return t.ticker.Chan()
+}
+
+func (t *reportingTicker) Stop() {
+	t.ticker.Stop()
+}
+
+// ticked must be called after every tick received from Chan.
+func (t *reportingTicker) ticked() {
+	if t.jittered {
+		t.reset()
+	}
+}
+
+// sendErrorNotifier returns the function to be called by the background
+// reporting goroutine with the result of every flush,
+// to pass the errors to OnSendError in StatsdConfig.
+//
+// OnSendError is called from its own goroutine,
+// at most once per sendErrorInterval,
+// and the errors are dropped while the previous one is still being handled,
+// so it never blocks the background reporting goroutine.
+//
+// The error from the final flush (final is true) bypasses the limit and
+// replaces the pending one, if any, so it's always passed to OnSendError.
+//
+// It must only be called by startReporter, after st.shared.done is created.
+func (st *Statsd) sendErrorNotifier() func(err error, final bool) {
+	handler := st.cfg.OnSendError
+	if handler == nil {
+		return func(error, bool) {}
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		for {
+			select {
+			case err := <-errs:
+				handler(err)
+			case <-st.shared.done:
+				// The error from the final flush, if any.
+				select {
+				case err := <-errs:
+					handler(err)
+				default:
+				}
+				return
+			}
+		}
+	}()
+
+	var last time.Time
+	return func(err error, final bool) {
+		if err == nil {
+			return
+		}
+		if final {
+			// Only the background reporting goroutine sends to errs,
+			// so after dropping the pending one the send never blocks.
+			select {
+			case <-errs:
+			default:
+			}
+			errs <- err
+			return
+		}
+		now := st.clock.Now()
+		if !last.IsZero() && now.Sub(last) < sendErrorInterval {
+			return
+		}
+		select {
+		case errs <- err:
+			last = now
+		default:
+		}
+	}
+}
+
+// flush writes all the metrics to the statsd collector and the sinks,
+// and records the result into the reporter metrics.
+//
+// It must only be called when st.writer is non-nil.
+func (st *Statsd) flush() error {
+	if st.shared.reporterMetrics.heartbeat != nil {
+		// Added before the write, so it's in this write.
+		st.shared.reporterMetrics.heartbeat.Add(1)
+	}
+	start := st.clock.Now()
+	n, err := st.writer.doWrite(st, st.logger)
+	var failures int
+	if err != nil {
+		failures++
+	}
+	for _, s := range st.sinks {
+		m, sinkErr := s.writer.doWrite(s, kitlog.With(st.logger, "sink", s.target))
+		n += m
+		if sinkErr != nil {
+			failures++
+			if err == nil {
+				err = sinkErr
+			}
+		}
+	}
+	st.shared.reporterMetrics.flushDuration.Observe(
+		float64(st.clock.Now().Sub(start)) / timerUnit,
+	)
+	st.shared.reporterMetrics.sentBytes.Add(float64(n))
+	if failures > 0 {
+		st.shared.reporterMetrics.sendErrors.Add(float64(failures))
+	} else {
+		st.shared.reporterMetrics.flushes.Add(1)
+		if st.debugLogger != log.KitWrapper(log.ZapNopLevel) {
+			st.debugLogger.Log(
+				"during", "metricsbp.flush",
+				"msg", "flushed metrics",
+				"bytes", n,
+			)
+		}
+	}
+	return err
+}
+
+// TriggerFlush signals the background reporting goroutine to flush on its next
+// loop iteration, without waiting for the next tick.
+//
+// It's useful for low-traffic and bursty workloads,
+// for example a batch processor flushing right after finishing a unit of work,
+// without shrinking ReportingInterval globally.
+//
+// Unlike Flush, it never blocks, and multiple calls before the flush happens
+// are coalesced into a single flush.
+// It's a no-op while paused (see Pause),
+// or when there's no background reporting goroutine.
+func (st *Statsd) TriggerFlush() {
+	st.fallback().triggerFlush()
+}
+
+// triggerFlush is TriggerFlush without the fallback to M.
+func (st *Statsd) triggerFlush() {
+	select {
+	case st.shared.flushNow <- struct{}{}:
+	default:
+		// There's already a pending trigger.
+	}
+}
@@ -0,0 +1,94 @@
+package metricsbp_test
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/reddit/baseplate.go/metricsbp"
+)
+
+func TestReporterMetrics(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	st := metricsbp.NewStatsd(
+		context.Background(),
+		metricsbp.StatsdConfig{
+			Address:           pc.LocalAddr().String(),
+			ReportingInterval: time.Hour,
+		},
+	)
+	defer st.Close()
+	st.Counter("foo").Add(1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	read := func() string {
+		t.Helper()
+		if err := st.Flush(ctx); err != nil {
+			t.Fatalf("Flush failed: %v", err)
+		}
+		pc.SetReadDeadline(time.Now().Add(time.Second * 5))
+		buf := make([]byte, 4096)
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(buf[:n])
+	}
+
+	first := read()
+	// The reporter metrics of the first flush are reported in the second flush.
+	second := read()
+	for _, expected := range []string{
+		"baseplate.metricsbp.flushes:1.000000|c\n",
+		"baseplate.metricsbp.sent_bytes:" + strconv.Itoa(len(first)) + ".000000|c\n",
+	} {
+		if !strings.Contains(second, expected) {
+			t.Errorf("Expected %q in %q", expected, second)
+		}
+	}
+	if !strings.Contains(second, "baseplate.metricsbp.flush_duration:") {
+		t.Errorf("Expected flush_duration in %q", second)
+	}
+	if strings.Contains(second, "send_errors") {
+		t.Errorf("Did not expect send_errors in %q", second)
+	}
+}
+
+func TestReporterMetricsSendErrors(t *testing.T) {
+	// The socket does not exist so all writes will fail.
+	path := filepath.Join(t.TempDir(), "statsd.socket")
+	st := metricsbp.NewStatsd(
+		context.Background(),
+		metricsbp.StatsdConfig{
+			Address:           "unixgram://" + path,
+			ReportingInterval: time.Hour,
+		},
+	)
+	defer st.Close()
+	st.Counter("foo").Add(1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	if err := st.Flush(ctx); err == nil {
+		t.Fatal("Expected Flush to fail")
+	}
+
+	var sb strings.Builder
+	if _, err := st.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	const expected = "baseplate.metricsbp.send_errors:1.000000|c\n"
+	if !strings.Contains(sb.String(), expected) {
+		t.Errorf("Expected %q in %q", expected, sb.String())
+	}
+}
@@ -0,0 +1,84 @@
+package metricsbp
+
+import (
+	"io/ioutil"
+	"sync/atomic"
+
+	"github.com/go-kit/kit/log"
+)
+
+// retentionLimiter limits the number of observations retained in memory by a
+// Statsd without Address or Writer, or by a paused Statsd,
+// by discarding all of them once the limit is exceeded.
+type retentionLimiter struct {
+	max     int64
+	during  string
+	discard func()
+	logger  log.Logger
+
+	// active, when non-nil, reports whether the observations are currently
+	// retained, the observations are not counted when it returns false.
+	active func() bool
+
+	count  int64
+	logged int32
+}
+
+// newRetentionLimiter creates a retentionLimiter.
+//
+// It returns nil when max is not positive,
+// and a nil *retentionLimiter does not limit anything.
+//
+// during is the name of the config used in the log.
+func newRetentionLimiter(max int, during string, discard func(), logger log.Logger) *retentionLimiter {
+	if max <= 0 {
+		return nil
+	}
+	return &retentionLimiter{
+		max:     int64(max),
+		during:  during,
+		discard: discard,
+		logger:  logger,
+	}
+}
+
+// observe records a new retained observation,
+// and discards all the retained ones if the limit is exceeded.
+func (rl *retentionLimiter) observe() {
+	if rl == nil || (rl.active != nil && !rl.active()) {
+		return
+	}
+	n := atomic.AddInt64(&rl.count, 1)
+	if n <= rl.max || !atomic.CompareAndSwapInt64(&rl.count, n, 0) {
+		return
+	}
+	rl.discard()
+	if atomic.CompareAndSwapInt32(&rl.logged, 0, 1) {
+		rl.logger.Log(
+			"during", rl.during,
+			"msg", "discarded unreported metrics, further discards will not be logged",
+			"max", rl.max,
+		)
+	}
+}
+
+// reset resets the count after the retained observations are written.
+func (rl *retentionLimiter) reset() {
+	if rl == nil {
+		return
+	}
+	atomic.StoreInt64(&rl.count, 0)
+}
+
+// discardRetained discards all the metrics retained in memory.
+func (st *Statsd) discardRetained() {
+	st.shared.dumped.reset()
+	st.onDiscard.run()
+	st.statsd.WriteTo(ioutil.Discard)
+	st.sets.WriteTo(ioutil.Discard)
+	st.counted.WriteTo(ioutil.Discard)
+	st.bucketed.WriteTo(ioutil.Discard)
+	for _, s := range st.sinks {
+		s.WriteTo(ioutil.Discard)
+	}
+}
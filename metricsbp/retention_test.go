@@ -0,0 +1,78 @@
+package metricsbp_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/reddit/baseplate.go/metricsbp"
+)
+
+func TestMaxUnreportedObservations(t *testing.T) {
+	st := metricsbp.NewStatsd(
+		context.Background(),
+		metricsbp.StatsdConfig{
+			MaxUnreportedObservations: 3,
+		},
+	)
+	write := func() string {
+		t.Helper()
+		var sb strings.Builder
+		if _, err := st.WriteTo(&sb); err != nil {
+			t.Fatal(err)
+		}
+		return sb.String()
+	}
+
+	counter := st.Counter("counter")
+	counter.Add(1)
+	counter.Add(1)
+	st.Set("set").Add("a")
+	// Under the limit, nothing is discarded.
+	const expected = "counter:2.000000|c\nset:a|s\n"
+	if got := write(); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+
+	// WriteTo resets the count.
+	for i := 0; i < 3; i++ {
+		counter.Add(1)
+	}
+	if got, expected := write(), "counter:3.000000|c\n"; got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+
+	// Exceeding the limit discards everything retained.
+	for i := 0; i < 4; i++ {
+		counter.Add(1)
+	}
+	st.Histogram("histogram").Observe(1)
+	if got, expected := write(), "histogram:1.000000|h\n"; got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
+func TestMaxUnreportedObservationsWithWriter(t *testing.T) {
+	var buf bytes.Buffer
+	st := metricsbp.NewStatsd(
+		context.Background(),
+		metricsbp.StatsdConfig{
+			Writer:                    &buf,
+			MaxUnreportedObservations: 1,
+		},
+	)
+	defer st.Close()
+
+	counter := st.Counter("counter")
+	for i := 0; i < 3; i++ {
+		counter.Add(1)
+	}
+	if err := st.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	const expected = "counter:3.000000|c"
+	if !strings.Contains(buf.String(), expected) {
+		t.Errorf("Expected %q in %q", expected, buf.String())
+	}
+}
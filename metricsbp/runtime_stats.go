@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 	"runtime"
+	"runtime/pprof"
+	"strconv"
 	"sync"
 	"time"
 
@@ -16,16 +18,76 @@ var SysStatsTickerInterval = time.Second * 10
 
 var activeRequestCounter int64
 
-type cpuStats struct {
-	NumGoroutine int
-	NumCgoCall   int64
-}
+// SysStats is a bitmask of the groups of sys stats reported by
+// RunSysStatsWithConfig.
+type SysStats uint
+
+// Enums for SysStats.
+const (
+	// SysStatsCPU reports "cpu.goroutines" and "cpu.cgo_calls".
+	SysStatsCPU SysStats = 1 << iota
+
+	// SysStatsGC reports "mem.gc.*",
+	// including "mem.gc.count_delta" (the number of GCs during the interval)
+	// and "mem.gc.pauses" (a timing histogram of every GC pause during the
+	// interval, in milliseconds, which can be used to get the percentiles).
+	SysStatsGC
+
+	// SysStatsMem reports "mem.*" stats other than "mem.gc.*",
+	// including heap and stack stats,
+	// and the allocation rates during the interval:
+	// "mem.alloc_rate" (bytes per second),
+	// "mem.malloc_rate" and "mem.free_rate" (objects per second).
+	SysStatsMem
 
-func pullRuntimeStats() (cpu cpuStats, mem runtime.MemStats) {
-	runtime.ReadMemStats(&mem)
-	cpu.NumGoroutine = runtime.NumGoroutine()
-	cpu.NumCgoCall = runtime.NumCgoCall()
-	return
+	// SysStatsActiveRequests reports "active_requests",
+	// the number of active requests counted by CreateServerSpanHook.
+	SysStatsActiveRequests
+
+	// SysStatsProcess reports "process.threads",
+	// the number of OS threads created by the Go runtime,
+	// and "process.open_fds",
+	// the number of open file descriptors of the current process.
+	//
+	// "process.open_fds" is only reported on Linux,
+	// by reading /proc/self/fd.
+	// On other platforms it's skipped.
+	SysStatsProcess
+
+	// SysStatsAll reports all the stats above.
+	SysStatsAll = SysStatsCPU | SysStatsGC | SysStatsMem | SysStatsActiveRequests | SysStatsProcess
+)
+
+// SysStatsConfig is the config used by RunSysStatsWithConfig.
+type SysStatsConfig struct {
+	// The interval to pull and report sys stats.
+	//
+	// Optional, default to SysStatsTickerInterval.
+	//
+	// The delta and rate stats (for example "mem.gc.count_delta" and
+	// "mem.alloc_rate") are calculated over this interval.
+	// As reading runtime.MemStats briefly stops the world,
+	// avoid setting it too short when SysStatsGC or SysStatsMem is reported.
+	Interval time.Duration
+
+	// The stats to report.
+	//
+	// Optional, default to SysStatsAll.
+	//
+	// Please note that both SysStatsGC and SysStatsMem require reading
+	// runtime.MemStats, which briefly stops the world.
+	// When neither of them is set, runtime.MemStats will not be read.
+	Stats SysStats
+
+	// When positive, every tick with more goroutines than GoroutineThreshold
+	// adds 1 to the "runtime.cpu.goroutines.exceeded" counter,
+	// tagged with the threshold,
+	// so it can be alerted on before a goroutine leak causes trouble.
+	//
+	// It's reported regardless of Stats.
+	//
+	// Optional, default to 0 (disabled).
+	GoroutineThreshold int
 }
 
 // RunSysStats starts a goroutine to periodically pull and report sys stats.
@@ -33,96 +95,219 @@ func pullRuntimeStats() (cpu cpuStats, mem runtime.MemStats) {
 // All the sys stats will be reported as RuntimeGauges.
 //
 // Canceling the context passed into NewStatsd will stop this goroutine.
+//
+// It's the same as calling RunSysStatsWithConfig with zero value config,
+// which reports all stats on SysStatsTickerInterval.
+// As some of the stats require reading runtime.MemStats,
+// which briefly stops the world,
+// use RunSysStatsWithConfig to configure the interval or skip those stats.
 func (st *Statsd) RunSysStats() {
+	st.RunSysStatsWithConfig(SysStatsConfig{})
+}
+
+// RunSysStatsWithConfig starts a goroutine to periodically pull and report sys
+// stats, with the interval and the stats to report configurable.
+//
+// All the sys stats will be reported as RuntimeGauges,
+// except for the counter of GoroutineThreshold in cfg.
+//
+// Canceling the context passed into NewStatsd will stop this goroutine.
+func (st *Statsd) RunSysStatsWithConfig(cfg SysStatsConfig) {
 	st = st.fallback()
 
-	// init the gauges
-	// cpu
-	cpuGoroutines := st.RuntimeGauge("cpu.goroutines")
-	cpuCgoCalls := st.RuntimeGauge("cpu.cgo_calls")
-	// gc
-	gcSys := st.RuntimeGauge("mem.gc.sys")
-	gcNext := st.RuntimeGauge("mem.gc.next")
-	gcLast := st.RuntimeGauge("mem.gc.last")
-	gcPauseTotal := st.RuntimeGauge("mem.gc.pause_total")
-	gcPause := st.RuntimeGauge("mem.gc.pause")
-	gcCount := st.RuntimeGauge("mem.gc.count")
-	// general
-	memAlloc := st.RuntimeGauge("mem.alloc")
-	memTotal := st.RuntimeGauge("mem.total")
-	memSys := st.RuntimeGauge("mem.sys")
-	memLookups := st.RuntimeGauge("mem.lookups")
-	memMalloc := st.RuntimeGauge("mem.malloc")
-	memFrees := st.RuntimeGauge("mem.frees")
-	// heap
-	heapAlloc := st.RuntimeGauge("mem.heap.alloc")
-	heapSys := st.RuntimeGauge("mem.heap.sys")
-	heapIdle := st.RuntimeGauge("mem.heap.idle")
-	heapInuse := st.RuntimeGauge("mem.heap.inuse")
-	heapReleased := st.RuntimeGauge("mem.heap.released")
-	heapObjects := st.RuntimeGauge("mem.heap.objects")
-	// stack
-	stackInuse := st.RuntimeGauge("mem.stack.inuse")
-	stackSys := st.RuntimeGauge("mem.stack.sys")
-	mspanInuse := st.RuntimeGauge("mem.stack.mspan_inuse")
-	mspanSys := st.RuntimeGauge("mem.stack.mspan_sys")
-	mcacheInuse := st.RuntimeGauge("mem.stack.mcache_inuse")
-	mcacheSys := st.RuntimeGauge("mem.stack.mcache_sys")
-	// other
-	memOther := st.RuntimeGauge("mem.othersys")
-	activeRequests := st.RuntimeGauge("active_requests")
+	interval := cfg.Interval
+	if interval <= 0 {
+		interval = SysStatsTickerInterval
+	}
+	stats := cfg.Stats
+	if stats == 0 {
+		stats = SysStatsAll
+	}
+
+	// last and lastTime are the MemStats read by the previous tick,
+	// used to calculate the deltas and rates.
+	var (
+		last     runtime.MemStats
+		lastTime time.Time
+		now      time.Time
+	)
+	var reporters []func(mem *runtime.MemStats)
+	if stats&SysStatsCPU != 0 {
+		cpuGoroutines := st.RuntimeGauge("cpu.goroutines")
+		cpuCgoCalls := st.RuntimeGauge("cpu.cgo_calls")
+		reporters = append(reporters, func(_ *runtime.MemStats) {
+			cpuGoroutines.Set(float64(runtime.NumGoroutine()))
+			cpuCgoCalls.Set(float64(runtime.NumCgoCall()))
+		})
+	}
+	if cfg.GoroutineThreshold > 0 {
+		threshold := cfg.GoroutineThreshold
+		exceeded := st.Counter(runtimeGaugePrefix+goroutinesExceededCounter).
+			With(getRuntimeGaugeTags()...).
+			With("threshold", strconv.Itoa(threshold))
+		reporters = append(reporters, func(_ *runtime.MemStats) {
+			if runtime.NumGoroutine() > threshold {
+				exceeded.Add(1)
+			}
+		})
+	}
+	if stats&SysStatsGC != 0 {
+		gcSys := st.RuntimeGauge("mem.gc.sys")
+		gcNext := st.RuntimeGauge("mem.gc.next")
+		gcLast := st.RuntimeGauge("mem.gc.last")
+		gcPauseTotal := st.RuntimeGauge("mem.gc.pause_total")
+		gcPause := st.RuntimeGauge("mem.gc.pause")
+		gcCount := st.RuntimeGauge("mem.gc.count")
+		gcCountDelta := st.RuntimeGauge("mem.gc.count_delta")
+		gcPauses := st.Timing(runtimeGaugePrefix + "mem.gc.pauses").With(getRuntimeGaugeTags()...)
+		reporters = append(reporters, func(mem *runtime.MemStats) {
+			gcSys.Set(float64(mem.GCSys))
+			gcNext.Set(float64(mem.NextGC))
+			gcLast.Set(float64(mem.LastGC))
+			gcPauseTotal.Set(float64(mem.PauseTotalNs))
+			gcPause.Set(float64(mem.PauseNs[(mem.NumGC+255)%256]))
+			gcCount.Set(float64(mem.NumGC))
+
+			gcCountDelta.Set(float64(mem.NumGC - last.NumGC))
+			for _, pause := range gcPausesSince(mem, last.NumGC) {
+				gcPauses.Observe(float64(pause) / timerUnit)
+			}
+		})
+	}
+	if stats&SysStatsMem != 0 {
+		// general
+		memAlloc := st.RuntimeGauge("mem.alloc")
+		memTotal := st.RuntimeGauge("mem.total")
+		memSys := st.RuntimeGauge("mem.sys")
+		memLookups := st.RuntimeGauge("mem.lookups")
+		memMalloc := st.RuntimeGauge("mem.malloc")
+		memFrees := st.RuntimeGauge("mem.frees")
+		// heap
+		heapAlloc := st.RuntimeGauge("mem.heap.alloc")
+		heapSys := st.RuntimeGauge("mem.heap.sys")
+		heapIdle := st.RuntimeGauge("mem.heap.idle")
+		heapInuse := st.RuntimeGauge("mem.heap.inuse")
+		heapReleased := st.RuntimeGauge("mem.heap.released")
+		heapObjects := st.RuntimeGauge("mem.heap.objects")
+		// stack
+		stackInuse := st.RuntimeGauge("mem.stack.inuse")
+		stackSys := st.RuntimeGauge("mem.stack.sys")
+		mspanInuse := st.RuntimeGauge("mem.stack.mspan_inuse")
+		mspanSys := st.RuntimeGauge("mem.stack.mspan_sys")
+		mcacheInuse := st.RuntimeGauge("mem.stack.mcache_inuse")
+		mcacheSys := st.RuntimeGauge("mem.stack.mcache_sys")
+		// other
+		memOther := st.RuntimeGauge("mem.othersys")
+		// rates
+		allocRate := st.RuntimeGauge("mem.alloc_rate")
+		mallocRate := st.RuntimeGauge("mem.malloc_rate")
+		freeRate := st.RuntimeGauge("mem.free_rate")
+		reporters = append(reporters, func(mem *runtime.MemStats) {
+			// general
+			memAlloc.Set(float64(mem.Alloc))
+			memTotal.Set(float64(mem.TotalAlloc))
+			memSys.Set(float64(mem.Sys))
+			memLookups.Set(float64(mem.Lookups))
+			memMalloc.Set(float64(mem.Mallocs))
+			memFrees.Set(float64(mem.Frees))
+			// heap
+			heapAlloc.Set(float64(mem.HeapAlloc))
+			heapSys.Set(float64(mem.HeapSys))
+			heapIdle.Set(float64(mem.HeapIdle))
+			heapInuse.Set(float64(mem.HeapInuse))
+			heapReleased.Set(float64(mem.HeapReleased))
+			heapObjects.Set(float64(mem.HeapObjects))
+			// stack
+			stackInuse.Set(float64(mem.StackInuse))
+			stackSys.Set(float64(mem.StackSys))
+			mspanInuse.Set(float64(mem.MSpanInuse))
+			mspanSys.Set(float64(mem.MSpanSys))
+			mcacheInuse.Set(float64(mem.MCacheInuse))
+			mcacheSys.Set(float64(mem.MCacheSys))
+			// other
+			memOther.Set(float64(mem.OtherSys))
+			// rates
+			if seconds := now.Sub(lastTime).Seconds(); seconds > 0 {
+				allocRate.Set(float64(mem.TotalAlloc-last.TotalAlloc) / seconds)
+				mallocRate.Set(float64(mem.Mallocs-last.Mallocs) / seconds)
+				freeRate.Set(float64(mem.Frees-last.Frees) / seconds)
+			}
+		})
+	}
+	if stats&SysStatsActiveRequests != 0 {
+		activeRequests := st.RuntimeGauge("active_requests")
+		reporters = append(reporters, func(_ *runtime.MemStats) {
+			activeRequests.Set(float64(st.getActiveRequests()))
+		})
+	}
+	if stats&SysStatsProcess != 0 {
+		threads := st.RuntimeGauge("process.threads")
+		threadProfile := pprof.Lookup("threadcreate")
+		reporters = append(reporters, func(_ *runtime.MemStats) {
+			threads.Set(float64(threadProfile.Count()))
+		})
+		if openFDsSupported {
+			openFDs := st.RuntimeGauge("process.open_fds")
+			reporters = append(reporters, func(_ *runtime.MemStats) {
+				if n, err := countOpenFDs(); err == nil {
+					openFDs.Set(float64(n))
+				}
+			})
+		}
+	}
+	readMem := stats&(SysStatsGC|SysStatsMem) != 0
+	if readMem {
+		runtime.ReadMemStats(&last)
+		lastTime = st.clock.Now()
+	}
 
 	go func() {
-		ticker := time.NewTicker(SysStatsTickerInterval)
+		ticker := st.clock.NewTicker(interval)
 		defer ticker.Stop()
 
+		var mem runtime.MemStats
 		for {
 			select {
 			case <-st.ctx.Done():
 				return
-			case <-ticker.C:
-				cpu, mem := pullRuntimeStats()
-				// cpu
-				cpuGoroutines.Set(float64(cpu.NumGoroutine))
-				cpuCgoCalls.Set(float64(cpu.NumCgoCall))
-				// gc
-				gcSys.Set(float64(mem.GCSys))
-				gcNext.Set(float64(mem.NextGC))
-				gcLast.Set(float64(mem.LastGC))
-				gcPauseTotal.Set(float64(mem.PauseTotalNs))
-				gcPause.Set(float64(mem.PauseNs[(mem.NumGC+255)%256]))
-				gcCount.Set(float64(mem.NumGC))
-				// general
-				memAlloc.Set(float64(mem.Alloc))
-				memTotal.Set(float64(mem.TotalAlloc))
-				memSys.Set(float64(mem.Sys))
-				memLookups.Set(float64(mem.Lookups))
-				memMalloc.Set(float64(mem.Mallocs))
-				memFrees.Set(float64(mem.Frees))
-				// heap
-				heapAlloc.Set(float64(mem.HeapAlloc))
-				heapSys.Set(float64(mem.HeapSys))
-				heapIdle.Set(float64(mem.HeapIdle))
-				heapInuse.Set(float64(mem.HeapInuse))
-				heapReleased.Set(float64(mem.HeapReleased))
-				heapObjects.Set(float64(mem.HeapObjects))
-				// stack
-				stackInuse.Set(float64(mem.StackInuse))
-				stackSys.Set(float64(mem.StackSys))
-				mspanInuse.Set(float64(mem.MSpanInuse))
-				mspanSys.Set(float64(mem.MSpanSys))
-				mcacheInuse.Set(float64(mem.MCacheInuse))
-				mcacheSys.Set(float64(mem.MCacheSys))
-				// other
-				memOther.Set(float64(mem.OtherSys))
-				activeRequests.Set(float64(st.getActiveRequests()))
+			case <-ticker.Chan():
+				if readMem {
+					runtime.ReadMemStats(&mem)
+					now = st.clock.Now()
+				}
+				for _, report := range reporters {
+					report(&mem)
+				}
+				last, lastTime = mem, now
 			}
 		}
 	}()
 }
 
+// gcPausesSince returns the durations of the GC pauses happened after the
+// lastNumGC-th GC.
+//
+// As runtime.MemStats only keeps the most recent 256 GC pauses,
+// older ones are dropped.
+func gcPausesSince(mem *runtime.MemStats, lastNumGC uint32) []time.Duration {
+	n := mem.NumGC - lastNumGC
+	if n > uint32(len(mem.PauseNs)) {
+		n = uint32(len(mem.PauseNs))
+	}
+	pauses := make([]time.Duration, 0, n)
+	for i := mem.NumGC - n + 1; i <= mem.NumGC && i > 0; i++ {
+		pauses = append(pauses, time.Duration(mem.PauseNs[(i+255)%256]))
+	}
+	return pauses
+}
+
 const runtimeGaugePrefix = "runtime."
 
+// goroutinesExceededCounter is the counter reported when the number of
+// goroutines exceeds GoroutineThreshold in SysStatsConfig,
+// without runtimeGaugePrefix.
+const goroutinesExceededCounter = "cpu.goroutines.exceeded"
+
 // runtimeGaugeTags will be initialized by runtimeGaugeTagsOnce,
 // in getRuntimeGaugeTags.
 var (
@@ -0,0 +1,90 @@
+package metricsbp
+
+import (
+	"os"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestGCPausesSince(t *testing.T) {
+	var mem runtime.MemStats
+	mem.NumGC = 3
+	mem.PauseNs[0] = 1
+	mem.PauseNs[1] = 2
+	mem.PauseNs[2] = 3
+
+	for _, c := range []struct {
+		label     string
+		lastNumGC uint32
+		expected  []time.Duration
+	}{
+		{
+			label:     "all",
+			lastNumGC: 0,
+			expected:  []time.Duration{1, 2, 3},
+		},
+		{
+			label:     "some",
+			lastNumGC: 1,
+			expected:  []time.Duration{2, 3},
+		},
+		{
+			label:     "none",
+			lastNumGC: 3,
+			expected:  []time.Duration{},
+		},
+	} {
+		t.Run(c.label, func(t *testing.T) {
+			pauses := gcPausesSince(&mem, c.lastNumGC)
+			if !reflect.DeepEqual(pauses, c.expected) {
+				t.Errorf("Expected %v, got %v", c.expected, pauses)
+			}
+		})
+	}
+
+	t.Run("wrap", func(t *testing.T) {
+		var mem runtime.MemStats
+		mem.NumGC = 300
+		for i := range mem.PauseNs {
+			mem.PauseNs[i] = uint64(i)
+		}
+		pauses := gcPausesSince(&mem, 0)
+		if len(pauses) != len(mem.PauseNs) {
+			t.Fatalf("Expected %d pauses, got %d", len(mem.PauseNs), len(pauses))
+		}
+		// The 300th GC is stored at index (300-1)%256 = 43.
+		if last := pauses[len(pauses)-1]; last != 43 {
+			t.Errorf("Expected the last pause to be 43, got %v", last)
+		}
+	})
+}
+
+func TestCountOpenFDs(t *testing.T) {
+	if !openFDsSupported {
+		t.Skipf("Counting open fds is not supported on %s", runtime.GOOS)
+	}
+
+	before, err := countOpenFDs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	const n = 10
+	for i := 0; i < n; i++ {
+		f, err := os.Open(os.DevNull)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+	}
+	after, err := countOpenFDs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Other goroutines (e.g. connection managers) could also open or close fds
+	// concurrently, so only do a loose check here.
+	if after-before < n/2 {
+		t.Errorf("Expected about %d more open fds after opening files, got %d -> %d", n, before, after)
+	}
+}
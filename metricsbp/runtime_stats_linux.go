@@ -0,0 +1,26 @@
+// +build linux
+
+package metricsbp
+
+import (
+	"os"
+)
+
+const openFDsSupported = true
+
+// countOpenFDs returns the number of open file descriptors of the current
+// process by reading /proc/self/fd.
+func countOpenFDs() (int, error) {
+	f, err := os.Open("/proc/self/fd")
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	names, err := f.Readdirnames(-1)
+	if err != nil {
+		return 0, err
+	}
+	// Exclude the one we opened to read the directory.
+	return len(names) - 1, nil
+}
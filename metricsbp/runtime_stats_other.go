@@ -0,0 +1,13 @@
+// +build !linux
+
+package metricsbp
+
+import (
+	"errors"
+)
+
+const openFDsSupported = false
+
+func countOpenFDs() (int, error) {
+	return 0, errors.New("metricsbp: counting open file descriptors is only supported on linux")
+}
@@ -0,0 +1,138 @@
+package metricsbp_test
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/reddit/baseplate.go/metricsbp"
+)
+
+// waitForSysStats calls WriteTo on st until the output contains expected,
+// and returns all the output written.
+func waitForSysStats(t *testing.T, st *metricsbp.Statsd, expected string) string {
+	t.Helper()
+
+	var sb strings.Builder
+	deadline := time.Now().Add(time.Second * 5)
+	for time.Now().Before(deadline) {
+		if _, err := st.WriteTo(&sb); err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(sb.String(), expected) {
+			return sb.String()
+		}
+		time.Sleep(time.Millisecond * 5)
+	}
+	t.Fatalf("Timed out waiting for %q, got %q", expected, sb.String())
+	return ""
+}
+
+func TestRunSysStatsWithConfig(t *testing.T) {
+	st := metricsbp.NewStatsd(context.Background(), metricsbp.StatsdConfig{})
+	defer st.Close()
+	st.RunSysStatsWithConfig(metricsbp.SysStatsConfig{
+		Interval: time.Millisecond,
+		Stats:    metricsbp.SysStatsCPU,
+	})
+
+	output := waitForSysStats(t, st, "runtime.cpu.goroutines,")
+	if strings.Contains(output, "runtime.mem.") {
+		t.Errorf("Expected no mem stats, got %q", output)
+	}
+	if strings.Contains(output, "runtime.active_requests") {
+		t.Errorf("Expected no active requests stats, got %q", output)
+	}
+}
+
+func TestRunSysStatsDefault(t *testing.T) {
+	st := metricsbp.NewStatsd(context.Background(), metricsbp.StatsdConfig{})
+	defer st.Close()
+	st.RunSysStatsWithConfig(metricsbp.SysStatsConfig{
+		Interval: time.Millisecond,
+	})
+
+	// active_requests is the last one reported in each tick.
+	output := waitForSysStats(t, st, "runtime.active_requests,")
+	for _, expected := range []string{
+		"runtime.cpu.goroutines,",
+		"runtime.mem.gc.count,",
+		"runtime.mem.heap.alloc,",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected %q in %q", expected, output)
+		}
+	}
+}
+
+func TestRunSysStatsGCPauses(t *testing.T) {
+	st := metricsbp.NewStatsd(context.Background(), metricsbp.StatsdConfig{})
+	defer st.Close()
+	st.RunSysStatsWithConfig(metricsbp.SysStatsConfig{
+		Interval: time.Millisecond,
+		Stats:    metricsbp.SysStatsGC | metricsbp.SysStatsMem,
+	})
+	runtime.GC()
+
+	output := waitForSysStats(t, st, "runtime.mem.gc.pauses,")
+	if !strings.Contains(output, "runtime.mem.gc.count_delta,") {
+		t.Errorf("Expected count_delta in %q", output)
+	}
+	output = waitForSysStats(t, st, "runtime.mem.alloc_rate,")
+	if strings.Contains(output, "runtime.cpu.") {
+		t.Errorf("Expected no cpu stats, got %q", output)
+	}
+}
+
+func TestRunSysStatsProcess(t *testing.T) {
+	st := metricsbp.NewStatsd(context.Background(), metricsbp.StatsdConfig{})
+	defer st.Close()
+	st.RunSysStatsWithConfig(metricsbp.SysStatsConfig{
+		Interval: time.Millisecond,
+		Stats:    metricsbp.SysStatsProcess,
+	})
+
+	if runtime.GOOS == "linux" {
+		output := waitForSysStats(t, st, "runtime.process.open_fds,")
+		if !strings.Contains(output, "runtime.process.threads,") {
+			t.Errorf("Expected threads in %q", output)
+		}
+	} else {
+		output := waitForSysStats(t, st, "runtime.process.threads,")
+		if strings.Contains(output, "runtime.process.open_fds") {
+			t.Errorf("Expected no open_fds on %s, got %q", runtime.GOOS, output)
+		}
+	}
+}
+
+func TestRunSysStatsGoroutineThreshold(t *testing.T) {
+	st := metricsbp.NewStatsd(context.Background(), metricsbp.StatsdConfig{})
+	defer st.Close()
+	st.RunSysStatsWithConfig(metricsbp.SysStatsConfig{
+		Interval:           time.Millisecond,
+		Stats:              metricsbp.SysStatsActiveRequests,
+		GoroutineThreshold: 1,
+	})
+
+	output := waitForSysStats(t, st, "runtime.cpu.goroutines.exceeded,")
+	if !strings.Contains(output, "threshold=1") {
+		t.Errorf("Expected the threshold tag in %q", output)
+	}
+
+	t.Run("not-exceeded", func(t *testing.T) {
+		st := metricsbp.NewStatsd(context.Background(), metricsbp.StatsdConfig{})
+		defer st.Close()
+		st.RunSysStatsWithConfig(metricsbp.SysStatsConfig{
+			Interval:           time.Millisecond,
+			Stats:              metricsbp.SysStatsActiveRequests,
+			GoroutineThreshold: 1 << 20,
+		})
+
+		output := waitForSysStats(t, st, "runtime.active_requests,")
+		if strings.Contains(output, "runtime.cpu.goroutines.exceeded") {
+			t.Errorf("Expected no exceeded counter, got %q", output)
+		}
+	})
+}
@@ -51,3 +51,34 @@ func (h SampledHistogram) Observe(value float64) {
 		h.Histogram.Observe(value)
 	}
 }
+
+// SampledGauge is a metrics.Gauge implementation that actually sample the Set
+// calls.
+//
+// The Add calls are never sampled,
+// as dropping any delta would make the value of the gauge wrong forever.
+type SampledGauge struct {
+	Gauge metrics.Gauge
+
+	Rate float64
+}
+
+// With implements metrics.Gauge.
+func (g SampledGauge) With(labelValues ...string) metrics.Gauge {
+	return SampledGauge{
+		Gauge: g.Gauge.With(labelValues...),
+		Rate:  g.Rate,
+	}
+}
+
+// Set implements metrics.Gauge.
+func (g SampledGauge) Set(value float64) {
+	if randbp.ShouldSampleWithRate(g.Rate) {
+		g.Gauge.Set(value)
+	}
+}
+
+// Add implements metrics.Gauge.
+func (g SampledGauge) Add(delta float64) {
+	g.Gauge.Add(delta)
+}
@@ -421,3 +421,120 @@ func TestSampledCounter(t *testing.T) {
 		)
 	}
 }
+
+func TestSampledGauge(t *testing.T) {
+	st := NewStatsd(context.Background(), StatsdConfig{})
+
+	t.Run("never", func(t *testing.T) {
+		gauge := st.GaugeWithRate(RateArgs{
+			Name: "gauge.never",
+			Rate: 0,
+		})
+		for i := 0; i < 100; i++ {
+			gauge.Set(float64(i))
+		}
+		gauge.Add(5)
+
+		var buf bytes.Buffer
+		if _, err := st.WriteTo(&buf); err != nil {
+			t.Fatal(err)
+		}
+		// The Add calls are never sampled.
+		const expected = "gauge.never:5.000000|g\n"
+		if buf.String() != expected {
+			t.Errorf("Expected %q, got %q", expected, buf.String())
+		}
+	})
+
+	t.Run("always", func(t *testing.T) {
+		gauge := st.GaugeWithRate(RateArgs{
+			Name:             "gauge.always",
+			Rate:             1,
+			AlreadySampledAt: Float64Ptr(0.5),
+		})
+		gauge.Set(42)
+
+		var buf bytes.Buffer
+		if _, err := st.WriteTo(&buf); err != nil {
+			t.Fatal(err)
+		}
+		const expected = "gauge.always:42.000000|g\n"
+		if buf.String() != expected {
+			t.Errorf("Expected %q, got %q", expected, buf.String())
+		}
+	})
+}
+
+func TestGaugeIgnoresSampleRates(t *testing.T) {
+	st := NewStatsd(context.Background(), StatsdConfig{
+		Prefix:              "prefix",
+		CounterSampleRate:   Float64Ptr(0.5),
+		HistogramSampleRate: Float64Ptr(0.5),
+		SampleRates: map[string]float64{
+			"mixed": 0.5,
+		},
+	})
+	// Run enough times so that the sampled counters are emitted.
+	for i := 0; i < 100; i++ {
+		st.Counter("mixed").Add(1)
+	}
+	st.Gauge("mixed").Set(42)
+
+	var buf bytes.Buffer
+	if _, err := st.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var counterLine, gaugeLine string
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasSuffix(line, "|g"):
+			gaugeLine = line
+		case strings.Contains(line, "|c"):
+			counterLine = line
+		}
+	}
+	const expectedGauge = "prefix.mixed:42.000000|g"
+	if gaugeLine != expectedGauge {
+		t.Errorf("Expected gauge line %q, got %q", expectedGauge, gaugeLine)
+	}
+	if !strings.HasPrefix(counterLine, "prefix.mixed:") || !strings.HasSuffix(counterLine, "|c|@0.500000") {
+		t.Errorf("Expected sampled counter line, got %q", counterLine)
+	}
+}
+
+func TestGaugeAndGaugeWithRate(t *testing.T) {
+	st := NewStatsd(context.Background(), StatsdConfig{})
+	// The never sampled gauge must not be returned by Gauge with the same name.
+	st.GaugeWithRate(RateArgs{
+		Name: "gauge",
+		Rate: 0,
+	}).Set(1)
+	st.Gauge("gauge").Set(42)
+
+	var buf bytes.Buffer
+	if _, err := st.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	const expected = "gauge:42.000000|g\n"
+	if buf.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, buf.String())
+	}
+
+	// And the other way around.
+	st.Gauge("other").Set(1)
+	st.GaugeWithRate(RateArgs{
+		Name: "other",
+		Rate: 0,
+	}).Set(42)
+
+	buf.Reset()
+	if _, err := st.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	const expectedOther = "other:1.000000|g\n"
+	if buf.String() != expectedOther {
+		t.Errorf("Expected %q, got %q", expectedOther, buf.String())
+	}
+}
@@ -0,0 +1,52 @@
+package metricsbp
+
+import (
+	"strings"
+)
+
+// SanitizeName replaces all the characters in name that's not safe to be used
+// in a statsd metric path with underscores ("_").
+//
+// The safe characters are ASCII letters, digits, period ("."),
+// underscore ("_"), and hyphen ("-").
+// Other characters, for example colon (":") and pipe ("|"),
+// would corrupt the parsing of the whole statsd datagram on the collector.
+func SanitizeName(name string) string {
+	return strings.Map(sanitizeRune, name)
+}
+
+func sanitizeRune(r rune) rune {
+	switch {
+	case r >= 'a' && r <= 'z',
+		r >= 'A' && r <= 'Z',
+		r >= '0' && r <= '9',
+		r == '.',
+		r == '_',
+		r == '-':
+		return r
+	default:
+		return '_'
+	}
+}
+
+// metricName returns the name to be used to create metrics,
+// according to SanitizeNames in StatsdConfig.
+//
+// The first time a name is changed by sanitization it's logged.
+func (st *Statsd) metricName(name string) string {
+	if !st.cfg.SanitizeNames {
+		return name
+	}
+	sanitized := SanitizeName(name)
+	if sanitized != name {
+		if _, logged := st.shared.sanitizedNames.LoadOrStore(name, true); !logged {
+			st.logger.Log(
+				"during", "metricsbp.SanitizeName",
+				"msg", "metric name sanitized",
+				"name", name,
+				"sanitized", sanitized,
+			)
+		}
+	}
+	return sanitized
+}
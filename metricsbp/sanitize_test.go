@@ -0,0 +1,100 @@
+package metricsbp_test
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/reddit/baseplate.go/metricsbp"
+)
+
+func TestSanitizeName(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		expected string
+	}{
+		{
+			name:     "foo.bar-baz_1",
+			expected: "foo.bar-baz_1",
+		},
+		{
+			name:     "foo:bar|baz",
+			expected: "foo_bar_baz",
+		},
+		{
+			name:     "path./users/123",
+			expected: "path._users_123",
+		},
+		{
+			name:     "foo,tag=value",
+			expected: "foo_tag_value",
+		},
+		{
+			name:     "日本",
+			expected: "__",
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			if got := metricsbp.SanitizeName(c.name); got != c.expected {
+				t.Errorf("Expected %q, got %q", c.expected, got)
+			}
+		})
+	}
+}
+
+func TestSanitizeNames(t *testing.T) {
+	for _, c := range []struct {
+		label    string
+		sanitize bool
+		expected []string
+	}{
+		{
+			label:    "enabled",
+			sanitize: true,
+			expected: []string{
+				"counter_1:1.000000|c",
+				"gauge_1:1.000000|g",
+				"histogram_1:1.000000|h",
+				"set_1:foo|s",
+				"timing_1:1.000000|ms",
+			},
+		},
+		{
+			label:    "disabled",
+			sanitize: false,
+			expected: []string{
+				"counter:1:1.000000|c",
+				"gauge:1:1.000000|g",
+				"histogram:1:1.000000|h",
+				"set:1:foo|s",
+				"timing:1:1.000000|ms",
+			},
+		},
+	} {
+		t.Run(c.label, func(t *testing.T) {
+			st := metricsbp.NewStatsd(
+				context.Background(),
+				metricsbp.StatsdConfig{
+					SanitizeNames: c.sanitize,
+				},
+			)
+			st.Counter("counter:1").Add(1)
+			st.Gauge("gauge:1").Set(1)
+			st.Histogram("histogram:1").Observe(1)
+			st.Timing("timing:1").Observe(1)
+			st.Set("set:1").Add("foo")
+
+			var sb strings.Builder
+			if _, err := st.WriteTo(&sb); err != nil {
+				t.Fatal(err)
+			}
+			lines := strings.Split(strings.TrimSpace(sb.String()), "\n")
+			sort.Strings(lines)
+			if !reflect.DeepEqual(lines, c.expected) {
+				t.Errorf("Expected %q, got %q", c.expected, lines)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package metricsbp
+
+import (
+	"strings"
+)
+
+// Scoped returns a Statsd derived from st,
+// with the prefix prepended to the names of all the metrics created from it,
+// after the Prefix in StatsdConfig.
+//
+// It's useful to group the metrics of a subsystem without passing the fully
+// qualified names everywhere:
+//
+//     dbStatsd := metricsbp.M.Scoped("db")
+//     dbStatsd.Timing("query").Observe(...) // reported as "prefix.db.query"
+//
+// The prefix is separated from the metric names by PrefixSeparator in
+// StatsdConfig, which is added if the prefix is not already ending with it.
+// Scoped can be called on a scoped Statsd to nest the scopes,
+// and Scoped with an empty prefix returns st as-is.
+//
+// The scoped names are the names of the metrics for everything else,
+// for example the keys of SampleRates and MetricHistogramBuckets in
+// StatsdConfig, and the names returned by EmissionCounts.
+//
+// The derived Statsd shares everything else with st, the same as WithTags,
+// and st is not modified.
+func (st *Statsd) Scoped(prefix string) *Statsd {
+	st = st.fallback()
+	if prefix == "" {
+		return st
+	}
+
+	separator := st.cfg.PrefixSeparator
+	if !strings.HasSuffix(prefix, separator) {
+		prefix += separator
+	}
+	derived := *st
+	derived.scope = st.scope + prefix
+	return &derived
+}
+
+// scopedName returns name with the scope of st, see Scoped.
+func (st *Statsd) scopedName(name string) string {
+	return st.scope + name
+}
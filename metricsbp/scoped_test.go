@@ -0,0 +1,47 @@
+package metricsbp_test
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/reddit/baseplate.go/metricsbp"
+)
+
+func TestScoped(t *testing.T) {
+	st := metricsbp.NewStatsd(context.Background(), metricsbp.StatsdConfig{
+		Prefix: "prefix",
+		SampleRates: map[string]float64{
+			"db.latency": 0,
+		},
+	})
+	db := st.Scoped("db")
+	st.Counter("query").Add(1)
+	db.Counter("query").Add(2)
+	db.Scoped("conn.").Gauge("open").Set(3)
+	db.Set("users").Add("a")
+	// Sampled out by the scoped name in SampleRates.
+	db.Timing("latency").Observe(4)
+
+	if same := st.Scoped(""); same != st {
+		t.Error("Expected Scoped with empty prefix to return st as-is")
+	}
+
+	var sb strings.Builder
+	if _, err := st.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(sb.String()), "\n")
+	sort.Strings(lines)
+	expected := []string{
+		"prefix.db.conn.open:3.000000|g",
+		"prefix.db.query:2.000000|c",
+		"prefix.db.users:a|s",
+		"prefix.query:1.000000|c",
+	}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Expected %q, got %q", expected, lines)
+	}
+}
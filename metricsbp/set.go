@@ -0,0 +1,156 @@
+package metricsbp
+
+import (
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/reddit/baseplate.go/randbp"
+)
+
+// Set is a statsd set metric.
+//
+// A set counts the number of unique values reported during each reporting
+// interval, for example the number of unique users.
+//
+// Please use Statsd.Set to create it.
+// The zero value is a no-op Set.
+type Set struct {
+	name  string
+	tags  []string
+	rate  float64
+	space *setSpace
+	st    *Statsd
+
+	// emissions is the emission count when TrackEmissions is true.
+	emissions *int64
+}
+
+// Set returns a set metrics to the name,
+// with sample rate inherited from StatsdConfig.SampleRates or
+// StatsdConfig.HistogramSampleRate.
+//
+// Please note that sampled sets behave differently from sampled counters and
+// histograms:
+// when the sample rate is less than 1, Add calls are randomly dropped,
+// but the statsd collector cannot scale the number of unique values back,
+// so the reported number of unique values will likely be smaller than the
+// actual number.
+// For that reason the sample rate is not reported to the statsd collector for
+// sets.
+func (st *Statsd) Set(name string) Set {
+	st = st.fallback()
+	if st.discard {
+		return Set{st: st}
+	}
+	name = st.scopedName(name)
+	return Set{
+		name:      st.metricName(name),
+		tags:      st.tags,
+		rate:      st.sampleRate(name, st.histogramSampleRate),
+		space:     st.sets,
+		st:        st,
+		emissions: st.emissionCount(name, MetricTypeSet),
+	}
+}
+
+// With returns a Set with the additional tags (as key-value pairs).
+func (s Set) With(tagValues ...string) Set {
+	if len(tagValues)%2 != 0 {
+		panic("metricsbp: odd number of tagValues; programmer error!")
+	}
+	if s.st == nil {
+		return s
+	}
+	s.tags = s.st.mergeTags(s.tags, s.st.withTags(tagValues))
+	return s
+}
+
+// Add adds value to the set.
+func (s Set) Add(value string) {
+	if s.space == nil {
+		return
+	}
+	if s.emissions != nil {
+		atomic.AddInt64(s.emissions, 1)
+	}
+	if s.rate < 1 && !randbp.ShouldSampleWithRate(s.rate) {
+		return
+	}
+	s.space.add(s.name, s.tags, value)
+	for _, sink := range s.st.sinks {
+		sink.sets.add(s.name, s.tags, value)
+	}
+	s.st.retention.observe()
+}
+
+// setKey is the key of a set with tags in setSpace.
+type setKey struct {
+	name string
+	tags string
+}
+
+// setValues are the unique values of a set with tags in setSpace.
+type setValues struct {
+	tags   []string
+	values map[string]struct{}
+}
+
+// setSpace holds all the unique values reported to sets since last write,
+// keyed by the metric name with tags.
+type setSpace struct {
+	prefix   string
+	provider provider
+
+	mu   sync.Mutex
+	sets map[setKey]*setValues
+}
+
+func newSetSpace(prefix string, p provider) *setSpace {
+	return &setSpace{
+		prefix:   prefix,
+		provider: p,
+		sets:     make(map[setKey]*setValues),
+	}
+}
+
+func (ss *setSpace) add(name string, tags []string, value string) {
+	key := setKey{
+		name: name,
+		tags: strings.Join(tags, "\x00"),
+	}
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	set, ok := ss.sets[key]
+	if !ok {
+		set = &setValues{
+			tags:   tags,
+			values: make(map[string]struct{}),
+		}
+		ss.sets[key] = set
+	}
+	set.values[value] = struct{}{}
+}
+
+// WriteTo writes all the unique values to w and resets the space.
+func (ss *setSpace) WriteTo(w io.Writer) (count int64, err error) {
+	ss.mu.Lock()
+	all := ss.sets
+	ss.sets = make(map[setKey]*setValues)
+	ss.mu.Unlock()
+
+	for key, set := range all {
+		for value := range set.values {
+			var n int
+			n, err = io.WriteString(w, ss.provider.setLine(ss.prefix+key.name, set.tags, value))
+			count += int64(n)
+			if err != nil {
+				return count, err
+			}
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,78 @@
+package metricsbp_test
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/reddit/baseplate.go/metricsbp"
+)
+
+func TestSet(t *testing.T) {
+	st := metricsbp.NewStatsd(
+		context.Background(),
+		metricsbp.StatsdConfig{
+			Prefix: "prefix",
+			Tags: metricsbp.Tags{
+				"foo": "bar",
+			},
+		},
+	)
+	set := st.Set("users")
+	set.Add("a")
+	set.Add("b")
+	set.Add("a")
+	set.With("key", "value").Add("c")
+
+	expected := []string{
+		"prefix.users,foo=bar,key=value:c|s",
+		"prefix.users,foo=bar:a|s",
+		"prefix.users,foo=bar:b|s",
+	}
+	var sb strings.Builder
+	if _, err := st.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(sb.String()), "\n")
+	sort.Strings(lines)
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Expected %q, got %q", expected, lines)
+	}
+
+	// Sets should be reset after WriteTo.
+	sb.Reset()
+	if _, err := st.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	if sb.Len() != 0 {
+		t.Errorf("Expected nothing written after reset, got %q", sb.String())
+	}
+}
+
+func TestSetSampled(t *testing.T) {
+	st := metricsbp.NewStatsd(
+		context.Background(),
+		metricsbp.StatsdConfig{
+			SampleRates: map[string]float64{
+				"users": 0,
+			},
+		},
+	)
+	st.Set("users").Add("a")
+
+	var sb strings.Builder
+	if _, err := st.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	if sb.Len() != 0 {
+		t.Errorf("Expected nothing written with 0 sample rate, got %q", sb.String())
+	}
+}
+
+func TestSetZero(t *testing.T) {
+	var set metricsbp.Set
+	// Shouldn't panic.
+	set.With("key", "value").Add("a")
+}
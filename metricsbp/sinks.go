@@ -0,0 +1,154 @@
+package metricsbp
+
+import (
+	"io"
+
+	kitlog "github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/multi"
+	"github.com/go-kit/kit/util/conn"
+)
+
+// SinkConfig is the config of an additional statsd collector to send the
+// metrics to, see Sinks in StatsdConfig.
+type SinkConfig struct {
+	// Address and Network are the same as the ones in StatsdConfig.
+	Address string
+	Network string
+
+	// Format is the statsd line format used for this sink,
+	// it's the same as the one in StatsdConfig.
+	Format Format
+}
+
+// sink is an additional statsd collector configured by Sinks in StatsdConfig.
+//
+// It has its own provider, so the metrics are serialized in its own Format,
+// and its own sets, counted histograms, and locally bucketed histograms,
+// as their lines are format specific.
+type sink struct {
+	provider provider
+	sets     *setSpace
+	counted  *countedSpace
+	bucketed *bucketSpace
+	writer   *bufferedWriter
+	target   string
+}
+
+// newSink creates the sink from cfg.
+func newSink(
+	cfg SinkConfig,
+	prefix string,
+	bufferSize int,
+	newConn func(network, address string, logger kitlog.Logger) *conn.Manager,
+	logger kitlog.Logger,
+) (*sink, error) {
+	p, err := newProvider(cfg.Format, prefix, logger)
+	if err != nil {
+		return nil, err
+	}
+	network, address, err := parseAddress(cfg.Network, cfg.Address)
+	if err != nil {
+		return nil, err
+	}
+	return &sink{
+		provider: p,
+		sets:     newSetSpace(prefix, p),
+		counted:  newCountedSpace(prefix, p),
+		bucketed: newBucketSpace(prefix, p),
+		writer:   newBufferedWriter(newConn(network, address, logger), bufferSize),
+		target:   network + "://" + address,
+	}, nil
+}
+
+// WriteTo writes all the metrics of the sink to w.
+func (s *sink) WriteTo(w io.Writer) (int64, error) {
+	n, err := s.provider.WriteTo(w)
+	if err != nil {
+		return n, err
+	}
+	m, err := s.sets.WriteTo(w)
+	n += m
+	if err != nil {
+		return n, err
+	}
+	m, err = s.counted.WriteTo(w)
+	n += m
+	if err != nil {
+		return n, err
+	}
+	m, err = s.bucketed.WriteTo(w)
+	return n + m, err
+}
+
+// sinksProvider sends the metrics to both the main provider and the providers
+// of the sinks.
+//
+// WriteTo, setLine and countedLine are the ones of the main provider,
+// the sinks are written separately by the reporting goroutine.
+type sinksProvider struct {
+	provider
+
+	sinks []*sink
+}
+
+func (p sinksProvider) NewCounter(name string, sampleRate float64) metrics.Counter {
+	counters := make([]metrics.Counter, 0, len(p.sinks)+1)
+	counters = append(counters, p.provider.NewCounter(name, sampleRate))
+	for _, s := range p.sinks {
+		counters = append(counters, s.provider.NewCounter(name, sampleRate))
+	}
+	return multi.NewCounter(counters...)
+}
+
+func (p sinksProvider) NewGauge(name string) metrics.Gauge {
+	gauges := make([]metrics.Gauge, 0, len(p.sinks)+1)
+	gauges = append(gauges, p.provider.NewGauge(name))
+	for _, s := range p.sinks {
+		gauges = append(gauges, s.provider.NewGauge(name))
+	}
+	return multi.NewGauge(gauges...)
+}
+
+func (p sinksProvider) NewTiming(name string, sampleRate float64) metrics.Histogram {
+	histograms := make([]metrics.Histogram, 0, len(p.sinks)+1)
+	histograms = append(histograms, p.provider.NewTiming(name, sampleRate))
+	for _, s := range p.sinks {
+		histograms = append(histograms, s.provider.NewTiming(name, sampleRate))
+	}
+	return multi.NewHistogram(histograms...)
+}
+
+func (p sinksProvider) NewHistogram(name string, sampleRate float64) metrics.Histogram {
+	histograms := make([]metrics.Histogram, 0, len(p.sinks)+1)
+	histograms = append(histograms, p.provider.NewHistogram(name, sampleRate))
+	for _, s := range p.sinks {
+		histograms = append(histograms, s.provider.NewHistogram(name, sampleRate))
+	}
+	return multi.NewHistogram(histograms...)
+}
+
+// withSinks creates the sinks from cfgs,
+// and returns the provider sending the metrics to both p and the sinks.
+func (st *Statsd) withSinks(p provider, cfgs []SinkConfig, prefix string, bufferSize int, logger kitlog.Logger) provider {
+	for _, cfg := range cfgs {
+		s, err := newSink(cfg, prefix, bufferSize, st.newConnManager, logger)
+		if err != nil {
+			logger.Log(
+				"during", "NewStatsd",
+				"sink", cfg.Address,
+				"err", err,
+			)
+			continue
+		}
+		s.writer.now = st.clock.Now
+		st.sinks = append(st.sinks, s)
+	}
+	if len(st.sinks) == 0 {
+		return p
+	}
+	return sinksProvider{
+		provider: p,
+		sinks:    st.sinks,
+	}
+}
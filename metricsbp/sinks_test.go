@@ -0,0 +1,124 @@
+package metricsbp_test
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/reddit/baseplate.go/metricsbp"
+)
+
+func TestSinks(t *testing.T) {
+	listen := func(t *testing.T) net.PacketConn {
+		t.Helper()
+		pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			pc.Close()
+		})
+		return pc
+	}
+	read := func(t *testing.T, pc net.PacketConn) string {
+		t.Helper()
+		pc.SetReadDeadline(time.Now().Add(time.Second * 5))
+		buf := make([]byte, 4096)
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(buf[:n])
+	}
+
+	for _, c := range []struct {
+		label   string
+		address func(influx net.PacketConn) string
+		sinks   func(influx, dogstatsd net.PacketConn) []metricsbp.SinkConfig
+	}{
+		{
+			label: "address",
+			address: func(influx net.PacketConn) string {
+				return influx.LocalAddr().String()
+			},
+			sinks: func(_, dogstatsd net.PacketConn) []metricsbp.SinkConfig {
+				return []metricsbp.SinkConfig{
+					{
+						Address: dogstatsd.LocalAddr().String(),
+						Format:  metricsbp.FormatDogStatsd,
+					},
+				}
+			},
+		},
+		{
+			label: "sinks-only",
+			address: func(net.PacketConn) string {
+				return ""
+			},
+			sinks: func(influx, dogstatsd net.PacketConn) []metricsbp.SinkConfig {
+				return []metricsbp.SinkConfig{
+					{
+						Address: influx.LocalAddr().String(),
+						Format:  metricsbp.FormatInflux,
+					},
+					{
+						Address: dogstatsd.LocalAddr().String(),
+						Format:  metricsbp.FormatDogStatsd,
+					},
+				}
+			},
+		},
+	} {
+		t.Run(c.label, func(t *testing.T) {
+			influx := listen(t)
+			dogstatsd := listen(t)
+			st := metricsbp.NewStatsd(
+				context.Background(),
+				metricsbp.StatsdConfig{
+					Address:           c.address(influx),
+					Sinks:             c.sinks(influx, dogstatsd),
+					ReportingInterval: time.Hour,
+				},
+			)
+			defer st.Close()
+
+			st.Counter("counter").With("key", "value").Add(1)
+			st.Set("set").Add("a")
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+			defer cancel()
+			if err := st.Flush(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, r := range []struct {
+				pc       net.PacketConn
+				expected []string
+			}{
+				{
+					pc: influx,
+					expected: []string{
+						"counter,key=value:1.000000|c\n",
+						"set:a|s\n",
+					},
+				},
+				{
+					pc: dogstatsd,
+					expected: []string{
+						"counter:1.000000|c|#key:value\n",
+						"set:a|s\n",
+					},
+				},
+			} {
+				got := read(t, r.pc)
+				for _, expected := range r.expected {
+					if !strings.Contains(got, expected) {
+						t.Errorf("Expected %q in %q", expected, got)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package metricsbp
+
+import (
+	"bytes"
+	"io"
+)
+
+// Stats are the stats of the metrics reported by a Statsd in the last
+// reporting tick, returned by Statsd.Stats.
+type Stats struct {
+	// The number of distinct series (name and tags combinations) of each type.
+	//
+	// Timings are counted in NumHistograms.
+	NumCounters   int
+	NumGauges     int
+	NumHistograms int
+	NumSets       int
+
+	// NumSeries is the total number of distinct series of all types.
+	NumSeries int
+
+	// NumLines is the number of lines written,
+	// which is larger than NumSeries as histograms, timings, and sets write one
+	// line per observation (or unique value).
+	NumLines int
+
+	// Bytes is the total size of the lines written,
+	// which can be used as a rough approximation of the memory used to hold
+	// the metrics between the reporting ticks.
+	Bytes int64
+}
+
+// Stats returns the stats of the metrics written in the last reporting tick
+// (or the last WriteTo call).
+//
+// It's useful to detect the cardinality creep of tags,
+// and to verify that MaxTagCardinality works as expected.
+//
+// The stats are shared by st and all the Statsd derived from it via WithTags
+// and WithSampleRate, as they share the same buffers.
+// Metrics reported to Prometheus are not counted.
+// When neither Address nor Writer was set in StatsdConfig,
+// the stats stay at zero values unless WriteTo is called explicitly.
+func (st *Statsd) Stats() Stats {
+	st = st.fallback()
+	st.shared.statsMu.Lock()
+	defer st.shared.statsMu.Unlock()
+	return st.shared.stats
+}
+
+func (st *Statsd) setStats(stats Stats) {
+	st.shared.statsMu.Lock()
+	defer st.shared.statsMu.Unlock()
+	st.shared.stats = stats
+}
+
+// statsWriter is an io.Writer passing the lines through to w,
+// while collecting the stats of them.
+type statsWriter struct {
+	w       io.Writer
+	stats   Stats
+	series  map[string]struct{}
+	partial []byte
+}
+
+func newStatsWriter(w io.Writer) *statsWriter {
+	return &statsWriter{
+		w:      w,
+		series: make(map[string]struct{}),
+	}
+}
+
+func (sw *statsWriter) Write(p []byte) (int, error) {
+	n, err := sw.w.Write(p)
+	sw.stats.Bytes += int64(n)
+	data := p[:n]
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			sw.partial = append(sw.partial, data...)
+			break
+		}
+		if len(sw.partial) > 0 {
+			sw.partial = append(sw.partial, data[:i]...)
+			sw.observe(sw.partial)
+			sw.partial = sw.partial[:0]
+		} else {
+			sw.observe(data[:i])
+		}
+		data = data[i+1:]
+	}
+	return n, err
+}
+
+// observe collects the stats of a single line,
+// in the format of "name:value|type" with optional sample rate and tags
+// separated by "|" after the type.
+func (sw *statsWriter) observe(line []byte) {
+	sw.stats.NumLines++
+	i := bytes.IndexByte(line, ':')
+	if i < 0 {
+		return
+	}
+	name := line[:i]
+	parts := bytes.Split(line[i+1:], []byte("|"))
+	if len(parts) < 2 {
+		return
+	}
+	typ := string(parts[1])
+
+	key := make([]byte, 0, len(line))
+	key = append(key, typ...)
+	key = append(key, '|')
+	key = append(key, name...)
+	for _, part := range parts[2:] {
+		// Skip the sample rates, keep the dogstatsd tags.
+		if len(part) > 0 && part[0] == '#' {
+			key = append(key, '|')
+			key = append(key, part...)
+		}
+	}
+	if _, ok := sw.series[string(key)]; ok {
+		return
+	}
+	sw.series[string(key)] = struct{}{}
+
+	sw.stats.NumSeries++
+	switch typ {
+	case "c":
+		sw.stats.NumCounters++
+	case "g":
+		sw.stats.NumGauges++
+	case "ms", "h", "d":
+		sw.stats.NumHistograms++
+	case "s":
+		sw.stats.NumSets++
+	}
+}
@@ -0,0 +1,64 @@
+package metricsbp_test
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/reddit/baseplate.go/metricsbp"
+)
+
+func TestStats(t *testing.T) {
+	for _, format := range []metricsbp.Format{
+		metricsbp.FormatInflux,
+		metricsbp.FormatDogStatsd,
+	} {
+		t.Run(string(format), func(t *testing.T) {
+			st := metricsbp.NewStatsd(
+				context.Background(),
+				metricsbp.StatsdConfig{
+					Format: format,
+				},
+			)
+			if got := st.Stats(); got != (metricsbp.Stats{}) {
+				t.Errorf("Expected zero Stats before WriteTo, got %+v", got)
+			}
+
+			derived := st.WithTags(metricsbp.Tags{"foo": "bar"})
+			for i := 0; i < 3; i++ {
+				st.Counter("counter").Add(1)
+				st.Counter("counter").With("key", "value").Add(1)
+				derived.Counter("counter").Add(1)
+				st.Histogram("histogram").Observe(1)
+				st.Timing("timing").Observe(1)
+				st.Set("set").Add("a")
+			}
+			st.Gauge("gauge").Set(1)
+
+			n, err := st.WriteTo(ioutil.Discard)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected := metricsbp.Stats{
+				NumCounters:   3,
+				NumGauges:     1,
+				NumHistograms: 2,
+				NumSets:       1,
+				NumSeries:     7,
+				NumLines:      3 + 1 + 6 + 1,
+				Bytes:         n,
+			}
+			if got := derived.Stats(); got != expected {
+				t.Errorf("Expected %+v, got %+v", expected, got)
+			}
+
+			// Nothing was reported since the last WriteTo.
+			if _, err := st.WriteTo(ioutil.Discard); err != nil {
+				t.Fatal(err)
+			}
+			if got := st.Stats(); got != (metricsbp.Stats{}) {
+				t.Errorf("Expected zero Stats for the empty report, got %+v", got)
+			}
+		})
+	}
+}
@@ -2,14 +2,18 @@ package metricsbp
 
 import (
 	"context"
+	"fmt"
 	"io"
+	"math"
+	"net"
+	"sort"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
+	"unicode"
 
 	"github.com/go-kit/kit/metrics"
-	"github.com/go-kit/kit/metrics/influxstatsd"
-	"github.com/go-kit/kit/util/conn"
 
 	"github.com/reddit/baseplate.go/log"
 )
@@ -23,11 +27,43 @@ const (
 	// DefaultBufferSize is the default value to be used when BufferSize in
 	// StatsdConfig is 0.
 	DefaultBufferSize = 4096
+
+	// DefaultNetwork is the default value to be used when Network in
+	// StatsdConfig is empty.
+	DefaultNetwork = "udp"
+
+	// DefaultPrefixSeparator is the default value to be used when
+	// PrefixSeparator in StatsdConfig is empty.
+	DefaultPrefixSeparator = "."
+
+	// DefaultReportingInterval is the interval to be used when neither
+	// ReportingInterval in StatsdConfig nor ReporterTickerInterval is positive.
+	DefaultReportingInterval = time.Minute
 )
 
+// supportedNetworks are the values accepted by StatsdConfig.Network.
+var supportedNetworks = map[string]bool{
+	"udp":      true,
+	"udp4":     true,
+	"udp6":     true,
+	"tcp":      true,
+	"tcp4":     true,
+	"tcp6":     true,
+	"unix":     true,
+	"unixgram": true,
+}
+
+// isUnixNetwork returns whether network is one of the unix socket networks,
+// for which Address is the path to the socket instead of "host:port".
+func isUnixNetwork(network string) bool {
+	return network == "unix" || network == "unixgram"
+}
+
 // ReporterTickerInterval is the interval the reporter sends data to statsd
 // server. Default is one minute.
-var ReporterTickerInterval = time.Minute
+//
+// It's only used when ReportingInterval in StatsdConfig is not set.
+var ReporterTickerInterval = DefaultReportingInterval
 
 // M is short for "Metrics".
 //
@@ -38,57 +74,205 @@ var ReporterTickerInterval = time.Minute
 //
 // But in production code you should still properly initialize it to actually
 // send your metrics to your statsd collector,
-// usually early in your main function:
+// usually early in your main function, via SetM:
 //
 //     func main() {
 //       flag.Parse()
 //       ctx, cancel := context.WithCancel(context.Background())
 //       defer cancel()
-//       metricsbp.M = metricsbp.NewStatsd{
+//       metricsbp.SetM(metricsbp.NewStatsd(
 //         ctx,
 //         metricsbp.StatsdConfig{
 //           ...
 //         },
-//       }
-//       metricsbp.M.RunSysStats()
+//       ))
+//       metricsbp.GetM().RunSysStats()
 //       ...
 //     }
 //
 //     func someOtherFunction() {
 //       ...
-//       metricsbp.M.Counter("my-counter").Add(1)
+//       metricsbp.GetM().Counter("my-counter").Add(1)
 //       ...
 //     }
+//
+// Using M directly (for example metricsbp.M.Counter) is fine once it's set
+// up, but replacing it while other goroutines are reading it is a data race,
+// whether it's assigned directly or via SetM
+// (for example, when replacing it in TestMain while tests are running in
+// parallel).
+// Code that can run concurrently with the replacement should read it via GetM
+// instead, which is safe for concurrent use with SetM.
+// Direct assignments to M are only honored by GetM and the nil *Statsd
+// fallback until the first SetM call, and ignored after that.
 var M = NewStatsd(context.Background(), StatsdConfig{})
 
+var (
+	// globalM holds the *Statsd set by SetM.
+	globalM atomic.Value
+
+	// globalMLock serializes the writes to M in SetM.
+	globalMLock sync.Mutex
+)
+
+// SetM replaces the global Statsd in a concurrency-safe way.
+//
+// It also assigns M for compatibility,
+// so the code still reading M directly sees the same Statsd,
+// but such reads are a data race with SetM, use GetM for them instead.
+// As GetM reads M until the first SetM call,
+// the first call should happen early (in main or TestMain),
+// before other goroutines start to use the global Statsd,
+// and the following calls are always safe.
+func SetM(st *Statsd) {
+	globalMLock.Lock()
+	defer globalMLock.Unlock()
+	globalM.Store(st)
+	M = st
+}
+
+// GetM returns the global Statsd in a concurrency-safe way.
+//
+// It returns the one set by the last SetM call,
+// or M if SetM was never called.
+//
+// It never blocks, so it's cheap enough to be called on every emission.
+func GetM() *Statsd {
+	if st, ok := globalM.Load().(*Statsd); ok {
+		return st
+	}
+	return M
+}
+
 // Statsd defines a statsd reporter (with influx extension) and the root of the
 // metrics.
 //
 // It can be used to create metrics,
 // and also maintains the background reporting goroutine,
 //
-// It supports metrics tags in Influxstatsd format.
+// It supports metrics tags in Influxstatsd format by default,
+// or in DogStatsD format (see StatsdConfig.Format).
 //
 // Please use NewStatsd to initialize it.
 //
+// The metrics returned by Counter, Gauge, Histogram, Timing and their
+// -WithRate versions are cached by the name, the sample rates and the tags of
+// the Statsd (including the ones added by WithTags),
+// so calling them repeatedly with the same name,
+// for example in a hot loop,
+// returns the same metric instead of creating a new one every time.
+// The cache is shared by all the Statsd derived from the same one via WithTags,
+// Scoped, etc., and the order of the tags doesn't matter,
+// so the same (scoped) name with the same tags is always the same series,
+// regardless of which Statsd in the derivation tree created it.
+// The tags passed into With of the metrics are not part of the cache key,
+// With always returns a new metric with the tags merged.
+// The metrics not created again since the previous write are dropped from the
+// cache on every write (or Reset),
+// and the cache holds at most MaxCachedMetrics metrics,
+// so Statsd derived with unbounded tags (for example via WithCtxTags) never
+// grow it forever.
+//
 // When a *Statsd is nil,
-// any function calls to it will fallback to use M instead,
-// so they are gonna be safe to use (unless M was explicitly overridden as nil).
+// any function calls to it will fallback to use the global one (see GetM)
+// instead,
+// so they are gonna be safe to use (unless it was explicitly overridden as
+// nil).
 // For example:
 //
 //     st := (*metricsbp.Statsd)(nil)
 //     st.Counter("my-counter").Add(1) // does not panic unless metricsbp.M is nil
 type Statsd struct {
-	statsd *influxstatsd.Influxstatsd
+	statsd     provider
+	prometheus *prometheusProvider
+	custom     *customProvider
+	adaptive   *adaptiveSampler
+	sinks      []*sink
+	sets       *setSpace
+	counted    *countedSpace
+	bucketed   *bucketSpace
+	discard    bool
+	onTick     *tickFuncs
+	onDiscard  *tickFuncs
+	tags       []string
+	tagsKey    string
+	ctxTags    []CtxTag
+	scope      string
 
 	cfg                 StatsdConfig
 	ctx                 context.Context
 	cancel              context.CancelFunc
 	counterSampleRate   float64
 	histogramSampleRate float64
+	sampleRates         map[string]float64
+	buckets             []float64
+	metricBuckets       map[string][]float64
+	localBucketing      map[string][]float64
 	writer              *bufferedWriter
+	logger              log.KitWrapper
+	debugLogger         log.KitWrapper
+	clock               clock
+	timingUnit          TimingUnit
+	timingFactor        float64
+	tagValueSanitizer   func(string) string
+	cardinality         *cardinalityLimiter
+	retention           *retentionLimiter
+	tagKeys             *tagKeyChecker
+
+	// shared is the state shared by this Statsd and the ones derived from it
+	// via WithTags.
+	shared *sharedState
+}
+
+// sharedState is the mutable state of a Statsd,
+// shared with the ones derived from it.
+type sharedState struct {
+	// tagsSanitized is set to 1 after the first time sanitizeTags changed
+	// anything.
+	tagsSanitized int32
+
+	// sanitizedNames are the names already logged by metricName.
+	sanitizedNames sync.Map
+
+	// done is closed when the background reporting goroutine exits,
+	// and finalErr is the error from its final flush.
+	done      chan struct{}
+	finalErr  error
+	closeOnce sync.Once
+
+	// tickDone is closed when the background goroutine running the tick
+	// callbacks without a writer exits, see startTickFuncs.
+	tickDone chan struct{}
+
+	reporterMetrics reporterMetrics
+
+	// stats are the Stats of the last WriteTo call.
+	statsMu sync.Mutex
+	stats   Stats
 
 	activeRequests int64
+
+	// emissions are the emission counts keyed by emissionKey,
+	// or nil when TrackEmissions is false.
+	emissions *sync.Map
+
+	// metrics are the metrics cached by Counter, Gauge, Histogram, Timing and
+	// their -WithRate versions, keyed by metricKey.
+	metrics metricCache
+
+	// running is set to 1 while the background reporting goroutine is alive.
+	running int32
+
+	// paused is set to 1 while the background reporting goroutine is paused.
+	paused int32
+
+	// dumped are the lines drained by Dump and not yet written by WriteTo.
+	dumped dumpedLines
+
+	// flushNow wakes up the background reporting goroutine to flush without
+	// waiting for the ticker, on TriggerFlush and Resume.
+	// It's buffered by 1 so pending triggers are coalesced.
+	flushNow chan struct{}
 }
 
 // StatsdConfig is the configs used in NewStatsd.
@@ -96,14 +280,36 @@ type StatsdConfig struct {
 	// Prefix is the common metrics path prefix shared by all metrics managed by
 	// (created from) this Metrics object.
 	//
-	// If it's not ending with a period ("."), a period will be added.
+	// If it's not ending with PrefixSeparator, PrefixSeparator will be added.
+	//
+	// It can't contain any of the characters used by the statsd line protocol
+	// (":", "|", ",", "=", "#", "@") or whitespaces,
+	// as they would mangle all the metric lines.
+	// An invalid Prefix is logged at LogLevel and the empty prefix is used
+	// instead by NewStatsd, or returned as an error by NewStatsdE.
 	Prefix string
 
+	// PrefixSeparator is the separator between Prefix and the metric names.
+	//
+	// It must be a single character, and can't be one of the characters used
+	// by the statsd line protocol (":", "|", ",", "=", "#", "@") or a
+	// whitespace.
+	// DefaultPrefixSeparator (".") will be used when it's empty or invalid,
+	// and the invalid ones are logged at LogLevel.
+	PrefixSeparator string
+
 	// The reporting sample rate used when creating counters and
 	// timings/histograms, respectively.
 	//
 	// DefaultSampleRate will be used when they are nil (zero value).
 	//
+	// The rates must be in the range of (0, 1].
+	// 0, negative and NaN rates are treated as DefaultSampleRate (1, report
+	// everything) instead of reporting nothing,
+	// and rates larger than 1 are clamped to 1.
+	// Both are logged at LogLevel.
+	// To stop reporting certain metrics, use SampleRates instead.
+	//
 	// Use Float64Ptr to convert literals or other values that you can't get the
 	// pointer directly.
 	//
@@ -117,7 +323,80 @@ type StatsdConfig struct {
 	CounterSampleRate   *float64
 	HistogramSampleRate *float64
 
-	// Address is the UDP address (in "host:port" format) of the statsd service.
+	// SampleRates are the per-metric overrides of the sample rates,
+	// keyed by the metric name (without Prefix).
+	//
+	// When creating counters, histograms/timings and sets without explicit
+	// rate, the precedence of the sample rate is:
+	//
+	// 1. The rate set in SampleRates for the name, if any.
+	//
+	// 2. The adaptive sample rate for histograms/timings,
+	// if TargetEmitRate is set.
+	//
+	// 3. CounterSampleRate/HistogramSampleRate.
+	//
+	// 4. DefaultSampleRate.
+	//
+	// The -WithRate functions always use the rate passed in,
+	// and ignore SampleRates.
+	//
+	// A rate of 0 in SampleRates is honored as is,
+	// which means the metric will never be reported.
+	//
+	// Gauges are never sampled and ignore SampleRates, see Gauge.
+	SampleRates map[string]float64
+
+	// TargetEmitRate enables the adaptive sampling of histograms and timings,
+	// when it's positive.
+	//
+	// With adaptive sampling, the sample rate of every histogram and timing
+	// name is adjusted on every reporting tick,
+	// so that the number of observations emitted per second for the name stays
+	// around or below TargetEmitRate,
+	// based on the number of observations in the previous reporting interval.
+	// When the traffic is low the sample rate goes back to 1.
+	// The sample rates are still reported to the statsd collector,
+	// so the true counts can be reconstructed.
+	//
+	// It replaces HistogramSampleRate,
+	// but the names in SampleRates and the -WithRate functions still use the
+	// fixed sample rates.
+	// Counters are not affected,
+	// as they are always aggregated in memory and emitted once per tick anyway.
+	// It's ignored when Provider is non-nil.
+	TargetEmitRate float64
+
+	// TimingUnit is the unit of the timings emitted,
+	// for the statsd collectors expecting timings in a unit other than
+	// milliseconds.
+	//
+	// Supported values are TimingUnitMilliseconds ("ms"),
+	// TimingUnitMicroseconds ("us"), and TimingUnitSeconds ("s").
+	// When it's empty (default), DefaultTimingUnit (TimingUnitMilliseconds)
+	// will be used.
+	// An unsupported TimingUnit is logged at LogLevel and the default is used
+	// instead.
+	//
+	// The values observed on the timings (including Timer and TimeDuration)
+	// are always in milliseconds,
+	// and they are converted into TimingUnit when emitted.
+	// As the statsd line protocol always uses "ms" type for timings,
+	// timings in units other than milliseconds are also tagged with
+	// TimingUnitTagKey ("unit") set to the unit,
+	// so that the collector can't misinterpret them.
+	// HistogramBuckets for timings are in TimingUnit as well.
+	TimingUnit TimingUnit
+
+	// Address is the address of the statsd service.
+	//
+	// For "udp" and "tcp" networks (and their "4" and "6" variants) it should
+	// be in "host:port" format,
+	// with IPv6 literals in brackets, for example "[::1]:8125".
+	// For "unix" and "unixgram" networks it should be the path to the socket.
+	// Alternatively the network can be encoded into Address in URL format,
+	// for example "unixgram:///var/run/dsd.socket" or "tcp://localhost:8125",
+	// in which case Network can be left empty.
 	//
 	// It could be empty string, in which case we won't start the background
 	// reporting goroutine.
@@ -127,10 +406,102 @@ type StatsdConfig struct {
 	// anywhere,
 	// so it can be used in lieu of discarded metrics in test code.
 	// But the metrics are still stored in memory,
-	// so it shouldn't be used in lieu of discarded metrics in prod code.
+	// so it shouldn't be used in lieu of discarded metrics in prod code
+	// (see MaxUnreportedObservations for a safeguard).
+	//
+	// When Address is not empty (or Writer, HTTP or File is non-nil),
+	// the background reporting goroutine also reports the following metrics
+	// about itself (the metrics of a write are reported in the next write):
+	//
+	// - baseplate.metricsbp.send_errors: the number of failed writes.
+	//
+	// - baseplate.metricsbp.sent_bytes: the number of bytes written (before
+	//   Compression).
+	//
+	// - baseplate.metricsbp.flushes: the number of successful writes
+	// (to Address and all the Sinks).
+	//
+	// - baseplate.metricsbp.flush_duration: timing of every write,
+	// including the serialization of the metrics.
+	// Writes constantly taking longer than ReportingInterval mean the reporting
+	// goroutine is falling behind.
+	//
+	// - baseplate.metricsbp.heartbeat: 1 in every write, when Heartbeat is true.
+	//
+	// - baseplate.metricsbp.reporter_panics: the number of panics recovered in
+	// the background reporting goroutine, which is restarted with a backoff
+	// (see Running).
 	Address string
 
-	// When Address is configured,
+	// Network is the network used to connect to Address.
+	//
+	// Supported values are "udp", "udp4", "udp6", "tcp", "tcp4", "tcp6",
+	// "unix", and "unixgram".
+	// "udp4", "udp6", "tcp4", and "tcp6" pin the address family,
+	// for example to avoid dialing the IPv6 address of a collector with both
+	// A and AAAA records in a dual-stack environment,
+	// while "udp" and "tcp" use whichever the resolver returns first.
+	// When it's empty (default),
+	// the network encoded in Address will be used if any,
+	// otherwise DefaultNetwork ("udp") will be used.
+	//
+	// When the connection drops (for example when using "tcp"),
+	// a write to it fails,
+	// or it fails to be established (for example when the unix socket does not
+	// exist yet),
+	// the background reporting goroutine will re-dial with exponential backoff.
+	// Every re-dial resolves Address again,
+	// so a changed DNS record will be picked up after a failure.
+	// Consecutive failures are logged with exponential backoff as well,
+	// and the backoff resets after a successful write.
+	//
+	// When it's set to an unsupported value,
+	// NewStatsd will log the error and skip starting the background reporting
+	// goroutine, so no metrics will be sent.
+	Network string
+
+	// Writer is an optional writer to write the serialized metrics to,
+	// in lieu of Address.
+	//
+	// When Writer is non-nil, Address and Network are ignored,
+	// and the background reporting goroutine will write to Writer on every
+	// reporting tick (and on Flush/Close) instead of sending them over network.
+	// BufferSize still applies.
+	//
+	// It's useful in tests to verify the metrics emitted,
+	// for example by using a *bytes.Buffer:
+	//
+	//     var buf bytes.Buffer
+	//     st := metricsbp.NewStatsd(ctx, metricsbp.StatsdConfig{
+	//       Writer: &buf,
+	//     })
+	//     // emit metrics
+	//     st.Flush(ctx)
+	//     // check buf.String()
+	//
+	// Writes to Writer are never concurrent,
+	// but they happen in the background reporting goroutine,
+	// so only read from it after Flush or Close returns.
+	// Writer is not closed by Close.
+	Writer io.Writer
+
+	// Sinks are the additional statsd collectors to send the same metrics to,
+	// each with its own Address, Network, and Format,
+	// for example to dual-write during a migration to a different collector.
+	//
+	// Every metric is reported to all the sinks,
+	// in addition to Address (or Writer),
+	// by the same background reporting goroutine.
+	// When Address, Writer, HTTP and File are all empty,
+	// the first sink is used as Address, Network and Format instead.
+	// A sink with an unsupported Network or Format is logged at LogLevel and
+	// skipped.
+	//
+	// WriteTo and Stats only cover Address (or Writer).
+	// Sinks are ignored when Provider is non-nil.
+	Sinks []SinkConfig
+
+	// When Address or Writer is configured,
 	// BufferSize can be used to buffer writes to statsd collector together.
 	//
 	// Set it to an appropriate number will reduce the number of UDP messages sent
@@ -139,6 +510,13 @@ type StatsdConfig struct {
 	// It's guaranteed that every single UDP message will not exceed BufferSize,
 	// unless a single metric line exceeds it
 	// (usually around 100 bytes depending on the length of the metric path).
+	// Messages are always split on metric line boundaries.
+	//
+	// As a result BufferSize is also the max packet size.
+	// If the network between this process and the statsd collector drops
+	// fragmented UDP packets,
+	// set it to a value below the MTU minus the IP and UDP headers
+	// (for example, 1432 for an MTU of 1500 over IPv4).
 	//
 	// When it's 0 (default), DefaultBufferSize will be used.
 	//
@@ -148,20 +526,589 @@ type StatsdConfig struct {
 	// statsd collector.
 	BufferSize int
 
+	// MaxPacketSize, when positive, caps BufferSize (including the default one)
+	// to it, so that every UDP message stays below it without having to tune
+	// BufferSize,
+	// for example 1432 for an MTU of 1500 over IPv4.
+	//
+	// It doesn't enable buffering when BufferSize is negative.
+	MaxPacketSize int
+
+	// RedialInterval is the interval the connections to Address and the
+	// Sinks are dialed again (so the address is resolved again),
+	// for the statsd collectors behind DNS names pointing to rotating IPs,
+	// for example a Kubernetes service.
+	// The new connection is dialed before the first write after the interval,
+	// and the previous one is only closed after the new one is dialed.
+	//
+	// Regardless of it, the connection is also dialed again after every failed
+	// write, with exponential backoff.
+	//
+	// When it's 0 (default), DefaultRedialInterval will be used.
+	// Set it to a negative value to only dial again after failures.
+	RedialInterval time.Duration
+
+	// ReportingInterval is the interval the background reporting goroutine sends
+	// data to the statsd collector.
+	// With only Provider or Prometheus set (without Address or Writer),
+	// it's the interval the callbacks of GaugeFunc, CounterFunc, Meter and
+	// Summary are run instead.
+	//
+	// When it's 0 (default), ReporterTickerInterval will be used.
+	ReportingInterval time.Duration
+
+	// ReportingJitter, when positive,
+	// delays the first tick of the background reporting goroutine by a random
+	// duration in [0, ReportingJitter),
+	// so that a large fleet of instances started at the same time don't all
+	// flush at the same moment and spike the load of the statsd collector.
+	//
+	// The following ticks keep ReportingInterval apart,
+	// unless JitterEveryInterval is also true.
+	//
+	// When it's 0 (default), there's no jitter.
+	ReportingJitter time.Duration
+
+	// JitterEveryInterval, when true,
+	// also delays every following tick by a random duration in
+	// [0, ReportingJitter) on top of ReportingInterval,
+	// instead of only the first tick.
+	//
+	// It's ignored when ReportingJitter is not positive.
+	JitterEveryInterval bool
+
+	// OnSendError, when non-nil,
+	// is called with the error every time the background reporting goroutine
+	// fails to write the metrics to the statsd collector (or a sink),
+	// for example to route the metrics pipeline failures to your own alerting.
+	//
+	// It's called from a separate goroutine, one call at a time,
+	// and at most once per second,
+	// so that a sustained outage or a slow handler never stalls the background
+	// reporting goroutine.
+	// The errors happening in between are dropped,
+	// but they are still logged and counted by the
+	// "baseplate.metricsbp.send_errors" counter.
+	// The errors from the explicit Flush calls are only returned by Flush.
+	// The error from the final flush on Close (or the cancellation of the
+	// context passed into NewStatsd) is never dropped.
+	OnSendError func(error)
+
+	// Heartbeat, when true,
+	// makes the background reporting goroutine emit the counter
+	// "baseplate.metricsbp.heartbeat" with the value of 1 in every write,
+	// tagged with the Tags in StatsdConfig.
+	//
+	// As it's emitted even when there are no other metrics to write,
+	// a process that stopped reporting it is either dead or stuck,
+	// while a process still reporting it but missing the other metrics is
+	// alive but not emitting them.
+	// Combined with the per-host tags
+	// (for example from EnvTags),
+	// it makes the pods that stopped reporting easy to find.
+	//
+	// It's ignored when there's no background reporting goroutine.
+	Heartbeat bool
+
+	// Compression is the compression of the payloads sent to the statsd
+	// collector, to reduce the bandwidth of the verbose tags.
+	//
+	// With CompressionGzip, the body of every HTTP request is compressed with
+	// the "Content-Encoding: gzip" header,
+	// and every write to the stream networks ("tcp", "tcp4", "tcp6", and
+	// "unix") is sent as a complete gzip stream,
+	// so the collector must support decoding them
+	// (as a multistream for the stream networks).
+	// It's ignored (and logged at LogLevel) for the datagram networks
+	// ("udp", "udp4", "udp6", and "unixgram"),
+	// and it's also ignored for Writer and Sinks.
+	//
+	// When it's empty (default), DefaultCompression (CompressionNone) will be
+	// used.
+	// Invalid ones are logged at LogLevel and the default is used instead.
+	Compression Compression
+
+	// Format is the statsd line format used to report the metrics.
+	//
+	// Supported values are FormatInflux ("influx"), FormatDogStatsd
+	// ("dogstatsd"), and FormatPlain ("plain").
+	// When it's empty (default), DefaultFormat (FormatInflux) will be used.
+	//
+	// The Format only changes how the metrics and their tags are serialized,
+	// the API to create the metrics, the sample rates,
+	// and the background reporting goroutine all work the same.
+	//
+	// When it's set to an unsupported value,
+	// NewStatsd will log the error and skip starting the background reporting
+	// goroutine, so no metrics will be sent.
+	Format Format
+
+	// Provider is the optional go-kit metrics provider to be used instead of
+	// the statsd implementation of Format,
+	// for example an in-house aggregator.
+	//
+	// When it's non-nil, Format is ignored,
+	// and all the counters, gauges, histograms and timings are created from the
+	// Provider, with Prefix prepended to the names,
+	// and CustomProviderHistogramBuckets passed into NewHistogram.
+	// Timings are histograms observed in milliseconds.
+	// The API to create the metrics, the tags,
+	// and the sample rates all work the same,
+	// with the deltas of sampled counters scaled back by their sample rates.
+	// Sets are not supported and will be dropped.
+	//
+	// The Provider is responsible for reporting the metrics on its own,
+	// so nothing is written to Address or Writer.
+	// Stop of the Provider is called by Close.
+	Provider Provider
+
+	// HistogramBuckets are the bucket boundaries used by histograms and timings
+	// when the metrics are also exported to a backend supporting explicit
+	// buckets (currently Prometheus, see Prometheus below).
+	// Plain statsd backends calculate the percentiles on their own,
+	// so HistogramBuckets is ignored for them.
+	//
+	// The boundaries are sorted, and duplicates are removed.
+	// Please note that timings are emitted in TimingUnit (milliseconds by
+	// default),
+	// so the boundaries for timings should be in TimingUnit as well,
+	// for example 0.1 for 100 microseconds with the default TimingUnit.
+	//
+	// When it's empty (default),
+	// the default buckets of the backend will be used
+	// (prometheus.DefBuckets for Prometheus).
+	HistogramBuckets []float64
+
+	// MetricHistogramBuckets are the per-metric overrides of HistogramBuckets,
+	// keyed by the metric name (without Prefix).
+	MetricHistogramBuckets map[string][]float64
+
+	// LocalBucketing are the local bucket boundaries of the hot histograms and
+	// timings, keyed by the metric name (without Prefix).
+	//
+	// The observations of the histograms and timings created via Histogram and
+	// Timing with these names are tallied into the buckets in-process,
+	// instead of being emitted one line per observation.
+	// On every write, one line is emitted for every non-empty bucket (per tag
+	// combination),
+	// with the upper bound of the bucket as the value and the number of
+	// observations in it encoded as the sample rate, the same as
+	// CountedHistogram,
+	// so the statsd collector still sees the right number of samples.
+	// The values larger than the last bound are emitted with the largest one
+	// of them as the value.
+	//
+	// It dramatically reduces the number of lines of the high-QPS histograms and
+	// timings, at the cost of the precision of the values,
+	// and the memory used is bounded by the number of buckets (per tag
+	// combination) instead of the number of observations.
+	// The observations are never sampled,
+	// and the boundaries for timings are in TimingUnit.
+	// HistogramWithRate and TimingWithRate are not affected.
+	//
+	// The boundaries are sorted, and duplicates are removed.
+	// Names with empty boundaries are ignored.
+	// It's ignored when Provider or Prometheus is non-nil.
+	LocalBucketing map[string][]float64
+
+	// MaxUnreportedObservations caps the number of observations
+	// (counter adds, histogram/timing observations, and set adds)
+	// retained in memory when neither Address nor Writer is set.
+	//
+	// Without a background reporting goroutine,
+	// the metrics are only cleared by WriteTo calls,
+	// and every observation is retained in memory until then.
+	// When the number of observations since the last WriteTo call exceeds
+	// MaxUnreportedObservations,
+	// all the retained metrics are discarded,
+	// and it will be logged at LogLevel the first time that happens.
+	// This protects a Statsd with a test-style config accidentally shipped to
+	// production from leaking memory.
+	//
+	// When it's 0 (default) or negative,
+	// or when Address or Writer is set, it's ignored.
+	MaxUnreportedObservations int
+
+	// DiscardUnreported makes all the metrics created from a Statsd that's
+	// never reported anywhere no-ops.
+	//
+	// When it's true and Address, Writer, HTTP, File, Sinks, Provider and
+	// Prometheus are all empty,
+	// Counter, Gauge, Histogram, Timing, Set, Meter and all their variants
+	// return shared no-op metrics without allocating or locking,
+	// and WriteTo writes nothing.
+	// It's useful for libraries and large test suites not verifying the
+	// metrics,
+	// where the in-memory metrics of the default behavior are only overhead.
+	//
+	// It's ignored when any of the above is set.
+	DiscardUnreported bool
+
+	// TrackEmissions enables the debug mode counting the Add, Set, and Observe
+	// calls of every metric since NewStatsd, see Statsd.EmissionCounts.
+	//
+	// It adds an atomic increment to every emission,
+	// and it's ignored when the metrics are discarded by DiscardUnreported.
+	TrackEmissions bool
+
+	// MaxPausedObservations caps the number of observations
+	// (counter adds, histogram/timing observations, and set adds)
+	// retained in memory while the Statsd is paused (see Pause).
+	//
+	// When the number of observations since the last write exceeds it during a
+	// pause, all the retained metrics are discarded,
+	// and it will be logged at LogLevel the first time that happens.
+	//
+	// When it's 0 (default), DefaultMaxPausedObservations will be used.
+	// When it's negative, the observations retained while paused are not
+	// capped.
+	// It's ignored when neither Address nor Writer is set.
+	MaxPausedObservations int
+
+	// TraceExemplars controls whether to attach the trace id as TraceIDTagKey
+	// ("trace_id") to the histograms and timings created via HistogramCtx and
+	// TimingCtx, when there's a sampled baseplate span in the context,
+	// so a latency spike can be linked to the traces.
+	//
+	// Only the trace ids of sampled spans are attached,
+	// but it still increases the cardinality of those metrics significantly,
+	// so it should only be enabled with a low tracing sample rate.
+	// The trace ids are subject to MaxTagCardinality, if set.
+	TraceExemplars bool
+
+	// Prometheus is the optional config to also export the metrics created from
+	// this Statsd object to Prometheus,
+	// served by the http.Handler returned by PrometheusHandler.
+	//
+	// When it's non-nil, counters, gauges, histograms and timings are also
+	// created as Prometheus counters, gauges, and histograms, respectively,
+	// with the metric paths (including Prefix) converted to valid Prometheus
+	// names (for example "myservice.foo.bar" becomes "myservice_foo_bar").
+	// Tags and the tags passed into With are converted to labels,
+	// following the same override rules as statsd.
+	// Sets are not exported to Prometheus.
+	//
+	// As Prometheus requires the label names of a metric to be fixed,
+	// the label names used the first time a metric is updated are used for it.
+	// Labels missing in later updates are reported as empty,
+	// and extra labels are dropped (logged at LogLevel the first time).
+	//
+	// Prometheus metrics are not sampled.
+	// The deltas of sampled counters are scaled by the sample rate,
+	// but sampled histograms and timings will have smaller counts.
+	//
+	// When it's nil (default), the metrics are not exported to Prometheus.
+	Prometheus *PrometheusConfig
+
+	// HTTP is the optional config to send the metrics to a statsd-over-HTTP
+	// gateway instead of Address,
+	// for the environments only allowing outbound HTTP.
+	//
+	// When it's non-nil (and Writer is nil),
+	// the background reporting goroutine POSTs the statsd lines,
+	// serialized the same way as for Address,
+	// to the URL on every reporting tick.
+	// Every request contains up to BufferSize bytes of complete lines,
+	// so a larger BufferSize means fewer requests.
+	// Non-2xx responses are treated as write failures.
+	HTTP *HTTPConfig
+
+	// File is the optional config to append the metrics to a file instead of
+	// Address,
+	// for example to inspect the metrics emitted by a test run in an
+	// environment without a statsd collector.
+	//
+	// When it's non-nil (and Writer and HTTP are nil),
+	// the background reporting goroutine appends the statsd lines,
+	// serialized the same way as for Address,
+	// to the file on every reporting tick and on Close
+	// (every write is up to BufferSize bytes of complete lines).
+	// See MaxBytes and MaxBackups in FileConfig to bound the size of the file.
+	File *FileConfig
+
+	// SanitizeNames controls whether the metric names passed into Counter,
+	// Gauge, Histogram, Timing, Set, etc. will be sanitized by SanitizeName
+	// before creating the metrics.
+	//
+	// It's useful when the names contain user-supplied fragments, for example a
+	// dynamic path segment.
+	// The first time a name is changed by sanitization,
+	// it will be logged at LogLevel.
+	//
+	// Prefix and tags are not sanitized by SanitizeNames.
+	SanitizeNames bool
+
+	// TagValueSanitizer is used to sanitize the values of Tags,
+	// and the tag values passed into With of the metrics created from this
+	// Statsd object.
+	//
+	// When it's nil (default), SanitizeTag will be used,
+	// which replaces the characters reserved in Influxstatsd format with
+	// underscores.
+	// Tag keys are always sanitized by SanitizeTag.
+	// The first time a tag is changed by sanitization,
+	// it will be logged at LogLevel.
+	//
+	// A custom TagValueSanitizer must not return a value containing any of the
+	// reserved characters, or the tags will be mangled.
+	TagValueSanitizer func(string) string
+
+	// MaxTagCardinality is the max number of distinct values every tag key can
+	// have within a reporting interval,
+	// to protect the statsd collector from a tag with unbounded values,
+	// for example a request id.
+	//
+	// Once a tag key reached MaxTagCardinality distinct values passed into With
+	// of the metrics created from this Statsd object,
+	// additional values for that key will be replaced by OverflowTagValue
+	// ("__overflow__") until the next report,
+	// and it will be logged at LogLevel once per tag key per reporting interval.
+	//
+	// Tags is not limited by MaxTagCardinality.
+	// When it's 0 (default) or negative, the cardinality is not limited.
+	MaxTagCardinality int
+
 	// The log level used by the reporting goroutine.
+	//
+	// The start and the stop of the reporting goroutine are logged at LogLevel,
+	// and every successful flush is logged at debug level,
+	// unless LogLevel is empty or log.NopLevel.
 	LogLevel log.Level
 
 	// Tags are the tags to be attached to every metrics created from this Statsd
 	// object. For tags only needed by some metrics, use Counter/Gauge/Timing.With()
 	// instead.
+	//
+	// When a tag key appears more than once for a metric,
+	// the value set last wins and the key is only reported once, in the
+	// position it first appeared.
+	// So the tags passed into With override the ones with the same key from
+	// WithTags, which override the ones from Tags,
+	// and the tags passed into a later With call override the ones from an
+	// earlier call.
+	// See StrictTags to disallow such overrides.
 	Tags Tags
+
+	// StrictTags disallows overriding the value of a tag already set on a
+	// metric, by Tags, WithTags, or With.
+	//
+	// When it's true, such an override panics with a *TagConflictError,
+	// instead of silently replacing the value.
+	// Setting a tag again with the same value is not considered an override.
+	// It's meant to catch tags shadowed by mistake in tests and development.
+	StrictTags bool
+
+	// WarnUnknownTagKeys, when true,
+	// logs (at LogLevel) the first time every unknown tag key is used,
+	// via With of the metrics or WithTags,
+	// to catch the drifted tag keys (for example "status_code" instead of
+	// "status") in tests and CI.
+	//
+	// The known tag keys are KnownTagKeys, the keys of Tags (and the other
+	// default tags) in StatsdConfig, the LabelKey constants,
+	// and the tag keys attached by metricsbp itself.
+	// Every unknown tag key is only logged once.
+	// See Labels for a way to keep the tag keys consistent in code.
+	WarnUnknownTagKeys bool
+
+	// KnownTagKeys are the additional tag keys known by WarnUnknownTagKeys.
+	//
+	// It's ignored when WarnUnknownTagKeys is false.
+	KnownTagKeys []string
+
+	// AddHostnameTag controls whether to add the hostname of this machine
+	// (read by os.Hostname once in NewStatsd) into Tags,
+	// with HostnameTagKey as the key.
+	//
+	// If os.Hostname fails, the tag will be skipped and the error will be logged
+	// at debug level.
+	// If Tags or EnvTags already has HostnameTagKey, that one will be used.
+	AddHostnameTag bool
+
+	// HostnameTagKey is the tag key used by AddHostnameTag.
+	//
+	// When it's empty (default), DefaultHostnameTagKey ("hostname") will be used.
+	HostnameTagKey string
+
+	// EnvTags are the tags with values read from environment variables once in
+	// NewStatsd,
+	// for example the pod name and namespace exposed via Kubernetes downward API.
+	//
+	// It maps the tag keys to the names of the environment variables,
+	// see TagsFromEnv for more details.
+	// Tags takes precedence over EnvTags with the same keys,
+	// and EnvTags takes precedence over AddHostnameTag.
+	EnvTags map[string]string
+
+	// clock overrides the clock used by the background goroutines in tests.
+	// When it's nil (default), the real clock will be used.
+	clock clock
 }
 
-func convertSampleRate(rate *float64) float64 {
+// convertSampleRate returns the sample rate to be used for the configured
+// rate, which is DefaultSampleRate for nil.
+//
+// field is the name of the config field, used when logging the invalid values.
+func convertSampleRate(rate *float64, field string, logger log.KitWrapper) float64 {
 	if rate == nil {
 		return DefaultSampleRate
 	}
-	return *rate
+	return normalizeSampleRate(*rate, "metricsbp.NewStatsd", field, logger)
+}
+
+// normalizeSampleRate returns DefaultSampleRate for 0, negative and NaN rates,
+// and 1 for rates larger than 1, and logs them.
+func normalizeSampleRate(rate float64, during, field string, logger log.KitWrapper) float64 {
+	var normalized float64
+	switch {
+	case math.IsNaN(rate) || rate <= 0:
+		normalized = DefaultSampleRate
+	case rate > 1:
+		normalized = 1
+	default:
+		return rate
+	}
+	logger.Log(
+		"during", during,
+		"msg", "sample rate out of range (0, 1], using the normalized value instead",
+		"field", field,
+		"rate", rate,
+		"normalized", normalized,
+	)
+	return normalized
+}
+
+// prefixSeparator returns the separator to be used for the configured one.
+func prefixSeparator(separator string, logger log.KitWrapper) string {
+	if separator == "" {
+		return DefaultPrefixSeparator
+	}
+	if err := validatePrefixSeparator(separator); err != nil {
+		logger.Log(
+			"during", "metricsbp.NewStatsd",
+			"msg", "invalid PrefixSeparator, using the default instead",
+			"err", err,
+			"default", DefaultPrefixSeparator,
+		)
+		return DefaultPrefixSeparator
+	}
+	return separator
+}
+
+// isReservedPrefixRune returns true if r is not allowed in Prefix and
+// PrefixSeparator.
+func isReservedPrefixRune(r rune) bool {
+	return strings.ContainsRune(":|,=#@", r) || unicode.IsSpace(r)
+}
+
+func validateNetwork(network string) error {
+	if !supportedNetworks[network] {
+		return fmt.Errorf("metricsbp: unsupported network %q", network)
+	}
+	return nil
+}
+
+// reportsToWriter returns whether the Statsd created from cfg writes the
+// metrics via the background reporting goroutine.
+func reportsToWriter(cfg StatsdConfig) bool {
+	return cfg.Writer != nil ||
+		cfg.HTTP != nil ||
+		cfg.File != nil ||
+		cfg.Address != "" ||
+		len(cfg.Sinks) > 0
+}
+
+// parseAddress returns the network and address to dial according to the
+// Network and Address configured in StatsdConfig.
+func parseAddress(network, address string) (string, string, error) {
+	if i := strings.Index(address, "://"); i >= 0 {
+		scheme := address[:i]
+		if network != "" && network != scheme {
+			return "", "", fmt.Errorf(
+				"metricsbp: network %q conflicts with the one in address %q",
+				network,
+				address,
+			)
+		}
+		network = scheme
+		address = address[i+len("://"):]
+	}
+	if network == "" {
+		network = DefaultNetwork
+	}
+	if err := validateNetwork(network); err != nil {
+		return "", "", err
+	}
+	if !isUnixNetwork(network) && address != "" {
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			return "", "", fmt.Errorf(
+				"metricsbp: invalid address %q for network %q, IPv6 addresses must be in brackets (for example \"[::1]:8125\"): %w",
+				address,
+				network,
+				err,
+			)
+		}
+	}
+	return network, address, nil
+}
+
+func copySampleRates(rates map[string]float64) map[string]float64 {
+	if len(rates) == 0 {
+		return nil
+	}
+	copied := make(map[string]float64, len(rates))
+	for name, rate := range rates {
+		copied[name] = rate
+	}
+	return copied
+}
+
+// sampleRate returns the sample rate to be used for name,
+// according to SampleRates in StatsdConfig.
+func (st *Statsd) sampleRate(name string, fallback float64) float64 {
+	if rate, ok := st.sampleRates[name]; ok {
+		return rate
+	}
+	return fallback
+}
+
+// normalizeBuckets returns a sorted copy of buckets without duplicates.
+func normalizeBuckets(buckets []float64) []float64 {
+	if len(buckets) == 0 {
+		return nil
+	}
+	sorted := make([]float64, len(buckets))
+	copy(sorted, buckets)
+	sort.Float64s(sorted)
+	normalized := sorted[:1]
+	for _, b := range sorted[1:] {
+		if b != normalized[len(normalized)-1] {
+			normalized = append(normalized, b)
+		}
+	}
+	return normalized
+}
+
+func copyMetricBuckets(buckets map[string][]float64) map[string][]float64 {
+	if len(buckets) == 0 {
+		return nil
+	}
+	copied := make(map[string][]float64, len(buckets))
+	for name, b := range buckets {
+		copied[name] = normalizeBuckets(b)
+	}
+	return copied
+}
+
+// histogramBuckets returns the histogram buckets to be used for name,
+// according to HistogramBuckets and MetricHistogramBuckets in StatsdConfig.
+//
+// It returns nil when the default buckets of the backend should be used.
+func (st *Statsd) histogramBuckets(name string) []float64 {
+	if buckets, ok := st.metricBuckets[name]; ok && len(buckets) > 0 {
+		return buckets
+	}
+	return st.buckets
 }
 
 // Float64Ptr converts float64 value into pointer.
@@ -171,53 +1118,266 @@ func Float64Ptr(v float64) *float64 {
 
 // NewStatsd creates a Statsd object.
 //
-// It also starts a background reporting goroutine when Address is not empty.
+// It also starts a background reporting goroutine when Address is not empty or
+// Writer, HTTP or File is non-nil.
 // The goroutine will be stopped when the passed in context is canceled.
 //
+// When ctx is already done, for example due to a misordered shutdown,
+// the background reporting goroutine is not started and it's logged at
+// LogLevel.
+// The returned Statsd still keeps the metrics in memory,
+// the same as the one without Address, Writer, HTTP and File.
+//
 // NewStatsd never returns nil.
+// Invalid configs are logged at LogLevel and replaced by the defaults,
+// use NewStatsdE instead to fail on them.
 func NewStatsd(ctx context.Context, cfg StatsdConfig) *Statsd {
-	prefix := cfg.Prefix
-	if prefix != "" && !strings.HasSuffix(prefix, ".") {
-		prefix = prefix + "."
+	if cfg.Writer == nil && cfg.HTTP == nil && cfg.File == nil && cfg.Address == "" && len(cfg.Sinks) > 0 {
+		cfg.Address = cfg.Sinks[0].Address
+		cfg.Network = cfg.Sinks[0].Network
+		cfg.Format = cfg.Sinks[0].Format
+		cfg.Sinks = cfg.Sinks[1:]
 	}
-	tags := cfg.Tags.AsStatsdTags()
 	kitlogger := log.KitLogger(cfg.LogLevel)
+	separator := prefixSeparator(cfg.PrefixSeparator, kitlogger)
+	prefix := cfg.Prefix
+	if err := validatePrefix(prefix); err != nil {
+		kitlogger.Log(
+			"during", "metricsbp.NewStatsd",
+			"msg", "invalid Prefix, using the empty prefix instead",
+			"err", err,
+		)
+		prefix = ""
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, separator) {
+		prefix = prefix + separator
+	}
 	st := &Statsd{
-		statsd:              influxstatsd.New(prefix, kitlogger, tags...),
+		onTick:              new(tickFuncs),
+		onDiscard:           new(tickFuncs),
 		cfg:                 cfg,
-		counterSampleRate:   convertSampleRate(cfg.CounterSampleRate),
-		histogramSampleRate: convertSampleRate(cfg.HistogramSampleRate),
+		counterSampleRate:   convertSampleRate(cfg.CounterSampleRate, "CounterSampleRate", kitlogger),
+		histogramSampleRate: convertSampleRate(cfg.HistogramSampleRate, "HistogramSampleRate", kitlogger),
+		sampleRates:         copySampleRates(cfg.SampleRates),
+		buckets:             normalizeBuckets(cfg.HistogramBuckets),
+		metricBuckets:       copyMetricBuckets(cfg.MetricHistogramBuckets),
+		tagValueSanitizer:   cfg.TagValueSanitizer,
+		cardinality:         newCardinalityLimiter(cfg.MaxTagCardinality, kitlogger),
+		logger:              kitlogger,
+		debugLogger:         debugLogger(cfg.LogLevel),
+		clock:               cfg.clock,
+		timingUnit:          cfg.TimingUnit,
+		shared:              new(sharedState),
+		discard:             shouldDiscard(cfg),
+	}
+	if st.tagValueSanitizer == nil {
+		st.tagValueSanitizer = SanitizeTag
+	}
+	if st.clock == nil {
+		st.clock = realClock{}
+	}
+	if factor, err := timingFactor(st.timingUnit); err != nil {
+		kitlogger.Log(
+			"during", "metricsbp.NewStatsd",
+			"msg", "invalid TimingUnit, using the default instead",
+			"err", err,
+			"default", DefaultTimingUnit,
+		)
+		st.timingUnit, st.timingFactor = DefaultTimingUnit, 1
+	} else {
+		st.timingFactor = factor
+	}
+	if st.timingUnit == "" {
+		st.timingUnit = DefaultTimingUnit
+	}
+	st.shared.emissions = newEmissions(cfg.TrackEmissions)
+	st.tags = st.sanitizeTags(defaultTags(cfg).AsStatsdTags())
+	st.tagsKey = tagsKey(st.tags)
+	st.tagKeys = newTagKeyChecker(cfg, st.tags, kitlogger)
+	if cfg.BufferSize == 0 {
+		cfg.BufferSize = DefaultBufferSize
+	}
+	if st.cfg.RedialInterval == 0 {
+		st.cfg.RedialInterval = DefaultRedialInterval
+	}
+	if cfg.MaxPacketSize > 0 && cfg.BufferSize > cfg.MaxPacketSize {
+		cfg.BufferSize = cfg.MaxPacketSize
+	}
+	interval := cfg.ReportingInterval
+	if interval <= 0 {
+		interval = ReporterTickerInterval
+	}
+	if interval <= 0 {
+		interval = DefaultReportingInterval
+	}
+	st.cfg.Prefix = prefix
+	st.cfg.PrefixSeparator = separator
+	st.cfg.TimingUnit = st.timingUnit
+	st.cfg.BufferSize = cfg.BufferSize
+	st.cfg.ReportingInterval = interval
+	if cfg.ReportingJitter < 0 {
+		st.cfg.ReportingJitter = 0
 	}
 	st.ctx, st.cancel = context.WithCancel(ctx)
-
-	if cfg.Address != "" {
-		if cfg.BufferSize == 0 {
-			cfg.BufferSize = DefaultBufferSize
+	var p provider
+	var err error
+	if cfg.Provider != nil {
+		st.custom = &customProvider{
+			provider: cfg.Provider,
+			prefix:   prefix,
+		}
+		p = st.custom
+	} else {
+		st.adaptive = newAdaptiveSampler(cfg.TargetEmitRate, st.clock)
+		p, err = newProvider(cfg.Format, prefix, kitlogger)
+		if err != nil {
+			kitlogger.Log("during", "NewStatsd", "err", err)
+			p, _ = newProvider(DefaultFormat, prefix, kitlogger)
 		}
-		st.writer = newBufferedWriter(
-			conn.NewDefaultManager("udp", cfg.Address, kitlogger),
-			cfg.BufferSize,
+		if err != nil || cfg.Format == "" {
+			st.cfg.Format = DefaultFormat
+		}
+	}
+	if cfg.Prometheus != nil {
+		st.prometheus = newPrometheusProvider(
+			*cfg.Prometheus,
+			prefix,
+			kitlogger,
+			st.histogramBuckets,
+		)
+		p = multiProvider{
+			provider:   p,
+			prometheus: st.prometheus,
+		}
+	}
+	st.statsd = p
+	st.sets = newSetSpace(prefix, p)
+	st.counted = newCountedSpace(prefix, p)
+	st.bucketed = newBucketSpace(prefix, p)
+	if cfg.Provider == nil && cfg.Prometheus == nil {
+		st.localBucketing = copyMetricBuckets(cfg.LocalBucketing)
+	}
+	if err != nil {
+		return st
+	}
+
+	st.retention = newRetentionLimiter(
+		cfg.MaxUnreportedObservations,
+		"metricsbp.MaxUnreportedObservations",
+		st.discardRetained,
+		kitlogger,
+	)
+
+	if err := ctx.Err(); err != nil && reportsToWriter(cfg) {
+		kitlogger.Log(
+			"during", "metricsbp.NewStatsd",
+			"msg", "context already done, not starting the background reporting goroutine, the metrics will not be reported",
+			"err", err,
+		)
+		return st
+	}
+
+	compression := cfg.Compression
+	if err := validateCompression(compression); err != nil {
+		kitlogger.Log(
+			"during", "metricsbp.NewStatsd",
+			"msg", "invalid Compression, using the default instead",
+			"err", err,
+			"default", DefaultCompression,
 		)
-		go func() {
-			ticker := time.NewTicker(ReporterTickerInterval)
-			defer ticker.Stop()
-
-			for {
-				select {
-				case <-ticker.C:
-					st.writer.doWrite(st.statsd, kitlogger)
-				case <-st.ctx.Done():
-					// Flush one more time before returning.
-					st.writer.doWrite(st.statsd, kitlogger)
-					return
-				}
+		compression = DefaultCompression
+	}
+	if compression == "" {
+		compression = DefaultCompression
+	}
+	st.cfg.Compression = compression
+
+	var w io.Writer
+	var target string
+	switch {
+	case cfg.Writer != nil:
+		w = cfg.Writer
+		target = fmt.Sprintf("%T", cfg.Writer)
+	case cfg.HTTP != nil:
+		if err := validateHTTPConfig(*cfg.HTTP); err != nil {
+			kitlogger.Log("during", "NewStatsd", "err", err)
+			return st
+		}
+		w = newHTTPWriter(*cfg.HTTP, compression)
+		target = cfg.HTTP.URL
+	case cfg.File != nil:
+		if err := validateFileConfig(*cfg.File); err != nil {
+			kitlogger.Log("during", "NewStatsd", "err", err)
+			return st
+		}
+		w = &fileWriter{cfg: *cfg.File}
+		target = "file://" + cfg.File.Path
+	case cfg.Address != "":
+		network, address, err := parseAddress(cfg.Network, cfg.Address)
+		if err != nil {
+			kitlogger.Log("during", "NewStatsd", "err", err)
+			return st
+		}
+		st.cfg.Network, st.cfg.Address = network, address
+		w = st.newConnManager(network, address, kitlogger)
+		target = network + "://" + address
+		if compression == CompressionGzip {
+			if isStreamNetwork(network) {
+				w = &gzipWriter{w: w}
+			} else {
+				kitlogger.Log(
+					"during", "metricsbp.NewStatsd",
+					"msg", "Compression is only supported by the stream networks, sending uncompressed",
+					"network", network,
+				)
 			}
-		}()
+		}
+	}
+	if w != nil {
+		st.writer = newBufferedWriter(w, cfg.BufferSize)
+		st.writer.now = st.clock.Now
+		if cfg.Provider == nil {
+			st.statsd = st.withSinks(st.statsd, cfg.Sinks, prefix, cfg.BufferSize, kitlogger)
+			for _, s := range st.sinks {
+				target += "," + s.target
+			}
+		}
+		maxPaused := cfg.MaxPausedObservations
+		if maxPaused == 0 {
+			maxPaused = DefaultMaxPausedObservations
+		}
+		st.retention = newRetentionLimiter(
+			maxPaused,
+			"metricsbp.MaxPausedObservations",
+			st.discardRetained,
+			kitlogger,
+		)
+		if st.retention != nil {
+			st.retention.active = st.paused
+		}
+		st.startReporter(interval, target)
+	} else if cfg.Provider != nil || cfg.Prometheus != nil {
+		st.startTickFuncs(interval)
 	}
 
 	return st
 }
 
+// NewNoopStatsd creates a Statsd object that never reports the metrics anywhere.
+//
+// It never starts any background goroutine,
+// but the metrics created from it are still working (in memory),
+// so the code using them doesn't need to branch.
+// It's intended for tests and for libraries accepting a *Statsd,
+// to make the intent explicit.
+//
+// It's equivalent to:
+//
+//     metricsbp.NewStatsd(context.Background(), metricsbp.StatsdConfig{})
+func NewNoopStatsd() *Statsd {
+	return NewStatsd(context.Background(), StatsdConfig{})
+}
+
 // RateArgs defines the args used by -WithRate functions.
 type RateArgs struct {
 	// Name of the metric, required.
@@ -272,89 +1432,287 @@ func (ra RateArgs) ReportingRate() float64 {
 }
 
 // Counter returns a counter metrics to the name,
-// with sample rate inherited from StatsdConfig.
+// with sample rate inherited from StatsdConfig
+// (see StatsdConfig.SampleRates for the precedence).
+//
+// Counters are always aggregated in memory:
+// every Add only increments the in-process value,
+// and a single line with the sum for each tag combination is emitted on every
+// reporting tick, no matter how many times Add was called in between.
+//
+// The in-process value is reset after every write,
+// so the emitted values are always the deltas since the previous write,
+// and the statsd collector accumulates them.
+// The in-process sum never grows across reporting ticks,
+// so long-running, high-rate counters don't lose precision over time:
+// the float64 sum is exact as long as the integral deltas added to the same
+// tag combination during a single reporting interval stay below 2^53.
+// The counters mirrored to Provider or Prometheus in StatsdConfig are
+// cumulative, and it's up to them to handle large values.
 func (st *Statsd) Counter(name string) metrics.Counter {
 	st = st.fallback()
-	return st.CounterWithRate(RateArgs{
+	if st.discard {
+		return discardCounter
+	}
+	name = st.scopedName(name)
+	return st.counterWithRate(RateArgs{
 		Name: name,
-		Rate: st.counterSampleRate,
+		Rate: st.sampleRate(name, st.counterSampleRate),
 	})
 }
 
 // CounterWithRate returns a counter metrics to the name,
 // with sample rate passed in instead of inherited from StatsdConfig.
+//
+// Other than the sample rate, it behaves exactly the same as the non-WithRate
+// version, including the Tags from StatsdConfig and the fallback to M when st
+// is nil.
 func (st *Statsd) CounterWithRate(args RateArgs) metrics.Counter {
 	st = st.fallback()
-	counter := st.statsd.NewCounter(args.Name, args.ReportingRate())
-	if args.Rate >= 1 {
-		return counter
-	}
-	return SampledCounter{
-		Counter: counter,
-		Rate:    args.Rate,
+	if st.discard {
+		return discardCounter
 	}
+	args.Name = st.scopedName(args.Name)
+	return st.counterWithRate(args)
+}
+
+// counterWithRate is CounterWithRate with args.Name already scoped.
+func (st *Statsd) counterWithRate(args RateArgs) metrics.Counter {
+	return st.cachedMetric(st.rateKey(counterKind, args), func() interface{} {
+		var counter metrics.Counter = newTaggedCounter(
+			st,
+			st.statsd.NewCounter(st.metricName(args.Name), args.ReportingRate()),
+		)
+		if args.Rate < 1 {
+			counter = SampledCounter{
+				Counter: counter,
+				Rate:    args.Rate,
+			}
+		}
+		return st.trackCounter(args.Name, counter)
+	}).(metrics.Counter)
+}
+
+// ErrorCounter returns a counter metrics to the name that's never sampled.
+//
+// It always uses the sample rate of 1 (100%),
+// regardless of CounterSampleRate and SampleRates in StatsdConfig,
+// so that safety-critical counters (for example error counters) are always
+// exact.
+//
+// Other than the sample rate, it behaves exactly the same as Counter.
+func (st *Statsd) ErrorCounter(name string) metrics.Counter {
+	return st.CounterWithRate(RateArgs{
+		Name: name,
+		Rate: 1,
+	})
 }
 
 // Histogram returns a histogram metrics to the name with no specific unit,
-// with sample rate inherited from StatsdConfig.
+// with sample rate inherited from StatsdConfig
+// (see StatsdConfig.SampleRates for the precedence).
 func (st *Statsd) Histogram(name string) metrics.Histogram {
 	st = st.fallback()
-	return st.HistogramWithRate(RateArgs{
+	if st.discard {
+		return discardHistogram
+	}
+	name = st.scopedName(name)
+	if bounds := st.localBucketing[name]; len(bounds) > 0 {
+		return st.cachedMetric(st.nameKey(bucketedHistogramKind, name), func() interface{} {
+			return st.trackHistogram(
+				name,
+				MetricTypeHistogram,
+				newTaggedHistogram(st, st.newBucketedHistogram(name, countedHistogramType, bounds)),
+			)
+		}).(metrics.Histogram)
+	}
+	if _, ok := st.sampleRates[name]; !ok && st.adaptive != nil {
+		return st.cachedMetric(st.nameKey(adaptiveHistogramKind, name), func() interface{} {
+			return st.trackHistogram(
+				name,
+				MetricTypeHistogram,
+				st.adaptiveHistogram(name, st.statsd.NewHistogram),
+			)
+		}).(metrics.Histogram)
+	}
+	return st.histogramWithRate(RateArgs{
 		Name: name,
-		Rate: st.histogramSampleRate,
+		Rate: st.sampleRate(name, st.histogramSampleRate),
 	})
 }
 
+// Distribution returns a histogram metrics to the name for arbitrary values,
+// for example payload sizes and queue depths,
+// with sample rate inherited from StatsdConfig
+// (see StatsdConfig.SampleRates for the precedence).
+//
+// It's the same as Histogram, with the name making it clear that the values
+// are not durations:
+// the values are emitted as-is with the statsd histogram type ("h"),
+// and never converted by TimingUnit.
+// For durations, use Timing instead, which emits the statsd timing type
+// ("ms").
+func (st *Statsd) Distribution(name string) metrics.Histogram {
+	return st.Histogram(name)
+}
+
 // HistogramWithRate returns a histogram metrics to the name with no specific
 // unit, with sample rate passed in instead of inherited from StatsdConfig.
+//
+// Other than the sample rate, it behaves exactly the same as the non-WithRate
+// version, including the Tags from StatsdConfig and the fallback to M when st
+// is nil.
 func (st *Statsd) HistogramWithRate(args RateArgs) metrics.Histogram {
 	st = st.fallback()
-	histogram := st.statsd.NewHistogram(args.Name, args.ReportingRate())
-	if args.Rate >= 1 {
-		return histogram
-	}
-	return SampledHistogram{
-		Histogram: histogram,
-		Rate:      args.Rate,
+	if st.discard {
+		return discardHistogram
 	}
+	args.Name = st.scopedName(args.Name)
+	return st.histogramWithRate(args)
+}
+
+// histogramWithRate is HistogramWithRate with args.Name already scoped.
+func (st *Statsd) histogramWithRate(args RateArgs) metrics.Histogram {
+	return st.cachedMetric(st.rateKey(histogramKind, args), func() interface{} {
+		var histogram metrics.Histogram = newTaggedHistogram(
+			st,
+			st.statsd.NewHistogram(st.metricName(args.Name), args.ReportingRate()),
+		)
+		if args.Rate < 1 {
+			histogram = SampledHistogram{
+				Histogram: histogram,
+				Rate:      args.Rate,
+			}
+		}
+		return st.trackHistogram(args.Name, MetricTypeHistogram, histogram)
+	}).(metrics.Histogram)
 }
 
 // Timing returns a histogram metrics to the name with milliseconds as the unit,
-// with sample rate inherited from StatsdConfig.
+// with sample rate inherited from StatsdConfig
+// (see StatsdConfig.SampleRates for the precedence).
+//
+// The values observed are always in milliseconds,
+// and converted into TimingUnit in StatsdConfig when emitted.
 func (st *Statsd) Timing(name string) metrics.Histogram {
 	st = st.fallback()
-	return st.TimingWithRate(RateArgs{
+	if st.discard {
+		return discardHistogram
+	}
+	name = st.scopedName(name)
+	if bounds := st.localBucketing[name]; len(bounds) > 0 {
+		return st.cachedMetric(st.nameKey(bucketedTimingKind, name), func() interface{} {
+			return st.trackHistogram(
+				name,
+				MetricTypeTiming,
+				st.withTimingUnit(newTaggedHistogram(st, st.newBucketedHistogram(name, countedTimingType, bounds))),
+			)
+		}).(metrics.Histogram)
+	}
+	if _, ok := st.sampleRates[name]; !ok && st.adaptive != nil {
+		return st.cachedMetric(st.nameKey(adaptiveTimingKind, name), func() interface{} {
+			return st.trackHistogram(
+				name,
+				MetricTypeTiming,
+				st.withTimingUnit(st.adaptiveHistogram(name, st.statsd.NewTiming)),
+			)
+		}).(metrics.Histogram)
+	}
+	return st.timingWithRate(RateArgs{
 		Name: name,
-		Rate: st.histogramSampleRate,
+		Rate: st.sampleRate(name, st.histogramSampleRate),
 	})
 }
 
 // TimingWithRate returns a histogram metrics to the name with milliseconds as
 // the unit, with sample rate passed in instead of inherited from StatsdConfig.
+//
+// Other than the sample rate, it behaves exactly the same as the non-WithRate
+// version, including the Tags from StatsdConfig and the fallback to M when st
+// is nil.
 func (st *Statsd) TimingWithRate(args RateArgs) metrics.Histogram {
 	st = st.fallback()
-	histogram := st.statsd.NewTiming(args.Name, args.ReportingRate())
-	if args.Rate >= 1 {
-		return histogram
-	}
-	return SampledHistogram{
-		Histogram: histogram,
-		Rate:      args.Rate,
+	if st.discard {
+		return discardHistogram
 	}
+	args.Name = st.scopedName(args.Name)
+	return st.timingWithRate(args)
+}
+
+// timingWithRate is TimingWithRate with args.Name already scoped.
+func (st *Statsd) timingWithRate(args RateArgs) metrics.Histogram {
+	return st.cachedMetric(st.rateKey(timingKind, args), func() interface{} {
+		var histogram metrics.Histogram = st.withTimingUnit(newTaggedHistogram(
+			st,
+			st.statsd.NewTiming(st.metricName(args.Name), args.ReportingRate()),
+		))
+		if args.Rate < 1 {
+			histogram = SampledHistogram{
+				Histogram: histogram,
+				Rate:      args.Rate,
+			}
+		}
+		return st.trackHistogram(args.Name, MetricTypeTiming, histogram)
+	}).(metrics.Histogram)
 }
 
 // Gauge returns a gauge metrics to the name.
 //
 // Please note that gauges are considered "low level".
 // In most cases when you use a Gauge, you want to use RuntimeGauge instead.
+//
+// Gauges are never sampled:
+// DefaultSampleRate, CounterSampleRate, HistogramSampleRate and SampleRates in
+// StatsdConfig don't apply to them,
+// as a gauge only reports its last value on every reporting tick,
+// and there's nothing to scale up for a sampled value.
+// Gauges are also aggregated separately from the counters and histograms with
+// the same name, so mixing sampled counters and unsampled gauges under the same
+// Prefix is fine.
+// Use GaugeWithRate to sample the Set calls explicitly.
 func (st *Statsd) Gauge(name string) metrics.Gauge {
 	st = st.fallback()
-	return st.statsd.NewGauge(name)
+	if st.discard {
+		return discardGauge
+	}
+	name = st.scopedName(name)
+	return st.cachedMetric(st.nameKey(gaugeKind, name), func() interface{} {
+		return st.trackGauge(name, newTaggedGauge(st, st.statsd.NewGauge(st.metricName(name))))
+	}).(metrics.Gauge)
+}
+
+// GaugeWithRate returns a gauge metrics to the name,
+// with the Set calls randomly sampled at args.Rate.
+//
+// It's useful for gauges set on hot code paths,
+// where setting every value is unnecessarily expensive.
+// The Add calls are never sampled (see SampledGauge),
+// and as gauges don't carry a sample rate in the statsd line protocol,
+// AlreadySampledAt in args is ignored.
+//
+// Other than the sampling, it behaves exactly the same as Gauge.
+func (st *Statsd) GaugeWithRate(args RateArgs) metrics.Gauge {
+	st = st.fallback()
+	if st.discard {
+		return discardGauge
+	}
+	args.Name = st.scopedName(args.Name)
+	args.AlreadySampledAt = nil
+	return st.cachedMetric(st.rateKey(sampledGaugeKind, args), func() interface{} {
+		var gauge metrics.Gauge = newTaggedGauge(st, st.statsd.NewGauge(st.metricName(args.Name)))
+		if args.Rate < 1 {
+			gauge = SampledGauge{
+				Gauge: gauge,
+				Rate:  args.Rate,
+			}
+		}
+		return st.trackGauge(args.Name, gauge)
+	}).(metrics.Gauge)
 }
 
 func (st *Statsd) fallback() *Statsd {
 	if st == nil {
-		return M
+		return GetM()
 	}
 	return st
 }
@@ -386,14 +1744,77 @@ func (st *Statsd) Ctx() context.Context {
 	return st.ctx
 }
 
-// Close flushes all metrics not written to collector (if Address was set),
+// Config returns a copy of the effective config of the Statsd object.
+//
+// Compared to the config passed into NewStatsd,
+// the defaults and fallbacks are resolved:
+// Prefix has the separator appended (or is the empty prefix if it's invalid),
+// Network and Address are the parsed ones,
+// CounterSampleRate and HistogramSampleRate are always non-nil,
+// Tags contains all the tags applied to the metrics,
+// including the ones from AddHostnameTag, EnvTags and WithTags,
+// and ReportingInterval, RedialInterval, BufferSize, Format and TimingUnit are
+// never empty.
+// The sample rates are also the ones set by WithSampleRate, if used.
+//
+// The maps and slices are copied so changing them doesn't affect st,
+// but Writer, Provider, registries and clients are shared.
+func (st *Statsd) Config() StatsdConfig {
+	st = st.fallback()
+	cfg := st.cfg
+	cfg.CounterSampleRate = Float64Ptr(st.counterSampleRate)
+	cfg.HistogramSampleRate = Float64Ptr(st.histogramSampleRate)
+	cfg.SampleRates = copySampleRates(st.sampleRates)
+	cfg.HistogramBuckets = normalizeBuckets(st.buckets)
+	cfg.MetricHistogramBuckets = copyMetricBuckets(st.metricBuckets)
+	cfg.LocalBucketing = copyMetricBuckets(st.localBucketing)
+	cfg.Tags = make(Tags, len(st.tags)/2)
+	for i := 0; i+1 < len(st.tags); i += 2 {
+		cfg.Tags[st.tags[i]] = st.tags[i+1]
+	}
+	if cfg.EnvTags != nil {
+		envTags := make(map[string]string, len(cfg.EnvTags))
+		for k, v := range cfg.EnvTags {
+			envTags[k] = v
+		}
+		cfg.EnvTags = envTags
+	}
+	if cfg.Sinks != nil {
+		cfg.Sinks = append([]SinkConfig(nil), cfg.Sinks...)
+	}
+	if cfg.KnownTagKeys != nil {
+		cfg.KnownTagKeys = append([]string(nil), cfg.KnownTagKeys...)
+	}
+	if cfg.Prometheus != nil {
+		promCfg := *cfg.Prometheus
+		cfg.Prometheus = &promCfg
+	}
+	if cfg.File != nil {
+		fileCfg := *cfg.File
+		cfg.File = &fileCfg
+	}
+	if cfg.HTTP != nil {
+		httpCfg := *cfg.HTTP
+		httpCfg.Header = httpCfg.Header.Clone()
+		cfg.HTTP = &httpCfg
+	}
+	cfg.clock = nil
+	return cfg
+}
+
+// Close flushes all metrics not written to collector
+// (if Address or Writer was set),
 // and cancel the context,
 // thus stop all background goroutines started by this Statsd.
 //
+// It blocks until the background reporting goroutine finishes its final flush,
+// and returns the error from that flush, if any.
+// Use CloseContext instead to bound the wait.
+//
 // After Close() is called,
 // no more metrics will be send to the remote collector,
 // similar to the situation that this Statsd was initialized without Address
-// set,
+// or Writer set,
 // but the difference is that calling Close() again will do the manual flush
 // again.
 //
@@ -408,30 +1829,177 @@ func (st *Statsd) Ctx() context.Context {
 // and use Close() call to do the cleanup instead of canceling the context.
 func (st *Statsd) Close() error {
 	st.cancel()
-	return nil
+	if st.custom != nil {
+		defer st.custom.stop()
+	}
+	if st.writer == nil {
+		if st.shared.tickDone != nil {
+			<-st.shared.tickDone
+		}
+		return nil
+	}
+
+	<-st.shared.done
+	var err error
+	first := false
+	st.shared.closeOnce.Do(func() {
+		first = true
+		err = st.shared.finalErr
+	})
+	if first {
+		return err
+	}
+	return st.flush()
+}
+
+// CloseContext is the same as Close,
+// but only blocks until the final flush finishes or ctx is done,
+// whichever happens first.
+//
+// If ctx is done before the final flush finishes, ctx.Err() will be returned,
+// and the final flush will still finish in the background.
+//
+// It's useful in the shutdown sequence of services with a deadline to drain
+// everything.
+func (st *Statsd) CloseContext(ctx context.Context) error {
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- st.Close()
+	}()
+	select {
+	case err := <-errChan:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Flush writes all the buffered metrics to the statsd collector immediately,
+// and blocks until the write finishes or ctx is done,
+// whichever happens first.
+//
+// If ctx is done before the write finishes, ctx.Err() will be returned,
+// and the write will still finish in the background.
+//
+// It's no-op when neither Address nor Writer was set.
+//
+// This function is useful for jobs that exit,
+// to make sure that the final metrics are reported before exiting.
+func (st *Statsd) Flush(ctx context.Context) error {
+	st = st.fallback()
+	if st.writer == nil {
+		return nil
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- st.flush()
+	}()
+	select {
+	case err := <-errChan:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
-// WriteTo calls the underlying statsd implementation's WriteTo function.
+// WriteTo calls the underlying statsd implementation's WriteTo function,
+// and also writes the sets, counted histograms, and locally bucketed histograms
+// created by this Statsd, after the lines kept by Dump.
+//
+// Before writing, it calls the callbacks registered via GaugeFunc,
+// unless the context passed into NewStatsd is already canceled.
 //
 // Doing this will flush all the buffered metrics to the writer,
 // so in most cases you shouldn't be using it in production code.
 // But it's useful in unit tests to verify that you have the correct metrics you
 // want to report.
 func (st *Statsd) WriteTo(w io.Writer) (n int64, err error) {
-	return st.fallback().statsd.WriteTo(w)
+	st = st.fallback()
+	if st.ctx.Err() == nil {
+		st.onTick.run()
+	}
+	sw := newStatsWriter(w)
+	defer func() {
+		st.setStats(sw.stats)
+	}()
+	n, err = st.shared.dumped.WriteTo(sw)
+	if err != nil {
+		return n, err
+	}
+	m, err := st.writeMetrics(sw)
+	n += m
+	st.adaptive.adjust()
+	st.cardinality.reset()
+	st.retention.reset()
+	st.shared.metrics.prune()
+	return n, err
+}
+
+// writeMetrics drains all the metrics accumulated in memory by st and writes
+// them to w, without running the callbacks or resetting anything else.
+func (st *Statsd) writeMetrics(w io.Writer) (n int64, err error) {
+	n, err = st.statsd.WriteTo(w)
+	if err != nil {
+		return n, err
+	}
+	m, err := st.sets.WriteTo(w)
+	n += m
+	if err != nil {
+		return n, err
+	}
+	m, err = st.counted.WriteTo(w)
+	n += m
+	if err != nil {
+		return n, err
+	}
+	m, err = st.bucketed.WriteTo(w)
+	return n + m, err
+}
+
+// Reset discards all the metrics accumulated in memory and not yet written,
+// without writing them anywhere.
+// That includes the observations of Summary and the events of Meter not yet
+// reported, and the rates of Meter start a new window.
+//
+// It also starts a new window for MaxTagCardinality,
+// MaxUnreportedObservations and MaxPausedObservations in StatsdConfig,
+// and resets the counts returned by EmissionCounts to 0.
+//
+// Since st shares the accumulated metrics with all the Statsd derived from it
+// (and the one it's derived from), via WithTags, WithoutTags, WithCtxTags,
+// WithSampleRate and Scoped, they are all reset.
+// The metrics already mirrored to Provider or Prometheus in StatsdConfig are
+// not affected.
+//
+// It's safe to be called concurrently with the emissions,
+// but it's mainly useful in tests sharing the same Statsd between cases,
+// so that every case starts clean.
+func (st *Statsd) Reset() {
+	st = st.fallback()
+	st.discardRetained()
+	st.cardinality.reset()
+	st.retention.reset()
+	st.shared.metrics.reset()
+	if st.shared.emissions != nil {
+		st.shared.emissions.Range(func(_, v interface{}) bool {
+			atomic.StoreInt64(v.(*int64), 0)
+			return true
+		})
+	}
 }
 
 func (st *Statsd) incActiveRequests() {
 	st = st.fallback()
-	atomic.AddInt64(&st.activeRequests, 1)
+	atomic.AddInt64(&st.shared.activeRequests, 1)
 }
 
 func (st *Statsd) decActiveRequests() {
 	st = st.fallback()
-	atomic.AddInt64(&st.activeRequests, -1)
+	atomic.AddInt64(&st.shared.activeRequests, -1)
 }
 
 func (st *Statsd) getActiveRequests() int64 {
 	st = st.fallback()
-	return atomic.LoadInt64(&st.activeRequests)
+	return atomic.LoadInt64(&st.shared.activeRequests)
 }
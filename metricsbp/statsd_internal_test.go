@@ -0,0 +1,145 @@
+package metricsbp
+
+import (
+	"testing"
+
+	"github.com/reddit/baseplate.go/log"
+)
+
+func TestValidateNetwork(t *testing.T) {
+	for _, c := range []struct {
+		network string
+		valid   bool
+	}{
+		{network: "udp", valid: true},
+		{network: "udp4", valid: true},
+		{network: "udp6", valid: true},
+		{network: "tcp", valid: true},
+		{network: "tcp6", valid: true},
+		{network: "", valid: false},
+		{network: "foo", valid: false},
+	} {
+		t.Run(c.network, func(t *testing.T) {
+			err := validateNetwork(c.network)
+			if c.valid && err != nil {
+				t.Errorf("Expected %q to be valid, got %v", c.network, err)
+			}
+			if !c.valid && err == nil {
+				t.Errorf("Expected %q to be invalid, got nil error", c.network)
+			}
+		})
+	}
+}
+
+func TestParseAddress(t *testing.T) {
+	for _, c := range []struct {
+		label   string
+		network string
+		address string
+
+		expectedNetwork string
+		expectedAddress string
+		expectErr       bool
+	}{
+		{
+			label:           "default",
+			address:         "localhost:8125",
+			expectedNetwork: "udp",
+			expectedAddress: "localhost:8125",
+		},
+		{
+			label:           "network",
+			network:         "tcp",
+			address:         "localhost:8125",
+			expectedNetwork: "tcp",
+			expectedAddress: "localhost:8125",
+		},
+		{
+			label:           "unixgram-url",
+			address:         "unixgram:///var/run/dsd.socket",
+			expectedNetwork: "unixgram",
+			expectedAddress: "/var/run/dsd.socket",
+		},
+		{
+			label:           "unixgram-network",
+			network:         "unixgram",
+			address:         "/var/run/dsd.socket",
+			expectedNetwork: "unixgram",
+			expectedAddress: "/var/run/dsd.socket",
+		},
+		{
+			label:           "same-network",
+			network:         "tcp",
+			address:         "tcp://localhost:8125",
+			expectedNetwork: "tcp",
+			expectedAddress: "localhost:8125",
+		},
+		{
+			label:           "ipv6",
+			network:         "udp6",
+			address:         "[::1]:8125",
+			expectedNetwork: "udp6",
+			expectedAddress: "[::1]:8125",
+		},
+		{
+			label:           "ipv6-url",
+			address:         "udp6://[::1]:8125",
+			expectedNetwork: "udp6",
+			expectedAddress: "[::1]:8125",
+		},
+		{
+			label:     "ipv6-unbracketed",
+			network:   "udp6",
+			address:   "::1:8125",
+			expectErr: true,
+		},
+		{
+			label:     "conflict",
+			network:   "udp",
+			address:   "tcp://localhost:8125",
+			expectErr: true,
+		},
+		{
+			label:     "unsupported",
+			address:   "http://localhost:8125",
+			expectErr: true,
+		},
+	} {
+		t.Run(c.label, func(t *testing.T) {
+			network, address, err := parseAddress(c.network, c.address)
+			if c.expectErr {
+				if err == nil {
+					t.Errorf("Expected error, got network %q, address %q", network, address)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if network != c.expectedNetwork {
+				t.Errorf("Expected network %q, got %q", c.expectedNetwork, network)
+			}
+			if address != c.expectedAddress {
+				t.Errorf("Expected address %q, got %q", c.expectedAddress, address)
+			}
+		})
+	}
+}
+
+func TestDebugLogger(t *testing.T) {
+	for _, c := range []struct {
+		level    log.Level
+		expected log.KitWrapper
+	}{
+		{level: "", expected: log.KitWrapper(log.ZapNopLevel)},
+		{level: log.NopLevel, expected: log.KitWrapper(log.ZapNopLevel)},
+		{level: log.ErrorLevel, expected: log.KitLogger(log.DebugLevel)},
+		{level: log.DebugLevel, expected: log.KitLogger(log.DebugLevel)},
+	} {
+		t.Run(string(c.level), func(t *testing.T) {
+			if got := debugLogger(c.level); got != c.expected {
+				t.Errorf("Expected %v, got %v", c.expected, got)
+			}
+		})
+	}
+}
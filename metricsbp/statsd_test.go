@@ -1,12 +1,21 @@
 package metricsbp_test
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"io"
 	"math"
+	"net"
+	"path/filepath"
+	"reflect"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/reddit/baseplate.go/metricsbp"
 )
@@ -17,15 +26,75 @@ func TestGlobalStatsd(t *testing.T) {
 	metricsbp.M.RunSysStats()
 	metricsbp.M.Counter("counter").Add(1)
 	metricsbp.M.CounterWithRate(metricsbp.RateArgs{}).Add(1)
+	metricsbp.M.ErrorCounter("errors").Add(1)
 	metricsbp.M.Histogram("hitogram").Observe(1)
 	metricsbp.M.HistogramWithRate(metricsbp.RateArgs{}).Observe(1)
 	metricsbp.M.Timing("timing").Observe(1)
 	metricsbp.M.TimingWithRate(metricsbp.RateArgs{}).Observe(1)
 	metricsbp.M.Gauge("gauge").Set(1)
 	metricsbp.M.RuntimeGauge("gauge").Set(1)
+	metricsbp.M.Set("set").Add("value")
 	metricsbp.M.WriteTo(io.Discard)
 }
 
+func TestSetM(t *testing.T) {
+	prev := metricsbp.GetM()
+	// The first SetM call should happen before the concurrent GetM calls.
+	metricsbp.SetM(prev)
+	defer metricsbp.SetM(prev)
+
+	st := metricsbp.NewStatsd(context.Background(), metricsbp.StatsdConfig{})
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			metricsbp.SetM(st)
+		}()
+		go func() {
+			defer wg.Done()
+			metricsbp.GetM().Counter("counter").Add(1)
+			// nil fallback should also be safe.
+			(*metricsbp.Statsd)(nil).Counter("counter").Add(1)
+		}()
+	}
+	wg.Wait()
+
+	if got := metricsbp.GetM(); got != st {
+		t.Errorf("Expected GetM to return %p, got %p", st, got)
+	}
+	var buf bytes.Buffer
+	(*metricsbp.Statsd)(nil).Counter("fallback").Add(1)
+	if _, err := st.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "fallback:1.000000|c") {
+		t.Errorf("Expected nil fallback to use the Statsd from SetM, got %q", buf.String())
+	}
+}
+
+func TestNoopStatsd(t *testing.T) {
+	st := metricsbp.NewNoopStatsd()
+	st.Counter("counter").Add(1)
+	st.Gauge("gauge").Set(1)
+
+	// Flush and Close are no-ops.
+	if err := st.Flush(context.Background()); err != nil {
+		t.Errorf("Flush returned error: %v", err)
+	}
+	var sb strings.Builder
+	if _, err := st.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	const expected = "counter:1.000000|c\ngauge:1.000000|g\n"
+	if sb.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, sb.String())
+	}
+	if err := st.Close(); err != nil {
+		t.Errorf("Close returned error: %v", err)
+	}
+}
+
 func TestNilStatsd(t *testing.T) {
 	var st *metricsbp.Statsd
 	// Make sure nil *Statsd is safe to use and won't cause panics, no real
@@ -33,12 +102,14 @@ func TestNilStatsd(t *testing.T) {
 	st.RunSysStats()
 	st.Counter("counter").Add(1)
 	st.CounterWithRate(metricsbp.RateArgs{}).Add(1)
+	st.ErrorCounter("errors").Add(1)
 	st.Histogram("hitogram").Observe(1)
 	st.HistogramWithRate(metricsbp.RateArgs{}).Observe(1)
 	st.Timing("timing").Observe(1)
 	st.TimingWithRate(metricsbp.RateArgs{}).Observe(1)
 	st.Gauge("gauge").Set(1)
 	st.RuntimeGauge("gauge").Set(1)
+	st.Set("set").Add("value")
 	st.WriteTo(io.Discard)
 }
 
@@ -107,27 +178,42 @@ func TestNoFallback(t *testing.T) {
 }
 
 func BenchmarkStatsd(b *testing.B) {
-	const (
-		tag        = "tag"
-		sampleRate = 1
-	)
-
 	initialTags := map[string]string{
 		"source": "test",
 	}
 
+	for _, c := range []struct {
+		label   string
+		discard bool
+	}{
+		{
+			label: "in-memory",
+		},
+		{
+			label:   "discard",
+			discard: true,
+		},
+	} {
+		b.Run(c.label, func(b *testing.B) {
+			benchmarkStatsd(b, metricsbp.NewStatsd(
+				context.Background(),
+				metricsbp.StatsdConfig{
+					Tags:              initialTags,
+					DiscardUnreported: c.discard,
+				},
+			))
+		})
+	}
+}
+
+func benchmarkStatsd(b *testing.B, st *metricsbp.Statsd) {
+	const tag = "tag"
+
 	tags := []string{
 		"testtype",
 		"benchmark",
 	}
 
-	st := metricsbp.NewStatsd(
-		context.Background(),
-		metricsbp.StatsdConfig{
-			Tags: initialTags,
-		},
-	)
-
 	b.Run(
 		"pre-create",
 		func(b *testing.B) {
@@ -326,3 +412,809 @@ func TestRateArgsReportingRate(t *testing.T) {
 		})
 	}
 }
+
+func TestStatsdTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	st := metricsbp.NewStatsd(
+		ctx,
+		metricsbp.StatsdConfig{
+			Address: ln.Addr().String(),
+			Network: "tcp",
+		},
+	)
+	st.Counter("foo").Add(1)
+	// Canceling the context triggers the final flush.
+	cancel()
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(time.Second * 5))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	const expected = "foo:1.000000|c\n"
+	if line != expected {
+		t.Errorf("Expected %q, got %q", expected, line)
+	}
+}
+
+func TestStatsdReportingInterval(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	st := metricsbp.NewStatsd(
+		ctx,
+		metricsbp.StatsdConfig{
+			Address:           pc.LocalAddr().String(),
+			ReportingInterval: time.Millisecond * 10,
+		},
+	)
+	st.Counter("foo").Add(1)
+
+	// The context is not canceled, so the packet can only come from the ticker.
+	pc.SetReadDeadline(time.Now().Add(time.Second * 5))
+	buf := make([]byte, 1024)
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const expected = "foo:1.000000|c\n"
+	if got := string(buf[:n]); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
+func TestStatsdFlush(t *testing.T) {
+	t.Run("no-address", func(t *testing.T) {
+		st := metricsbp.NewStatsd(context.Background(), metricsbp.StatsdConfig{})
+		st.Counter("foo").Add(1)
+		if err := st.Flush(context.Background()); err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		var st *metricsbp.Statsd
+		if err := st.Flush(context.Background()); err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+	})
+
+	t.Run("udp", func(t *testing.T) {
+		pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer pc.Close()
+
+		st := metricsbp.NewStatsd(
+			context.Background(),
+			metricsbp.StatsdConfig{
+				Address:           pc.LocalAddr().String(),
+				ReportingInterval: time.Hour,
+			},
+		)
+		defer st.Close()
+		st.Counter("foo").Add(1)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancel()
+		if err := st.Flush(ctx); err != nil {
+			t.Fatalf("Flush failed: %v", err)
+		}
+
+		pc.SetReadDeadline(time.Now().Add(time.Second * 5))
+		buf := make([]byte, 1024)
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		const expected = "foo:1.000000|c\n"
+		if got := string(buf[:n]); got != expected {
+			t.Errorf("Expected %q, got %q", expected, got)
+		}
+	})
+}
+
+func TestStatsdClose(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	st := metricsbp.NewStatsd(
+		context.Background(),
+		metricsbp.StatsdConfig{
+			Address:           pc.LocalAddr().String(),
+			ReportingInterval: time.Hour,
+		},
+	)
+	st.Counter("foo").Add(1)
+	if err := st.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if st.Ctx().Err() == nil {
+		t.Error("Expected Ctx() to be canceled after Close")
+	}
+
+	pc.SetReadDeadline(time.Now().Add(time.Second * 5))
+	buf := make([]byte, 1024)
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const expected = "foo:1.000000|c\n"
+	if got := string(buf[:n]); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+
+	// Metrics should still work after Close, and Close again should be safe.
+	st.Counter("bar").Add(1)
+	if err := st.Close(); err != nil {
+		t.Errorf("Second Close failed: %v", err)
+	}
+}
+
+func TestStatsdCloseContext(t *testing.T) {
+	t.Run("flushed", func(t *testing.T) {
+		var buf bytes.Buffer
+		st := metricsbp.NewStatsd(
+			context.Background(),
+			metricsbp.StatsdConfig{
+				Writer:            &buf,
+				ReportingInterval: time.Hour,
+			},
+		)
+		st.Counter("foo").Add(1)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancel()
+		if err := st.CloseContext(ctx); err != nil {
+			t.Fatalf("CloseContext failed: %v", err)
+		}
+		const expected = "foo:1.000000|c\n"
+		if got := buf.String(); got != expected {
+			t.Errorf("Expected %q, got %q", expected, got)
+		}
+	})
+
+	t.Run("deadline", func(t *testing.T) {
+		w := &blockingWriter{unblock: make(chan struct{})}
+		st := metricsbp.NewStatsd(
+			context.Background(),
+			metricsbp.StatsdConfig{
+				Writer:            w,
+				ReportingInterval: time.Hour,
+			},
+		)
+		st.Counter("foo").Add(1)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+		defer cancel()
+		if err := st.CloseContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+		}
+
+		// The final flush still finishes in the background.
+		close(w.unblock)
+		if err := st.Close(); err != nil {
+			t.Errorf("Close failed: %v", err)
+		}
+	})
+}
+
+// blockingWriter is an io.Writer blocking every write until unblock is
+// closed.
+type blockingWriter struct {
+	unblock chan struct{}
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	<-w.unblock
+	return len(p), nil
+}
+
+func TestStatsdCanceledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var buf bytes.Buffer
+	cfg := metricsbp.StatsdConfig{
+		Writer:            &buf,
+		ReportingInterval: time.Hour,
+	}
+	st := metricsbp.NewStatsd(ctx, cfg)
+	st.Counter("foo").Add(1)
+	if err := st.Flush(context.Background()); err != nil {
+		t.Errorf("Flush failed: %v", err)
+	}
+	if err := st.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("Expected nothing to be reported, got %q", buf.String())
+	}
+
+	// The metrics are still kept in memory.
+	var sb strings.Builder
+	if _, err := st.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	const expected = "foo:1.000000|c\n"
+	if sb.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, sb.String())
+	}
+
+	if _, err := metricsbp.NewStatsdE(ctx, cfg); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected NewStatsdE to return context.Canceled, got %v", err)
+	}
+}
+
+func TestStatsdUnixgram(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "statsd.socket")
+	pc, err := net.ListenPacket("unixgram", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	st := metricsbp.NewStatsd(
+		context.Background(),
+		metricsbp.StatsdConfig{
+			Address:           "unixgram://" + path,
+			ReportingInterval: time.Hour,
+		},
+	)
+	st.Counter("foo").Add(1)
+	if err := st.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	pc.SetReadDeadline(time.Now().Add(time.Second * 5))
+	buf := make([]byte, 1024)
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const expected = "foo:1.000000|c\n"
+	if got := string(buf[:n]); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
+func TestWithRateTags(t *testing.T) {
+	st := metricsbp.NewStatsd(
+		context.Background(),
+		metricsbp.StatsdConfig{
+			Tags: metricsbp.Tags{
+				"foo": "bar",
+			},
+		},
+	)
+	st.CounterWithRate(metricsbp.RateArgs{
+		Name: "counter",
+		Rate: 1,
+	}).Add(1)
+	st.HistogramWithRate(metricsbp.RateArgs{
+		Name: "histogram",
+		Rate: 1,
+	}).Observe(1)
+	st.TimingWithRate(metricsbp.RateArgs{
+		Name: "timing",
+		Rate: 1,
+	}).Observe(1)
+
+	var sb strings.Builder
+	if _, err := st.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(sb.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 lines, got %q", lines)
+	}
+	for _, line := range lines {
+		if !strings.Contains(line, ",foo=bar:") {
+			t.Errorf("Expected tags in %q", line)
+		}
+	}
+}
+
+func TestPrefixSeparator(t *testing.T) {
+	for _, c := range []struct {
+		label     string
+		prefix    string
+		separator string
+		expected  string
+	}{
+		{
+			label:    "default",
+			prefix:   "service",
+			expected: "service.counter:1.000000|c",
+		},
+		{
+			label:     "custom",
+			prefix:    "service",
+			separator: "/",
+			expected:  "service/counter:1.000000|c",
+		},
+		{
+			label:     "custom-already-suffixed",
+			prefix:    "service/",
+			separator: "/",
+			expected:  "service/counter:1.000000|c",
+		},
+		{
+			label:     "too-long",
+			prefix:    "service",
+			separator: "::",
+			expected:  "service.counter:1.000000|c",
+		},
+		{
+			label:     "protocol-character",
+			prefix:    "service",
+			separator: ":",
+			expected:  "service.counter:1.000000|c",
+		},
+		{
+			label:    "invalid-prefix",
+			prefix:   "my service",
+			expected: "counter:1.000000|c",
+		},
+	} {
+		t.Run(c.label, func(t *testing.T) {
+			st := metricsbp.NewStatsd(
+				context.Background(),
+				metricsbp.StatsdConfig{
+					Prefix:          c.prefix,
+					PrefixSeparator: c.separator,
+				},
+			)
+			st.Counter("counter").Add(1)
+
+			var sb strings.Builder
+			if _, err := st.WriteTo(&sb); err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.TrimSpace(sb.String()); got != c.expected {
+				t.Errorf("Expected %q, got %q", c.expected, got)
+			}
+		})
+	}
+}
+
+func TestSampleRates(t *testing.T) {
+	st := metricsbp.NewStatsd(
+		context.Background(),
+		metricsbp.StatsdConfig{
+			SampleRates: map[string]float64{
+				"always": 1,
+				"never":  0,
+			},
+		},
+	)
+	for _, name := range []string{"always", "never", "default"} {
+		st.Counter(name).Add(1)
+		st.Histogram(name).Observe(1)
+		st.Timing(name).Observe(1)
+	}
+	// Explicit rate overrides SampleRates.
+	st.CounterWithRate(metricsbp.RateArgs{
+		Name: "never",
+		Rate: 1,
+	}).Add(2)
+
+	var sb strings.Builder
+	if _, err := st.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(sb.String()), "\n")
+	sort.Strings(lines)
+	expected := []string{
+		"always:1.000000|c",
+		"always:1.000000|h",
+		"always:1.000000|ms",
+		"default:1.000000|c",
+		"default:1.000000|h",
+		"default:1.000000|ms",
+		"never:2.000000|c",
+	}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Expected %q, got %q", expected, lines)
+	}
+}
+
+func TestSampleRatesNormalized(t *testing.T) {
+	for _, rate := range []float64{0, -1, math.NaN(), 1.5} {
+		t.Run(fmt.Sprintf("%v", rate), func(t *testing.T) {
+			st := metricsbp.NewStatsd(
+				context.Background(),
+				metricsbp.StatsdConfig{
+					CounterSampleRate:   metricsbp.Float64Ptr(rate),
+					HistogramSampleRate: metricsbp.Float64Ptr(rate),
+				},
+			)
+			st.Counter("counter").Add(1)
+			st.Histogram("histogram").Observe(1)
+			st.Timing("timing").Observe(1)
+
+			var sb strings.Builder
+			if _, err := st.WriteTo(&sb); err != nil {
+				t.Fatal(err)
+			}
+			lines := strings.Split(strings.TrimSpace(sb.String()), "\n")
+			sort.Strings(lines)
+			expected := []string{
+				"counter:1.000000|c",
+				"histogram:1.000000|h",
+				"timing:1.000000|ms",
+			}
+			if !reflect.DeepEqual(lines, expected) {
+				t.Errorf("Expected %q, got %q", expected, lines)
+			}
+		})
+	}
+}
+
+func TestCounterAggregated(t *testing.T) {
+	st := metricsbp.NewStatsd(
+		context.Background(),
+		metricsbp.StatsdConfig{},
+	)
+	counter := st.Counter("counter")
+	for i := 0; i < 1000; i++ {
+		counter.Add(1)
+		counter.With("key", "value").Add(2)
+	}
+
+	for _, c := range []struct {
+		label    string
+		expected []string
+	}{
+		{
+			label: "first-tick",
+			expected: []string{
+				"counter,key=value:2000.000000|c",
+				"counter:1000.000000|c",
+			},
+		},
+		{
+			label:    "next-tick",
+			expected: nil,
+		},
+	} {
+		var sb strings.Builder
+		if _, err := st.WriteTo(&sb); err != nil {
+			t.Fatal(err)
+		}
+		var lines []string
+		if s := strings.TrimSpace(sb.String()); s != "" {
+			lines = strings.Split(s, "\n")
+		}
+		sort.Strings(lines)
+		if !reflect.DeepEqual(lines, c.expected) {
+			t.Errorf("%s: Expected %q, got %q", c.label, c.expected, lines)
+		}
+	}
+}
+
+func TestErrorCounter(t *testing.T) {
+	st := metricsbp.NewStatsd(
+		context.Background(),
+		metricsbp.StatsdConfig{
+			CounterSampleRate: metricsbp.Float64Ptr(0.1),
+			SampleRates: map[string]float64{
+				"errors": 0,
+			},
+		},
+	)
+	for i := 0; i < 10; i++ {
+		st.ErrorCounter("errors").Add(1)
+	}
+
+	var sb strings.Builder
+	if _, err := st.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	const expected = "errors:10.000000|c\n"
+	if got := sb.String(); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
+func TestStatsdBufferSize(t *testing.T) {
+	const (
+		bufSize = 100
+		n       = 20
+	)
+
+	for _, c := range []struct {
+		label string
+		cfg   metricsbp.StatsdConfig
+	}{
+		{
+			label: "buffer-size",
+			cfg:   metricsbp.StatsdConfig{BufferSize: bufSize},
+		},
+		{
+			label: "max-packet-size",
+			cfg:   metricsbp.StatsdConfig{MaxPacketSize: bufSize},
+		},
+		{
+			label: "max-packet-size-above-buffer-size",
+			cfg: metricsbp.StatsdConfig{
+				BufferSize:    bufSize,
+				MaxPacketSize: metricsbp.DefaultBufferSize,
+			},
+		},
+	} {
+		t.Run(c.label, func(t *testing.T) {
+			pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer pc.Close()
+
+			cfg := c.cfg
+			cfg.Address = pc.LocalAddr().String()
+			cfg.ReportingInterval = time.Hour
+			st := metricsbp.NewStatsd(context.Background(), cfg)
+			for i := 0; i < n; i++ {
+				st.Counter(fmt.Sprintf("counter.%d", i)).Add(1)
+			}
+			if err := st.Close(); err != nil {
+				t.Fatalf("Close failed: %v", err)
+			}
+
+			var lines int
+			buf := make([]byte, 1024)
+			for lines < n {
+				pc.SetReadDeadline(time.Now().Add(time.Second * 5))
+				size, _, err := pc.ReadFrom(buf)
+				if err != nil {
+					t.Fatalf("Failed to read packets after %d lines: %v", lines, err)
+				}
+				packet := string(buf[:size])
+				if size > bufSize {
+					t.Errorf("Expected packet size <= %d, got %d: %q", bufSize, size, packet)
+				}
+				if !strings.HasSuffix(packet, "\n") {
+					t.Errorf("Packet not split on line boundary: %q", packet)
+				}
+				lines += strings.Count(packet, "\n")
+			}
+			if lines != n {
+				t.Errorf("Expected %d lines, got %d", n, lines)
+			}
+		})
+	}
+}
+
+func TestStatsdWriter(t *testing.T) {
+	var buf bytes.Buffer
+	st := metricsbp.NewStatsd(
+		context.Background(),
+		metricsbp.StatsdConfig{
+			// Address should be ignored when Writer is set.
+			Address: "unixgram:///does/not/exist",
+			Writer:  &buf,
+			Tags: metricsbp.Tags{
+				"foo": "bar",
+			},
+		},
+	)
+	st.Counter("counter").Add(1)
+	if err := st.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	const expected = "counter,foo=bar:1.000000|c\n"
+	if got := buf.String(); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+
+	buf.Reset()
+	st.Gauge("gauge").Set(1)
+	if err := st.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if got := buf.String(); !strings.Contains(got, "gauge,foo=bar:1.000000|g\n") {
+		t.Errorf("Expected gauge to be flushed on Close, got %q", got)
+	}
+}
+
+func TestDistribution(t *testing.T) {
+	for _, c := range []struct {
+		format   metricsbp.Format
+		expected string
+	}{
+		{
+			format:   metricsbp.FormatInflux,
+			expected: "payload.size,format=json:1024.000000|h\n",
+		},
+		{
+			format:   metricsbp.FormatDogStatsd,
+			expected: "payload.size:1024.000000|h|#format:json\n",
+		},
+	} {
+		t.Run(string(c.format), func(t *testing.T) {
+			st := metricsbp.NewStatsd(
+				context.Background(),
+				metricsbp.StatsdConfig{
+					Format: c.format,
+					// Distributions are never converted.
+					TimingUnit: metricsbp.TimingUnitSeconds,
+				},
+			)
+			st.Distribution("payload.size").With("format", "json").Observe(1024)
+
+			var sb strings.Builder
+			if _, err := st.WriteTo(&sb); err != nil {
+				t.Fatal(err)
+			}
+			if sb.String() != c.expected {
+				t.Errorf("Expected %q, got %q", c.expected, sb.String())
+			}
+		})
+	}
+}
+
+func TestStatsdConfig(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	st := metricsbp.NewStatsd(
+		ctx,
+		metricsbp.StatsdConfig{
+			Prefix:  "svc",
+			Address: "localhost:8125",
+			SampleRates: map[string]float64{
+				"foo": 0.5,
+			},
+			HistogramBuckets: []float64{2, 1},
+			Tags: metricsbp.Tags{
+				"foo": "bar",
+			},
+		},
+	)
+	defer st.Close()
+
+	cfg := st.Config()
+	if cfg.Prefix != "svc." {
+		t.Errorf("Expected Prefix %q, got %q", "svc.", cfg.Prefix)
+	}
+	if cfg.Network != metricsbp.DefaultNetwork {
+		t.Errorf("Expected Network %q, got %q", metricsbp.DefaultNetwork, cfg.Network)
+	}
+	if cfg.Address != "localhost:8125" {
+		t.Errorf("Expected Address %q, got %q", "localhost:8125", cfg.Address)
+	}
+	if cfg.CounterSampleRate == nil || *cfg.CounterSampleRate != metricsbp.DefaultSampleRate {
+		t.Errorf("Expected CounterSampleRate %v, got %v", metricsbp.DefaultSampleRate, cfg.CounterSampleRate)
+	}
+	if cfg.ReportingInterval != metricsbp.ReporterTickerInterval {
+		t.Errorf("Expected ReportingInterval %v, got %v", metricsbp.ReporterTickerInterval, cfg.ReportingInterval)
+	}
+	if cfg.BufferSize != metricsbp.DefaultBufferSize {
+		t.Errorf("Expected BufferSize %d, got %d", metricsbp.DefaultBufferSize, cfg.BufferSize)
+	}
+	if cfg.Format != metricsbp.DefaultFormat {
+		t.Errorf("Expected Format %q, got %q", metricsbp.DefaultFormat, cfg.Format)
+	}
+	if expected := []float64{1, 2}; !reflect.DeepEqual(cfg.HistogramBuckets, expected) {
+		t.Errorf("Expected HistogramBuckets %v, got %v", expected, cfg.HistogramBuckets)
+	}
+
+	cfg.SampleRates["foo"] = 1
+	cfg.HistogramBuckets[0] = 3
+	cfg.Tags["foo"] = "baz"
+	cfg = st.Config()
+	if cfg.SampleRates["foo"] != 0.5 {
+		t.Errorf("Expected SampleRates to be copied, got %v", cfg.SampleRates)
+	}
+	if cfg.HistogramBuckets[0] != 1 {
+		t.Errorf("Expected HistogramBuckets to be copied, got %v", cfg.HistogramBuckets)
+	}
+	if expected := (metricsbp.Tags{"foo": "bar"}); !reflect.DeepEqual(cfg.Tags, expected) {
+		t.Errorf("Expected Tags %v, got %v", expected, cfg.Tags)
+	}
+
+	t.Run("derived", func(t *testing.T) {
+		cfg := st.WithTags(metricsbp.Tags{"key": "value"}).WithSampleRate(0.1).Config()
+		if expected := (metricsbp.Tags{"foo": "bar", "key": "value"}); !reflect.DeepEqual(cfg.Tags, expected) {
+			t.Errorf("Expected Tags %v, got %v", expected, cfg.Tags)
+		}
+		if cfg.HistogramSampleRate == nil || *cfg.HistogramSampleRate != 0.1 {
+			t.Errorf("Expected HistogramSampleRate 0.1, got %v", cfg.HistogramSampleRate)
+		}
+	})
+}
+
+func TestStatsdReset(t *testing.T) {
+	st := metricsbp.NewStatsd(context.Background(), metricsbp.StatsdConfig{
+		TrackEmissions: true,
+	})
+	st.Counter("counter").Add(1)
+	st.WithTags(metricsbp.Tags{"key": "value"}).Gauge("gauge").Set(1)
+	st.Set("set").Add("foo")
+	st.Summary("summary", []float64{0.5}).Observe(1)
+	st.Meter("meter").Mark(1)
+	st.Reset()
+
+	var sb strings.Builder
+	if _, err := st.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	if sb.Len() != 0 {
+		t.Errorf("Expected nothing written after Reset, got %q", sb.String())
+	}
+	for _, c := range st.EmissionCounts() {
+		if c.Count != 0 {
+			t.Errorf("Expected emission counts reset to 0, got %+v", c)
+		}
+	}
+
+	st.Counter("counter").Add(1)
+	sb.Reset()
+	if _, err := st.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := sb.String(), "counter:1.000000|c\n"; got != want {
+		t.Errorf("Expected %q after Reset, got %q", want, got)
+	}
+}
+
+func TestDiscardUnreported(t *testing.T) {
+	st := metricsbp.NewStatsd(
+		context.Background(),
+		metricsbp.StatsdConfig{
+			DiscardUnreported: true,
+		},
+	)
+	st.Counter("counter").With("foo", "bar").Add(1)
+	st.Gauge("gauge").Set(1)
+	st.Histogram("histogram").Observe(1)
+	st.Timing("timing").Observe(1)
+	st.Set("set").With("foo", "bar").Add("value")
+	st.Meter("meter").With("foo", "bar").Mark(1)
+	st.Summary("summary", []float64{0.5}).With("foo", "bar").Observe(1)
+
+	var sb strings.Builder
+	if _, err := st.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	if sb.String() != "" {
+		t.Errorf("Expected nothing to be written, got %q", sb.String())
+	}
+
+	t.Run("ignored", func(t *testing.T) {
+		var buf bytes.Buffer
+		st := metricsbp.NewStatsd(
+			context.Background(),
+			metricsbp.StatsdConfig{
+				DiscardUnreported: true,
+				Writer:            &buf,
+				ReportingInterval: time.Hour,
+			},
+		)
+		st.Counter("counter").Add(1)
+		if err := st.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(buf.String(), "counter:1.000000|c\n") {
+			t.Errorf("Expected counter to be written, got %q", buf.String())
+		}
+	})
+}
@@ -0,0 +1,246 @@
+package metricsbp
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/beorn7/perks/quantile"
+	"github.com/go-kit/kit/metrics"
+)
+
+// SummaryEpsilon is the allowed rank error of the quantiles estimated by the
+// histograms returned by Summary.
+//
+// For example with 1,000 observations the p99 reported could be anything
+// between the 989th and 991st smallest observations.
+const SummaryEpsilon = 0.001
+
+// QuantileTagKey is the tag key of the quantile gauges reported by Summary.
+const QuantileTagKey = "quantile"
+
+// Summary returns a histogram metrics to the name,
+// which estimates the quantiles of the observations in process,
+// and reports them as gauges on every reporting tick,
+// for the statsd backends without percentiles support.
+//
+// For example, with name "latency" and quantiles []float64{0.5, 0.99},
+// on every tick it reports gauges "latency" tagged with quantile=0.5 and
+// quantile=0.99 respectively (and the tags passed into With),
+// estimated from the observations since the last tick.
+// Nothing is reported for a tick without observations,
+// and the streams without observations since the previous tick are dropped
+// from memory.
+//
+// The tags passed into With are limited by MaxTagCardinality in StatsdConfig
+// the same way as the other metrics, so that the number of streams kept in
+// memory is bounded.
+//
+// The quantiles are estimated by a streaming quantile estimator
+// (see SummaryEpsilon for the allowed error),
+// so the memory used only grows logarithmically with the number of
+// observations within a reporting tick.
+// Quantiles outside of [0, 1] are ignored.
+//
+// The summaries are cached the same way as the other metrics,
+// and never dropped from the cache,
+// so calling Summary with the same name and quantiles again
+// (for example on every request) returns the same summary,
+// instead of registering the callbacks for the reporting ticks again.
+// The quantiles are compared after dropping the invalid and duplicated ones,
+// regardless of their order.
+//
+// When DiscardUnreported in StatsdConfig is in effect,
+// it returns a no-op histogram.
+//
+// The observations are never sampled.
+func (st *Statsd) Summary(name string, quantiles []float64) metrics.Histogram {
+	st = st.fallback()
+	if st.discard {
+		return discardHistogram
+	}
+	qs := summaryQuantiles(quantiles)
+	key := st.nameKey(summaryKind, st.scopedName(name))
+	key.quantiles = quantilesKey(qs)
+	return st.shared.metrics.getPinned(key, func() interface{} {
+		targets := make(map[float64]float64, len(qs))
+		for _, q := range qs {
+			targets[q.q] = SummaryEpsilon
+		}
+		s := &summarySpace{
+			gauge:     st.Gauge(name),
+			withTags:  st.withTags,
+			quantiles: qs,
+			targets:   targets,
+			children:  make(map[string]*summaryStream),
+		}
+		st.onTick.add(s.report)
+		st.onDiscard.add(s.reset)
+		return summary{space: s}
+	}).(metrics.Histogram)
+}
+
+// summaryQuantiles returns the valid quantiles deduplicated and sorted.
+func summaryQuantiles(quantiles []float64) []summaryQuantile {
+	sorted := make([]float64, 0, len(quantiles))
+	for _, q := range quantiles {
+		if q < 0 || q > 1 {
+			continue
+		}
+		sorted = append(sorted, q)
+	}
+	sort.Float64s(sorted)
+	qs := make([]summaryQuantile, 0, len(sorted))
+	for i, q := range sorted {
+		if i > 0 && q == sorted[i-1] {
+			continue
+		}
+		qs = append(qs, summaryQuantile{
+			q:   q,
+			tag: strconv.FormatFloat(q, 'f', -1, 64),
+		})
+	}
+	return qs
+}
+
+// quantilesKey returns the canonical string of the quantiles,
+// used as the quantiles in metricKey.
+func quantilesKey(qs []summaryQuantile) string {
+	tags := make([]string, len(qs))
+	for i, q := range qs {
+		tags[i] = q.tag
+	}
+	return strings.Join(tags, ",")
+}
+
+type summaryQuantile struct {
+	q   float64
+	tag string
+}
+
+// summarySpace holds the streams of all the label values of a summary.
+type summarySpace struct {
+	gauge     metrics.Gauge
+	withTags  func(tagValues []string) []string
+	quantiles []summaryQuantile
+	targets   map[float64]float64
+
+	mu       sync.Mutex
+	children map[string]*summaryStream
+}
+
+// observe records the observation into the stream of the label values,
+// creating it if it doesn't exist yet.
+//
+// It holds s.mu during the observation,
+// so that report never drops a stream being observed.
+func (s *summarySpace) observe(labelValues []string, value float64) {
+	key := strings.Join(labelValues, "\x00")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ss := s.children[key]
+	if ss == nil {
+		ss = &summaryStream{
+			labelValues: labelValues,
+			stream:      quantile.NewTargeted(s.targets),
+		}
+		s.children[key] = ss
+	}
+	ss.observe(value)
+}
+
+// report sets the quantile gauges of all the streams with observations,
+// and resets them.
+//
+// The streams without observations since the previous report are dropped.
+func (s *summarySpace) report() {
+	s.mu.Lock()
+	children := make([]*summaryStream, 0, len(s.children))
+	for key, ss := range s.children {
+		if ss.idle() {
+			delete(s.children, key)
+			continue
+		}
+		children = append(children, ss)
+	}
+	s.mu.Unlock()
+
+	for _, ss := range children {
+		values := ss.queryAndReset(s.quantiles)
+		if values == nil {
+			continue
+		}
+		for i, q := range s.quantiles {
+			labelValues := make([]string, 0, len(ss.labelValues)+2)
+			labelValues = append(labelValues, ss.labelValues...)
+			labelValues = append(labelValues, QuantileTagKey, q.tag)
+			s.gauge.With(labelValues...).Set(values[i])
+		}
+	}
+}
+
+// reset discards all the streams.
+func (s *summarySpace) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.children = make(map[string]*summaryStream)
+}
+
+type summaryStream struct {
+	labelValues []string
+
+	mu     sync.Mutex
+	stream *quantile.Stream
+}
+
+func (ss *summaryStream) observe(value float64) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ss.stream.Insert(value)
+}
+
+// idle reports whether there's no observations since the last reset.
+func (ss *summaryStream) idle() bool {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	return ss.stream.Count() == 0
+}
+
+// queryAndReset returns the values of the quantiles,
+// or nil if there's no observations,
+// and resets the stream.
+func (ss *summaryStream) queryAndReset(quantiles []summaryQuantile) []float64 {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if ss.stream.Count() == 0 {
+		return nil
+	}
+	values := make([]float64, len(quantiles))
+	for i, q := range quantiles {
+		values[i] = ss.stream.Query(q.q)
+	}
+	ss.stream.Reset()
+	return values
+}
+
+// summary is the metrics.Histogram returned by Statsd.Summary.
+type summary struct {
+	space       *summarySpace
+	labelValues []string
+}
+
+func (s summary) With(labelValues ...string) metrics.Histogram {
+	labelValues = s.space.withTags(labelValues)
+	combined := make([]string, 0, len(s.labelValues)+len(labelValues))
+	combined = append(combined, s.labelValues...)
+	combined = append(combined, labelValues...)
+	return summary{
+		space:       s.space,
+		labelValues: combined,
+	}
+}
+
+func (s summary) Observe(value float64) {
+	s.space.observe(s.labelValues, value)
+}
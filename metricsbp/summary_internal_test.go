@@ -0,0 +1,48 @@
+package metricsbp
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+)
+
+func TestSummaryDropIdleStreams(t *testing.T) {
+	st := NewStatsd(context.Background(), StatsdConfig{})
+	s := st.Summary("latency", []float64{0.5}).(summary)
+	s.With("endpoint", "foo").Observe(1)
+	s.With("endpoint", "bar").Observe(1)
+
+	count := func() int {
+		s.space.mu.Lock()
+		defer s.space.mu.Unlock()
+		return len(s.space.children)
+	}
+
+	st.WriteTo(ioutil.Discard)
+	if got := count(); got != 2 {
+		t.Errorf("Expected 2 streams after the first write, got %d", got)
+	}
+
+	s.With("endpoint", "foo").Observe(1)
+	st.WriteTo(ioutil.Discard)
+	if got := count(); got != 1 {
+		t.Errorf("Expected the idle stream to be dropped, got %d streams", got)
+	}
+
+	st.WriteTo(ioutil.Discard)
+	if got := count(); got != 0 {
+		t.Errorf("Expected all the idle streams to be dropped, got %d streams", got)
+	}
+}
+
+func TestSummaryDiscardUnreported(t *testing.T) {
+	st := NewStatsd(context.Background(), StatsdConfig{
+		DiscardUnreported: true,
+	})
+	if s := st.Summary("latency", []float64{0.5}); s != discardHistogram {
+		t.Errorf("Expected the no-op histogram, got %#v", s)
+	}
+	if n := len(st.onTick.funcs); n != 0 {
+		t.Errorf("Expected no tick callbacks registered, got %d", n)
+	}
+}
@@ -0,0 +1,148 @@
+package metricsbp_test
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/reddit/baseplate.go/metricsbp"
+)
+
+func TestSummary(t *testing.T) {
+	const n = 1000
+
+	st := metricsbp.NewStatsd(
+		context.Background(),
+		metricsbp.StatsdConfig{},
+	)
+	summary := st.Summary("latency", []float64{0.5, 0.99, 2})
+	tagged := summary.With("endpoint", "foo")
+	// Observe in a non-sorted order.
+	for i := 0; i < n; i++ {
+		tagged.Observe(float64((i*7)%n + 1))
+	}
+
+	var sb strings.Builder
+	if _, err := st.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	values := make(map[string]float64)
+	for _, line := range strings.Split(strings.TrimSpace(sb.String()), "\n") {
+		if !strings.HasSuffix(line, "|g") {
+			t.Errorf("Expected gauge, got %q", line)
+			continue
+		}
+		i := strings.LastIndex(line, ":")
+		v, err := strconv.ParseFloat(strings.TrimSuffix(line[i+1:], "|g"), 64)
+		if err != nil {
+			t.Fatalf("Failed to parse %q: %v", line, err)
+		}
+		values[line[:i]] = v
+	}
+	if len(values) != 2 {
+		t.Errorf("Expected 2 quantile gauges, got %v", values)
+	}
+	for name, expected := range map[string]float64{
+		"latency,endpoint=foo,quantile=0.5":  500,
+		"latency,endpoint=foo,quantile=0.99": 990,
+	} {
+		got, ok := values[name]
+		if !ok {
+			t.Errorf("Expected %q in %v", name, values)
+			continue
+		}
+		if math.Abs(got-expected) > n*metricsbp.SummaryEpsilon*2 {
+			t.Errorf("Expected %q to be about %v, got %v", name, expected, got)
+		}
+	}
+
+	// Nothing is reported for the tick without observations.
+	sb.Reset()
+	if _, err := st.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	if sb.Len() != 0 {
+		t.Errorf("Expected nothing written without observations, got %q", sb.String())
+	}
+}
+
+func TestSummaryMaxTagCardinality(t *testing.T) {
+	st := metricsbp.NewStatsd(
+		context.Background(),
+		metricsbp.StatsdConfig{
+			MaxTagCardinality: 1,
+		},
+	)
+	summary := st.Summary("latency", []float64{0.5})
+	summary.With("endpoint", "foo").Observe(1)
+	summary.With("endpoint", "bar").Observe(2)
+	summary.With("endpoint", "baz").Observe(2)
+
+	var sb strings.Builder
+	if _, err := st.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(sb.String()), "\n")
+	sort.Strings(lines)
+	expected := []string{
+		"latency,endpoint=__overflow__,quantile=0.5:2.000000|g",
+		"latency,endpoint=foo,quantile=0.5:1.000000|g",
+	}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Expected %q, got %q", expected, lines)
+	}
+}
+
+func TestSummaryCached(t *testing.T) {
+	st := metricsbp.NewStatsd(
+		context.Background(),
+		metricsbp.StatsdConfig{},
+	)
+	write := func(t *testing.T) []string {
+		t.Helper()
+		var sb strings.Builder
+		if _, err := st.WriteTo(&sb); err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSpace(sb.String()), "\n")
+		sort.Strings(lines)
+		return lines
+	}
+
+	// Created on every "request", with the quantiles in different orders.
+	st.Summary("latency", []float64{0.5, 1}).Observe(5)
+	st.Summary("latency", []float64{1, 0.5, 0.5}).Observe(1)
+	expected := []string{
+		"latency,quantile=0.5:1.000000|g",
+		"latency,quantile=1:5.000000|g",
+	}
+	if lines := write(t); !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Expected %q, got %q", expected, lines)
+	}
+
+	// Still the same summary after the metric cache is pruned and reset.
+	write(t)
+	st.Reset()
+	st.Summary("latency", []float64{0.5, 1}).Observe(3)
+	st.Summary("latency", []float64{0.5, 1}).Observe(2)
+	expected = []string{
+		"latency,quantile=0.5:2.000000|g",
+		"latency,quantile=1:3.000000|g",
+	}
+	if lines := write(t); !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Expected %q, got %q", expected, lines)
+	}
+
+	// Different quantiles are different summaries.
+	st.Summary("latency", []float64{0.5}).Observe(1)
+	expected = []string{
+		"latency,quantile=0.5:1.000000|g",
+	}
+	if lines := write(t); !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Expected %q, got %q", expected, lines)
+	}
+}
@@ -0,0 +1,231 @@
+package metricsbp
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"unicode"
+
+	"github.com/go-kit/kit/metrics"
+)
+
+// SanitizeTag replaces all the characters in a tag key or value that's
+// reserved in Influxstatsd format with underscores ("_").
+//
+// The reserved characters are comma (","), equal sign ("="), colon (":"),
+// pipe ("|"), and whitespaces.
+// All of them would mangle the tags of the whole metric line,
+// or the whole statsd datagram.
+//
+// It's the default TagValueSanitizer, and it's always used for tag keys.
+func SanitizeTag(s string) string {
+	return strings.Map(sanitizeTagRune, s)
+}
+
+func sanitizeTagRune(r rune) rune {
+	switch r {
+	case ',', '=', ':', '|':
+		return '_'
+	}
+	if unicode.IsSpace(r) {
+		return '_'
+	}
+	return r
+}
+
+// sanitizeTags returns tagValues (as key-value pairs) sanitized.
+//
+// Keys are always sanitized by SanitizeTag,
+// values are sanitized by TagValueSanitizer in StatsdConfig.
+// The first time a tag is changed by sanitization it's logged.
+func (st *Statsd) sanitizeTags(tagValues []string) []string {
+	var sanitized []string
+	for i, s := range tagValues {
+		var v string
+		if i%2 == 0 {
+			v = SanitizeTag(s)
+		} else {
+			v = st.tagValueSanitizer(s)
+		}
+		if v == s {
+			continue
+		}
+		if sanitized == nil {
+			sanitized = make([]string, len(tagValues))
+			copy(sanitized, tagValues)
+		}
+		sanitized[i] = v
+	}
+	if sanitized == nil {
+		return tagValues
+	}
+	if atomic.CompareAndSwapInt32(&st.shared.tagsSanitized, 0, 1) {
+		st.logger.Log(
+			"during", "metricsbp.SanitizeTag",
+			"msg", "metric tags sanitized, further sanitizations will not be logged",
+			"tags", strings.Join(tagValues, ","),
+			"sanitized", strings.Join(sanitized, ","),
+		)
+	}
+	return sanitized
+}
+
+// withTags returns the tags (as key-value pairs) passed into With of the
+// metrics, sanitized and limited by MaxTagCardinality in StatsdConfig,
+// after checking the keys according to WarnUnknownTagKeys.
+func (st *Statsd) withTags(tagValues []string) []string {
+	sanitized := st.sanitizeTags(tagValues)
+	st.tagKeys.check(sanitized)
+	return st.cardinality.limit(sanitized)
+}
+
+// mergeTags returns tags (as key-value pairs) with tagValues merged in.
+//
+// The values in tagValues override the ones with the same keys in tags,
+// or panic with *TagConflictError if StrictTags in StatsdConfig is true.
+// tags is never modified.
+func (st *Statsd) mergeTags(tags, tagValues []string) []string {
+	if len(tagValues)%2 != 0 {
+		// Same as go-kit's lv.LabelValues.With.
+		tagValues = append(tagValues[:len(tagValues):len(tagValues)], "unknown")
+	}
+	merged := make([]string, len(tags), len(tags)+len(tagValues))
+	copy(merged, tags)
+outer:
+	for i := 0; i+1 < len(tagValues); i += 2 {
+		key, value := tagValues[i], tagValues[i+1]
+		for j := 0; j+1 < len(merged); j += 2 {
+			if merged[j] != key {
+				continue
+			}
+			if merged[j+1] != value && st.cfg.StrictTags {
+				panic(&TagConflictError{
+					Key:      key,
+					Value:    value,
+					Existing: merged[j+1],
+				})
+			}
+			merged[j+1] = value
+			continue outer
+		}
+		merged = append(merged, key, value)
+	}
+	return merged
+}
+
+// TagConflictError is the panic value when a tag already set on a metric is
+// overridden with a different value, and StrictTags in StatsdConfig is true.
+type TagConflictError struct {
+	Key      string
+	Value    string
+	Existing string
+}
+
+func (e *TagConflictError) Error() string {
+	return fmt.Sprintf(
+		"metricsbp: tag %q is already set to %q, cannot override it with %q",
+		e.Key,
+		e.Existing,
+		e.Value,
+	)
+}
+
+// taggedCounter is a metrics.Counter processing the tags in With via withTags,
+// and merging them into the tags it already has via mergeTags.
+type taggedCounter struct {
+	metrics.Counter
+
+	base metrics.Counter
+	tags []string
+	st   *Statsd
+}
+
+// newTaggedCounter creates a taggedCounter with the tags of st.
+func newTaggedCounter(st *Statsd, base metrics.Counter) taggedCounter {
+	return taggedCounter{
+		Counter: base.With(st.tags...),
+		base:    base,
+		tags:    st.tags,
+		st:      st,
+	}
+}
+
+func (c taggedCounter) With(tagValues ...string) metrics.Counter {
+	tags := c.st.mergeTags(c.tags, c.st.withTags(tagValues))
+	return taggedCounter{
+		Counter: c.base.With(tags...),
+		base:    c.base,
+		tags:    tags,
+		st:      c.st,
+	}
+}
+
+func (c taggedCounter) Add(delta float64) {
+	c.Counter.Add(delta)
+	c.st.retention.observe()
+}
+
+// taggedHistogram is a metrics.Histogram processing the tags in With via
+// withTags, and merging them into the tags it already has via mergeTags.
+type taggedHistogram struct {
+	metrics.Histogram
+
+	base metrics.Histogram
+	tags []string
+	st   *Statsd
+}
+
+// newTaggedHistogram creates a taggedHistogram with the tags of st.
+func newTaggedHistogram(st *Statsd, base metrics.Histogram) taggedHistogram {
+	return taggedHistogram{
+		Histogram: base.With(st.tags...),
+		base:      base,
+		tags:      st.tags,
+		st:        st,
+	}
+}
+
+func (h taggedHistogram) With(tagValues ...string) metrics.Histogram {
+	tags := h.st.mergeTags(h.tags, h.st.withTags(tagValues))
+	return taggedHistogram{
+		Histogram: h.base.With(tags...),
+		base:      h.base,
+		tags:      tags,
+		st:        h.st,
+	}
+}
+
+func (h taggedHistogram) Observe(value float64) {
+	h.Histogram.Observe(value)
+	h.st.retention.observe()
+}
+
+// taggedGauge is a metrics.Gauge processing the tags in With via withTags,
+// and merging them into the tags it already has via mergeTags.
+type taggedGauge struct {
+	metrics.Gauge
+
+	base metrics.Gauge
+	tags []string
+	st   *Statsd
+}
+
+// newTaggedGauge creates a taggedGauge with the tags of st.
+func newTaggedGauge(st *Statsd, base metrics.Gauge) taggedGauge {
+	return taggedGauge{
+		Gauge: base.With(st.tags...),
+		base:  base,
+		tags:  st.tags,
+		st:    st,
+	}
+}
+
+func (g taggedGauge) With(tagValues ...string) metrics.Gauge {
+	tags := g.st.mergeTags(g.tags, g.st.withTags(tagValues))
+	return taggedGauge{
+		Gauge: g.base.With(tags...),
+		base:  g.base,
+		tags:  tags,
+		st:    g.st,
+	}
+}
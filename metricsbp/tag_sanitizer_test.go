@@ -0,0 +1,120 @@
+package metricsbp_test
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/reddit/baseplate.go/metricsbp"
+)
+
+func TestSanitizeTag(t *testing.T) {
+	for _, c := range []struct {
+		tag      string
+		expected string
+	}{
+		{
+			tag:      "foo.bar-baz_1/2",
+			expected: "foo.bar-baz_1/2",
+		},
+		{
+			tag:      "a,b=c",
+			expected: "a_b_c",
+		},
+		{
+			tag:      "a:b|c",
+			expected: "a_b_c",
+		},
+		{
+			tag:      "a b\tc\n",
+			expected: "a_b_c_",
+		},
+	} {
+		t.Run(c.tag, func(t *testing.T) {
+			if got := metricsbp.SanitizeTag(c.tag); got != c.expected {
+				t.Errorf("Expected %q, got %q", c.expected, got)
+			}
+		})
+	}
+}
+
+func TestTagSanitizing(t *testing.T) {
+	for _, c := range []struct {
+		label     string
+		sanitizer func(string) string
+		expected  []string
+	}{
+		{
+			label: "default",
+			expected: []string{
+				"counter,static_key=static_value,key_1=a_b:1.000000|c",
+				"gauge,static_key=static_value,key_1=a_b:1.000000|g",
+				"histogram,static_key=static_value,key_1=a_b:1.000000|h",
+				"set,static_key=static_value,key_1=a_b:foo|s",
+				"timing,static_key=static_value,key_1=a_b:1.000000|ms",
+			},
+		},
+		{
+			label: "custom",
+			sanitizer: func(s string) string {
+				return strings.NewReplacer(",", "", " ", "").Replace(s)
+			},
+			expected: []string{
+				"counter,static_key=staticvalue,key_1=ab:1.000000|c",
+				"gauge,static_key=staticvalue,key_1=ab:1.000000|g",
+				"histogram,static_key=staticvalue,key_1=ab:1.000000|h",
+				"set,static_key=staticvalue,key_1=ab:foo|s",
+				"timing,static_key=staticvalue,key_1=ab:1.000000|ms",
+			},
+		},
+	} {
+		t.Run(c.label, func(t *testing.T) {
+			st := metricsbp.NewStatsd(
+				context.Background(),
+				metricsbp.StatsdConfig{
+					Tags: metricsbp.Tags{
+						"static key": "static value",
+					},
+					TagValueSanitizer: c.sanitizer,
+				},
+			)
+			st.Counter("counter").With("key,1", "a,b").Add(1)
+			st.Gauge("gauge").With("key,1", "a,b").Set(1)
+			st.Histogram("histogram").With("key,1", "a,b").Observe(1)
+			st.Timing("timing").With("key,1", "a,b").Observe(1)
+			st.Set("set").With("key,1", "a,b").Add("foo")
+
+			var sb strings.Builder
+			if _, err := st.WriteTo(&sb); err != nil {
+				t.Fatal(err)
+			}
+			lines := strings.Split(strings.TrimSpace(sb.String()), "\n")
+			sort.Strings(lines)
+			if !reflect.DeepEqual(lines, c.expected) {
+				t.Errorf("Expected %q, got %q", c.expected, lines)
+			}
+		})
+	}
+}
+
+func TestTagSanitizingSampled(t *testing.T) {
+	st := metricsbp.NewStatsd(
+		context.Background(),
+		metricsbp.StatsdConfig{},
+	)
+	st.CounterWithRate(metricsbp.RateArgs{
+		Name: "counter",
+		Rate: 0.99999,
+	}).With("key", "a b").With("other", "c=d").Add(1)
+
+	var sb strings.Builder
+	if _, err := st.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	const expected = "counter,key=a_b,other=c_d"
+	if got := sb.String(); got != "" && !strings.HasPrefix(got, expected) {
+		t.Errorf("Expected prefix %q, got %q", expected, got)
+	}
+}
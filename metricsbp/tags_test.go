@@ -1,6 +1,7 @@
 package metricsbp_test
 
 import (
+	"os"
 	"reflect"
 	"testing"
 
@@ -42,3 +43,25 @@ func TestTags(t *testing.T) {
 		})
 	}
 }
+
+func TestTagsFromEnv(t *testing.T) {
+	const (
+		env        = "METRICSBP_TEST_NAMESPACE"
+		missingEnv = "METRICSBP_TEST_MISSING"
+	)
+	os.Setenv(env, "")
+	defer os.Unsetenv(env)
+	os.Unsetenv(missingEnv)
+
+	got := metricsbp.TagsFromEnv(map[string]string{
+		"namespace": env,
+		"missing":   missingEnv,
+	})
+	// Set but empty environment variables are kept.
+	expected := metricsbp.Tags{
+		"namespace": "",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
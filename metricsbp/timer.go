@@ -12,7 +12,9 @@ const timerUnit = float64(time.Millisecond)
 //
 // It's very similar to go-kit's Timer, with a few differences:
 //
-// 1. The reporting unit is millisecond and non-changeable.
+// 1. The observed unit is millisecond and non-changeable
+// (the timings created by Statsd convert it into TimingUnit of StatsdConfig
+// when emitted).
 //
 // 2. It's nil-safe (zero values of *Timer or Timer will be safe to call, but
 // they are no-ops)
@@ -29,6 +31,23 @@ func NewTimer(h metrics.Histogram) *Timer {
 	return timer
 }
 
+// Time creates a new Timer reporting to the timing metrics to the name,
+// and records its start time.
+//
+// It's a shortcut for:
+//
+//     metricsbp.NewTimer(st.Timing(name))
+//
+// It's useful to time a function or a block of code:
+//
+//     func MyHandler() {
+//       defer metricsbp.M.Time("my.handler").ObserveDuration()
+//       // do the work
+//     }
+func (st *Statsd) Time(name string) *Timer {
+	return NewTimer(st.Timing(name))
+}
+
 // Start records the start time for the Timer.
 //
 // This is a shortcut for:
@@ -1,6 +1,9 @@
 package metricsbp_test
 
 import (
+	"context"
+	"regexp"
+	"strings"
 	"testing"
 	"time"
 
@@ -75,3 +78,31 @@ func TestTimerZero(_ *testing.T) {
 	t2.OverrideStartTime(time.Now())
 	t2.ObserveWithEndTime(time.Now())
 }
+
+func TestStatsdTime(t *testing.T) {
+	st := metricsbp.NewStatsd(
+		context.Background(),
+		metricsbp.StatsdConfig{
+			Tags: metricsbp.Tags{
+				"foo": "bar",
+			},
+		},
+	)
+	func() {
+		defer st.Time("handler").ObserveDuration()
+		time.Sleep(time.Millisecond * 10)
+	}()
+
+	var sb strings.Builder
+	if _, err := st.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	pattern := regexp.MustCompile(`^handler,foo=bar:(\d+)\.\d+\|ms\n$`)
+	str := sb.String()
+	if !pattern.MatchString(str) {
+		t.Fatalf("Expected %q to match %v", str, pattern)
+	}
+	if ms := pattern.FindStringSubmatch(str)[1]; ms == "0" {
+		t.Errorf("Expected non-zero duration, got %q", str)
+	}
+}
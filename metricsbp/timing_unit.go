@@ -0,0 +1,83 @@
+package metricsbp
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+)
+
+// TimingUnit is the unit of the timings emitted, see StatsdConfig.TimingUnit.
+type TimingUnit string
+
+// Supported TimingUnit values.
+const (
+	TimingUnitMilliseconds TimingUnit = "ms"
+	TimingUnitMicroseconds TimingUnit = "us"
+	TimingUnitSeconds      TimingUnit = "s"
+)
+
+// DefaultTimingUnit is the TimingUnit to be used when TimingUnit in
+// StatsdConfig is empty.
+const DefaultTimingUnit = TimingUnitMilliseconds
+
+// TimingUnitTagKey is the tag key of the unit attached to the timings when
+// TimingUnit in StatsdConfig is not milliseconds.
+const TimingUnitTagKey = "unit"
+
+// timingFactor returns the factor to convert milliseconds into unit.
+func timingFactor(unit TimingUnit) (float64, error) {
+	switch unit {
+	case "", TimingUnitMilliseconds:
+		return 1, nil
+	case TimingUnitMicroseconds:
+		return float64(time.Millisecond / time.Microsecond), nil
+	case TimingUnitSeconds:
+		return float64(time.Millisecond) / float64(time.Second), nil
+	default:
+		return 0, fmt.Errorf("metricsbp: unsupported timing unit %q", unit)
+	}
+}
+
+// withTimingUnit returns h converting the observed milliseconds into
+// TimingUnit, tagged with the unit.
+//
+// It returns h as-is when TimingUnit is milliseconds.
+func (st *Statsd) withTimingUnit(h metrics.Histogram) metrics.Histogram {
+	if st.timingFactor == 1 {
+		return h
+	}
+	return unitHistogram{
+		Histogram: h.With(TimingUnitTagKey, string(st.timingUnit)),
+		factor:    st.timingFactor,
+	}
+}
+
+// unitHistogram is a metrics.Histogram multiplying the observed values by
+// factor.
+type unitHistogram struct {
+	metrics.Histogram
+
+	factor float64
+}
+
+func (h unitHistogram) With(labelValues ...string) metrics.Histogram {
+	return unitHistogram{
+		Histogram: h.Histogram.With(labelValues...),
+		factor:    h.factor,
+	}
+}
+
+func (h unitHistogram) Observe(value float64) {
+	h.Histogram.Observe(value * h.factor)
+}
+
+// TimeDuration reports d to the timing metrics to the name,
+// converted into TimingUnit in StatsdConfig.
+//
+// It's a shortcut for:
+//
+//     st.Timing(name).Observe(float64(d) / float64(time.Millisecond))
+func (st *Statsd) TimeDuration(name string, d time.Duration) {
+	st.Timing(name).Observe(float64(d) / timerUnit)
+}
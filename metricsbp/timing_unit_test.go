@@ -0,0 +1,56 @@
+package metricsbp_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/reddit/baseplate.go/metricsbp"
+)
+
+func TestTimingUnit(t *testing.T) {
+	for _, c := range []struct {
+		unit     metricsbp.TimingUnit
+		expected string
+	}{
+		{
+			unit:     "",
+			expected: "timing:1500.000000|ms\n",
+		},
+		{
+			unit:     metricsbp.TimingUnitMilliseconds,
+			expected: "timing:1500.000000|ms\n",
+		},
+		{
+			unit:     metricsbp.TimingUnitMicroseconds,
+			expected: "timing,unit=us:1500000.000000|ms\n",
+		},
+		{
+			unit:     metricsbp.TimingUnitSeconds,
+			expected: "timing,unit=s:1.500000|ms\n",
+		},
+		{
+			unit:     "ns",
+			expected: "timing:1500.000000|ms\n",
+		},
+	} {
+		t.Run(string(c.unit), func(t *testing.T) {
+			st := metricsbp.NewStatsd(
+				context.Background(),
+				metricsbp.StatsdConfig{
+					TimingUnit: c.unit,
+				},
+			)
+			st.TimeDuration("timing", 1500*time.Millisecond)
+
+			var sb strings.Builder
+			if _, err := st.WriteTo(&sb); err != nil {
+				t.Fatal(err)
+			}
+			if sb.String() != c.expected {
+				t.Errorf("Expected %q, got %q", c.expected, sb.String())
+			}
+		})
+	}
+}
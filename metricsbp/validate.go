@@ -0,0 +1,222 @@
+package metricsbp
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// NewStatsdE is the same as NewStatsd,
+// except that it returns an error instead of logging and falling back to the
+// defaults for the invalid configs,
+// so that main can fail fast on a misconfigured deploy.
+//
+// It validates Prefix, PrefixSeparator, Tags, Format, the sample rates
+// (including TargetEmitRate), TimingUnit, Compression,
+// Network and Address, HTTP, and Sinks.
+// Tags must not contain any characters changed by the sanitization.
+//
+// It also dials Address (and the Address of every sink) once with ctx,
+// to catch the errors like unresolvable hosts or unix sockets not exist.
+// Please note that for udp that only resolves the address,
+// as there's no handshake.
+// The connection is closed right away,
+// the background reporting goroutine dials its own connection.
+//
+// Unlike NewStatsd, it returns an error when ctx is already done and the
+// background reporting goroutine would be started.
+//
+// The returned *Statsd is non-nil if and only if the returned error is nil.
+func NewStatsdE(ctx context.Context, cfg StatsdConfig) (*Statsd, error) {
+	if err := validateStatsdConfig(cfg); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil && reportsToWriter(cfg) {
+		return nil, fmt.Errorf("metricsbp: context already done: %w", err)
+	}
+	if cfg.Provider == nil {
+		if cfg.Writer == nil && cfg.HTTP == nil && cfg.File == nil && cfg.Address != "" {
+			if err := dial(ctx, cfg.Network, cfg.Address); err != nil {
+				return nil, err
+			}
+		}
+		for _, sink := range cfg.Sinks {
+			if err := dial(ctx, sink.Network, sink.Address); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return NewStatsd(ctx, cfg), nil
+}
+
+// validateStatsdConfig returns the first invalid config found in cfg.
+func validateStatsdConfig(cfg StatsdConfig) error {
+	if err := validatePrefix(cfg.Prefix); err != nil {
+		return err
+	}
+	if cfg.PrefixSeparator != "" {
+		if err := validatePrefixSeparator(cfg.PrefixSeparator); err != nil {
+			return err
+		}
+	}
+	if err := validateTags(cfg.Tags, cfg.TagValueSanitizer); err != nil {
+		return err
+	}
+	if cfg.CounterSampleRate != nil {
+		if err := validateSampleRate(*cfg.CounterSampleRate, "CounterSampleRate"); err != nil {
+			return err
+		}
+	}
+	if cfg.HistogramSampleRate != nil {
+		if err := validateSampleRate(*cfg.HistogramSampleRate, "HistogramSampleRate"); err != nil {
+			return err
+		}
+	}
+	for name, rate := range cfg.SampleRates {
+		if err := validateSampleRate(rate, fmt.Sprintf("SampleRates[%q]", name)); err != nil {
+			return err
+		}
+	}
+	if math.IsNaN(cfg.TargetEmitRate) || cfg.TargetEmitRate < 0 {
+		return fmt.Errorf(
+			"metricsbp: TargetEmitRate %v must be non-negative",
+			cfg.TargetEmitRate,
+		)
+	}
+	if _, err := timingFactor(cfg.TimingUnit); err != nil {
+		return err
+	}
+	if cfg.Provider != nil {
+		return nil
+	}
+	if err := validateCompression(cfg.Compression); err != nil {
+		return err
+	}
+	if err := validateFormat(cfg.Format); err != nil {
+		return err
+	}
+	switch {
+	case cfg.Writer != nil:
+	case cfg.HTTP != nil:
+		if err := validateHTTPConfig(*cfg.HTTP); err != nil {
+			return err
+		}
+	case cfg.File != nil:
+		if err := validateFileConfig(*cfg.File); err != nil {
+			return err
+		}
+	case cfg.Address != "":
+		if _, _, err := parseAddress(cfg.Network, cfg.Address); err != nil {
+			return err
+		}
+	}
+	for _, sink := range cfg.Sinks {
+		if err := validateFormat(sink.Format); err != nil {
+			return err
+		}
+		if _, _, err := parseAddress(sink.Network, sink.Address); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validatePrefix returns an error if prefix contains any of the characters
+// used by the statsd line protocol.
+func validatePrefix(prefix string) error {
+	if i := strings.IndexFunc(prefix, isReservedPrefixRune); i >= 0 {
+		r, _ := utf8.DecodeRuneInString(prefix[i:])
+		return fmt.Errorf(
+			"metricsbp: Prefix %q contains reserved character %q",
+			prefix,
+			r,
+		)
+	}
+	return nil
+}
+
+// validatePrefixSeparator returns an error if separator is not a single
+// character allowed in Prefix.
+func validatePrefixSeparator(separator string) error {
+	if utf8.RuneCountInString(separator) != 1 {
+		return fmt.Errorf(
+			"metricsbp: PrefixSeparator %q is not a single character",
+			separator,
+		)
+	}
+	if strings.IndexFunc(separator, isReservedPrefixRune) >= 0 {
+		return fmt.Errorf(
+			"metricsbp: PrefixSeparator %q is a reserved character",
+			separator,
+		)
+	}
+	return nil
+}
+
+// validateTags returns an error if any of the tags would be changed by the
+// sanitization.
+func validateTags(tags Tags, valueSanitizer func(string) string) error {
+	if valueSanitizer == nil {
+		valueSanitizer = SanitizeTag
+	}
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if SanitizeTag(k) != k {
+			return fmt.Errorf("metricsbp: tag key %q contains reserved characters", k)
+		}
+		if v := tags[k]; valueSanitizer(v) != v {
+			return fmt.Errorf(
+				"metricsbp: value %q of tag %q contains reserved characters",
+				v,
+				k,
+			)
+		}
+	}
+	return nil
+}
+
+// validateSampleRate returns an error if rate would be normalized by
+// normalizeSampleRate.
+func validateSampleRate(rate float64, field string) error {
+	if math.IsNaN(rate) || rate <= 0 || rate > 1 {
+		return fmt.Errorf(
+			"metricsbp: %s %v is out of range (0, 1]",
+			field,
+			rate,
+		)
+	}
+	return nil
+}
+
+// validateFormat returns an error if format is not supported.
+func validateFormat(format Format) error {
+	switch format {
+	case "", FormatInflux, FormatDogStatsd, FormatPlain:
+		return nil
+	default:
+		return fmt.Errorf("metricsbp: unsupported format %q", format)
+	}
+}
+
+// dial dials the configured network and address once and closes the
+// connection.
+func dial(ctx context.Context, network, address string) error {
+	network, address, err := parseAddress(network, address)
+	if err != nil {
+		return err
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, address)
+	if err != nil {
+		return fmt.Errorf("metricsbp: failed to dial %s://%s: %w", network, address, err)
+	}
+	return conn.Close()
+}
@@ -0,0 +1,169 @@
+package metricsbp_test
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/reddit/baseplate.go/metricsbp"
+)
+
+func TestNewStatsdE(t *testing.T) {
+	zero := 0.0
+	for _, c := range []struct {
+		label string
+		cfg   metricsbp.StatsdConfig
+		err   bool
+	}{
+		{
+			label: "valid",
+			cfg: metricsbp.StatsdConfig{
+				Prefix: "service",
+				Tags: metricsbp.Tags{
+					"foo": "bar",
+				},
+			},
+		},
+		{
+			label: "prefix",
+			cfg: metricsbp.StatsdConfig{
+				Prefix: "service,foo=bar",
+			},
+			err: true,
+		},
+		{
+			label: "tag-key",
+			cfg: metricsbp.StatsdConfig{
+				Tags: metricsbp.Tags{
+					"foo bar": "bar",
+				},
+			},
+			err: true,
+		},
+		{
+			label: "tag-value",
+			cfg: metricsbp.StatsdConfig{
+				Tags: metricsbp.Tags{
+					"foo": "bar:baz",
+				},
+			},
+			err: true,
+		},
+		{
+			label: "separator",
+			cfg: metricsbp.StatsdConfig{
+				PrefixSeparator: "|",
+			},
+			err: true,
+		},
+		{
+			label: "sample-rate",
+			cfg: metricsbp.StatsdConfig{
+				HistogramSampleRate: &zero,
+			},
+			err: true,
+		},
+		{
+			label: "sample-rates",
+			cfg: metricsbp.StatsdConfig{
+				SampleRates: map[string]float64{
+					"foo": 2,
+				},
+			},
+			err: true,
+		},
+		{
+			label: "target-emit-rate",
+			cfg: metricsbp.StatsdConfig{
+				TargetEmitRate: -1,
+			},
+			err: true,
+		},
+		{
+			label: "timing-unit",
+			cfg: metricsbp.StatsdConfig{
+				TimingUnit: "ns",
+			},
+			err: true,
+		},
+		{
+			label: "http",
+			cfg: metricsbp.StatsdConfig{
+				HTTP: &metricsbp.HTTPConfig{
+					URL: "statsd-gateway/lines",
+				},
+			},
+			err: true,
+		},
+		{
+			label: "format",
+			cfg: metricsbp.StatsdConfig{
+				Format: "graphite",
+			},
+			err: true,
+		},
+		{
+			label: "network",
+			cfg: metricsbp.StatsdConfig{
+				Address: "localhost:8125",
+				Network: "http",
+			},
+			err: true,
+		},
+		{
+			label: "udp",
+			cfg: metricsbp.StatsdConfig{
+				Address: "127.0.0.1:8125",
+			},
+		},
+		{
+			label: "unix-socket-not-exist",
+			cfg: metricsbp.StatsdConfig{
+				Address: "unixgram://" + filepath.Join(t.TempDir(), "statsd.socket"),
+			},
+			err: true,
+		},
+		{
+			label: "sink-unix-socket-not-exist",
+			cfg: metricsbp.StatsdConfig{
+				Sinks: []metricsbp.SinkConfig{
+					{
+						Address: "unixgram://" + filepath.Join(t.TempDir(), "statsd.socket"),
+					},
+				},
+			},
+			err: true,
+		},
+		{
+			label: "tag-value-custom-sanitizer",
+			cfg: metricsbp.StatsdConfig{
+				Tags: metricsbp.Tags{
+					"foo": "BAR",
+				},
+				TagValueSanitizer: strings.ToLower,
+			},
+			err: true,
+		},
+	} {
+		t.Run(c.label, func(t *testing.T) {
+			st, err := metricsbp.NewStatsdE(context.Background(), c.cfg)
+			if c.err {
+				if err == nil {
+					t.Error("Expected error, got nil")
+				}
+				if st != nil {
+					t.Errorf("Expected nil Statsd on error, got %v", st)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if st == nil {
+				t.Fatal("Expected non-nil Statsd")
+			}
+			st.Close()
+		})
+	}
+}
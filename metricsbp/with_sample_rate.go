@@ -0,0 +1,32 @@
+package metricsbp
+
+// WithSampleRate returns a Statsd derived from st,
+// with rate used as the sample rate of the counters, histograms and timings
+// created from it, instead of CounterSampleRate and HistogramSampleRate from
+// StatsdConfig.
+//
+// It's useful when a whole subsystem wants a different sample rate than the
+// rest of the service:
+//
+//     st := metricsbp.M.WithSampleRate(0.1)
+//
+// The per-metric overrides in StatsdConfig.SampleRates still take precedence,
+// and the *WithRate functions and ErrorCounter are not affected.
+//
+// Same as WithTags, the derived Statsd shares everything else with st,
+// so no new background reporting goroutine will be started.
+// It can be combined with WithTags in either order.
+//
+// rate is normalized the same way as the sample rates in StatsdConfig,
+// so 0, negative and NaN rates are treated as DefaultSampleRate,
+// and rates larger than 1 are clamped to 1.
+//
+// It's safe to be called concurrently, and st is not modified.
+func (st *Statsd) WithSampleRate(rate float64) *Statsd {
+	st = st.fallback()
+	rate = normalizeSampleRate(rate, "metricsbp.Statsd.WithSampleRate", "rate", st.logger)
+	derived := *st
+	derived.counterSampleRate = rate
+	derived.histogramSampleRate = rate
+	return &derived
+}
@@ -0,0 +1,80 @@
+package metricsbp_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/reddit/baseplate.go/metricsbp"
+)
+
+func TestWithSampleRate(t *testing.T) {
+	const n = 100
+
+	var buf bytes.Buffer
+	st := metricsbp.NewStatsd(
+		context.Background(),
+		metricsbp.StatsdConfig{
+			Writer: &buf,
+			SampleRates: map[string]float64{
+				"override": 1,
+			},
+		},
+	)
+	defer st.Close()
+
+	derived := st.WithSampleRate(0.5)
+	tagged := derived.WithTags(metricsbp.Tags{"foo": "bar"})
+	for i := 0; i < n; i++ {
+		st.Counter("root").Add(1)
+		derived.Counter("counter").Add(1)
+		derived.Histogram("histogram").Observe(1)
+		derived.Timing("timing").Observe(1)
+		derived.Counter("override").Add(1)
+		derived.ErrorCounter("error").Add(1)
+		tagged.Counter("tagged").Add(1)
+		st.WithTags(metricsbp.Tags{"foo": "bar"}).WithSampleRate(0.5).Counter("tagged-first").Add(1)
+		// 0 is treated as 1.
+		st.WithSampleRate(0).Counter("zero").Add(1)
+	}
+
+	if err := st.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	lines := make(map[string]bool)
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		lines[line] = true
+	}
+
+	for _, expected := range []string{
+		"root:100.000000|c",
+		"override:100.000000|c",
+		"error:100.000000|c",
+		"zero:100.000000|c",
+	} {
+		if !lines[expected] {
+			t.Errorf("Expected line %q, got %q", expected, buf.String())
+		}
+	}
+	for _, prefix := range []string{
+		"counter:",
+		"histogram:",
+		"timing:",
+		"tagged,foo=bar:",
+		"tagged-first,foo=bar:",
+	} {
+		var found bool
+		for line := range lines {
+			if strings.HasPrefix(line, prefix) {
+				found = true
+				if !strings.HasSuffix(line, "|@0.500000") {
+					t.Errorf("Expected %q to be sampled at 0.5", line)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("Expected line with prefix %q, got %q", prefix, buf.String())
+		}
+	}
+}
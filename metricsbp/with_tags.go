@@ -0,0 +1,89 @@
+package metricsbp
+
+// WithTags returns a Statsd derived from st,
+// with the additional tags attached to every metric created from it.
+//
+// It's useful for the tags that are not known until after NewStatsd,
+// for example a dynamically discovered shard id:
+//
+//     metricsbp.SetM(metricsbp.GetM().WithTags(metricsbp.Tags{
+//       "shard": shardID,
+//     }))
+//
+// The derived Statsd shares everything else with st,
+// including the Prefix, the sample rates, the background reporting goroutine,
+// and the context,
+// so no new background reporting goroutine will be started,
+// and calling Close on either of them stops the background reporting
+// goroutine for both.
+//
+// The tags are sanitized the same way as Tags in StatsdConfig,
+// and they are not limited by MaxTagCardinality.
+// Metrics created from st before WithTags is called are not affected.
+// The tags override the ones with the same keys from st,
+// and can be overridden by the tags passed into With of the metrics
+// (see Tags in StatsdConfig).
+//
+// It's safe to be called concurrently, and st is not modified.
+func (st *Statsd) WithTags(tags Tags) *Statsd {
+	st = st.fallback()
+	extra := st.sanitizeTags(tags.AsStatsdTags())
+	if len(extra) == 0 {
+		return st
+	}
+	st.tagKeys.check(extra)
+
+	derived := *st
+	derived.tags = st.mergeTags(st.tags, extra)
+	derived.tagsKey = tagsKey(derived.tags)
+	return &derived
+}
+
+// WithoutTags returns a Statsd derived from st,
+// with the tags of the keys removed from the tags attached to every metric
+// created from it.
+//
+// It's useful for the fleet-wide metrics that shouldn't be fragmented by the
+// per-host tags, for example:
+//
+//     var fleetWide = metricsbp.M.WithoutTags(metricsbp.DefaultHostnameTagKey)
+//
+//     func (h *myHandler) Handle(ctx context.Context) {
+//       defer fleetWide.Counter("my.handler.requests").Add(1)
+//       ...
+//     }
+//
+// The keys are sanitized the same way as the tag keys,
+// and the keys not attached to st are ignored.
+// It only removes the tags of st, including the Tags in StatsdConfig, the
+// default tags, and the ones added by WithTags.
+// The tags passed into With of the metrics and the ones resolved via
+// WithCtxTags are still attached, even with the removed keys,
+// and the tags added by WithTags on the derived Statsd are attached as well.
+//
+// The derived Statsd shares everything else with st, the same as WithTags,
+// and st is not modified.
+func (st *Statsd) WithoutTags(keys ...string) *Statsd {
+	st = st.fallback()
+	if len(keys) == 0 {
+		return st
+	}
+	removed := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		removed[SanitizeTag(key)] = true
+	}
+	tags := make([]string, 0, len(st.tags))
+	for i := 0; i+1 < len(st.tags); i += 2 {
+		if !removed[st.tags[i]] {
+			tags = append(tags, st.tags[i], st.tags[i+1])
+		}
+	}
+	if len(tags) == len(st.tags) {
+		return st
+	}
+
+	derived := *st
+	derived.tags = tags
+	derived.tagsKey = tagsKey(derived.tags)
+	return &derived
+}
@@ -0,0 +1,241 @@
+package metricsbp_test
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/reddit/baseplate.go/metricsbp"
+)
+
+func TestWithTags(t *testing.T) {
+	var buf bytes.Buffer
+	st := metricsbp.NewStatsd(
+		context.Background(),
+		metricsbp.StatsdConfig{
+			Writer: &buf,
+			Tags: metricsbp.Tags{
+				"foo": "bar",
+			},
+		},
+	)
+	defer st.Close()
+
+	derived := st.WithTags(metricsbp.Tags{
+		"shard id": "1",
+	})
+	st.Counter("root").Add(1)
+	derived.Counter("counter").With("key", "value").Add(1)
+	derived.Gauge("gauge").Set(1)
+	derived.Histogram("histogram").Observe(1)
+	derived.Timing("timing").Observe(1)
+	derived.Set("set").Add("a")
+
+	// Flushing the derived one flushes st as they share the writer.
+	if err := derived.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if !strings.Contains(line, "baseplate.metricsbp.") {
+			lines = append(lines, line)
+		}
+	}
+	sort.Strings(lines)
+	expected := []string{
+		"counter,foo=bar,shard_id=1,key=value:1.000000|c",
+		"gauge,foo=bar,shard_id=1:1.000000|g",
+		"histogram,foo=bar,shard_id=1:1.000000|h",
+		"root,foo=bar:1.000000|c",
+		"set,foo=bar,shard_id=1:a|s",
+		"timing,foo=bar,shard_id=1:1.000000|ms",
+	}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Expected %q, got %q", expected, lines)
+	}
+}
+
+func TestWithTagsConcurrent(t *testing.T) {
+	st := metricsbp.NewStatsd(
+		context.Background(),
+		metricsbp.StatsdConfig{},
+	)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			st.WithTags(metricsbp.Tags{"foo": "bar"}).Counter("counter").Add(1)
+		}()
+	}
+	wg.Wait()
+
+	var sb strings.Builder
+	if _, err := st.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	const expected = "counter,foo=bar:10.000000|c\n"
+	if sb.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, sb.String())
+	}
+}
+
+func TestTagPrecedence(t *testing.T) {
+	for _, c := range []struct {
+		format   metricsbp.Format
+		expected []string
+	}{
+		{
+			format: metricsbp.FormatInflux,
+			expected: []string{
+				"counter,method=with,shard=derived:1.000000|c",
+				"gauge,method=later,shard=derived:1.000000|g",
+				"histogram,method=static,shard=histogram:1.000000|h",
+				"set,method=with,shard=derived:a|s",
+			},
+		},
+		{
+			format: metricsbp.FormatDogStatsd,
+			expected: []string{
+				"counter:1.000000|c|#method:with,shard:derived",
+				"gauge:1.000000|g|#method:later,shard:derived",
+				"histogram:1.000000|h|#method:static,shard:histogram",
+				"set:a|s|#method:with,shard:derived",
+			},
+		},
+	} {
+		t.Run(string(c.format), func(t *testing.T) {
+			var buf bytes.Buffer
+			st := metricsbp.NewStatsd(
+				context.Background(),
+				metricsbp.StatsdConfig{
+					Writer: &buf,
+					Format: c.format,
+					Tags: metricsbp.Tags{
+						"method": "static",
+					},
+				},
+			)
+			defer st.Close()
+
+			derived := st.WithTags(metricsbp.Tags{
+				"shard": "derived",
+			})
+			derived.Counter("counter").With("method", "with").Add(1)
+			derived.Gauge("gauge").With("method", "with").With("method", "later").Set(1)
+			derived.Histogram("histogram").With("shard", "histogram").Observe(1)
+			derived.Set("set").With("method", "with").Add("a")
+
+			if _, err := st.WriteTo(&buf); err != nil {
+				t.Fatal(err)
+			}
+			var lines []string
+			for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+				if !strings.Contains(line, "baseplate.metricsbp.") {
+					lines = append(lines, line)
+				}
+			}
+			sort.Strings(lines)
+			if !reflect.DeepEqual(lines, c.expected) {
+				t.Errorf("Expected %q, got %q", c.expected, lines)
+			}
+		})
+	}
+}
+
+func TestStrictTags(t *testing.T) {
+	st := metricsbp.NewStatsd(
+		context.Background(),
+		metricsbp.StatsdConfig{
+			Tags: metricsbp.Tags{
+				"method": "static",
+			},
+			StrictTags: true,
+		},
+	)
+	defer st.Close()
+
+	// Setting the same value again is not a conflict.
+	st.Counter("same").With("method", "static").Add(1)
+
+	expectConflict := func(t *testing.T, f func()) {
+		t.Helper()
+		defer func() {
+			t.Helper()
+			r := recover()
+			err, ok := r.(*metricsbp.TagConflictError)
+			if !ok {
+				t.Fatalf("Expected panic with *TagConflictError, got %#v", r)
+			}
+			if err.Key != "method" || err.Existing != "static" || err.Value != "with" {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		}()
+		f()
+	}
+	t.Run("With", func(t *testing.T) {
+		expectConflict(t, func() {
+			st.Counter("counter").With("method", "with")
+		})
+	})
+	t.Run("WithTags", func(t *testing.T) {
+		expectConflict(t, func() {
+			st.WithTags(metricsbp.Tags{"method": "with"})
+		})
+	})
+	t.Run("Set", func(t *testing.T) {
+		expectConflict(t, func() {
+			st.Set("set").With("method", "with")
+		})
+	})
+}
+
+func TestWithoutTags(t *testing.T) {
+	st := metricsbp.NewStatsd(
+		context.Background(),
+		metricsbp.StatsdConfig{
+			Tags: metricsbp.Tags{
+				"host": "host-1",
+			},
+		},
+	)
+	derived := st.WithTags(metricsbp.Tags{
+		"foo": "bar",
+	}).WithTags(metricsbp.Tags{
+		"shard": "1",
+	}).WithoutTags("host", "shard", "unknown")
+
+	st.Counter("counter").Add(1)
+	derived.Counter("counter").Add(1)
+	derived.Counter("counter").Add(1)
+	// With still attaches the removed keys.
+	derived.Counter("with").With("host", "host-2").Add(1)
+	derived.WithTags(metricsbp.Tags{"shard": "2"}).Gauge("gauge").Set(1)
+
+	var sb strings.Builder
+	if _, err := st.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(sb.String()), "\n")
+	sort.Strings(lines)
+	expected := []string{
+		"counter,foo=bar:2.000000|c",
+		"counter,host=host-1:1.000000|c",
+		"gauge,foo=bar,shard=2:1.000000|g",
+		"with,foo=bar,host=host-2:1.000000|c",
+	}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Expected %q, got %q", expected, lines)
+	}
+
+	if st.WithoutTags() != st {
+		t.Error("Expected WithoutTags without keys to return st")
+	}
+	if st.WithoutTags("unknown") != st {
+		t.Error("Expected WithoutTags without any attached keys to return st")
+	}
+}
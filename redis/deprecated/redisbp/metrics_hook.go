@@ -0,0 +1,89 @@
+package redisbp
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+
+	"github.com/reddit/baseplate.go/metricsbp"
+)
+
+// MetricsHook is a redis.Hook for reporting metrics of Redis commands and
+// pipelines.
+//
+// For client named "redis", it reports:
+//
+// - counter redis.client.calls, tagged with client=redis and command
+//
+// - counter redis.client.errors, tagged with client=redis and command
+//
+// - timing redis.client.latency, tagged with client=redis and command
+//
+// command is the name of the Redis command (e.g. "mget"),
+// or "pipeline" for pipelines.
+// The arguments of the command are never part of the tags,
+// so the cardinality of the command tag is bounded by the number of Redis
+// commands used.
+// redis.Nil is not treated as an error.
+type MetricsHook struct {
+	ClientName string
+
+	// The Statsd to report the metrics to.
+	//
+	// If it's nil, metricsbp.M will be used instead.
+	Statsd metricsbp.Metrics
+}
+
+var _ redis.Hook = MetricsHook{}
+
+type metricsHookStartKey struct{}
+
+// BeforeProcess records the start time of the Redis command.
+func (h MetricsHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return h.start(ctx), nil
+}
+
+// AfterProcess reports the metrics of the Redis command.
+func (h MetricsHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	h.report(ctx, cmd.Name(), cmd.Err())
+	return nil
+}
+
+// BeforeProcessPipeline records the start time of the Redis pipeline.
+func (h MetricsHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return h.start(ctx), nil
+}
+
+// AfterProcessPipeline reports the metrics of the Redis pipeline,
+// which is treated as an error when any of the commands failed.
+func (h MetricsHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	var err error
+	for _, cmd := range cmds {
+		if e := cmd.Err(); e != nil && !errors.Is(e, redis.Nil) {
+			err = e
+			break
+		}
+	}
+	h.report(ctx, "pipeline", err)
+	return nil
+}
+
+func (h MetricsHook) start(ctx context.Context) context.Context {
+	return context.WithValue(ctx, metricsHookStartKey{}, time.Now())
+}
+
+func (h MetricsHook) report(ctx context.Context, command string, err error) {
+	st := metricsbp.MetricsOrM(h.Statsd)
+	tags := []string{"client", h.ClientName, "command", command}
+	st.Counter("redis.client.calls").With(tags...).Add(1)
+	if err != nil && !errors.Is(err, redis.Nil) {
+		st.Counter("redis.client.errors").With(tags...).Add(1)
+	}
+	if start, ok := ctx.Value(metricsHookStartKey{}).(time.Time); ok {
+		metricsbp.NewTimer(st.Timing("redis.client.latency").With(tags...)).
+			OverrideStartTime(start).
+			ObserveDuration()
+	}
+}
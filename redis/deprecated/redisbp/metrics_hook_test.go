@@ -0,0 +1,115 @@
+package redisbp_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v7"
+
+	"github.com/reddit/baseplate.go/metricsbp"
+	"github.com/reddit/baseplate.go/metricsbp/metricsbptest"
+	"github.com/reddit/baseplate.go/redis/deprecated/redisbp"
+)
+
+func TestMetricsHook(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	st := metricsbptest.NewRecordingStatsd(t, metricsbp.StatsdConfig{})
+	client := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	defer client.Close()
+	client.AddHook(redisbp.MetricsHook{
+		ClientName: "redis",
+		Statsd:     st.Statsd,
+	})
+
+	client.MGet("a", "b", "c")
+	client.MGet("d")
+	// redis.Nil is not an error
+	client.Get("a")
+	// wrong type
+	client.Set("key", "value", 0)
+	client.LPush("key", "value")
+	pipe := client.Pipeline()
+	pipe.Get("a")
+	pipe.Set("b", "value", 0)
+	if _, err := pipe.Exec(); err != nil && err != redis.Nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		command string
+		calls   float64
+		errors  float64
+	}{
+		{command: "mget", calls: 2},
+		{command: "get", calls: 1},
+		{command: "lpush", calls: 1, errors: 1},
+		{command: "pipeline", calls: 1},
+	} {
+		t.Run(c.command, func(t *testing.T) {
+			tags := metricsbp.Tags{
+				"client":  "redis",
+				"command": c.command,
+			}
+			if got := st.AssertCounter("redis.client.calls", tags); got != c.calls {
+				t.Errorf("Expected %v calls, got %v", c.calls, got)
+			}
+			var errs float64
+			for _, m := range st.Metrics() {
+				if m.Name == "redis.client.errors" && m.HasTags(tags) {
+					errs += m.Value
+				}
+			}
+			if errs != c.errors {
+				t.Errorf("Expected %v errors, got %v", c.errors, errs)
+			}
+		})
+	}
+}
+
+func TestReportPoolStats(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	st := metricsbptest.NewRecordingStatsd(t, metricsbp.StatsdConfig{})
+	factory := redisbp.NewMonitoredClientFactory(
+		"redis",
+		redis.NewClient(&redis.Options{Addr: s.Addr()}),
+	)
+	defer factory.Close()
+	factory.ReportPoolStats(st.Statsd)
+
+	client := factory.BuildClient(context.Background())
+	for i := 0; i < 3; i++ {
+		if resp := client.Ping(); resp.Err() != nil {
+			t.Fatal(resp.Err())
+		}
+	}
+
+	gauges := make(map[string]float64)
+	for _, m := range st.Metrics() {
+		if m.Type == metricsbptest.TypeGauge {
+			gauges[m.Name] = m.Value
+		}
+	}
+	for name, expected := range map[string]float64{
+		"redis.pool.hits":              2,
+		"redis.pool.misses":            1,
+		"redis.pool.timeouts":          0,
+		"redis.pool.connections.total": 1,
+	} {
+		if got, ok := gauges[name]; !ok {
+			t.Errorf("Gauge %q not reported in %v", name, gauges)
+		} else if got != expected {
+			t.Errorf("Expected gauge %q to be %v, got %v", name, expected, got)
+		}
+	}
+}
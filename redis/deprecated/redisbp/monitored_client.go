@@ -138,6 +138,35 @@ func (f MonitoredCmdableFactory) MonitorPoolStats(ctx context.Context, tags metr
 	}
 }
 
+// ReportPoolStats registers gauges for the stats of the underlying Redis
+// client pool to st, reported once per reporting tick via st.GaugeFunc.
+//
+// If st is nil, metricsbp.M will be used instead.
+// The gauges are the same as the ones reported by MonitorPoolStats,
+// but it doesn't need a separate goroutine.
+// To add tags to the gauges, use st.WithTags.
+func (f MonitoredCmdableFactory) ReportPoolStats(st metricsbp.Metrics) {
+	st = metricsbp.MetricsOrM(st)
+	client := f.BuildClient(context.TODO())
+	prefix := f.name + ".pool"
+	for _, gauge := range []struct {
+		name  string
+		value func(stats *redis.PoolStats) uint32
+	}{
+		{".hits", func(stats *redis.PoolStats) uint32 { return stats.Hits }},
+		{".misses", func(stats *redis.PoolStats) uint32 { return stats.Misses }},
+		{".timeouts", func(stats *redis.PoolStats) uint32 { return stats.Timeouts }},
+		{".connections.total", func(stats *redis.PoolStats) uint32 { return stats.TotalConns }},
+		{".connections.idle", func(stats *redis.PoolStats) uint32 { return stats.IdleConns }},
+		{".connections.stale", func(stats *redis.PoolStats) uint32 { return stats.StaleConns }},
+	} {
+		value := gauge.value
+		st.GaugeFunc(prefix+gauge.name, func() float64 {
+			return float64(value(client.PoolStats()))
+		})
+	}
+}
+
 var (
 	_ MonitoredCmdable = (*monitoredClient)(nil)
 	_ MonitoredCmdable = (*monitoredCluster)(nil)
@@ -0,0 +1,87 @@
+package retrybp
+
+import (
+	"context"
+
+	"github.com/reddit/baseplate.go/metricsbp"
+)
+
+// The result tag values reported by Do when WithMetrics is used.
+const (
+	// The operation succeeded on the first attempt.
+	ResultSuccess = "success"
+
+	// The operation succeeded after one or more retries.
+	ResultSuccessAfterRetry = "success-after-retry"
+
+	// The operation failed after exhausted all the attempts,
+	// or stopped retrying because of the retry.RetryIf option.
+	ResultFailure = "failure"
+)
+
+type metricsContextKeyType struct{}
+
+var metricsContextKey metricsContextKeyType
+
+type metricsArgs struct {
+	st        metricsbp.Metrics
+	operation string
+}
+
+// WithMetrics sets the Statsd and the operation name on the given context,
+// to make the Do calls with the context report the metrics of the attempts.
+//
+// If st is nil, metricsbp.M will be used instead.
+//
+// For operation named "my-operation", Do reports:
+//
+// - counter retrybp.calls, tagged with operation=my-operation and result set
+// to one of the Result* constants
+//
+// - counter retrybp.retries, tagged with operation=my-operation,
+// the number of attempts after the first one
+//
+// - histogram retrybp.attempts, tagged with operation=my-operation,
+// the number of attempts of every call
+//
+// It also works with the thriftbp.Retry client middleware,
+// as the context is passed through to Do.
+func WithMetrics(ctx context.Context, st metricsbp.Metrics, operation string) context.Context {
+	return context.WithValue(ctx, metricsContextKey, metricsArgs{
+		st:        metricsbp.MetricsOrM(st),
+		operation: operation,
+	})
+}
+
+// withMetrics wraps fn to count the attempts if WithMetrics was used on ctx,
+// and returns the function to report the metrics with the final error of Do.
+func withMetrics(ctx context.Context, fn func() error) (func() error, func(err error)) {
+	args, ok := ctx.Value(metricsContextKey).(metricsArgs)
+	if !ok {
+		return fn, func(error) {}
+	}
+
+	var attempts int
+	wrapped := func() error {
+		attempts++
+		return fn()
+	}
+	report := func(err error) {
+		result := ResultFailure
+		if err == nil {
+			result = ResultSuccess
+			if attempts > 1 {
+				result = ResultSuccessAfterRetry
+			}
+		}
+		args.st.Counter("retrybp.calls").With(
+			"operation", args.operation,
+			"result", result,
+		).Add(1)
+		if attempts > 1 {
+			args.st.Counter("retrybp.retries").With("operation", args.operation).Add(float64(attempts - 1))
+		}
+		args.st.Histogram("retrybp.attempts").With("operation", args.operation).Observe(float64(attempts))
+	}
+	return wrapped, report
+}
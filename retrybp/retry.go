@@ -48,10 +48,18 @@ func GetOptions(ctx context.Context) (options []retry.Option, ok bool) {
 //
 // 2. If retry.Do returns a batch of errors (retry.Error), put those in a
 // errorsbp.Batch from baseplate.go.
-func Do(ctx context.Context, fn func() error, defaults ...retry.Option) error {
+//
+// 3. If the context was set via WithMetrics, report the metrics of the
+// attempts.
+func Do(ctx context.Context, fn func() error, defaults ...retry.Option) (err error) {
+	fn, report := withMetrics(ctx, fn)
+	defer func() {
+		report(err)
+	}()
+
 	options, _ := GetOptions(ctx)
 	options = append(defaults, options...)
-	err := retry.Do(fn, options...)
+	err = retry.Do(fn, options...)
 
 	var retryErr retry.Error
 	if errors.As(err, &retryErr) {
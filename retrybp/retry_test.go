@@ -9,6 +9,8 @@ import (
 	retry "github.com/avast/retry-go"
 
 	"github.com/reddit/baseplate.go/errorsbp"
+	"github.com/reddit/baseplate.go/metricsbp"
+	"github.com/reddit/baseplate.go/metricsbp/metricsbptest"
 	"github.com/reddit/baseplate.go/retrybp"
 )
 
@@ -130,6 +132,67 @@ func TestDoContextOverridesDefaults(t *testing.T) {
 	}
 }
 
+func TestDoMetrics(t *testing.T) {
+	t.Parallel()
+
+	st := metricsbptest.NewRecordingStatsd(t, metricsbp.StatsdConfig{})
+	ctx := retrybp.WithMetrics(context.Background(), st.Statsd, "op")
+
+	// Success on the first attempt.
+	retrybp.Do(ctx, func() error { return nil }, retry.Attempts(3))
+
+	// Success after 2 retries.
+	attempts := 0
+	retrybp.Do(
+		ctx,
+		func() error {
+			attempts++
+			if attempts < 3 {
+				return errors.New("retry")
+			}
+			return nil
+		},
+		retry.Attempts(3),
+	)
+
+	// Failure after exhausted all the 3 attempts.
+	retrybp.Do(ctx, func() error { return errors.New("fail") }, retry.Attempts(3))
+
+	for _, c := range []struct {
+		result   string
+		expected float64
+	}{
+		{retrybp.ResultSuccess, 1},
+		{retrybp.ResultSuccessAfterRetry, 1},
+		{retrybp.ResultFailure, 1},
+	} {
+		tags := metricsbp.Tags{"operation": "op", "result": c.result}
+		if got := st.AssertCounter("retrybp.calls", tags); got != c.expected {
+			t.Errorf("Expected retrybp.calls with %v to be %v, got %v", tags, c.expected, got)
+		}
+	}
+	if got := st.AssertCounter("retrybp.retries", metricsbp.Tags{"operation": "op"}); got != 4 {
+		t.Errorf("Expected 4 retries, got %v", got)
+	}
+
+	var observed []float64
+	for _, m := range st.Metrics() {
+		if m.Type == metricsbptest.TypeHistogram && m.Name == "retrybp.attempts" && m.HasTags(metricsbp.Tags{"operation": "op"}) {
+			observed = append(observed, m.Value)
+		}
+	}
+	if len(observed) != 3 {
+		t.Fatalf("Expected 3 observations of retrybp.attempts, got %v", observed)
+	}
+	var sum float64
+	for _, v := range observed {
+		sum += v
+	}
+	if sum != 7 {
+		t.Errorf("Expected 7 total attempts, got %v (%v)", sum, observed)
+	}
+}
+
 func TestSingleAttempt(t *testing.T) {
 	t.Parallel()
 
@@ -0,0 +1,334 @@
+package sqlbp
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"time"
+
+	"github.com/reddit/baseplate.go/metricsbp"
+)
+
+// The operation tag values reported by the connector returned by
+// WrapConnector.
+const (
+	OperationPrepare  = "prepare"
+	OperationQuery    = "query"
+	OperationExec     = "exec"
+	OperationBegin    = "begin"
+	OperationCommit   = "commit"
+	OperationRollback = "rollback"
+)
+
+// WrapConnector wraps a driver.Connector to report the metrics of the database
+// calls to st.
+//
+// If st is nil, metricsbp.M will be used instead.
+//
+// For database named "postgres", it reports:
+//
+// - counter sql.client.calls, tagged with db=postgres and operation
+//
+// - counter sql.client.errors, tagged with db=postgres and operation
+//
+// - timing sql.client.latency, tagged with db=postgres and operation
+//
+// operation is one of the Operation* constants.
+// The SQL queries are never part of the tags.
+// The latency of queries is the time it takes to get the rows back from the
+// driver, it doesn't include the time iterating the rows.
+func WrapConnector(name string, connector driver.Connector, st metricsbp.Metrics) driver.Connector {
+	return wrappedConnector{
+		Connector: connector,
+		monitor: monitor{
+			name: name,
+			st:   metricsbp.MetricsOrM(st),
+		},
+	}
+}
+
+// DSNConnector returns a driver.Connector for the driver and the dsn,
+// to be wrapped by WrapConnector.
+//
+// If d implements driver.DriverContext, its OpenConnector will be used.
+// Otherwise the returned driver.Connector calls d.Open(dsn) on every Connect.
+func DSNConnector(d driver.Driver, dsn string) (driver.Connector, error) {
+	if dc, ok := d.(driver.DriverContext); ok {
+		return dc.OpenConnector(dsn)
+	}
+	return dsnConnector{driver: d, dsn: dsn}, nil
+}
+
+type dsnConnector struct {
+	driver driver.Driver
+	dsn    string
+}
+
+func (c dsnConnector) Connect(_ context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// monitor reports the metrics of the database calls.
+type monitor struct {
+	name string
+	st   metricsbp.Metrics
+}
+
+func (m monitor) observe(operation string, start time.Time, err error) {
+	if errors.Is(err, driver.ErrSkip) {
+		// The call will be retried by database/sql in another way.
+		return
+	}
+	tags := []string{"db", m.name, "operation", operation}
+	m.st.Counter("sql.client.calls").With(tags...).Add(1)
+	if err != nil {
+		m.st.Counter("sql.client.errors").With(tags...).Add(1)
+	}
+	metricsbp.NewTimer(m.st.Timing("sql.client.latency").With(tags...)).
+		OverrideStartTime(start).
+		ObserveDuration()
+}
+
+type wrappedConnector struct {
+	driver.Connector
+	monitor
+}
+
+func (c wrappedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	wc := &wrappedConn{Conn: conn, monitor: c.monitor}
+	if _, ok := conn.(driver.Pinger); ok {
+		return pingerConn{wc}, nil
+	}
+	return wc, nil
+}
+
+type wrappedConn struct {
+	driver.Conn
+	monitor
+}
+
+func (c *wrappedConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *wrappedConn) PrepareContext(ctx context.Context, query string) (_ driver.Stmt, err error) {
+	defer func(start time.Time) {
+		c.observe(OperationPrepare, start, err)
+	}(time.Now())
+
+	var stmt driver.Stmt
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = p.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	ws := &wrappedStmt{Stmt: stmt, conn: c.Conn, monitor: c.monitor}
+	if _, ok := stmt.(driver.ColumnConverter); ok {
+		return columnConverterStmt{ws}, nil
+	}
+	return ws, nil
+}
+
+func (c *wrappedConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *wrappedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (_ driver.Tx, err error) {
+	defer func(start time.Time) {
+		c.observe(OperationBegin, start, err)
+	}(time.Now())
+
+	var tx driver.Tx
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		tx, err = b.BeginTx(ctx, opts)
+	} else {
+		if opts.Isolation != driver.IsolationLevel(0) || opts.ReadOnly {
+			return nil, errors.New("sqlbp: driver does not support non-default transaction options")
+		}
+		tx, err = c.Conn.Begin()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return wrappedTx{Tx: tx, monitor: c.monitor}, nil
+}
+
+func (c *wrappedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (_ driver.Result, err error) {
+	e, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	defer func(start time.Time) {
+		c.observe(OperationExec, start, err)
+	}(time.Now())
+	return e.ExecContext(ctx, query, args)
+}
+
+func (c *wrappedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (_ driver.Rows, err error) {
+	q, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	defer func(start time.Time) {
+		c.observe(OperationQuery, start, err)
+	}(time.Now())
+	return q.QueryContext(ctx, query, args)
+}
+
+func (c *wrappedConn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *wrappedConn) IsValid() bool {
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+func (c *wrappedConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// pingerConn is the wrappedConn of the driver.Conn implementing driver.Pinger.
+//
+// The other driver.Conn are not wrapped as a driver.Pinger,
+// so database/sql keeps its own behavior for them.
+type pingerConn struct {
+	*wrappedConn
+}
+
+func (c pingerConn) Ping(ctx context.Context) error {
+	return c.Conn.(driver.Pinger).Ping(ctx)
+}
+
+type wrappedStmt struct {
+	driver.Stmt
+	monitor
+
+	// conn is the driver.Conn the statement is prepared on.
+	conn driver.Conn
+}
+
+func (s *wrappedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (_ driver.Result, err error) {
+	defer func(start time.Time) {
+		s.observe(OperationExec, start, err)
+	}(time.Now())
+
+	if e, ok := s.Stmt.(driver.StmtExecContext); ok {
+		return e.ExecContext(ctx, args)
+	}
+	values, err := namedValuesToValues(args)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.Stmt.Exec(values)
+}
+
+func (s *wrappedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (_ driver.Rows, err error) {
+	defer func(start time.Time) {
+		s.observe(OperationQuery, start, err)
+	}(time.Now())
+
+	if q, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		return q.QueryContext(ctx, args)
+	}
+	values, err := namedValuesToValues(args)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.Stmt.Query(values)
+}
+
+// CheckNamedValue uses the driver.NamedValueChecker of the statement,
+// or the one of the conn when the statement doesn't implement it,
+// the same as database/sql does for the unwrapped ones.
+func (s *wrappedStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	if checker, ok := s.conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// columnConverterStmt is the wrappedStmt of the driver.Stmt implementing
+// driver.ColumnConverter.
+type columnConverterStmt struct {
+	*wrappedStmt
+}
+
+func (s columnConverterStmt) ColumnConverter(idx int) driver.ValueConverter {
+	return s.Stmt.(driver.ColumnConverter).ColumnConverter(idx)
+}
+
+func namedValuesToValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, errors.New("sqlbp: driver does not support the use of Named Parameters")
+		}
+		values[i] = arg.Value
+	}
+	return values, nil
+}
+
+type wrappedTx struct {
+	driver.Tx
+	monitor
+}
+
+func (tx wrappedTx) Commit() (err error) {
+	defer func(start time.Time) {
+		tx.observe(OperationCommit, start, err)
+	}(time.Now())
+	return tx.Tx.Commit()
+}
+
+func (tx wrappedTx) Rollback() (err error) {
+	defer func(start time.Time) {
+		tx.observe(OperationRollback, start, err)
+	}(time.Now())
+	return tx.Tx.Rollback()
+}
+
+var (
+	_ driver.Connector          = wrappedConnector{}
+	_ driver.Connector          = dsnConnector{}
+	_ driver.ConnPrepareContext = (*wrappedConn)(nil)
+	_ driver.ConnBeginTx        = (*wrappedConn)(nil)
+	_ driver.ExecerContext      = (*wrappedConn)(nil)
+	_ driver.QueryerContext     = (*wrappedConn)(nil)
+	_ driver.SessionResetter    = (*wrappedConn)(nil)
+	_ driver.Validator          = (*wrappedConn)(nil)
+	_ driver.NamedValueChecker  = (*wrappedConn)(nil)
+	_ driver.Pinger             = pingerConn{}
+	_ driver.StmtExecContext    = (*wrappedStmt)(nil)
+	_ driver.StmtQueryContext   = (*wrappedStmt)(nil)
+	_ driver.NamedValueChecker  = (*wrappedStmt)(nil)
+	_ driver.ColumnConverter    = columnConverterStmt{}
+)
@@ -0,0 +1,147 @@
+package sqlbp_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/reddit/baseplate.go/metricsbp"
+	"github.com/reddit/baseplate.go/metricsbp/metricsbptest"
+	"github.com/reddit/baseplate.go/sqlbp"
+)
+
+func TestWrapConnector(t *testing.T) {
+	const name = "db"
+	st := metricsbptest.NewRecordingStatsd(t, metricsbp.StatsdConfig{})
+	db := sql.OpenDB(sqlbp.WrapConnector(name, fakeConnector{}, st.Statsd))
+	defer db.Close()
+
+	if _, err := db.Exec("INSERT"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(failQuery); err == nil {
+		t.Error("Expected error, got nil")
+	}
+	rows, err := db.Query("SELECT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows.Close()
+	stmt, err := db.Prepare("SELECT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err = stmt.Query()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows.Close()
+	stmt.Close()
+	if _, err := db.Prepare(failQuery); err == nil {
+		t.Error("Expected error, got nil")
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	tx, err = db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Rollback(); err == nil {
+		t.Error("Expected error, got nil")
+	}
+
+	for _, c := range []struct {
+		operation string
+		calls     float64
+		errors    float64
+	}{
+		{operation: sqlbp.OperationExec, calls: 2, errors: 1},
+		{operation: sqlbp.OperationQuery, calls: 2},
+		{operation: sqlbp.OperationPrepare, calls: 2, errors: 1},
+		{operation: sqlbp.OperationBegin, calls: 2},
+		{operation: sqlbp.OperationCommit, calls: 1},
+		{operation: sqlbp.OperationRollback, calls: 1, errors: 1},
+	} {
+		t.Run(c.operation, func(t *testing.T) {
+			tags := metricsbp.Tags{
+				"db":        name,
+				"operation": c.operation,
+			}
+			if got := st.AssertCounter("sql.client.calls", tags); got != c.calls {
+				t.Errorf("Expected %v calls, got %v", c.calls, got)
+			}
+			var errs float64
+			var timings int
+			for _, m := range st.Metrics() {
+				if !m.HasTags(tags) {
+					continue
+				}
+				switch m.Name {
+				case "sql.client.errors":
+					errs += m.Value
+				case "sql.client.latency":
+					timings++
+				}
+			}
+			if errs != c.errors {
+				t.Errorf("Expected %v errors, got %v", c.errors, errs)
+			}
+			if timings != int(c.calls) {
+				t.Errorf("Expected %v timings, got %d", c.calls, timings)
+			}
+		})
+	}
+}
+
+func TestWrapConnectorConnNamedValueChecker(t *testing.T) {
+	st := metricsbptest.NewRecordingStatsd(t, metricsbp.StatsdConfig{})
+	db := sql.OpenDB(sqlbp.WrapConnector("db", checkerConnector{}, st.Statsd))
+	defer db.Close()
+
+	stmt, err := db.Prepare("INSERT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	if _, err := stmt.Exec([]string{"foo", "bar"}); err != nil {
+		t.Errorf("Expected the arg accepted by the conn, got %v", err)
+	}
+	if _, err := stmt.Exec(1); err != nil {
+		t.Errorf("Expected the arg converted by default, got %v", err)
+	}
+}
+
+func TestWrapConnectorPing(t *testing.T) {
+	st := metricsbptest.NewRecordingStatsd(t, metricsbp.StatsdConfig{})
+
+	t.Run("pinger", func(t *testing.T) {
+		conn, err := sqlbp.WrapConnector("db", pingerConnector{}, st.Statsd).Connect(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, ok := conn.(driver.Pinger)
+		if !ok {
+			t.Fatal("Expected the wrapped conn to implement driver.Pinger")
+		}
+		if err := p.Ping(context.Background()); !errors.Is(err, errFake) {
+			t.Errorf("Expected %v, got %v", errFake, err)
+		}
+	})
+
+	t.Run("non-pinger", func(t *testing.T) {
+		conn, err := sqlbp.WrapConnector("db", fakeConnector{}, st.Statsd).Connect(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := conn.(driver.Pinger); ok {
+			t.Error("Expected the wrapped conn to not implement driver.Pinger")
+		}
+	})
+}
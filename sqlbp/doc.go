@@ -0,0 +1,12 @@
+// Package sqlbp provides Baseplate integrations for database/sql.
+//
+// It wraps a driver.Connector to report metrics of the database calls,
+// which can be used with sql.OpenDB:
+//
+//     connector, err := sqlbp.DSNConnector(pq.Driver{}, dsn)
+//     if err != nil {
+//       // TODO: handle error
+//     }
+//     db := sql.OpenDB(sqlbp.WrapConnector("postgres", connector, metricsbp.M))
+//     sqlbp.ReportPoolStats("postgres", db, metricsbp.M)
+package sqlbp
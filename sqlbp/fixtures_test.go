@@ -0,0 +1,141 @@
+package sqlbp_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+)
+
+const failQuery = "FAIL"
+
+var errFake = errors.New("fake error")
+
+// fakeConnector creates fakeConns.
+type fakeConnector struct{}
+
+func (fakeConnector) Connect(context.Context) (driver.Conn, error) {
+	return fakeConn{}, nil
+}
+
+func (fakeConnector) Driver() driver.Driver {
+	return nil
+}
+
+// fakeConn is a driver.Conn that fails the queries equal to failQuery.
+type fakeConn struct{}
+
+func (fakeConn) Prepare(query string) (driver.Stmt, error) {
+	if query == failQuery {
+		return nil, errFake
+	}
+	return fakeStmt{}, nil
+}
+
+func (fakeConn) Close() error {
+	return nil
+}
+
+func (fakeConn) Begin() (driver.Tx, error) {
+	return fakeTx{}, nil
+}
+
+func (fakeConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	if query == failQuery {
+		return nil, errFake
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (fakeConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	if query == failQuery {
+		return nil, errFake
+	}
+	return fakeRows{}, nil
+}
+
+type fakeStmt struct{}
+
+func (fakeStmt) Close() error {
+	return nil
+}
+
+func (fakeStmt) NumInput() int {
+	return -1
+}
+
+func (fakeStmt) Exec([]driver.Value) (driver.Result, error) {
+	return driver.RowsAffected(1), nil
+}
+
+func (fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	return fakeRows{}, nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error {
+	return nil
+}
+
+func (fakeTx) Rollback() error {
+	return errFake
+}
+
+type fakeRows struct{}
+
+func (fakeRows) Columns() []string {
+	return nil
+}
+
+func (fakeRows) Close() error {
+	return nil
+}
+
+func (fakeRows) Next([]driver.Value) error {
+	return io.EOF
+}
+
+// checkerConnector creates checkerConns.
+type checkerConnector struct{}
+
+func (checkerConnector) Connect(context.Context) (driver.Conn, error) {
+	return checkerConn{}, nil
+}
+
+func (checkerConnector) Driver() driver.Driver {
+	return nil
+}
+
+// checkerConn is a fakeConn with a driver.NamedValueChecker accepting
+// []string, while its statements don't implement driver.NamedValueChecker.
+type checkerConn struct {
+	fakeConn
+}
+
+func (checkerConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if _, ok := nv.Value.([]string); ok {
+		return nil
+	}
+	return driver.ErrSkip
+}
+
+// pingerConn is a fakeConn failing the pings.
+type pingerConn struct {
+	fakeConn
+}
+
+func (pingerConn) Ping(context.Context) error {
+	return errFake
+}
+
+// pingerConnector creates pingerConns.
+type pingerConnector struct{}
+
+func (pingerConnector) Connect(context.Context) (driver.Conn, error) {
+	return pingerConn{}, nil
+}
+
+func (pingerConnector) Driver() driver.Driver {
+	return nil
+}
@@ -0,0 +1,41 @@
+package sqlbp
+
+import (
+	"database/sql"
+
+	"github.com/reddit/baseplate.go/metricsbp"
+)
+
+// ReportPoolStats registers gauges for the connection pool stats of db to st,
+// reported once per reporting tick via st.GaugeFunc.
+//
+// If st is nil, metricsbp.M will be used instead.
+// To add tags to the gauges, use st.WithTags.
+//
+// For database named "postgres", it reports gauges:
+//
+// - postgres.pool.connections.open
+//
+// - postgres.pool.connections.idle
+//
+// - postgres.pool.connections.in-use
+//
+// - postgres.pool.wait-count
+func ReportPoolStats(name string, db *sql.DB, st metricsbp.Metrics) {
+	st = metricsbp.MetricsOrM(st)
+	prefix := name + ".pool"
+	for _, gauge := range []struct {
+		name  string
+		value func(stats sql.DBStats) float64
+	}{
+		{".connections.open", func(stats sql.DBStats) float64 { return float64(stats.OpenConnections) }},
+		{".connections.idle", func(stats sql.DBStats) float64 { return float64(stats.Idle) }},
+		{".connections.in-use", func(stats sql.DBStats) float64 { return float64(stats.InUse) }},
+		{".wait-count", func(stats sql.DBStats) float64 { return float64(stats.WaitCount) }},
+	} {
+		value := gauge.value
+		st.GaugeFunc(prefix+gauge.name, func() float64 {
+			return value(db.Stats())
+		})
+	}
+}
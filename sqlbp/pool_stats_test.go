@@ -0,0 +1,43 @@
+package sqlbp_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/reddit/baseplate.go/metricsbp"
+	"github.com/reddit/baseplate.go/metricsbp/metricsbptest"
+	"github.com/reddit/baseplate.go/sqlbp"
+)
+
+func TestReportPoolStats(t *testing.T) {
+	st := metricsbptest.NewRecordingStatsd(t, metricsbp.StatsdConfig{})
+	db := sql.OpenDB(sqlbp.WrapConnector("db", fakeConnector{}, st.Statsd))
+	defer db.Close()
+	sqlbp.ReportPoolStats("db", db, st.Statsd)
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	gauges := make(map[string]float64)
+	for _, m := range st.Metrics() {
+		if m.Type == metricsbptest.TypeGauge {
+			gauges[m.Name] = m.Value
+		}
+	}
+	for name, expected := range map[string]float64{
+		"db.pool.connections.open":   1,
+		"db.pool.connections.idle":   0,
+		"db.pool.connections.in-use": 1,
+		"db.pool.wait-count":         0,
+	} {
+		if got, ok := gauges[name]; !ok {
+			t.Errorf("Gauge %q not reported in %v", name, gauges)
+		} else if got != expected {
+			t.Errorf("Expected gauge %q to be %v, got %v", name, expected, got)
+		}
+	}
+}
@@ -2,6 +2,8 @@ package thriftbp
 
 import (
 	"context"
+	"errors"
+	"net"
 	"strconv"
 	"time"
 
@@ -12,6 +14,7 @@ import (
 	"github.com/reddit/baseplate.go/breakerbp"
 	"github.com/reddit/baseplate.go/ecinterface"
 	"github.com/reddit/baseplate.go/errorsbp"
+	"github.com/reddit/baseplate.go/metricsbp"
 	"github.com/reddit/baseplate.go/retrybp"
 	"github.com/reddit/baseplate.go/tracing"
 )
@@ -279,6 +282,82 @@ func BaseplateErrorWrapper(next thrift.TClient) thrift.TClient {
 	}
 }
 
+// ReportClientMetrics returns a ClientMiddleware that reports the request
+// count, error count and latency of the calls to the service to st.
+//
+// If st is nil, metricsbp.M will be used instead.
+// The counters and timings are sampled with the sample rates configured in st.
+//
+// For calls to endpoint "myEndpoint" of service "my-service", it reports:
+//
+// - counter thrift.client.requests, tagged with service=my-service and
+// method=myEndpoint
+//
+// - counter thrift.client.errors, tagged with service=my-service,
+// method=myEndpoint and error_type
+//
+// - timing thrift.client.latency, tagged with service=my-service and
+// method=myEndpoint
+//
+// error_type is "timeout" for timeouts,
+// "connection" for connection errors (including PoolError),
+// and "application" for all the other errors,
+// for example the exceptions defined in thrift IDL files.
+//
+// When passed into NewBaseplateClientPool,
+// it's outside of the retries of the default middlewares,
+// so a call retried multiple times is only reported once.
+func ReportClientMetrics(serviceSlug string, st metricsbp.Metrics) thrift.ClientMiddleware {
+	st = metricsbp.MetricsOrM(st)
+	requests := st.Counter("thrift.client.requests")
+	errs := st.Counter("thrift.client.errors")
+	latency := st.Timing("thrift.client.latency")
+	return func(next thrift.TClient) thrift.TClient {
+		return thrift.WrappedTClient{
+			Wrapped: func(ctx context.Context, method string, args, result thrift.TStruct) (thrift.ResponseMeta, error) {
+				tags := []string{"service", serviceSlug, "method", method}
+				timer := metricsbp.NewTimer(latency.With(tags...))
+				defer timer.ObserveDuration()
+
+				meta, err := next.Call(ctx, method, args, result)
+				requests.With(tags...).Add(1)
+				if err != nil {
+					errs.With(append(tags, "error_type", clientErrorType(err))...).Add(1)
+				}
+				return meta, err
+			},
+		}
+	}
+}
+
+// clientErrorType returns the error_type tag value for the error returned by
+// a client call.
+func clientErrorType(err error) string {
+	var (
+		te      thrift.TTransportException
+		netErr  net.Error
+		poolErr PoolError
+	)
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.As(err, &te):
+		if te.TypeId() == thrift.TIMED_OUT {
+			return "timeout"
+		}
+		return "connection"
+	case errors.As(err, &netErr):
+		if netErr.Timeout() {
+			return "timeout"
+		}
+		return "connection"
+	case errors.As(err, &poolErr):
+		return "connection"
+	default:
+		return "application"
+	}
+}
+
 var (
 	_ thrift.ClientMiddleware = SetDeadlineBudget
 	_ thrift.ClientMiddleware = BaseplateErrorWrapper
@@ -14,6 +14,8 @@ import (
 	baseplate "github.com/reddit/baseplate.go"
 	"github.com/reddit/baseplate.go/ecinterface"
 	baseplatethrift "github.com/reddit/baseplate.go/internal/gen-go/reddit/baseplate"
+	"github.com/reddit/baseplate.go/metricsbp"
+	"github.com/reddit/baseplate.go/metricsbp/metricsbptest"
 	"github.com/reddit/baseplate.go/mqsend"
 	"github.com/reddit/baseplate.go/retrybp"
 	"github.com/reddit/baseplate.go/thriftbp"
@@ -377,3 +379,73 @@ func TestRetry(t *testing.T) {
 		t.Errorf("expected middleware to trigger a retry %d times, got %d", expected, c.count)
 	}
 }
+
+func TestReportClientMetrics(t *testing.T) {
+	for _, c := range []struct {
+		label     string
+		err       error
+		errorType string
+	}{
+		{
+			label: "success",
+		},
+		{
+			label:     "deadline",
+			err:       context.DeadlineExceeded,
+			errorType: "timeout",
+		},
+		{
+			label:     "transport-timeout",
+			err:       thrift.NewTTransportException(thrift.TIMED_OUT, "timeout"),
+			errorType: "timeout",
+		},
+		{
+			label:     "transport",
+			err:       thrift.NewTTransportException(thrift.NOT_OPEN, "not open"),
+			errorType: "connection",
+		},
+		{
+			label:     "pool",
+			err:       thriftbp.PoolError{Cause: errors.New("exhausted")},
+			errorType: "connection",
+		},
+		{
+			label:     "idl-exception",
+			err:       baseplatethrift.NewError(),
+			errorType: "application",
+		},
+	} {
+		t.Run(c.label, func(t *testing.T) {
+			st := metricsbptest.NewRecordingStatsd(t, metricsbp.StatsdConfig{})
+			mock := &thrifttest.MockClient{FailUnregisteredMethods: true}
+			mock.AddMockCall(method, func(ctx context.Context, args, result thrift.TStruct) (thrift.ResponseMeta, error) {
+				return thrift.ResponseMeta{}, c.err
+			})
+			client := thrift.WrapClient(mock, thriftbp.ReportClientMetrics(service, st.Statsd))
+			client.Call(context.Background(), method, nil, nil)
+
+			tags := metricsbp.Tags{
+				"service": service,
+				"method":  method,
+			}
+			if got := st.AssertCounter("thrift.client.requests", tags); got != 1 {
+				t.Errorf("Expected 1 request, got %v", got)
+			}
+			var errs int
+			for _, m := range st.Metrics() {
+				if m.Name == "thrift.client.errors" && m.HasTags(tags) {
+					errs++
+					if got := m.Tags["error_type"]; got != c.errorType {
+						t.Errorf("Expected error_type %q, got %q", c.errorType, got)
+					}
+				}
+			}
+			if c.err == nil && errs != 0 {
+				t.Errorf("Expected no errors reported, got %d", errs)
+			}
+			if c.err != nil && errs != 1 {
+				t.Errorf("Expected 1 error reported, got %d", errs)
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/apache/thrift/lib/go/thrift"
@@ -301,6 +302,149 @@ func ReportPayloadSizeMetrics(rate float64) thrift.ProcessorMiddleware {
 	}
 }
 
+// ReportServerMetrics returns a ProcessorMiddleware that reports the request
+// count, error count and latency of every endpoint to st.
+//
+// If st is nil, metricsbp.M will be used instead.
+// The counters and timings are sampled with the sample rates configured in st.
+//
+// For endpoint named "myEndpoint", it reports:
+//
+// - counter thrift.server.requests, tagged with method=myEndpoint
+//
+// - counter thrift.server.errors, tagged with method=myEndpoint and
+// exception_type set to the type of the returned error
+// (e.g. "baseplate.Error" for baseplate.Error exceptions defined in IDL)
+//
+// - timing thrift.server.latency, tagged with method=myEndpoint
+func ReportServerMetrics(st metricsbp.Metrics) thrift.ProcessorMiddleware {
+	st = metricsbp.MetricsOrM(st)
+	return func(name string, next thrift.TProcessorFunction) thrift.TProcessorFunction {
+		requests := st.Counter("thrift.server.requests").With("method", name)
+		latency := st.Timing("thrift.server.latency").With("method", name)
+		errs := st.Counter("thrift.server.errors")
+		return thrift.WrappedTProcessorFunction{
+			Wrapped: func(ctx context.Context, seqID int32, in, out thrift.TProtocol) (bool, thrift.TException) {
+				timer := metricsbp.NewTimer(latency)
+				defer timer.ObserveDuration()
+
+				ok, err := next.Process(ctx, seqID, in, out)
+				requests.Add(1)
+				if err != nil {
+					errs.With(
+						"method", name,
+						"exception_type", exceptionType(err),
+					).Add(1)
+				}
+				return ok, err
+			},
+		}
+	}
+}
+
+// exceptionType returns the type name of the error to be used as the
+// exception_type tag.
+//
+// For exceptions defined in thrift IDL files it's the generated type,
+// otherwise it's the type of the innermost wrapped error.
+func exceptionType(err error) string {
+	var te thrift.TException
+	if errors.As(err, &te) && te.TExceptionType() == thrift.TExceptionTypeCompiled {
+		err = te
+	} else {
+		for unwrapped := errors.Unwrap(err); unwrapped != nil; unwrapped = errors.Unwrap(err) {
+			err = unwrapped
+		}
+	}
+	return strings.TrimPrefix(fmt.Sprintf("%T", err), "*")
+}
+
+// RecoverPanicsArgs are the args to be passed into RecoverPanics.
+type RecoverPanicsArgs struct {
+	// Metrics is where the panic counter is reported to.
+	//
+	// If it's nil, metricsbp.M will be used instead.
+	Metrics metricsbp.Metrics
+
+	// Repanic controls what to do after a recovered panic is counted.
+	//
+	// When it's false (default),
+	// the panic is written to the client as a TApplicationException with
+	// INTERNAL_ERROR type, the same way as the generated processors do for the
+	// errors not declared in the IDL,
+	// and returned as an error to the other middlewares,
+	// and the server keeps running.
+	// When it's true, it panics again with the recovered value.
+	Repanic bool
+}
+
+// RecoverPanics returns a ProcessorMiddleware that recovers the panics from
+// the handlers and reports them to a counter,
+// making the number of panics swallowed by the service visible.
+//
+// For endpoint named "myEndpoint", it reports:
+//
+// - counter thrift.server.panics, tagged with method=myEndpoint
+//
+// It should be the innermost middleware wrapping the handler,
+// so that the other middlewares (for example InjectServerSpan and
+// ReportServerMetrics) see the error returned instead of the panic.
+func RecoverPanics(args RecoverPanicsArgs) thrift.ProcessorMiddleware {
+	st := metricsbp.MetricsOrM(args.Metrics)
+	return func(name string, next thrift.TProcessorFunction) thrift.TProcessorFunction {
+		panics := st.Counter("thrift.server.panics").With("method", name)
+		return thrift.WrappedTProcessorFunction{
+			Wrapped: func(ctx context.Context, seqID int32, in, out thrift.TProtocol) (success bool, err thrift.TException) {
+				defer func() {
+					rec := recover()
+					if rec == nil {
+						return
+					}
+					panics.Add(1)
+					if args.Repanic {
+						panic(rec)
+					}
+					var recErr error
+					if e, ok := rec.(error); ok {
+						recErr = fmt.Errorf("thriftbp: recovered from panic in endpoint %q: %w", name, e)
+					} else {
+						recErr = fmt.Errorf("thriftbp: recovered from panic in endpoint %q: %v", name, rec)
+					}
+					exc := thrift.NewTApplicationException(thrift.INTERNAL_ERROR, recErr.Error())
+					if writeErr := writeApplicationException(ctx, out, name, seqID, exc); writeErr != nil {
+						success, err = false, thrift.WrapTException(writeErr)
+						return
+					}
+					success, err = true, thrift.WrapTException(recErr)
+				}()
+
+				return next.Process(ctx, seqID, in, out)
+			},
+		}
+	}
+}
+
+// writeApplicationException writes exc as the reply of the request of name and
+// seqID to out, and flushes out.
+func writeApplicationException(
+	ctx context.Context,
+	out thrift.TProtocol,
+	name string,
+	seqID int32,
+	exc thrift.TApplicationException,
+) error {
+	if err := out.WriteMessageBegin(ctx, name, thrift.EXCEPTION, seqID); err != nil {
+		return err
+	}
+	if err := exc.Write(ctx, out); err != nil {
+		return err
+	}
+	if err := out.WriteMessageEnd(ctx); err != nil {
+		return err
+	}
+	return out.Flush(ctx)
+}
+
 // countingTransport implements thrift.TTransport
 type countingTransport int64
 
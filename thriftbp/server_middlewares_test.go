@@ -4,12 +4,16 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/apache/thrift/lib/go/thrift"
 
 	"github.com/reddit/baseplate.go/ecinterface"
+	baseplatethrift "github.com/reddit/baseplate.go/internal/gen-go/reddit/baseplate"
+	"github.com/reddit/baseplate.go/metricsbp"
+	"github.com/reddit/baseplate.go/metricsbp/metricsbptest"
 	"github.com/reddit/baseplate.go/mqsend"
 	"github.com/reddit/baseplate.go/thriftbp"
 	"github.com/reddit/baseplate.go/thriftbp/thrifttest"
@@ -276,3 +280,149 @@ func TestExtractDeadlineBudget(t *testing.T) {
 		},
 	)
 }
+
+func TestReportServerMetrics(t *testing.T) {
+	const name = "test"
+	for _, c := range []struct {
+		label         string
+		err           thrift.TException
+		exceptionType string
+	}{
+		{
+			label: "success",
+		},
+		{
+			label:         "idl-exception",
+			err:           baseplatethrift.NewError(),
+			exceptionType: "baseplate.Error",
+		},
+		{
+			label:         "wrapped-error",
+			err:           thrift.WrapTException(context.DeadlineExceeded),
+			exceptionType: "context.deadlineExceededError",
+		},
+	} {
+		t.Run(c.label, func(t *testing.T) {
+			st := metricsbptest.NewRecordingStatsd(t, metricsbp.StatsdConfig{})
+			processor := thrifttest.NewMockTProcessor(
+				t,
+				map[string]thrift.TProcessorFunction{
+					name: thrift.WrappedTProcessorFunction{
+						Wrapped: func(ctx context.Context, seqID int32, in, out thrift.TProtocol) (bool, thrift.TException) {
+							return true, c.err
+						},
+					},
+				},
+			)
+			ctx := thrifttest.SetMockTProcessorName(context.Background(), name)
+			wrapped := thrift.WrapProcessor(
+				processor,
+				thriftbp.ReportServerMetrics(st.Statsd),
+			)
+			wrapped.Process(ctx, nil, nil)
+
+			tags := metricsbp.Tags{"method": name}
+			if got := st.AssertCounter("thrift.server.requests", tags); got != 1 {
+				t.Errorf("Expected 1 request, got %v", got)
+			}
+			var errs, timings int
+			for _, m := range st.Metrics() {
+				switch {
+				case m.Name == "thrift.server.errors" && m.HasTags(tags):
+					errs++
+					if got := m.Tags["exception_type"]; got != c.exceptionType {
+						t.Errorf("Expected exception_type %q, got %q", c.exceptionType, got)
+					}
+				case m.Name == "thrift.server.latency" && m.HasTags(tags):
+					timings++
+				}
+			}
+			if c.err == nil && errs != 0 {
+				t.Errorf("Expected no errors reported, got %d", errs)
+			}
+			if c.err != nil && errs != 1 {
+				t.Errorf("Expected 1 error reported, got %d", errs)
+			}
+			if timings != 1 {
+				t.Errorf("Expected 1 latency reported, got %d", timings)
+			}
+		})
+	}
+}
+
+func TestRecoverPanics(t *testing.T) {
+	const (
+		name  = "test"
+		seqID = 42
+	)
+	tags := metricsbp.Tags{"method": name}
+	for _, c := range []struct {
+		label   string
+		repanic bool
+	}{
+		{
+			label: "error",
+		},
+		{
+			label:   "repanic",
+			repanic: true,
+		},
+	} {
+		t.Run(c.label, func(t *testing.T) {
+			st := metricsbptest.NewRecordingStatsd(t, metricsbp.StatsdConfig{})
+			out := thrift.NewTBinaryProtocolConf(thrift.NewTMemoryBuffer(), nil)
+			wrapped := thrift.WrappedTProcessorFunction{
+				Wrapped: func(ctx context.Context, seqID int32, in, out thrift.TProtocol) (bool, thrift.TException) {
+					panic("oops")
+				},
+			}
+			recovered := thriftbp.RecoverPanics(thriftbp.RecoverPanicsArgs{
+				Metrics: st.Statsd,
+				Repanic: c.repanic,
+			})(name, wrapped)
+
+			func() {
+				defer func() {
+					rec := recover()
+					if c.repanic && rec != "oops" {
+						t.Errorf("Expected to panic again with %q, got %v", "oops", rec)
+					}
+					if !c.repanic && rec != nil {
+						t.Errorf("Expected no panic, got %v", rec)
+					}
+				}()
+				ok, err := recovered.Process(context.Background(), seqID, nil, out)
+				if !ok || err == nil || !strings.Contains(err.Error(), "oops") {
+					t.Errorf("Expected the panic to be returned as an error, got %v, %v", ok, err)
+				}
+			}()
+			if got := st.AssertCounter("thrift.server.panics", tags); got != 1 {
+				t.Errorf("Expected 1 panic, got %v", got)
+			}
+			if c.repanic {
+				return
+			}
+
+			// The client should get the exception as the reply.
+			ctx := context.Background()
+			gotName, typ, gotSeqID, err := out.ReadMessageBegin(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if gotName != name || typ != thrift.EXCEPTION || gotSeqID != seqID {
+				t.Errorf(
+					"Expected message %q, %v, %d, got %q, %v, %d",
+					name, thrift.EXCEPTION, seqID,
+					gotName, typ, gotSeqID,
+				)
+			}
+			exc := thrift.NewTApplicationException(thrift.UNKNOWN_APPLICATION_EXCEPTION, "")
+			if err := exc.Read(ctx, out); err != nil {
+				t.Fatal(err)
+			}
+			if exc.TypeId() != thrift.INTERNAL_ERROR || !strings.Contains(exc.Error(), "oops") {
+				t.Errorf("Expected INTERNAL_ERROR with the panic, got %d: %v", exc.TypeId(), exc)
+			}
+		})
+	}
+}